@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// cliOptions holds the command line flags.
+type cliOptions struct {
+	DevTLS      bool
+	DevTLSHosts []string // extra host names and IPs for the -dev-tls certificate
+	NoBrowser   bool
+	Headless    bool
+}
+
+// parseFlags reads the command line. getenv supplies environment defaults, so
+// FORGE_HEADLESS=1 behaves like -headless unless the flag says otherwise.
+// Errors (including flag.ErrHelp for -h) are returned rather than exiting; usage
+// and error text go to output.
+func parseFlags(args []string, getenv func(string) string, output io.Writer) (cliOptions, error) {
+	var opts cliOptions
+	headlessDefault, _ := strconv.ParseBool(getenv("FORGE_HEADLESS"))
+
+	flags := flag.NewFlagSet("forge-orchestrator", flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.BoolVar(&opts.DevTLS, "dev-tls", false, "Generate self-signed certificate for development")
+	devTLSHosts := flags.String("dev-tls-hosts", getenv("FORGE_DEV_TLS_HOSTS"),
+		"Comma-separated host names and IPs, besides localhost and 127.0.0.1, for the -dev-tls certificate,\n"+
+			"e.g. 192.168.1.20,forge.lan to reach Forge from other devices. Defaults to the value of FORGE_DEV_TLS_HOSTS")
+	flags.BoolVar(&opts.NoBrowser, "no-browser", false, "Don't open browser on startup")
+	flags.BoolVar(&opts.Headless, "headless", headlessDefault,
+		"Run as a server with no desktop: never open a browser and skip desktop-only startup messages.\n"+
+			"The API works as normal, and a placeholder page is served if the UI wasn't built in.\n"+
+			"Defaults to the value of FORGE_HEADLESS")
+	if err := flags.Parse(args); err != nil {
+		return opts, err
+	}
+	for _, host := range strings.Split(*devTLSHosts, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			opts.DevTLSHosts = append(opts.DevTLSHosts, host)
+		}
+	}
+	return opts, nil
+}
+
+// shouldOpenBrowser decides whether to open the UI on startup. The config must allow it,
+// and none of -headless, -no-browser or NO_BROWSER may be set.
+func shouldOpenBrowser(opts cliOptions, configAllows bool, getenv func(string) string) bool {
+	if opts.Headless || opts.NoBrowser || getenv("NO_BROWSER") != "" {
+		return false
+	}
+	return configAllows
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"strings"
+	"testing"
+)
+
+// envMap returns a getenv backed by m.
+func envMap(m map[string]string) func(string) string {
+	return func(key string) string { return m[key] }
+}
+
+func TestParseFlags_Headless(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		want bool
+	}{
+		{"default", nil, nil, false},
+		{"flag", []string{"-headless"}, nil, true},
+		{"env", nil, map[string]string{"FORGE_HEADLESS": "1"}, true},
+		{"env true", nil, map[string]string{"FORGE_HEADLESS": "true"}, true},
+		{"env unparseable", nil, map[string]string{"FORGE_HEADLESS": "yes please"}, false},
+		{"flag overrides env", []string{"-headless=false"}, map[string]string{"FORGE_HEADLESS": "1"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := parseFlags(tt.args, envMap(tt.env), io.Discard)
+			if err != nil {
+				t.Fatalf("parseFlags: %v", err)
+			}
+			if opts.Headless != tt.want {
+				t.Errorf("Expected Headless=%v, got %v", tt.want, opts.Headless)
+			}
+		})
+	}
+}
+
+func TestParseFlags_DevTLSHosts(t *testing.T) {
+	opts, err := parseFlags([]string{"-dev-tls-hosts", "192.168.1.20, forge.lan,"}, envMap(nil), io.Discard)
+	if err != nil || strings.Join(opts.DevTLSHosts, "|") != "192.168.1.20|forge.lan" {
+		t.Errorf("Expected the listed hosts, got %q (%v)", opts.DevTLSHosts, err)
+	}
+
+	opts, _ = parseFlags(nil, envMap(map[string]string{"FORGE_DEV_TLS_HOSTS": "10.0.0.5"}), io.Discard)
+	if strings.Join(opts.DevTLSHosts, "|") != "10.0.0.5" {
+		t.Errorf("Expected the hosts from FORGE_DEV_TLS_HOSTS, got %q", opts.DevTLSHosts)
+	}
+
+	if opts, _ := parseFlags(nil, envMap(nil), io.Discard); opts.DevTLSHosts != nil {
+		t.Errorf("Expected no extra hosts by default, got %q", opts.DevTLSHosts)
+	}
+}
+
+func TestParseFlags_Errors(t *testing.T) {
+	if _, err := parseFlags([]string{"-h"}, envMap(nil), io.Discard); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("Expected flag.ErrHelp for -h, got %v", err)
+	}
+	if _, err := parseFlags([]string{"-no-such-flag"}, envMap(nil), io.Discard); err == nil {
+		t.Error("Expected an error for an unknown flag")
+	}
+}
+
+func TestShouldOpenBrowser(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         cliOptions
+		configAllows bool
+		env          map[string]string
+		wantOpen     bool
+	}{
+		{"config allows", cliOptions{}, true, nil, true},
+		{"config disallows", cliOptions{}, false, nil, false},
+		{"headless", cliOptions{Headless: true}, true, nil, false},
+		{"no-browser", cliOptions{NoBrowser: true}, true, nil, false},
+		{"NO_BROWSER", cliOptions{}, true, map[string]string{"NO_BROWSER": "1"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shouldOpenBrowser(tt.opts, tt.configAllows, envMap(tt.env)); got != tt.wantOpen {
+				t.Errorf("Expected %v, got %v", tt.wantOpen, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/logging"
+)
+
+// fallbackPage is served in place of the UI when the binary was built without the frontend.
+const fallbackPage = `<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="utf-8"><title>Forge Orchestrator</title></head>
+<body style="font-family: sans-serif; max-width: 40em; margin: 4em auto; line-height: 1.5">
+<h1>Forge Orchestrator is running</h1>
+<p>This build doesn't include the web UI, but the API is available.</p>
+<ul>
+<li><a href="/api/health">/api/health</a> - server status</li>
+<li><a href="/api/version">/api/version</a> - build information</li>
+<li><a href="/metrics">/metrics</a> - Prometheus metrics</li>
+</ul>
+<p>To get the UI, run <code>cd frontend &amp;&amp; npm install &amp;&amp; npm run build</code> and rebuild the binary.</p>
+</body>
+</html>
+`
+
+// frontendFS returns the built UI inside the embedded files, or false when the
+// binary was built without it (no frontend/dist/index.html).
+func frontendFS(embedded fs.FS) (fs.FS, bool) {
+	dist, err := fs.Sub(embedded, "frontend/dist")
+	if err != nil {
+		return nil, false
+	}
+	if _, err := fs.Stat(dist, "index.html"); err != nil {
+		return nil, false
+	}
+	return dist, true
+}
+
+// spaHandler serves the built UI, sending unknown paths to index.html so client-side routes work.
+// Without an embedded UI it logs a warning and serves fallbackPage, so headless use of the API still works.
+// Educational Comment: A path whose last segment has an extension (/assets/app.js) is an
+// asset: it's served if that file exists and 404 otherwise, so a missing script doesn't
+// come back as HTML. Every other path is a client-side route and gets index.html. Nothing
+// else is served, so there are no directory listings and no dotfiles.
+func spaHandler(embedded fs.FS) http.HandlerFunc {
+	distFS, ok := frontendFS(embedded)
+	if !ok {
+		logging.Warnf("Embedded frontend not found; serving a placeholder page. The API is still available under /api/")
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(fallbackPage))
+		}
+	}
+
+	etags := frontendETags(distFS)
+	hashed := hashedAssets(distFS)
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Prepare path for fs.Open (no leading slash, no .. or //)
+		name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+		if !isAssetPath(name) {
+			name = "index.html"
+		}
+		serveFrontendFile(w, r, distFS, name, etags, hashed)
+	}
+}
+
+// viteManifest is where Vite lists its build output (build.manifest in vite.config.ts).
+const viteManifest = ".vite/manifest.json"
+
+// hashedAssetName matches the file names Vite gives build output, e.g. index-B3x9_kQa.js:
+// one "-", an 8 character hash and the extension. It's only used without a build manifest.
+var hashedAssetName = regexp.MustCompile(`-[A-Za-z0-9_]{8}\.[A-Za-z0-9]+$`)
+
+// hashedAssets returns a test for whether a UI file has a content hash in its name. The
+// hash changes whenever the content does, so such a file can be cached for good.
+// Educational Comment: Vite's build manifest names exactly the files it hashed. Without one
+// (an older build), a file counts if it's in assets/ and its name ends like a hash; files
+// from public/ such as site-manifest.json keep their names as is, however they look.
+func hashedAssets(distFS fs.FS) func(name string) bool {
+	data, err := fs.ReadFile(distFS, viteManifest)
+	if err != nil {
+		return func(name string) bool {
+			return path.Dir(name) == "assets" && hashedAssetName.MatchString(path.Base(name))
+		}
+	}
+
+	var manifest map[string]struct {
+		File   string   `json:"file"`
+		CSS    []string `json:"css"`
+		Assets []string `json:"assets"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		logging.Warnf("Frontend: can't read %s, so no UI file is cached long-term: %v", viteManifest, err)
+	}
+	hashed := map[string]bool{}
+	for _, chunk := range manifest {
+		hashed[chunk.File] = true
+		for _, name := range slices.Concat(chunk.CSS, chunk.Assets) {
+			hashed[name] = true
+		}
+	}
+	return func(name string) bool { return hashed[name] }
+}
+
+// frontendETags hashes every file of the UI once. The files are embedded in the binary,
+// so they can't change while it runs, and their modification times are all zero.
+func frontendETags(distFS fs.FS) map[string]string {
+	etags := map[string]string{}
+	fs.WalkDir(distFS, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		data, err := fs.ReadFile(distFS, name)
+		if err != nil {
+			return nil
+		}
+		sum := sha256.Sum256(data)
+		etags[name] = `"` + hex.EncodeToString(sum[:16]) + `"`
+		return nil
+	})
+	return etags
+}
+
+// cacheControlFor returns how long browsers may keep a UI file: hashed assets for a year,
+// index.html only after checking it's unchanged (it names the current hashed assets),
+// and anything else (favicon, images) for an hour.
+func cacheControlFor(name string, hashed func(string) bool) string {
+	switch {
+	case name == "index.html":
+		return "no-cache"
+	case hashed(name):
+		return "public, max-age=31536000, immutable"
+	default:
+		return "public, max-age=3600"
+	}
+}
+
+// isAssetPath reports whether a cleaned request path names a file rather than a client-side route.
+func isAssetPath(name string) bool {
+	return name != "" && strings.Contains(path.Base(name), ".")
+}
+
+// serveFrontendFile serves one regular file from the UI, or 404 if there isn't one by that
+// name or any part of the name is hidden (starts with a dot). A request whose If-None-Match
+// holds the file's ETag gets 304 Not Modified.
+func serveFrontendFile(w http.ResponseWriter, r *http.Request, distFS fs.FS, name string, etags map[string]string, hashed func(string) bool) {
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") {
+			http.NotFound(w, r)
+			return
+		}
+	}
+
+	f, err := distFS.Open(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		http.NotFound(w, r)
+		return
+	}
+
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			http.Error(w, "Failed to read "+name, http.StatusInternalServerError)
+			return
+		}
+		content = bytes.NewReader(data)
+	}
+	w.Header().Set("Cache-Control", cacheControlFor(name, hashed))
+	if etag, ok := etags[name]; ok {
+		w.Header().Set("ETag", etag)
+	}
+	// ServeContent sets Content-Type from the extension and handles Range, and If-None-Match against the ETag
+	http.ServeContent(w, r, name, info.ModTime(), content)
+}
@@ -17,6 +17,10 @@ interface BudgetStatus {
     remainingPrompts: number;
     costUnit: 'TOKEN' | 'PROMPT';
     model: string;
+    cost_model?: 'per_token' | 'per_prompt';
+    remainingTokens?: number;
+    input_tokens?: number;
+    output_tokens?: number;
 }
 
 /**
@@ -133,6 +137,10 @@ export const ArchitectView: React.FC = () => {
                         totalBudget={budgetStatus.totalBudget}
                         costUnit={budgetStatus.costUnit}
                         model={budgetStatus.model}
+                        costModel={budgetStatus.cost_model}
+                        remainingTokens={budgetStatus.remainingTokens}
+                        inputTokens={budgetStatus.input_tokens}
+                        outputTokens={budgetStatus.output_tokens}
                     />
                     {/* Token Measurement Explanation */}
                     <div className="bg-slate-800/50 border border-slate-700 rounded-lg p-3">
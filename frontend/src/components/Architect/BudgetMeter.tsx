@@ -5,7 +5,8 @@ import { Wallet, Zap } from 'lucide-react';
  * Task 4.2: Dynamic Budget Meter
  * 
  * Displays the remaining budget for the selected model.
- * Shows "X Prompts Remaining" for transparent resource gating.
+ * Shows "X Tokens Remaining" for per-token models and "X Prompts Remaining"
+ * for per-prompt models, following the backend's cost_model.
  */
 
 interface BudgetMeterProps {
@@ -14,6 +15,10 @@ interface BudgetMeterProps {
     totalBudget: number;
     costUnit: 'TOKEN' | 'PROMPT';
     model: string;
+    costModel?: 'per_token' | 'per_prompt';
+    remainingTokens?: number;
+    inputTokens?: number;
+    outputTokens?: number;
 }
 
 export const BudgetMeter: React.FC<BudgetMeterProps> = ({
@@ -21,7 +26,11 @@ export const BudgetMeter: React.FC<BudgetMeterProps> = ({
     remainingBudget,
     totalBudget,
     costUnit,
-    model
+    model,
+    costModel,
+    remainingTokens,
+    inputTokens,
+    outputTokens
 }) => {
     const percentage = (remainingBudget / totalBudget) * 100;
     
@@ -36,7 +45,10 @@ export const BudgetMeter: React.FC<BudgetMeterProps> = ({
         bgColorClass = 'bg-yellow-500';
     }
 
-    const unitLabel = costUnit === 'PROMPT' ? 'Prompts' : 'Prompts';
+    // Per-token models count down tokens; per-prompt models (and older servers) count prompts
+    const showTokens = costModel === 'per_token' && remainingTokens !== undefined;
+    const remaining = showTokens ? remainingTokens : remainingPrompts;
+    const unitLabel = showTokens ? 'Tokens' : 'Prompts';
     const unitIcon = costUnit === 'PROMPT' ? '💬' : '🪙';
 
     return (
@@ -54,8 +66,11 @@ export const BudgetMeter: React.FC<BudgetMeterProps> = ({
                 </div>
                 <div className="flex items-center gap-1">
                     <Zap className={`w-4 h-4 ${colorClass}`} />
-                    <span className={`text-lg font-bold ${colorClass}`} data-testid="remaining-prompts">
-                        {remainingPrompts.toLocaleString()}
+                    <span
+                        className={`text-lg font-bold ${colorClass}`}
+                        data-testid={showTokens ? 'remaining-tokens' : 'remaining-prompts'}
+                    >
+                        {remaining.toLocaleString()}
                     </span>
                     <span className="text-sm text-gray-400">{unitLabel} Remaining</span>
                 </div>
@@ -75,6 +90,11 @@ export const BudgetMeter: React.FC<BudgetMeterProps> = ({
                 <span>{unitIcon} ${remainingBudget.toFixed(2)} remaining</span>
                 <span>${totalBudget.toFixed(2)} daily limit</span>
             </div>
+            {costModel === 'per_token' && inputTokens !== undefined && outputTokens !== undefined && (
+                <div className="mt-1 text-xs text-gray-500" data-testid="budget-token-split">
+                    Today: {inputTokens.toLocaleString()} input + {outputTokens.toLocaleString()} output tokens
+                </div>
+            )}
         </div>
     );
 };
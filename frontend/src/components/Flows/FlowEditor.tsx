@@ -46,6 +46,8 @@ const FlowEditorContent: React.FC = () => {
     
     // Flow metadata
     const [flowName, setFlowName] = useState('New Flow');
+    // Revision the loaded flow is at; sent with saves so the server can refuse stale edits
+    const [revision, setRevision] = useState(0);
     
     // Selected node for configuration - show panel when a node is selected
     const [selectedNode, setSelectedNode] = useState<Node<AgentNodeData> | null>(null);
@@ -85,6 +87,7 @@ const FlowEditorContent: React.FC = () => {
             
             const flow = await response.json();
             setFlowName(flow.name);
+            setRevision(flow.revision);
             
             // Parse the graph data
             if (flow.data) {
@@ -210,7 +213,8 @@ const FlowEditorContent: React.FC = () => {
                     nodes: nodes,
                     edges: edges
                 }),
-                status: 'active'
+                status: 'active',
+                revision: revision
             };
 
             const url = id ? `/api/flows/${id}` : '/api/flows';
@@ -224,6 +228,10 @@ const FlowEditorContent: React.FC = () => {
                 body: JSON.stringify(flowData),
             });
 
+            if (response.status === 409) {
+                setToast({ type: 'error', message: 'This flow was changed elsewhere. Reload it before saving.' });
+                return;
+            }
             if (!response.ok) {
                 throw new Error('Failed to save flow');
             }
@@ -1,5 +1,5 @@
 import { useState, useEffect, useCallback } from 'react';
-import { OptimizationCard, type Suggestion } from './OptimizationCard';
+import { OptimizationCard, type OptimizationAnalysis, type Suggestion } from './OptimizationCard';
 import { useWebSocket } from '../../hooks/useWebSocket';
 
 /**
@@ -78,6 +78,8 @@ function Toast({ message, type, onClose }: { message: string; type: 'info' | 'su
 export function LedgerView() {
     const [entries, setEntries] = useState<LedgerEntry[]>([]);
     const [optimizations, setOptimizations] = useState<Suggestion[]>([]);
+    const [analyzedRows, setAnalyzedRows] = useState(0);
+    const [analysisMessage, setAnalysisMessage] = useState<string | null>(null);
     const [loading, setLoading] = useState(true);
     const [error, setError] = useState<string | null>(null);
     const [toasts, setToasts] = useState<Array<{ id: number; message: string; type: 'info' | 'success' | 'error' }>>([]);
@@ -105,10 +107,12 @@ export function LedgerView() {
             if (!optimizationsRes.ok) throw new Error('Failed to fetch optimizations');
 
             const ledgerData: ApiLedgerEntry[] = await ledgerRes.json();
-            const optimizationsData = await optimizationsRes.json();
+            const optimizationsData: OptimizationAnalysis = await optimizationsRes.json();
 
             setEntries(ledgerData.map(mapLedgerEntry));
-            setOptimizations(optimizationsData);
+            setOptimizations(optimizationsData.suggestions);
+            setAnalyzedRows(optimizationsData.ledger_rows);
+            setAnalysisMessage(optimizationsData.analyzed ? null : optimizationsData.message ?? null);
         } catch (err) {
             setError(err instanceof Error ? err.message : 'An unknown error occurred');
         } finally {
@@ -210,8 +214,12 @@ export function LedgerView() {
                             onApply={handleApplyOptimization}
                         />
                     ))
+                ) : analyzedRows === 0 ? (
+                    <div className="text-gray-500 italic">No usage data yet. Run a command or flow to get optimization suggestions.</div>
+                ) : analysisMessage ? (
+                    <div className="text-gray-500 italic">{analysisMessage}</div>
                 ) : (
-                    <div className="text-gray-500 italic">No optimization suggestions yet</div>
+                    <div className="text-gray-500 italic">Nothing to optimize. Your recent usage looks efficient.</div>
                 )}
             </div>
 
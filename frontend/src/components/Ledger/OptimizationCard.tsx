@@ -13,6 +13,16 @@ export interface Suggestion {
     status: 'pending' | 'applied';
 }
 
+// OptimizationAnalysis is the envelope returned by GET /api/ledger/optimizations.
+// ledger_rows lets the UI tell "no data yet" apart from "nothing to optimize".
+export interface OptimizationAnalysis {
+    analyzed: boolean;
+    ledger_rows: number;
+    min_ledger_rows: number;
+    message?: string;
+    suggestions: Suggestion[];
+}
+
 interface ApplyAction {
     action: string;
     from_model?: string;
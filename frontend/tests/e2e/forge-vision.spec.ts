@@ -72,7 +72,7 @@ test.describe('Ledger Cost Unit Display', () => {
         });
 
         await page.route('/api/ledger/optimizations', async route => {
-            await route.fulfill({ json: [] });
+            await route.fulfill({ json: { analyzed: false, ledger_rows: 0, min_ledger_rows: 5, suggestions: [] } });
         });
 
         await page.goto('/');
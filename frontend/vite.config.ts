@@ -4,6 +4,10 @@ import react from '@vitejs/plugin-react'
 // https://vite.dev/config/
 export default defineConfig({
   plugins: [react()],
+  build: {
+    // dist/.vite/manifest.json lists the hashed files, which the Go server caches for a year
+    manifest: true,
+  },
   server: {
     port: 8081,
     proxy: {
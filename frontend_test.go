@@ -0,0 +1,198 @@
+package main
+
+import (
+	"database/sql"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/data"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/server"
+)
+
+// startTestServer runs the full handler over a real listener with the given embedded files.
+func startTestServer(t *testing.T, frontend fstest.MapFS) *httptest.Server {
+	t.Helper()
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if _, err := db.Exec(data.SQLiteSchema); err != nil {
+		t.Fatalf("Failed to init schema: %v", err)
+	}
+	srv := server.NewServer(db)
+
+	ts := httptest.NewServer(newHandler(srv, frontend))
+	t.Cleanup(func() {
+		ts.Close()
+		srv.Close()
+		db.Close()
+	})
+	return ts
+}
+
+func get(t *testing.T, url string) (int, string) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", url, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestNewHandler_WithoutFrontend(t *testing.T) {
+	ts := startTestServer(t, fstest.MapFS{})
+
+	if status, _ := get(t, ts.URL+"/api/health"); status != http.StatusOK {
+		t.Errorf("Expected the API to respond without a frontend, got %d", status)
+	}
+
+	for _, path := range []string{"/", "/flows"} {
+		status, body := get(t, ts.URL+path)
+		if status != http.StatusOK || !strings.Contains(body, "/api/health") {
+			t.Errorf("%s: expected the fallback page, got %d: %.80s", path, status, body)
+		}
+	}
+}
+
+func TestNewHandler_WithFrontend(t *testing.T) {
+	ts := startTestServer(t, fstest.MapFS{
+		"frontend/dist/index.html":    {Data: []byte("<html>forge ui</html>")},
+		"frontend/dist/assets/app.js": {Data: []byte("console.log('app')")},
+	})
+
+	// Real files are served, and client-side routes fall back to index.html
+	if _, body := get(t, ts.URL+"/assets/app.js"); body != "console.log('app')" {
+		t.Errorf("Expected the asset, got %q", body)
+	}
+	if _, body := get(t, ts.URL+"/flows"); body != "<html>forge ui</html>" {
+		t.Errorf("Expected index.html for an SPA route, got %q", body)
+	}
+}
+
+func TestNewHandler_ServesOnlyFilesAndRoutes(t *testing.T) {
+	ts := startTestServer(t, fstest.MapFS{
+		"frontend/dist/index.html":    {Data: []byte("<html>forge ui</html>")},
+		"frontend/dist/assets/app.js": {Data: []byte("console.log('app')")},
+		"frontend/dist/.env":          {Data: []byte("SECRET=1")},
+	})
+
+	// The root and directories get the app, never a listing
+	for _, path := range []string{"/", "/assets", "/assets/", "/some/spa/route"} {
+		status, body := get(t, ts.URL+path)
+		if status != http.StatusOK || body != "<html>forge ui</html>" {
+			t.Errorf("%s: expected index.html, got %d: %q", path, status, body)
+		}
+	}
+
+	// Missing assets and dotfiles are 404s, not the app
+	for _, path := range []string{"/nonexistent.js", "/assets/missing.css", "/.env"} {
+		if status, body := get(t, ts.URL+path); status != http.StatusNotFound || strings.Contains(body, "forge ui") {
+			t.Errorf("%s: expected 404, got %d: %q", path, status, body)
+		}
+	}
+
+	resp, err := http.Get(ts.URL + "/assets/app.js")
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); !strings.Contains(ct, "javascript") {
+		t.Errorf("Expected a JavaScript Content-Type for the asset, got %q", ct)
+	}
+}
+
+func TestHashedAssets(t *testing.T) {
+	// Without a build manifest, only names in assets/ that end in a Vite hash count
+	hashed := hashedAssets(fstest.MapFS{})
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"assets/index-B3x9_kQa.js", true},
+		{"assets/vendor-react-Dk2l_8aZ.css", true},
+		{"apple-touch-icon.png", false},
+		{"site-manifest.json", false},
+		{"my-long-logo.svg", false},
+		{"assets/my-long-logo.svg", false},         // 4 character segment
+		{"assets/index-B3x9_kQa9.js", false},       // 9 characters
+		{"assets/index.js", false},                 // no hash
+		{"nested/assets/index-B3x9_kQa.js", false}, // not Vite's output directory
+	}
+	for _, tt := range tests {
+		if got := hashed(tt.name); got != tt.want {
+			t.Errorf("without a manifest, hashed(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// With one, exactly the files it lists count
+	hashed = hashedAssets(fstest.MapFS{viteManifest: {Data: []byte(`{
+		"index.html": {"file": "assets/index-B3x9_kQa.js", "css": ["assets/index-Cc1_x2Yz.css"], "assets": ["assets/logo-Q7a_b9Lm.svg"]}
+	}`)}})
+	for name, want := range map[string]bool{
+		"assets/index-B3x9_kQa.js":  true,
+		"assets/index-Cc1_x2Yz.css": true,
+		"assets/logo-Q7a_b9Lm.svg":  true,
+		"assets/site-manifest.json": false,
+		"assets/other-A1b2C3d4.js":  false,
+	} {
+		if got := hashed(name); got != want {
+			t.Errorf("with a manifest, hashed(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestNewHandler_CachesAssets(t *testing.T) {
+	ts := startTestServer(t, fstest.MapFS{
+		"frontend/dist/index.html":               {Data: []byte("<html>forge ui</html>")},
+		"frontend/dist/assets/index-B3x9_kQa.js": {Data: []byte("console.log('app')")},
+		"frontend/dist/favicon.ico":              {Data: []byte("icon")},
+		"frontend/dist/site-manifest.json":       {Data: []byte("{}")},
+	})
+	fetch := func(path, ifNoneMatch string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest("GET", ts.URL+path, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", path, err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	tests := []struct{ path, cacheControl string }{
+		{"/assets/index-B3x9_kQa.js", "public, max-age=31536000, immutable"},
+		{"/flows", "no-cache"}, // index.html
+		{"/favicon.ico", "public, max-age=3600"},
+		{"/site-manifest.json", "public, max-age=3600"},
+	}
+	for _, tt := range tests {
+		first := fetch(tt.path, "")
+		etag := first.Header.Get("ETag")
+		if first.StatusCode != http.StatusOK || etag == "" || first.Header.Get("Cache-Control") != tt.cacheControl {
+			t.Errorf("%s: expected 200 with an ETag and %q, got %d %v", tt.path, tt.cacheControl, first.StatusCode, first.Header)
+			continue
+		}
+
+		// A matching ETag gets 304; a stale one gets the file again
+		if resp := fetch(tt.path, etag); resp.StatusCode != http.StatusNotModified {
+			t.Errorf("%s: expected 304 for a matching ETag, got %d", tt.path, resp.StatusCode)
+		}
+		if resp := fetch(tt.path, `"stale"`); resp.StatusCode != http.StatusOK || resp.Header.Get("ETag") != etag {
+			t.Errorf("%s: expected a fresh 200 for another ETag, got %d", tt.path, resp.StatusCode)
+		}
+	}
+
+	// Every SPA route is the same index.html, so they share its ETag
+	if fetch("/", "").Header.Get("ETag") != fetch("/some/route", "").Header.Get("ETag") {
+		t.Error("Expected SPA routes to share index.html's ETag")
+	}
+}
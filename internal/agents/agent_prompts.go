@@ -47,6 +47,7 @@ Suggestion must be concrete (e.g., 'Change prompt to use JSON instead of Markdow
 `
 
 // GetAgentPrompt retrieves the correct prompt string based on the agent's role.
+// Roles that aren't built in are looked up among the custom roles (see UseCustomRoles).
 func GetAgentPrompt(role string) (string, error) {
 	resolvedRole := resolveRole(role)
 
@@ -60,6 +61,9 @@ func GetAgentPrompt(role string) (string, error) {
 	case "Optimizer":
 		return SystemPromptOptimizer, nil
 	default:
+		if custom, ok := findCustomRole(role); ok {
+			return custom.SystemPrompt, nil
+		}
 		return "", fmt.Errorf("unknown agent role: %s", role)
 	}
 }
@@ -100,6 +104,27 @@ func resolveRole(role string) string {
 	return role // Return as-is, GetAgentPrompt will error
 }
 
+// ResolveRole returns the canonical role name for a role or alias,
+// and false if the role is not recognised. Custom roles resolve to their stored name.
+func ResolveRole(role string) (string, bool) {
+	if name, ok := builtinRole(role); ok {
+		return name, true
+	}
+	if custom, ok := findCustomRole(role); ok {
+		return custom.Name, true
+	}
+	return "", false
+}
+
+// CanonicalRole returns the name a role or alias is recorded under, such as
+// "Implementation" for "coder". Unrecognised roles are returned unchanged.
+func CanonicalRole(role string) string {
+	if name, ok := ResolveRole(role); ok {
+		return name
+	}
+	return role
+}
+
 // GetCanonicalRoles returns the list of valid canonical role names
 func GetCanonicalRoles() []string {
 	return canonicalRoles
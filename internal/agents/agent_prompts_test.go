@@ -162,6 +162,30 @@ func TestResolveRole(t *testing.T) {
 	}
 }
 
+func TestResolveRoleExported(t *testing.T) {
+	if role, ok := ResolveRole(" QA "); !ok || role != "Test" {
+		t.Errorf("ResolveRole(\" QA \") = %q, %v; want Test, true", role, ok)
+	}
+	if role, ok := ResolveRole("wizard"); ok || role != "" {
+		t.Errorf("ResolveRole(\"wizard\") = %q, %v; want \"\", false", role, ok)
+	}
+}
+
+func TestCanonicalRole(t *testing.T) {
+	tests := map[string]string{
+		"coder":          "Implementation",
+		" qa ":           "Test",
+		"architect":      "Architect",
+		"Implementation": "Implementation",
+		"wizard":         "wizard", // Unknown roles are left alone
+	}
+	for role, want := range tests {
+		if got := CanonicalRole(role); got != want {
+			t.Errorf("CanonicalRole(%q) = %q, want %q", role, got, want)
+		}
+	}
+}
+
 func TestGetCanonicalRoles(t *testing.T) {
 	roles := GetCanonicalRoles()
 	if len(roles) != 4 {
@@ -0,0 +1,66 @@
+package agents
+
+import (
+	"database/sql"
+	"strings"
+	"sync"
+)
+
+// CustomRole is a user-defined agent role, stored in the custom_agents table.
+// Built-in roles and their aliases always win, so a custom role can't replace one.
+type CustomRole struct {
+	ID           int64  `json:"id"`
+	Name         string `json:"name"`
+	SystemPrompt string `json:"system_prompt"`
+}
+
+var (
+	customMu sync.RWMutex
+	customDB *sql.DB
+)
+
+// UseCustomRoles makes GetAgentPrompt and ResolveRole look up roles that aren't built in
+// in db's custom_agents table. Pass nil to go back to built-in roles only.
+func UseCustomRoles(db *sql.DB) {
+	customMu.Lock()
+	defer customMu.Unlock()
+	customDB = db
+}
+
+// IsBuiltinRole reports whether name is a built-in role or alias (case-insensitive).
+func IsBuiltinRole(name string) bool {
+	_, ok := builtinRole(name)
+	return ok
+}
+
+// builtinRole returns the canonical built-in role for name, if there is one.
+func builtinRole(name string) (string, bool) {
+	resolved := resolveRole(name)
+	for _, canonical := range canonicalRoles {
+		if resolved == canonical {
+			return canonical, true
+		}
+	}
+	return "", false
+}
+
+// findCustomRole looks name up in the custom_agents table (case-insensitive).
+// A lookup error is treated as not found, so GetAgentPrompt reports the role as unknown.
+func findCustomRole(name string) (CustomRole, bool) {
+	customMu.RLock()
+	db := customDB
+	customMu.RUnlock()
+
+	name = strings.TrimSpace(name)
+	if db == nil || name == "" {
+		return CustomRole{}, false
+	}
+
+	var role CustomRole
+	err := db.QueryRow(`SELECT id, name, system_prompt FROM custom_agents WHERE name = ? COLLATE NOCASE`, name).
+		Scan(&role.ID, &role.Name, &role.SystemPrompt)
+	if err != nil {
+		return CustomRole{}, false
+	}
+	return role, true
+}
@@ -0,0 +1,73 @@
+package agents
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/data"
+	_ "modernc.org/sqlite"
+)
+
+// useCustomRolesDB installs an in-memory database with the given custom roles for the test.
+func useCustomRolesDB(t *testing.T, roles map[string]string) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if _, err := db.Exec(data.SQLiteSchema); err != nil {
+		t.Fatalf("Failed to init schema: %v", err)
+	}
+	for name, prompt := range roles {
+		if _, err := db.Exec("INSERT INTO custom_agents (name, system_prompt) VALUES (?, ?)", name, prompt); err != nil {
+			t.Fatalf("Failed to insert custom role: %v", err)
+		}
+	}
+	UseCustomRoles(db)
+	t.Cleanup(func() {
+		UseCustomRoles(nil)
+		db.Close()
+	})
+}
+
+func TestGetAgentPrompt_CustomRole(t *testing.T) {
+	useCustomRolesDB(t, map[string]string{"Reviewer": "You review pull requests."})
+
+	for _, role := range []string{"Reviewer", "reviewer", "  REVIEWER "} {
+		prompt, err := GetAgentPrompt(role)
+		if err != nil {
+			t.Fatalf("GetAgentPrompt(%q) returned error: %v", role, err)
+		}
+		if prompt != "You review pull requests." {
+			t.Errorf("GetAgentPrompt(%q) = %q, want the stored prompt", role, prompt)
+		}
+	}
+
+	if name, ok := ResolveRole("reviewer"); !ok || name != "Reviewer" {
+		t.Errorf("ResolveRole(reviewer) = %q, %v; want Reviewer, true", name, ok)
+	}
+
+	// Unknown roles still error with custom roles in place
+	if _, err := GetAgentPrompt("wizard"); err == nil || !strings.Contains(err.Error(), "unknown agent role") {
+		t.Errorf("Expected an unknown agent role error, got %v", err)
+	}
+	if _, ok := ResolveRole("wizard"); ok {
+		t.Error("ResolveRole(wizard) should not resolve")
+	}
+}
+
+func TestGetAgentPrompt_BuiltinsWinOverCustomRoles(t *testing.T) {
+	// Shouldn't happen through the API, but a stored "coder" must not shadow the alias
+	useCustomRolesDB(t, map[string]string{"coder": "Not the real coder."})
+
+	prompt, err := GetAgentPrompt("coder")
+	if err != nil {
+		t.Fatalf("GetAgentPrompt(coder) returned error: %v", err)
+	}
+	if prompt != SystemPromptImplementation {
+		t.Errorf("Expected the built-in Implementation prompt, got %q", prompt)
+	}
+	if !IsBuiltinRole("Coder") || IsBuiltinRole("Reviewer") {
+		t.Error("IsBuiltinRole should accept aliases and reject custom names")
+	}
+}
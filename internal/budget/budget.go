@@ -0,0 +1,59 @@
+// Package budget enforces spending and quota limits before LLM calls are made.
+// Every code path that calls the LLM Gateway (command runs, flows, the OpenAI proxy)
+// checks here first so the limits apply no matter how a call is triggered.
+package budget
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/config"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/data"
+)
+
+// CodeTokenLimitExceeded is the error code reported to clients when the daily token limit is hit.
+const CodeTokenLimitExceeded = "TOKEN_LIMIT_EXCEEDED"
+
+// ErrTokenLimitExceeded is returned (wrapped) when today's token usage has reached the limit.
+var ErrTokenLimitExceeded = errors.New(CodeTokenLimitExceeded)
+
+// CodeBudgetExceeded is the status reported when a flow or command card hits its own MaxCostUSD.
+const CodeBudgetExceeded = "BUDGET_EXCEEDED"
+
+// ErrBudgetExceeded is returned (wrapped) by CheckCostCap.
+var ErrBudgetExceeded = errors.New(CodeBudgetExceeded)
+
+// CheckDailyTokenLimit returns ErrTokenLimitExceeded if today's input+output tokens
+// have reached Budget.DailyTokenLimit. A limit of 0 means unlimited.
+// "Today" follows Budget.TimeZone.
+// If the config or ledger can't be read, the call is allowed rather than blocked.
+func CheckDailyTokenLimit(db *sql.DB) error {
+	cfg, err := config.Get()
+	if err != nil || cfg.Budget.DailyTokenLimit <= 0 {
+		return nil
+	}
+
+	used, err := data.NewLedgerService(db).TokensUsedToday(cfg.Budget.Location())
+	if err != nil {
+		return nil
+	}
+
+	if used >= cfg.Budget.DailyTokenLimit {
+		return fmt.Errorf("%w: %d of %d daily tokens used", ErrTokenLimitExceeded, used, cfg.Budget.DailyTokenLimit)
+	}
+	return nil
+}
+
+// CheckCostCap returns ErrBudgetExceeded if spent has already reached maxCostUSD,
+// or if spending a further projected dollars would take it past the cap.
+// A cap of 0 means unlimited.
+func CheckCostCap(spent, projected, maxCostUSD float64) error {
+	if maxCostUSD <= 0 {
+		return nil
+	}
+	if spent >= maxCostUSD || spent+projected > maxCostUSD {
+		return fmt.Errorf("%w: $%.4f spent, next call would pass the $%.4f cap", ErrBudgetExceeded, spent, maxCostUSD)
+	}
+	return nil
+}
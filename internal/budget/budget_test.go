@@ -0,0 +1,100 @@
+package budget
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/config"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/data"
+	_ "modernc.org/sqlite"
+)
+
+// setupBudgetDB opens an in-memory database with the full schema.
+func setupBudgetDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if _, err := db.Exec(data.SQLiteSchema); err != nil {
+		t.Fatalf("Failed to init schema: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// useBudgetConfig saves a config with the given budget settings in a temp config dir.
+func useBudgetConfig(t *testing.T, budgetCfg config.BudgetConfig) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	cfg := config.DefaultConfig()
+	cfg.Budget = budgetCfg
+	if err := config.Save(cfg); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	t.Cleanup(func() { config.Save(config.DefaultConfig()) })
+}
+
+// seedTokens records a ledger entry for today with the given token counts.
+func seedTokens(t *testing.T, db *sql.DB, input, output int) {
+	err := data.NewLedgerService(db).LogUsage(data.TokenLedgerEntry{
+		FlowID: "seed", ModelUsed: "gpt-4o", AgentRole: "Implementation", PromptHash: "h",
+		InputTokens: input, OutputTokens: output, Status: "SUCCESS",
+	})
+	if err != nil {
+		t.Fatalf("Failed to seed ledger: %v", err)
+	}
+}
+
+func TestCheckDailyTokenLimit(t *testing.T) {
+	db := setupBudgetDB(t)
+	useBudgetConfig(t, config.BudgetConfig{DailyTokenLimit: 1000})
+
+	// Just under the limit: calls are still allowed
+	seedTokens(t, db, 600, 399)
+	if err := CheckDailyTokenLimit(db); err != nil {
+		t.Fatalf("Expected call under the limit to be allowed, got %v", err)
+	}
+
+	// The next call pushes usage to the limit, so the one after is blocked
+	seedTokens(t, db, 1, 0)
+	err := CheckDailyTokenLimit(db)
+	if !errors.Is(err, ErrTokenLimitExceeded) {
+		t.Fatalf("Expected ErrTokenLimitExceeded, got %v", err)
+	}
+	if got := err.Error(); got[:len(CodeTokenLimitExceeded)] != CodeTokenLimitExceeded {
+		t.Errorf("Expected error to start with %s, got %q", CodeTokenLimitExceeded, got)
+	}
+}
+
+func TestCheckDailyTokenLimit_DisabledByDefault(t *testing.T) {
+	db := setupBudgetDB(t)
+	useBudgetConfig(t, config.BudgetConfig{})
+
+	seedTokens(t, db, 10_000_000, 10_000_000)
+	if err := CheckDailyTokenLimit(db); err != nil {
+		t.Errorf("Expected no limit when DailyTokenLimit is 0, got %v", err)
+	}
+}
+
+func TestCheckCostCap(t *testing.T) {
+	tests := []struct {
+		name                  string
+		spent, projected, max float64
+		wantErr               bool
+	}{
+		{"no cap", 100, 100, 0, false},
+		{"nothing spent yet", 0, 0, 0.01, false},
+		{"next call fits", 0.4, 0.4, 1, false},
+		{"next call would pass the cap", 0.6, 0.6, 1, true},
+		{"cap already reached", 1, 0, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckCostCap(tt.spent, tt.projected, tt.max)
+			if tt.wantErr != errors.Is(err, ErrBudgetExceeded) {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -8,6 +8,10 @@ import (
 	"path/filepath"
 	"runtime"
 	"sync"
+	"time"
+
+	// Embed the zone database so Budget.TimeZone works on Windows, which ships none
+	_ "time/tzdata"
 )
 
 // ShellType represents the type of shell to use for command execution.
@@ -30,6 +34,27 @@ type Config struct {
 
 	// Server configuration
 	Server ServerConfig `json:"server"`
+
+	// Flows configuration
+	Flows FlowsConfig `json:"flows"`
+
+	// Budget configuration
+	Budget BudgetConfig `json:"budget"`
+
+	// Optimizer configuration
+	Optimizer OptimizerConfig `json:"optimizer"`
+
+	// Logging configuration
+	Logging LoggingConfig `json:"logging"`
+
+	// Ledger configuration
+	Ledger LedgerConfig `json:"ledger"`
+
+	// Cache configuration
+	Cache CacheConfig `json:"cache"`
+
+	// Execute configuration
+	Execute ExecuteConfig `json:"execute"`
 }
 
 // ShellConfig contains shell-related settings.
@@ -66,6 +91,310 @@ type ServerConfig struct {
 
 	// OpenBrowser determines if browser should open on startup
 	OpenBrowser bool `json:"open_browser"`
+
+	// BindAddress is the interface the HTTP or HTTPS listener binds to (default 127.0.0.1).
+	// Use 0.0.0.0 to expose Forge on every network interface.
+	BindAddress string `json:"bind_address,omitempty"`
+
+	// PreferredPorts are tried in order when Port is taken (empty = DefaultPreferredPorts)
+	PreferredPorts []int `json:"preferred_ports,omitempty"`
+
+	// MaxConcurrentCommandRuns caps simultaneous command-card runs (0 = DefaultMaxConcurrentCommandRuns)
+	MaxConcurrentCommandRuns int `json:"max_concurrent_command_runs,omitempty"`
+
+	// MaxRequestBodyBytes caps API request bodies (0 = DefaultMaxRequestBodyBytes).
+	// Feedback screenshot uploads have their own, larger cap.
+	MaxRequestBodyBytes int64 `json:"max_request_body_bytes,omitempty"`
+
+	// ReadTimeoutSeconds bounds reading a request, headers and body (0 = DefaultReadTimeout)
+	ReadTimeoutSeconds int `json:"read_timeout_seconds,omitempty"`
+
+	// WriteTimeoutSeconds bounds writing a response (0 = no limit). Flow runs answer
+	// once they finish, which can take minutes, so only set it if every run is quick.
+	// WebSockets aren't affected.
+	WriteTimeoutSeconds int `json:"write_timeout_seconds,omitempty"`
+
+	// IdleTimeoutSeconds is how long a keep-alive connection waits for its next request (0 = DefaultIdleTimeout)
+	IdleTimeoutSeconds int `json:"idle_timeout_seconds,omitempty"`
+
+	// ACME gets the HTTPS certificate from Let's Encrypt instead of FORGE_TLS_CERT/FORGE_TLS_KEY
+	ACME ACMEConfig `json:"acme"`
+}
+
+// ACMEConfig configures automatic certificates from Let's Encrypt (or another ACME CA).
+// Educational Comment: Let's Encrypt proves the domain is ours by connecting to it on
+// port 80 (HTTP-01, answered by a listener Forge opens there) or on port 443 (TLS-ALPN-01,
+// answered by the HTTPS listener). The domain must resolve to this machine and one of
+// those ports must reach Forge.
+type ACMEConfig struct {
+	// Enabled turns ACME on. It's ignored when FORGE_TLS_CERT and FORGE_TLS_KEY are set.
+	Enabled bool `json:"enabled"`
+
+	// Domains are the host names to get a certificate for; requests for others are refused
+	Domains []string `json:"domains,omitempty"`
+
+	// Email is given to the CA for expiry and problem notices (optional)
+	Email string `json:"email,omitempty"`
+
+	// CacheDir keeps the account key and certificates between restarts
+	// (empty = an acme-cache folder in the data directory)
+	CacheDir string `json:"cache_dir,omitempty"`
+}
+
+// ResolvedCacheDir returns the configured cache directory, or the default in the data directory.
+func (a ACMEConfig) ResolvedCacheDir() (string, error) {
+	if a.CacheDir != "" {
+		return a.CacheDir, nil
+	}
+	dataDir, err := GetDataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dataDir, "acme-cache"), nil
+}
+
+// DefaultPreferredPorts is the fallback port list used when none is configured.
+var DefaultPreferredPorts = []int{8080, 8333, 9000, 3000, 3333}
+
+// DefaultMaxConcurrentCommandRuns is how many command cards may run at once when no limit is configured.
+const DefaultMaxConcurrentCommandRuns = 4
+
+// DefaultMaxRequestBodyBytes is the largest API request body accepted when no limit is configured (1 MB).
+const DefaultMaxRequestBodyBytes = 1 << 20
+
+// DefaultReadTimeout is how long a client gets to send a request when no timeout is configured.
+const DefaultReadTimeout = 30 * time.Second
+
+// DefaultIdleTimeout is how long an idle keep-alive connection is kept when no timeout is configured.
+const DefaultIdleTimeout = 120 * time.Second
+
+// DefaultBindAddress keeps Forge reachable only from this machine.
+const DefaultBindAddress = "127.0.0.1"
+
+// ListenAddress returns the configured bind address, or DefaultBindAddress when unset.
+func (s ServerConfig) ListenAddress() string {
+	if s.BindAddress == "" {
+		return DefaultBindAddress
+	}
+	return s.BindAddress
+}
+
+// FallbackPorts returns the configured preferred ports, or the defaults when none are set.
+func (s ServerConfig) FallbackPorts() []int {
+	if len(s.PreferredPorts) == 0 {
+		return DefaultPreferredPorts
+	}
+	return s.PreferredPorts
+}
+
+// CommandRunLimit returns the configured concurrent command-run cap, or the default when unset.
+func (s ServerConfig) CommandRunLimit() int {
+	if s.MaxConcurrentCommandRuns <= 0 {
+		return DefaultMaxConcurrentCommandRuns
+	}
+	return s.MaxConcurrentCommandRuns
+}
+
+// RequestBodyLimit returns the configured request body cap, or the default when unset.
+func (s ServerConfig) RequestBodyLimit() int64 {
+	if s.MaxRequestBodyBytes <= 0 {
+		return DefaultMaxRequestBodyBytes
+	}
+	return s.MaxRequestBodyBytes
+}
+
+// ReadTimeout returns the configured request read timeout, or the default when unset.
+func (s ServerConfig) ReadTimeout() time.Duration {
+	if s.ReadTimeoutSeconds <= 0 {
+		return DefaultReadTimeout
+	}
+	return time.Duration(s.ReadTimeoutSeconds) * time.Second
+}
+
+// WriteTimeout returns the configured response write timeout; 0 means no limit.
+func (s ServerConfig) WriteTimeout() time.Duration {
+	if s.WriteTimeoutSeconds <= 0 {
+		return 0
+	}
+	return time.Duration(s.WriteTimeoutSeconds) * time.Second
+}
+
+// IdleTimeout returns the configured keep-alive idle timeout, or the default when unset.
+func (s ServerConfig) IdleTimeout() time.Duration {
+	if s.IdleTimeoutSeconds <= 0 {
+		return DefaultIdleTimeout
+	}
+	return time.Duration(s.IdleTimeoutSeconds) * time.Second
+}
+
+// FlowsConfig contains flow execution settings.
+type FlowsConfig struct {
+	// InterNodeDelayMs is a pause between sequential node calls, for providers
+	// that throttle aggressively (0 = no delay)
+	InterNodeDelayMs int `json:"inter_node_delay_ms"`
+
+	// WebhookURL receives a POST when a flow completes or fails (empty = disabled)
+	WebhookURL string `json:"webhook_url,omitempty"`
+
+	// WebhookFormat is the payload shape: "json" (default) or "slack"
+	WebhookFormat string `json:"webhook_format,omitempty"`
+}
+
+// LedgerConfig contains settings for the token ledger.
+type LedgerConfig struct {
+	// StorePrompts keeps the system and user prompt text of each call in ledger_prompts,
+	// so calls can be audited and replayed. Off by default: only a hash is recorded.
+	StorePrompts bool `json:"store_prompts"`
+}
+
+// CacheConfig controls the gateway's in-memory response cache.
+// Educational Comment: Caching is off by default because an identical prompt doesn't
+// always want an identical answer, e.g. when sampling for varied drafts.
+type CacheConfig struct {
+	// Enabled answers a repeat of an identical prompt (same system prompt, user prompt,
+	// model and settings) from memory, at no cost, until the cached response expires
+	Enabled bool `json:"enabled"`
+
+	// TTLSeconds is how long a response stays cached (0 = DefaultCacheTTLSeconds)
+	TTLSeconds int `json:"ttl_seconds,omitempty"`
+}
+
+// DefaultCacheTTLSeconds keeps cached responses for ten minutes.
+const DefaultCacheTTLSeconds = 600
+
+// TTL returns how long responses stay cached, falling back to the default when unset.
+func (c CacheConfig) TTL() time.Duration {
+	if c.TTLSeconds <= 0 {
+		return DefaultCacheTTLSeconds * time.Second
+	}
+	return time.Duration(c.TTLSeconds) * time.Second
+}
+
+// ExecuteConfig restricts what POST /api/execute may run. Both lists are empty by
+// default, leaving it unrestricted for local development.
+// Educational Comment: /api/execute runs shell commands as the user Forge runs as. Set
+// these before binding Forge to anything other than localhost.
+type ExecuteConfig struct {
+	// AllowedWorkingDirs are the directories commands may run in, subdirectories
+	// included (empty = any directory)
+	AllowedWorkingDirs []string `json:"allowed_working_dirs,omitempty"`
+
+	// AllowedCommands are the programs that may be run, e.g. "git" or "npm"
+	// (empty = any command). With a list set, commands can't chain others with ; && | etc.
+	AllowedCommands []string `json:"allowed_commands,omitempty"`
+}
+
+// OptimizerConfig contains settings for the ledger optimization analyzer.
+type OptimizerConfig struct {
+	// MinLedgerEntries is how many ledger rows must exist before suggestions are made
+	// (0 = DefaultMinLedgerEntries)
+	MinLedgerEntries int `json:"min_ledger_entries,omitempty"`
+}
+
+// DefaultMinLedgerEntries keeps the analyzer quiet until there is enough history to be worth reading.
+const DefaultMinLedgerEntries = 5
+
+// SuggestionThreshold returns the configured minimum ledger rows, or the default when unset.
+func (o OptimizerConfig) SuggestionThreshold() int {
+	if o.MinLedgerEntries <= 0 {
+		return DefaultMinLedgerEntries
+	}
+	return o.MinLedgerEntries
+}
+
+// LoggingConfig controls log output. FORGE_LOG_FORMAT and FORGE_LOG_LEVEL override it.
+type LoggingConfig struct {
+	// Format is "console" (default, human-readable) or "json" (one record per line)
+	Format string `json:"format,omitempty"`
+
+	// Level is the minimum level logged: debug, info (default), warn or error
+	Level string `json:"level,omitempty"`
+}
+
+// BudgetConfig contains spending and quota limits.
+type BudgetConfig struct {
+	// DailyTokenLimit caps input+output tokens across all LLM calls per day (0 = unlimited)
+	DailyTokenLimit int `json:"daily_token_limit"`
+
+	// MaxInputTokens rejects any single prompt estimated above this many tokens (0 = unlimited)
+	MaxInputTokens int `json:"max_input_tokens"`
+
+	// MaxOutputTokens is sent to providers as max_tokens to cap response length (0 = provider default)
+	MaxOutputTokens int `json:"max_output_tokens"`
+
+	// DailyLimitUSD caps spend per day across all LLM calls (0 = $10.00)
+	DailyLimitUSD float64 `json:"daily_limit_usd,omitempty"`
+
+	// MonthlyLimitUSD caps spend per calendar month (0 = the daily limit for every day of the month)
+	MonthlyLimitUSD float64 `json:"monthly_limit_usd,omitempty"`
+
+	// TimeZone is the IANA zone (e.g. "America/New_York") whose midnight resets
+	// daily limits and summaries (empty = UTC)
+	TimeZone string `json:"time_zone,omitempty"`
+
+	// SpendingPaused is the emergency stop: while true, no real provider calls are made
+	SpendingPaused bool `json:"spending_paused"`
+
+	// Pricing overrides the built-in price list, keyed by provider name ("Anthropic", "OpenAI")
+	Pricing map[string]ProviderPricing `json:"pricing,omitempty"`
+}
+
+// ProviderPricing is how one provider bills, replacing its built-in prices.
+// Educational Comment: Some deployments (flat-rate proxies, enterprise plans) charge
+// per request rather than per token. Setting cost_unit to "PROMPT" charges prompt_rate
+// for every call, however large.
+type ProviderPricing struct {
+	// CostUnit is "TOKEN" (default) or "PROMPT"
+	CostUnit string `json:"cost_unit,omitempty"`
+
+	// InputRate and OutputRate are USD per 1M tokens (TOKEN billing)
+	InputRate  float64 `json:"input_rate,omitempty"`
+	OutputRate float64 `json:"output_rate,omitempty"`
+
+	// PromptRate is USD per call (PROMPT billing)
+	PromptRate float64 `json:"prompt_rate,omitempty"`
+
+	// ContextWindow is the model's context window in tokens, for deployments whose model
+	// differs from the built-in one (0 = the built-in window). Calls whose prompt plus
+	// requested response wouldn't fit are refused before they are sent.
+	ContextWindow int `json:"context_window,omitempty"`
+}
+
+// Location returns the configured time zone, falling back to UTC when unset or unknown.
+func (b BudgetConfig) Location() *time.Location {
+	if b.TimeZone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(b.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// Clone returns a deep copy of the config, so the copy can be changed without
+// affecting anyone else holding the original.
+func (c *Config) Clone() *Config {
+	clone := *c
+	if c.Server.PreferredPorts != nil {
+		clone.Server.PreferredPorts = append([]int(nil), c.Server.PreferredPorts...)
+	}
+	if c.Server.ACME.Domains != nil {
+		clone.Server.ACME.Domains = append([]string(nil), c.Server.ACME.Domains...)
+	}
+	if c.Execute.AllowedWorkingDirs != nil {
+		clone.Execute.AllowedWorkingDirs = append([]string(nil), c.Execute.AllowedWorkingDirs...)
+	}
+	if c.Execute.AllowedCommands != nil {
+		clone.Execute.AllowedCommands = append([]string(nil), c.Execute.AllowedCommands...)
+	}
+	if c.Budget.Pricing != nil {
+		clone.Budget.Pricing = make(map[string]ProviderPricing, len(c.Budget.Pricing))
+		for provider, pricing := range c.Budget.Pricing {
+			clone.Budget.Pricing[provider] = pricing
+		}
+	}
+	return &clone
 }
 
 var (
@@ -93,6 +422,7 @@ func DefaultConfig() *Config {
 		Server: ServerConfig{
 			Port:        8080,
 			OpenBrowser: true,
+			BindAddress: DefaultBindAddress,
 		},
 	}
 }
@@ -168,12 +498,13 @@ func GetDataDir() (string, error) {
 }
 
 // Load loads the configuration from disk.
+// Like Get, it returns a copy that the caller is free to modify.
 func Load() (*Config, error) {
 	configMu.Lock()
 	defer configMu.Unlock()
 
 	if currentConfig != nil {
-		return currentConfig, nil
+		return currentConfig.Clone(), nil
 	}
 
 	configDir, err := GetConfigDir()
@@ -188,7 +519,7 @@ func Load() (*Config, error) {
 		if os.IsNotExist(err) {
 			// Return default config if file doesn't exist
 			currentConfig = DefaultConfig()
-			return currentConfig, nil
+			return currentConfig.Clone(), nil
 		}
 		return nil, err
 	}
@@ -199,10 +530,11 @@ func Load() (*Config, error) {
 	}
 
 	currentConfig = &cfg
-	return currentConfig, nil
+	return currentConfig.Clone(), nil
 }
 
 // Save saves the configuration to disk.
+// A copy of cfg is kept, so later changes to cfg don't leak into the shared config.
 func Save(cfg *Config) error {
 	configMu.Lock()
 	defer configMu.Unlock()
@@ -223,16 +555,19 @@ func Save(cfg *Config) error {
 		return err
 	}
 
-	currentConfig = cfg
+	currentConfig = cfg.Clone()
 	return nil
 }
 
 // Get returns the current configuration (loads if not already loaded).
+// Educational Comment: Each caller gets its own copy. Handing out the shared pointer
+// let a caller read fields while Save swapped them underneath it (a data race),
+// and let a caller's edits change the config without ever saving it.
 func Get() (*Config, error) {
 	configMu.RLock()
 	if currentConfig != nil {
 		defer configMu.RUnlock()
-		return currentConfig, nil
+		return currentConfig.Clone(), nil
 	}
 	configMu.RUnlock()
 
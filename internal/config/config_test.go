@@ -4,7 +4,9 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestDefaultConfig(t *testing.T) {
@@ -40,6 +42,55 @@ func TestDefaultConfig(t *testing.T) {
 	if !cfg.Server.OpenBrowser {
 		t.Error("Expected OpenBrowser to be true")
 	}
+
+	if cfg.Server.BindAddress != "127.0.0.1" {
+		t.Errorf("Expected bind address 127.0.0.1, got %s", cfg.Server.BindAddress)
+	}
+	if (ServerConfig{}).ListenAddress() != DefaultBindAddress {
+		t.Error("Expected empty bind address to fall back to the default")
+	}
+
+	// Fallback ports come from the defaults until the user pins their own
+	if len(cfg.Server.FallbackPorts()) != len(DefaultPreferredPorts) {
+		t.Errorf("Expected default fallback ports, got %v", cfg.Server.FallbackPorts())
+	}
+	cfg.Server.PreferredPorts = []int{7000}
+	if ports := cfg.Server.FallbackPorts(); len(ports) != 1 || ports[0] != 7000 {
+		t.Errorf("Expected custom fallback ports, got %v", ports)
+	}
+
+	// Daily limits reset at UTC midnight unless a valid zone is configured
+	if cfg.Budget.Location() != time.UTC {
+		t.Errorf("Expected UTC by default, got %v", cfg.Budget.Location())
+	}
+	if (BudgetConfig{TimeZone: "Not/AZone"}).Location() != time.UTC {
+		t.Error("Expected an unknown zone to fall back to UTC")
+	}
+	if loc := (BudgetConfig{TimeZone: "America/New_York"}).Location(); loc.String() != "America/New_York" {
+		t.Errorf("Expected America/New_York, got %v", loc)
+	}
+
+	// Command runs are capped at the default until the user sets their own limit
+	if cfg.Server.CommandRunLimit() != DefaultMaxConcurrentCommandRuns {
+		t.Errorf("Expected default command run limit, got %d", cfg.Server.CommandRunLimit())
+	}
+	cfg.Server.MaxConcurrentCommandRuns = 10
+	if cfg.Server.CommandRunLimit() != 10 {
+		t.Errorf("Expected command run limit 10, got %d", cfg.Server.CommandRunLimit())
+	}
+
+	// Flows run back-to-back unless a delay is configured
+	if cfg.Flows.InterNodeDelayMs != 0 {
+		t.Errorf("Expected InterNodeDelayMs 0, got %d", cfg.Flows.InterNodeDelayMs)
+	}
+
+	// The optimizer waits for the default amount of history unless told otherwise
+	if cfg.Optimizer.SuggestionThreshold() != DefaultMinLedgerEntries {
+		t.Errorf("Expected default suggestion threshold, got %d", cfg.Optimizer.SuggestionThreshold())
+	}
+	if (OptimizerConfig{MinLedgerEntries: 1}).SuggestionThreshold() != 1 {
+		t.Error("Expected a configured threshold of 1 to be honoured")
+	}
 }
 
 func TestGetConfigDir(t *testing.T) {
@@ -136,6 +187,101 @@ func TestLoadAndSave(t *testing.T) {
 	}
 }
 
+func TestGetReturnsCopy(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	cfg := DefaultConfig()
+	cfg.Server.PreferredPorts = []int{8080, 9000}
+	cfg.Execute.AllowedWorkingDirs = []string{"/srv/repo"}
+	cfg.Execute.AllowedCommands = []string{"git"}
+	if err := Save(cfg); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	t.Cleanup(func() { Save(DefaultConfig()) })
+
+	// Changing the saved struct afterwards must not change the shared config
+	cfg.Budget.SpendingPaused = true
+
+	got, err := Get()
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Budget.SpendingPaused {
+		t.Error("Edits to a saved config leaked into Get")
+	}
+
+	// Nor must changes to a returned copy, including its slices and maps
+	got.Server.Port = 1
+	got.Server.PreferredPorts[0] = 1
+	got.Budget.Pricing = map[string]ProviderPricing{"OpenAI": {CostUnit: "PROMPT"}}
+	again, _ := Get()
+	if again.Server.Port == 1 || again.Server.PreferredPorts[0] == 1 {
+		t.Errorf("Edits to a copy from Get leaked into the shared config: %+v", again.Server)
+	}
+	if again.Budget.Pricing != nil {
+		t.Errorf("Edits to a copy from Get leaked into the shared pricing: %+v", again.Budget.Pricing)
+	}
+
+	// The execute allowlists are security policy, so a copy's edits must stay in the copy
+	again.Execute.AllowedWorkingDirs[0] = "/"
+	again.Execute.AllowedCommands[0] = "rm"
+	if policy, _ := Get(); policy.Execute.AllowedWorkingDirs[0] != "/srv/repo" || policy.Execute.AllowedCommands[0] != "git" {
+		t.Errorf("Edits to a copy from Get leaked into the shared allowlists: %+v", policy.Execute)
+	}
+	again.Budget.Pricing = map[string]ProviderPricing{"OpenAI": {CostUnit: "TOKEN"}}
+	if err := Save(again); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	again.Budget.Pricing["OpenAI"] = ProviderPricing{CostUnit: "PROMPT"}
+	if final, _ := Get(); final.Budget.Pricing["OpenAI"].CostUnit != "TOKEN" {
+		t.Errorf("Edits to a saved map leaked into the shared config: %+v", final.Budget.Pricing)
+	}
+}
+
+// TestConcurrentGetAndSave is meant for go test -race: readers and writers
+// touching the config at once must not race.
+func TestConcurrentGetAndSave(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	if err := Save(DefaultConfig()); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	t.Cleanup(func() { Save(DefaultConfig()) })
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				cfg, err := Get()
+				if err != nil {
+					t.Errorf("Get failed: %v", err)
+					return
+				}
+				_ = cfg.Shell.Type
+				_ = cfg.Server.FallbackPorts()
+				cfg.Budget.SpendingPaused = !cfg.Budget.SpendingPaused
+			}
+		}()
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				cfg, err := Get()
+				if err != nil {
+					t.Errorf("Get failed: %v", err)
+					return
+				}
+				cfg.Server.Port = 9000 + i
+				cfg.Server.PreferredPorts = append(cfg.Server.PreferredPorts, i)
+				if err := Save(cfg); err != nil {
+					t.Errorf("Save failed: %v", err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
 func TestGetDatabasePath(t *testing.T) {
 	path, err := GetDatabasePath()
 	if err != nil {
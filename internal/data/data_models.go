@@ -38,9 +38,45 @@ type TokenLedgerEntry struct {
 	// LatencyMs is how long the API call took in milliseconds.
 	LatencyMs int `json:"latency_ms"`
 
-	// Status indicates the result of the API call: "SUCCESS", "FAILED", or "TIMEOUT".
+	// Status indicates the result of the API call: "SUCCESS", "FAILED", "TIMEOUT",
+	// "CAPPED" when the prompt was refused by the input token cap or the model's context
+	// window without being sent,
+	// "MALFORMED_JSON" when a JSON-mode node's response didn't parse (it is still billed),
+	// "CACHE_HIT" when the response came from the gateway's cache at no cost,
+	// or "SKIPPED" when a flow skipped the node because its provider had no API key.
 	Status string `json:"status"`
 
 	// ErrorMessage contains details if the call failed (empty on success).
 	ErrorMessage string `json:"error_message,omitempty"`
+
+	// Environment tags the run that made this call, e.g. "dev" or "prod".
+	// Empty is stored as DefaultEnvironment.
+	Environment string `json:"environment"`
+
+	// RequestID is shared by every call made by one flow or command run,
+	// so the run's rows can be found together (empty for rows logged before it existed).
+	RequestID string `json:"request_id,omitempty"`
+
+	// IdempotencyKey, when set, is unique across the ledger so a client retrying
+	// the same POST /api/ledger can't log the call twice. Stored as NULL when empty.
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+
+	// ReplayOf is the ID of the entry this call replayed (0 when it isn't a replay).
+	ReplayOf int64 `json:"replay_of,omitempty"`
+
+	// NodeID is the flow node that made this call (empty for calls outside a flow).
+	NodeID string `json:"node_id,omitempty"`
+
+	// Prompt, when set, is stored in ledger_prompts by LogUsage if Ledger.StorePrompts is on,
+	// so the call can be audited and replayed. It is never read back onto the entry.
+	Prompt *LedgerPrompt `json:"-"`
+}
+
+// LedgerPrompt is the prompt behind a ledger entry, kept in ledger_prompts.
+type LedgerPrompt struct {
+	// SystemPrompt is the system prompt sent: the override, or the agent role's persona.
+	SystemPrompt string `json:"system_prompt"`
+
+	// UserPrompt is the prompt text as sent, including any attachments.
+	UserPrompt string `json:"user_prompt"`
 }
@@ -4,7 +4,9 @@ package data
 
 import (
 	"database/sql"
+	"fmt"
 	"os"
+	"strings"
 
 	// Import the pure-Go SQLite driver. This allows cross-compilation without CGO.
 	// The underscore means we only want the driver to register itself.
@@ -15,14 +17,52 @@ import (
 // It's a pointer to a sql.DB, which is Go's standard way to talk to databases.
 var DB *sql.DB
 
+// BusyTimeoutMs is how long a connection waits for another connection's write to finish
+// before giving up with "database is locked".
+const BusyTimeoutMs = 5000
+
+// MaxOpenConns caps the connection pool. SQLite runs one write at a time however many
+// connections there are, so a larger pool only adds connections waiting on the lock,
+// while a few let readers work alongside the writer.
+const MaxOpenConns = 8
+
+// DSN returns the connection string for the database file at dbPath. Every connection
+// opened with it uses WAL journaling, waits up to BusyTimeoutMs for a busy database, and
+// syncs to disk at WAL checkpoints rather than on every commit.
+// Educational Comment: SQLite allows one writer at a time. In WAL mode readers don't block
+// the writer (or the other way round), and the busy timeout makes a second writer wait its
+// turn instead of failing at once, which concurrent flow runs would otherwise hit.
+// synchronous=NORMAL is safe with WAL: a power cut can lose the last commits, never corrupt the file.
+// The pragmas go in the DSN because database/sql pools connections: a PRAGMA run once
+// with db.Exec would only apply to whichever connection happened to run it.
+func DSN(dbPath string) string {
+	sep := "?"
+	if strings.Contains(dbPath, "?") {
+		sep = "&"
+	}
+	return fmt.Sprintf("%s%s_pragma=busy_timeout(%d)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)", dbPath, sep, BusyTimeoutMs)
+}
+
+// Open opens the SQLite database at dbPath with the DSN's settings and a pool sized for
+// SQLite. Like sql.Open, it doesn't connect until the database is first used.
+func Open(dbPath string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", DSN(dbPath))
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(MaxOpenConns)
+	// Keep idle connections, as each new one re-runs the pragmas and starts with a cold page cache
+	db.SetMaxIdleConns(MaxOpenConns)
+	return db, nil
+}
+
 // Connect opens a connection to the SQLite database file.
 // If the file doesn't exist, SQLite will create it automatically.
 // Think of it like opening a notebook - if you don't have one, you get a new blank one.
 func Connect(dbPath string) (*sql.DB, error) {
-	// sql.Open prepares a connection to the database.
-	// "sqlite3" tells Go which type of database we're connecting to.
+	// Open prepares a connection to the database.
 	// dbPath is the file path where our data will be stored.
-	db, err := sql.Open("sqlite", dbPath)
+	db, err := Open(dbPath)
 	if err != nil {
 		return nil, err
 	}
@@ -70,6 +110,12 @@ func InitializeDatabase(dbPath string) (*sql.DB, error) {
 		}
 	}
 
+	// Tables created by an older or interrupted run may be missing columns.
+	// CREATE TABLE IF NOT EXISTS won't add them, so repair them explicitly.
+	if _, err := RepairSchema(db); err != nil {
+		return nil, err
+	}
+
 	// Store the connection globally so other parts of the app can use it.
 	DB = db
 
@@ -3,8 +3,13 @@
 package data
 
 import (
+	"context"
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 // TestDatabaseConnection verifies that we can establish a connection to the SQLite database.
@@ -126,3 +131,113 @@ func TestDatabaseInitializationIsIdempotent(t *testing.T) {
 		}
 	}
 }
+
+// TestConnectUsesWAL verifies the tuning applied to every connection, and that with WAL a
+// reader in the middle of a transaction doesn't hold up a write.
+func TestConnectUsesWAL(t *testing.T) {
+	db, err := InitializeDatabase(filepath.Join(t.TempDir(), "wal.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer db.Close()
+
+	if max := db.Stats().MaxOpenConnections; max != MaxOpenConns {
+		t.Errorf("Expected the pool capped at %d connections, got %d", MaxOpenConns, max)
+	}
+
+	// A reader holds an open transaction on one connection...
+	ctx := context.Background()
+	reader, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("Failed to begin read transaction: %v", err)
+	}
+	defer reader.Rollback()
+	var before int
+	if err := reader.QueryRow(`SELECT COUNT(*) FROM forge_flows`).Scan(&before); err != nil {
+		t.Fatalf("Failed to read: %v", err)
+	}
+
+	// ...and each pooled connection reports the tuned settings
+	var mode string
+	var busyTimeout, synchronous int
+	if err := db.QueryRow(`PRAGMA journal_mode`).Scan(&mode); err != nil || mode != "wal" {
+		t.Errorf("Expected WAL journaling, got %q (%v)", mode, err)
+	}
+	if err := db.QueryRow(`PRAGMA busy_timeout`).Scan(&busyTimeout); err != nil || busyTimeout != BusyTimeoutMs {
+		t.Errorf("Expected a %dms busy timeout, got %d (%v)", BusyTimeoutMs, busyTimeout, err)
+	}
+	if err := db.QueryRow(`PRAGMA synchronous`).Scan(&synchronous); err != nil || synchronous != 1 {
+		t.Errorf("Expected synchronous=NORMAL (1), got %d (%v)", synchronous, err)
+	}
+
+	// A write on another connection goes through at once instead of waiting out the busy timeout
+	start := time.Now()
+	if _, err := db.Exec(`INSERT INTO forge_flows (name, data) VALUES ('written', '{}')`); err != nil {
+		t.Fatalf("Write failed while a read transaction was open: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the write not to wait for the reader, took %v", elapsed)
+	}
+
+	// The reader keeps its snapshot until it finishes
+	var during int
+	if err := reader.QueryRow(`SELECT COUNT(*) FROM forge_flows`).Scan(&during); err != nil || during != before {
+		t.Errorf("Expected the reader to still see %d flows, got %d (%v)", before, during, err)
+	}
+}
+
+// TestLedgerQueriesUseIndexes checks the query plans of the ledger's hot queries on a
+// populated table, so they don't fall back to full scans as the ledger grows.
+func TestLedgerQueriesUseIndexes(t *testing.T) {
+	db, err := InitializeDatabase(filepath.Join(t.TempDir(), "plans.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer db.Close()
+
+	service := NewLedgerService(db)
+	start := time.Now().AddDate(0, 0, -30)
+	for i := 0; i < 500; i++ {
+		status := "SUCCESS"
+		if i%10 == 0 {
+			status = "FAILED"
+		}
+		entry := TokenLedgerEntry{Timestamp: start.Add(time.Duration(i) * time.Hour), FlowID: fmt.Sprintf("%d", i%20), ModelUsed: "gpt-4o", Status: status}
+		if err := service.LogUsage(entry); err != nil {
+			t.Fatalf("Failed to populate ledger: %v", err)
+		}
+	}
+
+	dayStart, dayEnd := DayRange(time.Now(), time.UTC)
+	tests := []struct {
+		name, index, query string
+		args               []interface{}
+	}{
+		{"date range", "idx_ledger_datetime", `SELECT SUM(input_tokens) FROM token_ledger WHERE datetime(timestamp) >= ? AND datetime(timestamp) < ?`, []interface{}{dayStart, dayEnd}},
+		{"newest first", "idx_ledger_timestamp", `SELECT id FROM token_ledger ORDER BY timestamp DESC LIMIT 50`, nil},
+		{"by flow", "idx_ledger_flow_id", `SELECT id FROM token_ledger WHERE flow_id = ?`, []interface{}{"3"}},
+		{"by status", "idx_ledger_status", `SELECT flow_id, COUNT(*) FROM token_ledger WHERE status = 'FAILED' GROUP BY flow_id`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := db.Query("EXPLAIN QUERY PLAN "+tt.query, tt.args...)
+			if err != nil {
+				t.Fatalf("Failed to explain query: %v", err)
+			}
+			defer rows.Close()
+
+			var plan []string
+			for rows.Next() {
+				var id, parent, unused int
+				var detail string
+				if err := rows.Scan(&id, &parent, &unused, &detail); err != nil {
+					t.Fatalf("Failed to read plan: %v", err)
+				}
+				plan = append(plan, detail)
+			}
+			if joined := strings.Join(plan, "; "); !strings.Contains(joined, tt.index) {
+				t.Errorf("Expected the plan to use %s, got %q", tt.index, joined)
+			}
+		})
+	}
+}
@@ -4,9 +4,35 @@ package data
 
 import (
 	"database/sql"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/config"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/metrics"
 )
 
+// SQLiteTimeFormat is the timestamp layout SQLite's date and time functions understand.
+const SQLiteTimeFormat = "2006-01-02 15:04:05.000000000"
+
+// DefaultEnvironment is the environment recorded for ledger entries that don't name one.
+const DefaultEnvironment = "prod"
+
+// NormalizeEnvironment lower-cases and trims an environment tag, using
+// DefaultEnvironment when it is empty, so "Dev" and "dev " count as the same environment.
+func NormalizeEnvironment(env string) string {
+	env = strings.ToLower(strings.TrimSpace(env))
+	if env == "" {
+		return DefaultEnvironment
+	}
+	return env
+}
+
+// ledgerWrites serializes ledger writes across every LedgerService, which callers
+// create per request. SQLite has a single writer anyway; queuing here rather than in
+// SQLite keeps a burst of concurrent runs from eating into each other's busy timeout.
+var ledgerWrites sync.Mutex
+
 // LedgerService handles all operations related to the token ledger.
 // It provides methods to log and retrieve API usage records.
 // Think of it as a librarian that manages the "receipt book" for all AI calls.
@@ -34,6 +60,12 @@ func NewLedgerService(db *sql.DB) *LedgerService {
 //
 // Returns an error if the insert fails, nil on success.
 func (s *LedgerService) LogUsage(entry TokenLedgerEntry) error {
+	_, err := s.Insert(entry)
+	return err
+}
+
+// Insert is LogUsage that also returns the new entry's ID.
+func (s *LedgerService) Insert(entry TokenLedgerEntry) (int64, error) {
 	// SQL query to insert a new record into the token_ledger table.
 	// We use a parameterized query (with ?) to prevent SQL injection attacks.
 	// SQL injection is when bad actors try to sneak malicious commands into queries.
@@ -49,8 +81,13 @@ func (s *LedgerService) LogUsage(entry TokenLedgerEntry) error {
 			total_cost_usd,
 			latency_ms,
 			status,
-			error_message
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			error_message,
+			environment,
+			request_id,
+			idempotency_key,
+			replay_of,
+			node_id
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	// If no timestamp is provided, use the current time.
@@ -60,11 +97,17 @@ func (s *LedgerService) LogUsage(entry TokenLedgerEntry) error {
 		timestamp = time.Now()
 	}
 
+	// Store timestamps as UTC text in SQLite's own format. Passing a time.Time straight
+	// through makes the driver write Go's String() form, which SQLite's date()
+	// functions can't parse, so "today" queries would silently miss every row.
+	storedTimestamp := timestamp.UTC().Format(SQLiteTimeFormat)
+
 	// Execute the insert query with all the values from the entry.
 	// The order of values must match the order of columns in the query.
+	ledgerWrites.Lock()
 	result, err := s.db.Exec(
 		query,
-		timestamp,
+		storedTimestamp,
 		entry.FlowID,
 		entry.ModelUsed,
 		entry.AgentRole,
@@ -75,16 +118,74 @@ func (s *LedgerService) LogUsage(entry TokenLedgerEntry) error {
 		entry.LatencyMs,
 		entry.Status,
 		entry.ErrorMessage,
+		NormalizeEnvironment(entry.Environment),
+		entry.RequestID,
+		sql.NullString{String: entry.IdempotencyKey, Valid: entry.IdempotencyKey != ""},
+		sql.NullInt64{Int64: entry.ReplayOf, Valid: entry.ReplayOf != 0},
+		sql.NullString{String: entry.NodeID, Valid: entry.NodeID != ""},
 	)
+	ledgerWrites.Unlock()
 
 	if err != nil {
-		return err
+		return 0, err
 	}
+	metrics.LedgerEntries.Inc(entry.Status)
 
-	// Optionally, we can get the ID of the newly inserted row.
-	// This is useful for confirmation but we don't need it for logging.
-	_, err = result.LastInsertId()
-	return err
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	// Keep the prompt alongside the entry when the caller supplied it and prompts are stored
+	if entry.Prompt != nil {
+		if err := s.SavePrompt(id, *entry.Prompt); err != nil {
+			return id, err
+		}
+	}
+	return id, nil
+}
+
+// DayRange returns the start (inclusive) and end (exclusive) of the calendar day
+// containing now in loc, as UTC strings comparable with datetime(timestamp).
+// Educational Comment: Timestamps are stored in UTC, so "today" for a user in
+// New York is a 24-hour window that starts at 04:00 or 05:00 UTC, not at midnight.
+func DayRange(now time.Time, loc *time.Location) (string, string) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	local := now.In(loc)
+	start := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	return formatRange(start, start.AddDate(0, 0, 1))
+}
+
+// MonthRange is DayRange for the calendar month containing now in loc.
+func MonthRange(now time.Time, loc *time.Location) (string, string) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	local := now.In(loc)
+	start := time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, loc)
+	return formatRange(start, start.AddDate(0, 1, 0))
+}
+
+// formatRange renders local bounds as the UTC strings used by DayRange and MonthRange.
+func formatRange(start, end time.Time) (string, string) {
+	const layout = "2006-01-02 15:04:05"
+	return start.UTC().Format(layout), end.UTC().Format(layout)
+}
+
+// TokensUsedToday sums input and output tokens for every ledger entry recorded today
+// in loc (nil means UTC). Failed calls are included because providers may still bill for them.
+func (s *LedgerService) TokensUsedToday(loc *time.Location) (int, error) {
+	start, end := DayRange(time.Now(), loc)
+	query := `
+		SELECT COALESCE(SUM(input_tokens + output_tokens), 0)
+		FROM token_ledger
+		WHERE datetime(timestamp) >= ? AND datetime(timestamp) < ?
+	`
+	var total int
+	err := s.db.QueryRow(query, start, end).Scan(&total)
+	return total, err
 }
 
 // GetEntry retrieves a specific token ledger entry by its ID.
@@ -104,7 +205,11 @@ func (s *LedgerService) GetEntry(id int64) (*TokenLedgerEntry, error) {
 			total_cost_usd,
 			latency_ms,
 			status,
-			error_message
+			error_message,
+			environment,
+			COALESCE(request_id, ''),
+			COALESCE(idempotency_key, ''),
+			COALESCE(replay_of, 0)
 		FROM token_ledger
 		WHERE id = ?
 	`
@@ -126,6 +231,10 @@ func (s *LedgerService) GetEntry(id int64) (*TokenLedgerEntry, error) {
 		&entry.LatencyMs,
 		&entry.Status,
 		&entry.ErrorMessage,
+		&entry.Environment,
+		&entry.RequestID,
+		&entry.IdempotencyKey,
+		&entry.ReplayOf,
 	)
 
 	if err != nil {
@@ -135,6 +244,57 @@ func (s *LedgerService) GetEntry(id int64) (*TokenLedgerEntry, error) {
 	return &entry, nil
 }
 
+// GetEntryByIdempotencyKey retrieves the entry logged with the given idempotency key.
+// It returns sql.ErrNoRows when no entry has that key.
+func (s *LedgerService) GetEntryByIdempotencyKey(key string) (*TokenLedgerEntry, error) {
+	query := `
+		SELECT 
+			id,
+			timestamp,
+			flow_id,
+			model_used,
+			agent_role,
+			prompt_hash,
+			input_tokens,
+			output_tokens,
+			total_cost_usd,
+			latency_ms,
+			status,
+			error_message,
+			environment,
+			COALESCE(request_id, ''),
+			COALESCE(idempotency_key, ''),
+			COALESCE(replay_of, 0)
+		FROM token_ledger
+		WHERE idempotency_key = ?
+	`
+
+	var entry TokenLedgerEntry
+	err := s.db.QueryRow(query, key).Scan(
+		&entry.ID,
+		&entry.Timestamp,
+		&entry.FlowID,
+		&entry.ModelUsed,
+		&entry.AgentRole,
+		&entry.PromptHash,
+		&entry.InputTokens,
+		&entry.OutputTokens,
+		&entry.TotalCostUSD,
+		&entry.LatencyMs,
+		&entry.Status,
+		&entry.ErrorMessage,
+		&entry.Environment,
+		&entry.RequestID,
+		&entry.IdempotencyKey,
+		&entry.ReplayOf,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
 // GetEntriesByFlowID retrieves all ledger entries for a specific flow.
 // This is useful for analyzing the costs of a particular workflow.
 func (s *LedgerService) GetEntriesByFlowID(flowID string) ([]TokenLedgerEntry, error) {
@@ -151,7 +311,11 @@ func (s *LedgerService) GetEntriesByFlowID(flowID string) ([]TokenLedgerEntry, e
 			total_cost_usd,
 			latency_ms,
 			status,
-			error_message
+			error_message,
+			environment,
+			COALESCE(request_id, ''),
+			COALESCE(idempotency_key, ''),
+			COALESCE(replay_of, 0)
 		FROM token_ledger
 		WHERE flow_id = ?
 		ORDER BY timestamp DESC
@@ -179,6 +343,10 @@ func (s *LedgerService) GetEntriesByFlowID(flowID string) ([]TokenLedgerEntry, e
 			&entry.LatencyMs,
 			&entry.Status,
 			&entry.ErrorMessage,
+			&entry.Environment,
+			&entry.RequestID,
+			&entry.IdempotencyKey,
+			&entry.ReplayOf,
 		)
 		if err != nil {
 			return nil, err
@@ -205,7 +373,11 @@ func (s *LedgerService) GetLastInsertedEntry() (*TokenLedgerEntry, error) {
 			total_cost_usd,
 			latency_ms,
 			status,
-			error_message
+			error_message,
+			environment,
+			COALESCE(request_id, ''),
+			COALESCE(idempotency_key, ''),
+			COALESCE(replay_of, 0)
 		FROM token_ledger
 		ORDER BY id DESC
 		LIMIT 1
@@ -225,6 +397,10 @@ func (s *LedgerService) GetLastInsertedEntry() (*TokenLedgerEntry, error) {
 		&entry.LatencyMs,
 		&entry.Status,
 		&entry.ErrorMessage,
+		&entry.Environment,
+		&entry.RequestID,
+		&entry.IdempotencyKey,
+		&entry.ReplayOf,
 	)
 
 	if err != nil {
@@ -233,3 +409,39 @@ func (s *LedgerService) GetLastInsertedEntry() (*TokenLedgerEntry, error) {
 
 	return &entry, nil
 }
+
+// SavePrompt stores the prompt behind a ledger entry, replacing any already stored for it.
+// It stores nothing unless Ledger.StorePrompts is on, so by default prompt text never
+// reaches the database.
+func (s *LedgerService) SavePrompt(ledgerID int64, prompt LedgerPrompt) error {
+	if !StorePromptsEnabled() {
+		return nil
+	}
+	ledgerWrites.Lock()
+	defer ledgerWrites.Unlock()
+	_, err := s.db.Exec(`
+		INSERT OR REPLACE INTO ledger_prompts (ledger_id, system_prompt, user_prompt, created_at)
+		VALUES (?, ?, ?, ?)
+	`, ledgerID, prompt.SystemPrompt, prompt.UserPrompt, time.Now().UTC().Format(SQLiteTimeFormat))
+	return err
+}
+
+// GetPrompt retrieves the prompt stored for a ledger entry.
+// It returns sql.ErrNoRows when the entry's prompt wasn't kept: Ledger.StorePrompts was
+// off when it was logged, or it was posted to /api/ledger.
+func (s *LedgerService) GetPrompt(ledgerID int64) (*LedgerPrompt, error) {
+	var prompt LedgerPrompt
+	err := s.db.QueryRow(`SELECT system_prompt, user_prompt FROM ledger_prompts WHERE ledger_id = ?`, ledgerID).
+		Scan(&prompt.SystemPrompt, &prompt.UserPrompt)
+	if err != nil {
+		return nil, err
+	}
+	return &prompt, nil
+}
+
+// StorePromptsEnabled reports whether Ledger.StorePrompts is on. An unreadable config
+// counts as off, so prompts are only ever kept by choice.
+func StorePromptsEnabled() bool {
+	cfg, err := config.Get()
+	return err == nil && cfg.Ledger.StorePrompts
+}
@@ -3,9 +3,16 @@
 package data
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/config"
 )
 
 // TestLogUsageInsertsAndRetrievesEntry verifies that LogUsage inserts a complete
@@ -293,3 +300,281 @@ func TestLogUsageAutoTimestamp(t *testing.T) {
 		t.Errorf("Timestamp %v not in expected range [%v, %v]", retrieved.Timestamp, beforeInsert, afterInsert)
 	}
 }
+
+// TestLogUsageEnvironment verifies environment tags are normalized and default to prod.
+func TestLogUsageEnvironment(t *testing.T) {
+	tempDB := "test_ledger_environment.db"
+	defer os.Remove(tempDB)
+
+	db, err := InitializeDatabase(tempDB)
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer db.Close()
+
+	service := NewLedgerService(db)
+	for _, tt := range []struct{ env, want string }{
+		{"", DefaultEnvironment},
+		{" Dev ", "dev"},
+		{"staging", "staging"},
+	} {
+		entry := TokenLedgerEntry{FlowID: "env-flow", ModelUsed: "m", AgentRole: "r", PromptHash: "h", Status: "SUCCESS", Environment: tt.env}
+		if err := service.LogUsage(entry); err != nil {
+			t.Fatalf("LogUsage failed: %v", err)
+		}
+		retrieved, err := service.GetLastInsertedEntry()
+		if err != nil {
+			t.Fatalf("Failed to retrieve entry: %v", err)
+		}
+		if retrieved.Environment != tt.want {
+			t.Errorf("Environment %q: expected %q stored, got %q", tt.env, tt.want, retrieved.Environment)
+		}
+	}
+}
+
+// TestLogUsagePromptsOptIn verifies prompt text is only kept when Ledger.StorePrompts is on.
+func TestLogUsagePromptsOptIn(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	defer config.Save(config.DefaultConfig())
+
+	tempDB := "test_ledger_prompts.db"
+	defer os.Remove(tempDB)
+
+	db, err := InitializeDatabase(tempDB)
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer db.Close()
+
+	service := NewLedgerService(db)
+	logWithPrompt := func() int64 {
+		t.Helper()
+		id, err := service.Insert(TokenLedgerEntry{
+			FlowID: "prompt-flow", ModelUsed: "m", AgentRole: "r", PromptHash: "h", Status: "SUCCESS",
+			Prompt: &LedgerPrompt{SystemPrompt: "You are terse.", UserPrompt: "secret source code"},
+		})
+		if err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+		return id
+	}
+
+	// Off by default: only the hash is kept
+	if err := config.Save(config.DefaultConfig()); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	id := logWithPrompt()
+	if _, err := service.GetPrompt(id); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected no stored prompt with store_prompts off, got %v", err)
+	}
+	var stored int
+	db.QueryRow(`SELECT COUNT(*) FROM ledger_prompts`).Scan(&stored)
+	if stored != 0 {
+		t.Errorf("Expected ledger_prompts to stay empty, found %d rows", stored)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.Ledger.StorePrompts = true
+	if err := config.Save(cfg); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	id = logWithPrompt()
+	prompt, err := service.GetPrompt(id)
+	if err != nil {
+		t.Fatalf("Expected the prompt to be stored with store_prompts on: %v", err)
+	}
+	if prompt.SystemPrompt != "You are terse." || prompt.UserPrompt != "secret source code" {
+		t.Errorf("Expected the system and user prompt back, got %+v", prompt)
+	}
+}
+
+// TestTokensUsedToday verifies that only today's entries are summed.
+func TestTokensUsedToday(t *testing.T) {
+	tempDB := "test_tokens_today.db"
+	defer os.Remove(tempDB)
+
+	db, err := InitializeDatabase(tempDB)
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer db.Close()
+
+	service := NewLedgerService(db)
+	entries := []TokenLedgerEntry{
+		{Timestamp: time.Now(), FlowID: "a", ModelUsed: "m", AgentRole: "r", PromptHash: "h", InputTokens: 100, OutputTokens: 50, Status: "SUCCESS"},
+		{Timestamp: time.Now(), FlowID: "b", ModelUsed: "m", AgentRole: "r", PromptHash: "h", InputTokens: 10, OutputTokens: 5, Status: "FAILED"},
+		{Timestamp: time.Now().AddDate(0, 0, -2), FlowID: "old", ModelUsed: "m", AgentRole: "r", PromptHash: "h", InputTokens: 9999, OutputTokens: 9999, Status: "SUCCESS"},
+	}
+	for _, e := range entries {
+		if err := service.LogUsage(e); err != nil {
+			t.Fatalf("LogUsage failed: %v", err)
+		}
+	}
+
+	used, err := service.TokensUsedToday(time.UTC)
+	if err != nil {
+		t.Fatalf("TokensUsedToday failed: %v", err)
+	}
+	if used != 165 {
+		t.Errorf("Expected 165 tokens used today, got %d", used)
+	}
+}
+
+// TestDayRange verifies the day boundary moves with the time zone.
+func TestDayRange(t *testing.T) {
+	// 02:00 UTC on March 10th is still March 9th in New York and already the afternoon in Kiritimati
+	now := time.Date(2025, 3, 10, 2, 0, 0, 0, time.UTC)
+
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("Failed to load zone: %v", err)
+	}
+	kiritimati, err := time.LoadLocation("Pacific/Kiritimati")
+	if err != nil {
+		t.Fatalf("Failed to load zone: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		loc        *time.Location
+		start, end string
+	}{
+		{"utc", time.UTC, "2025-03-10 00:00:00", "2025-03-11 00:00:00"},
+		{"nil means utc", nil, "2025-03-10 00:00:00", "2025-03-11 00:00:00"},
+		// March 9th 2025 is the US switch to daylight saving, so that day is only 23 hours long
+		{"new york", newYork, "2025-03-09 05:00:00", "2025-03-10 04:00:00"},
+		{"kiritimati", kiritimati, "2025-03-09 10:00:00", "2025-03-10 10:00:00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := DayRange(now, tt.loc)
+			if start != tt.start || end != tt.end {
+				t.Errorf("Expected [%s, %s), got [%s, %s)", tt.start, tt.end, start, end)
+			}
+		})
+	}
+}
+
+func TestMonthRange(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("Failed to load zone: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		now        time.Time
+		loc        *time.Location
+		start, end string
+	}{
+		{"utc", time.Date(2025, 3, 10, 2, 0, 0, 0, time.UTC), time.UTC, "2025-03-01 00:00:00", "2025-04-01 00:00:00"},
+		{"nil means utc", time.Date(2025, 3, 10, 2, 0, 0, 0, time.UTC), nil, "2025-03-01 00:00:00", "2025-04-01 00:00:00"},
+		// 03:00 UTC on March 1st is still February 28th in New York
+		{"new york previous month", time.Date(2025, 3, 1, 3, 0, 0, 0, time.UTC), newYork, "2025-02-01 05:00:00", "2025-03-01 05:00:00"},
+		{"year rollover", time.Date(2024, 12, 31, 23, 0, 0, 0, time.UTC), time.UTC, "2024-12-01 00:00:00", "2025-01-01 00:00:00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := MonthRange(tt.now, tt.loc)
+			if start != tt.start || end != tt.end {
+				t.Errorf("Expected [%s, %s), got [%s, %s)", tt.start, tt.end, start, end)
+			}
+		})
+	}
+}
+
+// TestTokensUsedToday_TimeZone verifies an entry just after local midnight counts
+// as today while one just before it does not.
+func TestTokensUsedToday_TimeZone(t *testing.T) {
+	tempDB := "test_tokens_today_tz.db"
+	defer os.Remove(tempDB)
+
+	db, err := InitializeDatabase(tempDB)
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer db.Close()
+
+	loc, err := time.LoadLocation("Pacific/Kiritimati")
+	if err != nil {
+		t.Fatalf("Failed to load zone: %v", err)
+	}
+	local := time.Now().In(loc)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+
+	service := NewLedgerService(db)
+	for _, e := range []TokenLedgerEntry{
+		{Timestamp: midnight.Add(time.Minute), FlowID: "today", ModelUsed: "m", AgentRole: "r", PromptHash: "h", InputTokens: 7, OutputTokens: 3, Status: "SUCCESS"},
+		{Timestamp: midnight.Add(-time.Minute), FlowID: "yesterday", ModelUsed: "m", AgentRole: "r", PromptHash: "h", InputTokens: 500, OutputTokens: 500, Status: "SUCCESS"},
+	} {
+		if err := service.LogUsage(e); err != nil {
+			t.Fatalf("LogUsage failed: %v", err)
+		}
+	}
+
+	used, err := service.TokensUsedToday(loc)
+	if err != nil {
+		t.Fatalf("TokensUsedToday failed: %v", err)
+	}
+	if used != 10 {
+		t.Errorf("Expected only the entry after local midnight (10 tokens), got %d", used)
+	}
+}
+
+// TestLogUsageConcurrentWrites logs from many goroutines at once, alongside readers and
+// a writer that bypasses the ledger service, and checks no entry is lost to "database is locked".
+func TestLogUsageConcurrentWrites(t *testing.T) {
+	db, err := InitializeDatabase(filepath.Join(t.TempDir(), "ledger.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer db.Close()
+
+	var mode string
+	if err := db.QueryRow(`PRAGMA journal_mode`).Scan(&mode); err != nil || mode != "wal" {
+		t.Fatalf("Expected WAL journaling, got %q (%v)", mode, err)
+	}
+
+	const workers, perWorker = 20, 25
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*perWorker+workers)
+	for w := 0; w < workers; w++ {
+		wg.Add(2)
+		go func(w int) {
+			defer wg.Done()
+			// A fresh service per call, the way the server and the flow engine use it
+			for i := 0; i < perWorker; i++ {
+				entry := TokenLedgerEntry{FlowID: fmt.Sprintf("flow-%d", w), ModelUsed: "gpt-4o", InputTokens: 1, Status: "SUCCESS"}
+				if err := NewLedgerService(db).LogUsage(entry); err != nil {
+					errs <- err
+				}
+			}
+		}(w)
+		go func(w int) {
+			defer wg.Done()
+			// Reads and other writers share the database; the busy timeout makes them wait their turn
+			var n int
+			if err := db.QueryRow(`SELECT COUNT(*) FROM token_ledger`).Scan(&n); err != nil {
+				errs <- err
+			}
+			if _, err := db.Exec(`INSERT INTO forge_flows (name, data) VALUES (?, '{}')`, fmt.Sprintf("flow-%d", w)); err != nil {
+				errs <- err
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("Concurrent write failed: %v", err)
+	}
+
+	var count, tokens int
+	if err := db.QueryRow(`SELECT COUNT(*), SUM(input_tokens) FROM token_ledger`).Scan(&count, &tokens); err != nil {
+		t.Fatalf("Failed to count entries: %v", err)
+	}
+	if count != workers*perWorker || tokens != workers*perWorker {
+		t.Errorf("Expected %d entries, got %d (%d tokens)", workers*perWorker, count, tokens)
+	}
+}
@@ -0,0 +1,193 @@
+// Package data provides database initialization and management for the Forge Orchestrator.
+// This file verifies that every expected column exists and repairs tables that are missing some.
+package data
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// columnSpec describes a column we expect to find in a table.
+// Definition is what we pass to ALTER TABLE ... ADD COLUMN when the column is missing.
+// SQLite only allows constant defaults there and refuses NOT NULL without a default,
+// so these definitions are sometimes looser than the CREATE TABLE version in SQLiteSchema.
+type columnSpec struct {
+	Name       string
+	Definition string
+}
+
+// expectedColumns lists the columns each table must have.
+// Primary keys are omitted because SQLite cannot add them after the fact; a table
+// missing its primary key is recreated by SQLiteSchema only when it doesn't exist at all.
+// When you add a column to SQLiteSchema, add it here too so older databases get upgraded.
+var expectedColumns = []struct {
+	Table   string
+	Columns []columnSpec
+}{
+	{"token_ledger", []columnSpec{
+		{"timestamp", "DATETIME"},
+		{"flow_id", "TEXT NOT NULL DEFAULT ''"},
+		{"model_used", "TEXT NOT NULL DEFAULT ''"},
+		{"agent_role", "TEXT NOT NULL DEFAULT ''"},
+		{"prompt_hash", "TEXT NOT NULL DEFAULT ''"},
+		{"input_tokens", "INTEGER NOT NULL DEFAULT 0"},
+		{"output_tokens", "INTEGER NOT NULL DEFAULT 0"},
+		{"total_cost_usd", "REAL NOT NULL DEFAULT 0"},
+		{"latency_ms", "INTEGER NOT NULL DEFAULT 0"},
+		{"status", "TEXT NOT NULL DEFAULT 'SUCCESS'"},
+		{"error_message", "TEXT"},
+		{"environment", "TEXT NOT NULL DEFAULT 'prod'"},
+		{"request_id", "TEXT"},
+		{"idempotency_key", "TEXT"}, // unique through repairIndexes
+		{"replay_of", "INTEGER"},
+		{"node_id", "TEXT"},
+	}},
+	{"forge_flows", []columnSpec{
+		{"name", "TEXT NOT NULL DEFAULT ''"},
+		{"description", "TEXT"},
+		{"data", "TEXT NOT NULL DEFAULT '{}'"},
+		{"status", "TEXT DEFAULT 'draft'"},
+		{"created_at", "DATETIME"},
+		{"updated_at", "DATETIME"},
+		{"deleted_at", "DATETIME"},
+		{"max_cost_usd", "REAL"},
+		{"revision", "INTEGER NOT NULL DEFAULT 1"},
+	}},
+	{"user_secrets", []columnSpec{
+		{"encrypted_value", "BLOB NOT NULL DEFAULT x''"},
+		{"created_at", "DATETIME"},
+	}},
+	{"command_cards", []columnSpec{
+		{"name", "TEXT NOT NULL DEFAULT ''"},
+		{"command", "TEXT NOT NULL DEFAULT ''"},
+		{"description", "TEXT"},
+		{"max_cost_usd", "REAL"},
+	}},
+	{"optimization_suggestions", []columnSpec{
+		{"type", "TEXT NOT NULL DEFAULT ''"},
+		{"title", "TEXT NOT NULL DEFAULT ''"},
+		{"description", "TEXT NOT NULL DEFAULT ''"},
+		{"estimated_savings", "REAL NOT NULL DEFAULT 0"},
+		{"savings_unit", "TEXT NOT NULL DEFAULT 'USD'"},
+		{"target_flow_id", "TEXT"},
+		{"target_command_id", "INTEGER"},
+		{"apply_action", "TEXT NOT NULL DEFAULT '{}'"},
+		{"status", "TEXT DEFAULT 'pending'"},
+		{"applied_at", "DATETIME"},
+		{"created_at", "DATETIME"},
+	}},
+	{"flow_status", []columnSpec{
+		{"status", "TEXT NOT NULL DEFAULT 'UNKNOWN'"},
+		{"last_node", "TEXT"},
+		{"error", "TEXT"},
+		{"updated_at", "DATETIME"},
+	}},
+	{"custom_agents", []columnSpec{
+		{"name", "TEXT NOT NULL DEFAULT ''"},
+		{"system_prompt", "TEXT NOT NULL DEFAULT ''"},
+		{"created_at", "DATETIME"},
+		{"updated_at", "DATETIME"},
+	}},
+	{"flow_node_results", []columnSpec{
+		{"status", "TEXT NOT NULL DEFAULT 'COMPLETED'"},
+		{"output", "TEXT"},
+		{"cost_usd", "REAL NOT NULL DEFAULT 0"},
+		{"completed_at", "DATETIME"},
+	}},
+	{"flow_events", []columnSpec{
+		{"flow_id", "INTEGER NOT NULL DEFAULT 0"},
+		{"run_id", "TEXT NOT NULL DEFAULT ''"},
+		{"event_type", "TEXT NOT NULL DEFAULT ''"},
+		{"node_id", "TEXT"},
+		{"message", "TEXT"},
+		{"duration_ms", "INTEGER NOT NULL DEFAULT 0"},
+		{"created_at", "DATETIME"},
+	}},
+	{"ledger_prompts", []columnSpec{
+		{"system_prompt", "TEXT NOT NULL DEFAULT ''"},
+		{"user_prompt", "TEXT NOT NULL DEFAULT ''"},
+		{"created_at", "DATETIME"},
+	}},
+	{"flow_versions", []columnSpec{
+		{"flow_id", "INTEGER NOT NULL DEFAULT 0"},
+		{"version", "INTEGER NOT NULL DEFAULT 0"},
+		{"name", "TEXT NOT NULL DEFAULT ''"},
+		{"data", "TEXT NOT NULL DEFAULT '{}'"},
+		{"created_at", "DATETIME"},
+	}},
+	{"key_audit", []columnSpec{
+		{"provider", "TEXT NOT NULL DEFAULT ''"},
+		{"action", "TEXT NOT NULL DEFAULT ''"},
+		{"created_at", "DATETIME"},
+	}},
+}
+
+// repairIndexes are created after their column is added, for constraints such as UNIQUE
+// that ALTER TABLE ... ADD COLUMN can't carry. Keyed by "table.column".
+var repairIndexes = map[string]string{
+	"token_ledger.idempotency_key": "CREATE UNIQUE INDEX IF NOT EXISTS idx_ledger_idempotency_key ON token_ledger(idempotency_key)",
+}
+
+// tableColumns returns the set of column names currently present in a table.
+func tableColumns(db *sql.DB, table string) (map[string]bool, error) {
+	// PRAGMA arguments can't be bound as parameters, but table names come from
+	// expectedColumns above, never from user input.
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return nil, err
+		}
+		columns[name] = true
+	}
+	return columns, rows.Err()
+}
+
+// RepairSchema checks every table for missing columns and adds them.
+// It should run after SQLiteSchema so that missing tables are created first.
+// Returns a description of each repair made (empty when the schema was already healthy).
+func RepairSchema(db *sql.DB) ([]string, error) {
+	repairs := []string{}
+
+	for _, table := range expectedColumns {
+		existing, err := tableColumns(db, table.Table)
+		if err != nil {
+			return repairs, fmt.Errorf("failed to inspect table %s: %w", table.Table, err)
+		}
+		if len(existing) == 0 {
+			// Table is absent entirely; SQLiteSchema is responsible for creating it.
+			continue
+		}
+
+		for _, col := range table.Columns {
+			if existing[col.Name] {
+				continue
+			}
+
+			stmt := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table.Table, col.Name, col.Definition)
+			if _, err := db.Exec(stmt); err != nil {
+				return repairs, fmt.Errorf("failed to add column %s.%s: %w", table.Table, col.Name, err)
+			}
+			if index, ok := repairIndexes[table.Table+"."+col.Name]; ok {
+				if _, err := db.Exec(index); err != nil {
+					return repairs, fmt.Errorf("failed to index column %s.%s: %w", table.Table, col.Name, err)
+				}
+			}
+
+			repair := fmt.Sprintf("added missing column %s.%s", table.Table, col.Name)
+			log.Printf("Schema repair: %s", repair)
+			repairs = append(repairs, repair)
+		}
+	}
+
+	return repairs, nil
+}
@@ -0,0 +1,162 @@
+// Package data provides database initialization and management for the Forge Orchestrator.
+// This test file verifies that missing columns are detected and repaired on startup.
+package data
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+)
+
+// TestRepairSchemaAddsMissingColumn simulates an interrupted first run where
+// token_ledger was created without some of its columns.
+func TestRepairSchemaAddsMissingColumn(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	// An old token_ledger that predates error_message, latency_ms, environment, request_id and idempotency_key.
+	_, err = db.Exec(`
+		CREATE TABLE token_ledger (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+			flow_id TEXT NOT NULL,
+			model_used TEXT NOT NULL,
+			agent_role TEXT NOT NULL,
+			prompt_hash TEXT NOT NULL,
+			input_tokens INTEGER NOT NULL,
+			output_tokens INTEGER NOT NULL,
+			total_cost_usd REAL NOT NULL,
+			status TEXT NOT NULL
+		);
+		INSERT INTO token_ledger (flow_id, model_used, agent_role, prompt_hash, input_tokens, output_tokens, total_cost_usd, status)
+		VALUES ('flow-1', 'gpt-4o', 'Coder', 'h', 1, 2, 0.1, 'SUCCESS');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create legacy table: %v", err)
+	}
+
+	// The regular schema runs first and leaves the existing table untouched.
+	if _, err := db.Exec(SQLiteSchema); err != nil {
+		t.Fatalf("Failed to apply schema: %v", err)
+	}
+
+	repairs, err := RepairSchema(db)
+	if err != nil {
+		t.Fatalf("RepairSchema failed: %v", err)
+	}
+	if len(repairs) != 7 {
+		t.Errorf("Expected 7 repairs, got %d: %v", len(repairs), repairs)
+	}
+
+	columns, err := tableColumns(db, "token_ledger")
+	if err != nil {
+		t.Fatalf("Failed to read columns: %v", err)
+	}
+	for _, col := range []string{"error_message", "latency_ms", "environment", "request_id", "idempotency_key", "replay_of", "node_id"} {
+		if !columns[col] {
+			t.Errorf("Expected column %s to be added", col)
+		}
+	}
+
+	// Existing rows should pick up the default for the new NOT NULL column.
+	var latency int
+	if err := db.QueryRow("SELECT latency_ms FROM token_ledger WHERE flow_id = 'flow-1'").Scan(&latency); err != nil {
+		t.Fatalf("Failed to read repaired column: %v", err)
+	}
+	if latency != 0 {
+		t.Errorf("Expected default latency 0, got %d", latency)
+	}
+	var env string
+	if err := db.QueryRow("SELECT environment FROM token_ledger WHERE flow_id = 'flow-1'").Scan(&env); err != nil {
+		t.Fatalf("Failed to read repaired column: %v", err)
+	}
+	if env != DefaultEnvironment {
+		t.Errorf("Expected existing rows to default to %q, got %q", DefaultEnvironment, env)
+	}
+
+	// The added idempotency_key is unique like the fresh schema's, though empty keys stay NULL.
+	insertKeyed := `INSERT INTO token_ledger (flow_id, model_used, agent_role, prompt_hash, input_tokens, output_tokens, total_cost_usd, status, idempotency_key)
+		VALUES ('flow-1', 'gpt-4o', 'Coder', 'h', 1, 2, 0.1, 'SUCCESS', ?)`
+	if _, err := db.Exec(insertKeyed, "key-1"); err != nil {
+		t.Fatalf("Failed to insert keyed row: %v", err)
+	}
+	if _, err := db.Exec(insertKeyed, "key-1"); err == nil {
+		t.Error("Expected a repeated idempotency key to be rejected")
+	}
+
+	// A second pass should find nothing left to repair.
+	repairs, err = RepairSchema(db)
+	if err != nil {
+		t.Fatalf("Second RepairSchema failed: %v", err)
+	}
+	if len(repairs) != 0 {
+		t.Errorf("Expected no repairs on healthy schema, got %v", repairs)
+	}
+}
+
+// TestRepairSchemaMatchesFreshSchema guards against expectedColumns drifting from SQLiteSchema:
+// a brand-new database must need no repairs.
+func TestRepairSchemaMatchesFreshSchema(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(SQLiteSchema); err != nil {
+		t.Fatalf("Failed to apply schema: %v", err)
+	}
+
+	repairs, err := RepairSchema(db)
+	if err != nil {
+		t.Fatalf("RepairSchema failed: %v", err)
+	}
+	if len(repairs) != 0 {
+		t.Errorf("Fresh schema should not need repairs, got %v", repairs)
+	}
+
+	// And every expected column must exist in the fresh schema.
+	for _, table := range expectedColumns {
+		columns, err := tableColumns(db, table.Table)
+		if err != nil {
+			t.Fatalf("Failed to read columns for %s: %v", table.Table, err)
+		}
+		for _, col := range table.Columns {
+			if !columns[col.Name] {
+				t.Errorf("Column %s.%s is in expectedColumns but not SQLiteSchema", table.Table, col.Name)
+			}
+		}
+	}
+}
+
+// TestInitializeDatabaseRepairsExistingFile verifies the repair runs as part of InitializeDatabase.
+func TestInitializeDatabaseRepairsExistingFile(t *testing.T) {
+	tempDB := "test_repair.db"
+	defer os.Remove(tempDB)
+
+	db, err := Connect(tempDB)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	if _, err := db.Exec(`CREATE TABLE command_cards (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL, command TEXT NOT NULL)`); err != nil {
+		t.Fatalf("Failed to create legacy table: %v", err)
+	}
+	db.Close()
+
+	db, err = InitializeDatabase(tempDB)
+	if err != nil {
+		t.Fatalf("InitializeDatabase failed: %v", err)
+	}
+	defer db.Close()
+
+	columns, err := tableColumns(db, "command_cards")
+	if err != nil {
+		t.Fatalf("Failed to read columns: %v", err)
+	}
+	if !columns["description"] {
+		t.Error("Expected command_cards.description to be repaired")
+	}
+}
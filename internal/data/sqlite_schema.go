@@ -16,7 +16,12 @@ CREATE TABLE IF NOT EXISTS token_ledger (
     total_cost_usd REAL NOT NULL,
     latency_ms INTEGER NOT NULL,
     status TEXT NOT NULL, -- 'SUCCESS', 'FAILED', 'TIMEOUT'
-    error_message TEXT -- Detailed error log if the call failed
+    error_message TEXT, -- Detailed error log if the call failed
+    environment TEXT NOT NULL DEFAULT 'prod', -- Run tag such as 'dev' or 'prod', so test runs can be kept out of real cost stats
+    request_id TEXT, -- Shared by every call of one flow or command run, for correlating rows and logs
+    idempotency_key TEXT UNIQUE, -- Client-supplied key that stops a retried POST /api/ledger logging the call twice
+    replay_of INTEGER, -- ID of the entry this call replayed via POST /api/ledger/{id}/replay; NULL otherwise
+    node_id TEXT -- Flow node that made the call, for per-node run costs; NULL outside flow runs
 );
 
 -- Table 2: forge_flows
@@ -28,7 +33,10 @@ CREATE TABLE IF NOT EXISTS forge_flows (
     data TEXT NOT NULL, -- The serialized JSON structure of the nodes and edges
     status TEXT DEFAULT 'draft', -- 'draft', 'active', 'archived'
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    deleted_at DATETIME, -- Set when the flow is moved to the trash; NULL for live flows
+    max_cost_usd REAL, -- Per-run spending cap enforced by the engine; NULL or 0 = no cap
+    revision INTEGER NOT NULL DEFAULT 1 -- Bumped on every edit; updates must name the revision they're based on
 );
 
 -- Table 3: user_secrets
@@ -42,13 +50,21 @@ CREATE TABLE IF NOT EXISTS user_secrets (
 -- Index for fast retrieval of logs by flow ID
 CREATE INDEX IF NOT EXISTS idx_ledger_flow_id ON token_ledger(flow_id);
 
+-- Indexes for the ledger's hot queries: newest-first listing, date ranges (budgets, stats,
+-- trends, which compare datetime(timestamp) and so need an index on that expression),
+-- and status filters such as the optimizer's failure analysis
+CREATE INDEX IF NOT EXISTS idx_ledger_timestamp ON token_ledger(timestamp);
+CREATE INDEX IF NOT EXISTS idx_ledger_datetime ON token_ledger(datetime(timestamp));
+CREATE INDEX IF NOT EXISTS idx_ledger_status ON token_ledger(status);
+
 -- Table 4: command_cards
 -- Stores reusable terminal commands for the user.
 CREATE TABLE IF NOT EXISTS command_cards (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     name TEXT NOT NULL,
     command TEXT NOT NULL,
-    description TEXT
+    description TEXT,
+    max_cost_usd REAL -- Lifetime spending cap for runs of this card; NULL or 0 = no cap
 );
 
 -- Table 5: optimization_suggestions
@@ -67,6 +83,88 @@ CREATE TABLE IF NOT EXISTS optimization_suggestions (
     applied_at DATETIME,
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 );
+
+-- Table 6: flow_status
+-- Stores the latest execution status of each flow (one row per flow).
+CREATE TABLE IF NOT EXISTS flow_status (
+    flow_id INTEGER PRIMARY KEY,
+    status TEXT NOT NULL, -- 'PENDING', 'RUNNING', 'COMPLETED', 'FAILED'
+    last_node TEXT,
+    error TEXT,
+    updated_at DATETIME NOT NULL
+);
+
+-- Table 7: custom_agents
+-- Stores user-defined agent roles, used for roles that aren't built in.
+CREATE TABLE IF NOT EXISTS custom_agents (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+    system_prompt TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Table 8: flow_node_results
+-- Stores which nodes of each flow's latest run finished, so a failed run can resume.
+CREATE TABLE IF NOT EXISTS flow_node_results (
+    flow_id INTEGER NOT NULL,
+    node_id TEXT NOT NULL,
+    status TEXT NOT NULL, -- 'COMPLETED'
+    output TEXT,
+    cost_usd REAL NOT NULL DEFAULT 0,
+    completed_at DATETIME,
+    PRIMARY KEY (flow_id, node_id)
+);
+
+-- Table 9: flow_events
+-- An audit trail of each flow run (flow and node starts, finishes and failures) for post-mortems.
+CREATE TABLE IF NOT EXISTS flow_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    flow_id INTEGER NOT NULL,
+    run_id TEXT NOT NULL, -- Groups the events of one run
+    event_type TEXT NOT NULL, -- 'FLOW_STARTED', 'NODE_STARTED', 'NODE_COMPLETED', ...
+    node_id TEXT,
+    message TEXT,
+    duration_ms INTEGER NOT NULL DEFAULT 0, -- Set on events that end a node or the run
+    created_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_flow_events_flow ON flow_events(flow_id, id);
+
+-- Table 10: ledger_prompts
+-- The prompt behind each gateway call in token_ledger (flow nodes, commands, the OpenAI proxy), for
+-- auditing and replay. Only filled when ledger.store_prompts is on; token_ledger itself only holds a hash.
+CREATE TABLE IF NOT EXISTS ledger_prompts (
+    ledger_id INTEGER PRIMARY KEY, -- token_ledger.id
+    system_prompt TEXT NOT NULL DEFAULT '', -- The system prompt sent: the override or the role's persona
+    user_prompt TEXT NOT NULL,
+    created_at DATETIME
+);
+
+-- Table 11: flow_versions
+-- The graph each flow had before each edit, so a change can be reverted. Pruned to the newest few per flow.
+CREATE TABLE IF NOT EXISTS flow_versions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    flow_id INTEGER NOT NULL,
+    version INTEGER NOT NULL, -- Numbered from 1 per flow
+    name TEXT NOT NULL,
+    data TEXT NOT NULL, -- The serialized JSON graph as it was
+    created_at DATETIME,
+    UNIQUE (flow_id, version)
+);
+
+-- Table 12: key_audit
+-- When provider API keys were set, rotated or deleted, for compliance. Never holds the keys themselves.
+-- Append-only: the triggers refuse to change or remove a row.
+CREATE TABLE IF NOT EXISTS key_audit (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    provider TEXT NOT NULL, -- As given to the endpoint, or 'all' for DELETE_ALL
+    action TEXT NOT NULL, -- 'SET', 'ROTATE', 'DELETE' or 'DELETE_ALL'
+    created_at DATETIME NOT NULL
+);
+CREATE TRIGGER IF NOT EXISTS key_audit_no_update BEFORE UPDATE ON key_audit
+BEGIN SELECT RAISE(ABORT, 'key_audit is append-only'); END;
+CREATE TRIGGER IF NOT EXISTS key_audit_no_delete BEFORE DELETE ON key_audit
+BEGIN SELECT RAISE(ABORT, 'key_audit is append-only'); END;
 `
 
 // TokenLedgerPath is the filename for the SQLite database.
@@ -0,0 +1,37 @@
+// Package execution provides interfaces and implementations for running shell commands.
+// This file decides which of the server's environment variables commands never see.
+package execution
+
+import "strings"
+
+// providerKeyVars are the API key variables read by the provider SDKs and CLIs.
+// Forge keeps its own keys in the OS keyring, but a server started from a shell
+// that exports these would otherwise hand them to every command it runs.
+var providerKeyVars = map[string]bool{
+	"ANTHROPIC_API_KEY": true,
+	"OPENAI_API_KEY":    true,
+	"GOOGLE_API_KEY":    true,
+	"GEMINI_API_KEY":    true,
+}
+
+// sensitiveMarkers flag a FORGE_ variable as a secret when its name contains one.
+var sensitiveMarkers = []string{"KEY", "TOKEN", "SECRET", "PASSWORD"}
+
+// IsSensitiveEnv reports whether the environment variable name holds a secret that
+// commands must not inherit: a provider API key, or a FORGE_ variable such as
+// FORGE_TLS_KEY or FORGE_API_TOKEN. Names match case-insensitively, as on Windows.
+func IsSensitiveEnv(name string) bool {
+	name = strings.ToUpper(name)
+	if providerKeyVars[name] {
+		return true
+	}
+	if !strings.HasPrefix(name, "FORGE_") {
+		return false
+	}
+	for _, marker := range sensitiveMarkers {
+		if strings.Contains(name, marker) {
+			return true
+		}
+	}
+	return false
+}
@@ -9,6 +9,7 @@ package execution
 // - Where should I do it? (WorkingDir)
 // - How long should I wait? (TimeoutSeconds)
 // - What extra info might I need? (Environment)
+// - Should I see everything you can see? (InheritEnv)
 type ExecutionContext struct {
 	// Command is the shell command to execute (e.g., "echo hello world").
 	// This is the main instruction we want to run.
@@ -28,6 +29,12 @@ type ExecutionContext struct {
 	// Environment variables are like settings that programs can read.
 	// For example: {"DEBUG": "true", "LOG_LEVEL": "verbose"}
 	Environment map[string]string
+
+	// InheritEnv passes the server's own environment variables on to the command,
+	// minus Forge's secrets (see IsSensitiveEnv). When false the command starts with
+	// only Environment - no PATH or HOME unless Environment sets them.
+	// /api/execute defaults it to true, matching how commands ran before it existed.
+	InheritEnv bool
 }
 
 // ExecutionResult holds everything that happened when we ran a command.
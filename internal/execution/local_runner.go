@@ -6,7 +6,10 @@ import (
 	"bytes"
 	"context"
 	"os/exec"
+	"strings"
 	"time"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/config"
 )
 
 // LocalRunner runs commands on the local machine using Go's os/exec package.
@@ -22,7 +25,8 @@ func NewLocalRunner() *LocalRunner {
 }
 
 // Execute runs a shell command on the local machine and captures all output.
-// It uses the system's default shell (bash on Linux/Mac) to interpret the command.
+// It uses the shell from the Shell config to interpret the command: cmd, PowerShell or
+// WSL on Windows, and $SHELL (bash if unset) on Linux/Mac - the same shell the terminal opens.
 //
 // How it works:
 // 1. Create a shell process with the command
@@ -48,10 +52,17 @@ func (l *LocalRunner) Execute(ctx ExecutionContext) ExecutionResult {
 		cmdContext = context.Background()
 	}
 
-	// Create the command using bash to interpret the shell command.
-	// We use "bash -c" so that shell features like pipes and redirects work.
-	// For example: "echo hello | grep h" needs bash to work correctly.
-	cmd := exec.CommandContext(cmdContext, "bash", "-c", ctx.Command)
+	// Create the command using the configured shell to interpret the shell command.
+	// We hand the whole line to the shell so that features like pipes and redirects work.
+	// For example: "echo hello | grep h" needs a shell to work correctly.
+	// The config is read on every run, so a shell change applies without a restart.
+	shellCfg := config.DefaultConfig().Shell
+	if cfg, err := config.Get(); err == nil {
+		shellCfg = cfg.Shell
+	}
+	shell, args := ShellCommand(shellCfg, ctx.Command)
+	cmd := exec.CommandContext(cmdContext, shell, args...)
+	setShellCommandLine(cmd, shell, ctx.Command)
 
 	// Set the working directory if specified.
 	// This is where the command will run from.
@@ -59,17 +70,24 @@ func (l *LocalRunner) Execute(ctx ExecutionContext) ExecutionResult {
 		cmd.Dir = ctx.WorkingDir
 	}
 
-	// Set up environment variables if any were provided.
-	// We start with the current environment and add the extras.
-	if len(ctx.Environment) > 0 {
-		// Get the current environment as a starting point.
-		env := cmd.Environ()
-		// Add each extra variable.
-		for key, value := range ctx.Environment {
-			env = append(env, key+"="+value)
+	// Set up environment variables.
+	// We start with the current environment (without Forge's secrets) or, for an
+	// isolated run, an empty one, and add the extras.
+	// A non-nil empty Env matters: a nil Env would inherit everything.
+	env := []string{}
+	if ctx.InheritEnv {
+		for _, kv := range cmd.Environ() {
+			name, _, _ := strings.Cut(kv, "=")
+			if !IsSensitiveEnv(name) {
+				env = append(env, kv)
+			}
 		}
-		cmd.Env = env
 	}
+	// Add each extra variable. The caller chose these, so they're passed even if sensitive.
+	for key, value := range ctx.Environment {
+		env = append(env, key+"="+value)
+	}
+	cmd.Env = env
 
 	// Create buffers to capture stdout and stderr.
 	// A buffer is like a container that collects text as the command runs.
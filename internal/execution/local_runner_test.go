@@ -140,6 +140,44 @@ func TestLocalRunnerWithEnvironmentVariables(t *testing.T) {
 	}
 }
 
+// TestLocalRunnerInheritEnv verifies inherited runs see the server's variables but not its
+// secrets, and clean runs see only the variables they were given.
+func TestLocalRunnerInheritEnv(t *testing.T) {
+	t.Setenv("FORGE_TEST_SETTING", "inherited")
+	t.Setenv("OPENAI_API_KEY", "sk-test-secret")
+	t.Setenv("FORGE_TEST_TOKEN", "token-secret")
+	runner := NewLocalRunner()
+	command := "echo [$FORGE_TEST_SETTING][$OPENAI_API_KEY][$FORGE_TEST_TOKEN][$MY_TEST_VAR]"
+	extra := map[string]string{"MY_TEST_VAR": "given"}
+
+	inherited := runner.Execute(ExecutionContext{Command: command, Environment: extra, InheritEnv: true})
+	if got := strings.TrimSpace(inherited.Stdout); got != "[inherited][][][given]" {
+		t.Errorf("Inherited environment: expected only the secrets dropped, got %q", got)
+	}
+
+	clean := runner.Execute(ExecutionContext{Command: command, Environment: extra})
+	if got := strings.TrimSpace(clean.Stdout); got != "[][][][given]" {
+		t.Errorf("Clean environment: expected only MY_TEST_VAR, got %q", got)
+	}
+}
+
+// TestIsSensitiveEnv verifies which variable names count as secrets.
+func TestIsSensitiveEnv(t *testing.T) {
+	for name, want := range map[string]bool{
+		"ANTHROPIC_API_KEY": true,
+		"openai_api_key":    true,
+		"FORGE_TLS_KEY":     true,
+		"FORGE_API_TOKEN":   true,
+		"FORGE_LOG_LEVEL":   false,
+		"PATH":              false,
+		"MY_TOKEN":          false,
+	} {
+		if got := IsSensitiveEnv(name); got != want {
+			t.Errorf("IsSensitiveEnv(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
 // TestLocalRunnerImplementsExecutorInterface verifies that LocalRunner
 // properly implements the Executor interface.
 func TestLocalRunnerImplementsExecutorInterface(t *testing.T) {
@@ -0,0 +1,61 @@
+// Package execution provides interfaces and implementations for running shell commands.
+// This file picks the shell commands run in, shared by /api/execute and the terminal.
+package execution
+
+import (
+	"os"
+	"runtime"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/config"
+)
+
+// DefaultShell returns the shell program configured for this platform: on Windows the
+// ShellConfig's cmd, PowerShell or WSL (cmd when unset), elsewhere $SHELL (or /bin/bash).
+// Educational Comment: The terminal and /api/execute both start their shell from here,
+// so a command behaves the same whether it's typed in the terminal or sent to the API.
+func DefaultShell(cfg config.ShellConfig) string {
+	return shellFor(cfg, runtime.GOOS, os.Getenv)
+}
+
+// ShellCommand returns the program and arguments that run one command line in the
+// configured shell, for example "cmd.exe /C dir" or "$SHELL -c 'ls -la'".
+func ShellCommand(cfg config.ShellConfig, command string) (string, []string) {
+	return shellCommandFor(cfg, runtime.GOOS, os.Getenv, command)
+}
+
+func shellFor(cfg config.ShellConfig, goos string, getenv func(string) string) string {
+	if goos != "windows" {
+		if shell := getenv("SHELL"); shell != "" {
+			return shell
+		}
+		return "/bin/bash"
+	}
+
+	switch cfg.Type {
+	case config.ShellWSL:
+		return "wsl.exe"
+	case config.ShellPowerShell:
+		return "powershell.exe"
+	default:
+		return "cmd.exe"
+	}
+}
+
+func shellCommandFor(cfg config.ShellConfig, goos string, getenv func(string) string, command string) (string, []string) {
+	shell := shellFor(cfg, goos, getenv)
+	switch shell {
+	case "wsl.exe":
+		// wsl.exe starts in the process's working directory, translated to its /mnt path
+		args := []string{}
+		if cfg.WSLDistro != "" {
+			args = append(args, "-d", cfg.WSLDistro)
+		}
+		return shell, append(args, "-e", "bash", "-c", command)
+	case "powershell.exe":
+		return shell, []string{"-NoProfile", "-NonInteractive", "-Command", command}
+	case "cmd.exe":
+		return shell, []string{"/C", command}
+	default:
+		return shell, []string{"-c", command}
+	}
+}
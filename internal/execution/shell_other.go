@@ -0,0 +1,10 @@
+//go:build !windows
+// +build !windows
+
+package execution
+
+import "os/exec"
+
+// setShellCommandLine is only needed for cmd.exe's quoting; other shells take
+// the command as a single argument.
+func setShellCommandLine(cmd *exec.Cmd, shell, command string) {}
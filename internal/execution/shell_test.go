@@ -0,0 +1,38 @@
+package execution
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/config"
+)
+
+// TestShellCommandFor verifies each configured shell gets the arguments that run one command.
+func TestShellCommandFor(t *testing.T) {
+	noShell := func(string) string { return "" }
+	zsh := func(string) string { return "/bin/zsh" }
+
+	tests := []struct {
+		name      string
+		cfg       config.ShellConfig
+		goos      string
+		getenv    func(string) string
+		wantShell string
+		wantArgs  []string
+	}{
+		{"windows default", config.ShellConfig{}, "windows", noShell, "cmd.exe", []string{"/C", "dir"}},
+		{"windows cmd", config.ShellConfig{Type: config.ShellCmd}, "windows", noShell, "cmd.exe", []string{"/C", "dir"}},
+		{"windows powershell", config.ShellConfig{Type: config.ShellPowerShell}, "windows", noShell, "powershell.exe", []string{"-NoProfile", "-NonInteractive", "-Command", "dir"}},
+		{"windows wsl", config.ShellConfig{Type: config.ShellWSL, WSLDistro: "Ubuntu"}, "windows", noShell, "wsl.exe", []string{"-d", "Ubuntu", "-e", "bash", "-c", "dir"}},
+		{"unix $SHELL", config.ShellConfig{Type: config.ShellPowerShell}, "linux", zsh, "/bin/zsh", []string{"-c", "dir"}},
+		{"unix fallback", config.ShellConfig{}, "darwin", noShell, "/bin/bash", []string{"-c", "dir"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shell, args := shellCommandFor(tt.cfg, tt.goos, tt.getenv, "dir")
+			if shell != tt.wantShell || !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("Got %s %q, want %s %q", shell, args, tt.wantShell, tt.wantArgs)
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+//go:build !windows
+// +build !windows
+
+package execution
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestLocalRunnerUsesConfiguredShell verifies commands run under $SHELL rather than bash.
+func TestLocalRunnerUsesConfiguredShell(t *testing.T) {
+	t.Setenv("SHELL", "/bin/sh")
+
+	result := NewLocalRunner().Execute(ExecutionContext{Command: "echo $0"})
+
+	if result.ExitCode != 0 || result.Error != nil {
+		t.Fatalf("Expected success, got exit code %d: %v", result.ExitCode, result.Error)
+	}
+	if got := strings.TrimSpace(result.Stdout); got != "/bin/sh" {
+		t.Errorf("Expected the command to run under /bin/sh, got %q", got)
+	}
+}
@@ -0,0 +1,20 @@
+//go:build windows
+// +build windows
+
+package execution
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setShellCommandLine passes the command to cmd.exe untouched.
+// Educational Comment: Go quotes each argument the way most Windows programs parse them,
+// but cmd.exe doesn't, so `echo "a b"` would reach it with escaped quotes. Handing over
+// the raw command line keeps quoting the same as typing the command in a cmd window.
+func setShellCommandLine(cmd *exec.Cmd, shell, command string) {
+	if shell != "cmd.exe" {
+		return
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{CmdLine: shell + " /C " + command}
+}
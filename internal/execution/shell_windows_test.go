@@ -0,0 +1,46 @@
+//go:build windows
+// +build windows
+
+package execution
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/config"
+)
+
+// useShell saves a config with the given shell type for the rest of the test.
+func useShell(t *testing.T, shellType config.ShellType) {
+	t.Helper()
+	t.Setenv("APPDATA", t.TempDir())
+	cfg := config.DefaultConfig()
+	cfg.Shell.Type = shellType
+	if err := config.Save(cfg); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	t.Cleanup(func() { config.Save(config.DefaultConfig()) })
+}
+
+// TestLocalRunnerUsesPowerShell verifies a PowerShell-only expression runs when PowerShell is configured.
+func TestLocalRunnerUsesPowerShell(t *testing.T) {
+	useShell(t, config.ShellPowerShell)
+
+	result := NewLocalRunner().Execute(ExecutionContext{Command: "$PSVersionTable.PSVersion.Major -gt 0", InheritEnv: true})
+
+	if got := strings.TrimSpace(result.Stdout); result.ExitCode != 0 || got != "True" {
+		t.Errorf("Expected PowerShell to print True, got exit code %d, stdout %q, stderr %q", result.ExitCode, got, result.Stderr)
+	}
+}
+
+// TestLocalRunnerUsesCmd verifies cmd.exe receives the command line with its quotes intact.
+func TestLocalRunnerUsesCmd(t *testing.T) {
+	useShell(t, config.ShellCmd)
+
+	result := NewLocalRunner().Execute(ExecutionContext{Command: `echo "a b" & echo %COMSPEC%`, InheritEnv: true})
+
+	lines := strings.Split(strings.TrimSpace(result.Stdout), "\r\n")
+	if result.ExitCode != 0 || len(lines) != 2 || lines[0] != `"a b" ` || !strings.HasSuffix(strings.ToLower(lines[1]), "cmd.exe") {
+		t.Errorf("Expected cmd.exe output, got exit code %d, stdout %q, stderr %q", result.ExitCode, result.Stdout, result.Stderr)
+	}
+}
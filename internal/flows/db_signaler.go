@@ -0,0 +1,82 @@
+package flows
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// DBSignaler implements Signaler by upserting into the flow_status table.
+// Unlike FileSignaler, the status survives cleanup of .forge/ and can be queried.
+type DBSignaler struct {
+	db *sql.DB
+}
+
+// NewDBSignaler creates a new DBSignaler backed by the given database
+func NewDBSignaler(db *sql.DB) *DBSignaler {
+	return &DBSignaler{db: db}
+}
+
+// NotifyStatus inserts or replaces the status row for the flow
+func (d *DBSignaler) NotifyStatus(flowID int, status FlowStatus) error {
+	updatedAt := status.UpdatedAt
+	if updatedAt.IsZero() {
+		updatedAt = time.Now()
+	}
+
+	query := `
+		INSERT INTO flow_status (flow_id, status, last_node, error, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(flow_id) DO UPDATE SET
+			status = excluded.status,
+			last_node = excluded.last_node,
+			error = excluded.error,
+			updated_at = excluded.updated_at
+	`
+	if _, err := d.db.Exec(query, flowID, status.Status, status.LastNode, status.Error, updatedAt); err != nil {
+		return fmt.Errorf("failed to upsert flow status: %w", err)
+	}
+	return nil
+}
+
+// GetStatus reads the flow status row from the database
+func (d *DBSignaler) GetStatus(flowID int) (*FlowStatus, error) {
+	query := `SELECT flow_id, status, last_node, error, updated_at FROM flow_status WHERE flow_id = ?`
+
+	var status FlowStatus
+	var lastNode, errMsg sql.NullString
+	err := d.db.QueryRow(query, flowID).Scan(&status.FlowID, &status.Status, &lastNode, &errMsg, &status.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("status not found for flow %d", flowID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read flow status: %w", err)
+	}
+
+	status.LastNode = lastNode.String
+	status.Error = errMsg.String
+	return &status, nil
+}
+
+// ListStatuses returns the latest status of every flow, ordered by flow ID
+func (d *DBSignaler) ListStatuses() ([]FlowStatus, error) {
+	query := `SELECT flow_id, status, last_node, error, updated_at FROM flow_status ORDER BY flow_id`
+	rows, err := d.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list flow statuses: %w", err)
+	}
+	defer rows.Close()
+
+	statuses := []FlowStatus{}
+	for rows.Next() {
+		var status FlowStatus
+		var lastNode, errMsg sql.NullString
+		if err := rows.Scan(&status.FlowID, &status.Status, &lastNode, &errMsg, &status.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan flow status: %w", err)
+		}
+		status.LastNode = lastNode.String
+		status.Error = errMsg.String
+		statuses = append(statuses, status)
+	}
+	return statuses, rows.Err()
+}
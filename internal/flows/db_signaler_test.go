@@ -0,0 +1,108 @@
+package flows
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/data"
+	_ "modernc.org/sqlite"
+)
+
+func setupStatusDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db: %v", err)
+	}
+	if _, err := db.Exec(data.SQLiteSchema); err != nil {
+		t.Fatalf("Failed to init schema: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestDBSignalerNotifyAndGetStatus(t *testing.T) {
+	db := setupStatusDB(t)
+	signaler := NewDBSignaler(db)
+
+	status := FlowStatus{
+		FlowID:    7,
+		Status:    "RUNNING",
+		LastNode:  "agent-1",
+		UpdatedAt: time.Now(),
+	}
+	if err := signaler.NotifyStatus(7, status); err != nil {
+		t.Fatalf("NotifyStatus failed: %v", err)
+	}
+
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM flow_status WHERE flow_id = 7").Scan(&count)
+	if count != 1 {
+		t.Fatalf("Expected 1 flow_status row, got %d", count)
+	}
+
+	got, err := signaler.GetStatus(7)
+	if err != nil {
+		t.Fatalf("GetStatus failed: %v", err)
+	}
+	if got.Status != "RUNNING" || got.LastNode != "agent-1" {
+		t.Errorf("Unexpected status: %+v", got)
+	}
+}
+
+func TestDBSignalerUpsertsLatestStatus(t *testing.T) {
+	db := setupStatusDB(t)
+	signaler := NewDBSignaler(db)
+
+	signaler.NotifyStatus(3, FlowStatus{FlowID: 3, Status: "RUNNING", LastNode: "a", UpdatedAt: time.Now()})
+	signaler.NotifyStatus(3, FlowStatus{FlowID: 3, Status: "FAILED", LastNode: "b", Error: "boom", UpdatedAt: time.Now()})
+
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM flow_status WHERE flow_id = 3").Scan(&count)
+	if count != 1 {
+		t.Errorf("Expected upsert to keep a single row, got %d", count)
+	}
+
+	got, err := signaler.GetStatus(3)
+	if err != nil {
+		t.Fatalf("GetStatus failed: %v", err)
+	}
+	if got.Status != "FAILED" || got.LastNode != "b" || got.Error != "boom" {
+		t.Errorf("Expected latest status to win, got %+v", got)
+	}
+}
+
+func TestDBSignalerGetStatusNotFound(t *testing.T) {
+	signaler := NewDBSignaler(setupStatusDB(t))
+
+	if _, err := signaler.GetStatus(404); err == nil {
+		t.Error("Expected error for missing flow status")
+	}
+}
+
+func TestDBSignalerImplementsSignaler(t *testing.T) {
+	var _ Signaler = (*DBSignaler)(nil)
+}
+
+func TestDBSignalerListStatuses(t *testing.T) {
+	db := setupStatusDB(t)
+	signaler := NewDBSignaler(db)
+
+	signaler.NotifyStatus(2, FlowStatus{FlowID: 2, Status: "RUNNING", LastNode: "x", UpdatedAt: time.Now()})
+	signaler.NotifyStatus(1, FlowStatus{FlowID: 1, Status: "COMPLETED", LastNode: "y", UpdatedAt: time.Now()})
+	signaler.NotifyStatus(2, FlowStatus{FlowID: 2, Status: "COMPLETED", LastNode: "z", UpdatedAt: time.Now()})
+
+	statuses, err := signaler.ListStatuses()
+	if err != nil {
+		t.Fatalf("ListStatuses failed: %v", err)
+	}
+	if len(statuses) != 2 {
+		t.Fatalf("Expected 2 statuses, got %d", len(statuses))
+	}
+	if statuses[0].FlowID != 1 || statuses[1].FlowID != 2 {
+		t.Errorf("Expected statuses ordered by flow ID, got %+v", statuses)
+	}
+	if statuses[1].LastNode != "z" {
+		t.Errorf("Expected latest node for flow 2, got %s", statuses[1].LastNode)
+	}
+}
@@ -3,11 +3,17 @@ package flows
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/agents"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/budget"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/config"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/data"
 	"github.com/mikejsmith1985/forge-orchestrator/internal/llm"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/logging"
 	"github.com/mikejsmith1985/forge-orchestrator/internal/security"
 )
 
@@ -23,14 +29,34 @@ type Flow struct {
 	Data      string    `json:"data"` // JSON string of the graph
 	Status    string    `json:"status"`
 	CreatedAt time.Time `json:"created_at"`
+
+	// MaxCostUSD stops a run before a node that would take its spend past this cap (0 = no cap)
+	MaxCostUSD float64 `json:"max_cost_usd,omitempty"`
+
+	// Revision counts edits to the flow. An update must send the revision it was based on
+	// and is refused if the flow has been changed since.
+	Revision int `json:"revision"`
 }
 
 // FlowGraph represents the parsed JSON structure of the flow.
 type FlowGraph struct {
 	Nodes []Node `json:"nodes"`
 	Edges []Edge `json:"edges"`
+
+	// RetryConfig applies to every node that doesn't set its own
+	RetryConfig *RetryConfig `json:"retryConfig,omitempty"`
+
+	// OnMissingKey is what happens to a node whose provider has no API key:
+	// MissingKeyFail (the default) stops the run, MissingKeySkip skips the node and carries on
+	OnMissingKey string `json:"onMissingKey,omitempty"`
 }
 
+// Values for FlowGraph.OnMissingKey.
+const (
+	MissingKeyFail = "fail"
+	MissingKeySkip = "skip"
+)
+
 // Node represents a single step in the flow.
 type Node struct {
 	ID   string   `json:"id"`
@@ -44,6 +70,27 @@ type NodeData struct {
 	Role     string `json:"role"`     // e.g., "coder", "planner"
 	Prompt   string `json:"prompt"`   // The user input/task for this agent
 	Provider string `json:"provider"` // e.g., "Anthropic", "OpenAI"
+
+	// SystemPrompt, when set, replaces the role's persona for this node only
+	SystemPrompt string `json:"system_prompt,omitempty"`
+
+	// RetryConfig, when set, replaces the flow's retry settings for this node only
+	RetryConfig *RetryConfig `json:"retryConfig,omitempty"`
+
+	// FallbackModels are providers to try, in order, when Provider is rate limited or unavailable.
+	// An entry is a provider name or a model its client runs (see llm.ResolveFallback).
+	FallbackModels []string `json:"fallbackModels,omitempty"`
+
+	// MaxOutputTokens caps each response of this node (max_tokens); 0 uses the model's maximum
+	MaxOutputTokens int `json:"maxOutputTokens,omitempty"`
+
+	// Temperature and TopP tune the node's sampling; unset leaves the provider's default
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"topP,omitempty"`
+
+	// ResponseFormat "json" requires the node's output to be a JSON object, e.g. for an
+	// agent that hands a contract to the next; anything else fails as MALFORMED_JSON
+	ResponseFormat string `json:"responseFormat,omitempty"`
 }
 
 // Edge represents a connection between nodes.
@@ -53,19 +100,31 @@ type Edge struct {
 	Target string `json:"target"`
 }
 
-// notifyStatus sends status update via WebSocket with file fallback
+// sleep pauses between nodes. Tests replace it with a fake clock.
+var sleep = time.Sleep
+
+// interNodeDelay returns the configured pause between sequential node calls.
+func interNodeDelay() time.Duration {
+	cfg, err := config.Get()
+	if err != nil || cfg.Flows.InterNodeDelayMs <= 0 {
+		return 0
+	}
+	return time.Duration(cfg.Flows.InterNodeDelayMs) * time.Millisecond
+}
+
+// notifyStatus sends status update via the primary signaler (WebSocket or DB) with file fallback
 func notifyStatus(wsSignaler, fileSignaler Signaler, flowID int, status FlowStatus) {
 	// Always write to file for fallback polling
 	if fileSignaler != nil {
 		if err := fileSignaler.NotifyStatus(flowID, status); err != nil {
-			log.Printf("File signaler failed: %v", err)
+			logging.Warnf("File signaler failed: %v", err)
 		}
 	}
 
-	// Try WebSocket notification
+	// Try primary notification
 	if wsSignaler != nil {
 		if err := wsSignaler.NotifyStatus(flowID, status); err != nil {
-			log.Printf("WebSocket notify failed, using file fallback: %v", err)
+			logging.Warnf("Primary signaler notify failed, using file fallback: %v", err)
 		}
 	}
 }
@@ -75,13 +134,74 @@ func ExecuteFlowWithSignaling(flowID int, db *sql.DB, gateway *llm.Gateway, wsSi
 	return ExecuteFlowWithHub(flowID, db, gateway, wsSignaler, fileSignaler, nil)
 }
 
+// ExecuteOptions carries optional per-run settings for a flow execution.
+type ExecuteOptions struct {
+	// Attachments are appended to every agent node's prompt as extra context
+	Attachments []llm.Attachment
+
+	// Environment tags this run's ledger entries, e.g. "dev" (empty = data.DefaultEnvironment)
+	Environment string
+
+	// Resume skips the nodes that finished in the flow's last, failed run and
+	// starts from the node that failed. With nothing to resume, the whole flow runs.
+	Resume bool
+
+	// RequestID correlates the run's ledger rows, broadcasts, events and log lines.
+	// A new UUID is generated when it is empty.
+	RequestID string
+
+	// OnNodeResult, if set, is called as each agent node completes, fails or is skipped
+	// by a resume, e.g. to build a summary of the run's outputs
+	OnNodeResult func(NodeResult)
+
+	// Variables are substituted for {{env.NAME}} references in the nodes' prompts.
+	// A reference without a value fails the run with ErrUnresolvedVariable.
+	Variables map[string]string
+}
+
+// NodeResult is the outcome of one node in a run.
+type NodeResult struct {
+	NodeID  string  `json:"node_id"`
+	Status  string  `json:"status"` // COMPLETED, FAILED or SKIPPED
+	Output  string  `json:"output,omitempty"`
+	CostUSD float64 `json:"cost_usd"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// reportNode passes a node's outcome to OnNodeResult, if the caller set one.
+func (o ExecuteOptions) reportNode(result NodeResult) {
+	if o.OnNodeResult != nil {
+		o.OnNodeResult(result)
+	}
+}
+
 // ExecuteFlowWithHub runs the flow with full Hub integration for real-time broadcasts
 func ExecuteFlowWithHub(flowID int, db *sql.DB, gateway *llm.Gateway, wsSignaler, fileSignaler Signaler, hub Broadcaster) error {
+	return ExecuteFlowWithOptions(flowID, db, gateway, wsSignaler, fileSignaler, hub, ExecuteOptions{})
+}
+
+// ExecuteFlowWithOptions runs the flow with Hub integration and per-run options.
+// It returns ErrFlowAlreadyRunning if the same flow is already executing.
+func ExecuteFlowWithOptions(flowID int, db *sql.DB, gateway *llm.Gateway, wsSignaler, fileSignaler Signaler, hub Broadcaster, opts ExecuteOptions) error {
+	// Only one run per flow at a time; the lock is released however the run ends
+	if !acquireFlow(flowID) {
+		return fmt.Errorf("%w: flow %d", ErrFlowAlreadyRunning, flowID)
+	}
+	defer releaseFlow(flowID)
+
+	if opts.RequestID == "" {
+		opts.RequestID = uuid.NewString()
+	}
+	log := logging.ForRequest(opts.RequestID)
+	log.Infof("Flow %d started", flowID)
+
 	startTime := time.Now()
+	events := newEventLog(db, flowID, opts.RequestID)
+	events.record(EventFlowStarted, "", "", 0)
 
 	// Broadcast FLOW_STARTED
 	if hub != nil {
-		hub.Broadcast(NewFlowStartedMessage(flowID))
+		hub.Broadcast(NewFlowStartedMessage(flowID, opts.RequestID))
 	}
 
 	// Notify flow started (legacy signaler)
@@ -91,28 +211,56 @@ func ExecuteFlowWithHub(flowID int, db *sql.DB, gateway *llm.Gateway, wsSignaler
 		UpdatedAt: time.Now(),
 	})
 
-	err := executeFlowInternalWithHub(flowID, db, gateway, wsSignaler, fileSignaler, hub)
+	totalCost, err := executeFlowInternalWithHub(flowID, db, gateway, wsSignaler, fileSignaler, hub, opts, events)
+
+	elapsed := time.Since(startTime)
+	executionTime := elapsed.Milliseconds()
 
-	executionTime := time.Since(startTime).Milliseconds()
+	// What the run cost, per node, as its ledger rows record it
+	runCost, costErr := RunCostSummary(db, opts.RequestID)
+	if costErr != nil {
+		log.Warnf("Flow %d: %v", flowID, costErr)
+	}
 
 	// Notify flow completed or failed
 	if err != nil {
+		// A run stopped by its cost cap gets its own status so the UI can explain why
+		status := "FAILED"
+		if errors.Is(err, budget.ErrBudgetExceeded) {
+			status = budget.CodeBudgetExceeded
+		}
+
+		events.record(EventFlowFailed, "", err.Error(), elapsed)
+		log.Errorf("Flow %d failed after %dms: %v", flowID, executionTime, err)
+
 		// Broadcast FLOW_FAILED
 		if hub != nil {
-			hub.Broadcast(NewFlowFailedMessage(flowID, err.Error()))
+			hub.Broadcast(NewFlowFailedMessage(flowID, opts.RequestID, err.Error(), runCost))
 		}
 		notifyStatus(wsSignaler, fileSignaler, flowID, FlowStatus{
 			FlowID:    flowID,
-			Status:    "FAILED",
+			Status:    status,
 			UpdatedAt: time.Now(),
 			Error:     err.Error(),
 		})
+		sendWebhook(WebhookPayload{
+			Event:        "FLOW_FAILED",
+			FlowID:       flowID,
+			Status:       status,
+			DurationMs:   executionTime,
+			TotalCostUSD: totalCost,
+			Error:        err.Error(),
+			Timestamp:    time.Now(),
+		})
 		return err
 	}
 
+	events.record(EventFlowCompleted, "", "", elapsed)
+	log.Infof("Flow %d completed in %dms", flowID, executionTime)
+
 	// Broadcast FLOW_COMPLETED
 	if hub != nil {
-		hub.Broadcast(NewFlowCompletedMessage(flowID, executionTime))
+		hub.Broadcast(NewFlowCompletedMessage(flowID, opts.RequestID, executionTime, runCost))
 	}
 
 	notifyStatus(wsSignaler, fileSignaler, flowID, FlowStatus{
@@ -120,35 +268,121 @@ func ExecuteFlowWithHub(flowID int, db *sql.DB, gateway *llm.Gateway, wsSignaler
 		Status:    "COMPLETED",
 		UpdatedAt: time.Now(),
 	})
+	sendWebhook(WebhookPayload{
+		Event:        "FLOW_COMPLETED",
+		FlowID:       flowID,
+		Status:       "COMPLETED",
+		DurationMs:   executionTime,
+		TotalCostUSD: totalCost,
+		Timestamp:    time.Now(),
+	})
 
 	return nil
 }
 
-// executeFlowInternalWithHub contains the core flow execution logic with Hub broadcasting
-func executeFlowInternalWithHub(flowID int, db *sql.DB, gateway *llm.Gateway, wsSignaler, fileSignaler Signaler, hub Broadcaster) error {
+// fallbackChains resolves every agent node's provider and fallbacks up front, so a flow
+// with an unknown fallback fails with llm.ErrUnknownFallback before the first call is made.
+func fallbackChains(graph FlowGraph) (map[string][]llm.ProviderType, error) {
+	chains := map[string][]llm.ProviderType{}
+	for _, node := range graph.Nodes {
+		if node.Type != "agent" {
+			continue
+		}
+		chain, err := llm.FallbackChain(llm.ProviderType(node.Data.Provider), node.Data.FallbackModels)
+		if err != nil {
+			return nil, fmt.Errorf("node %s: %w", node.ID, err)
+		}
+		chains[node.ID] = chain
+	}
+	return chains, nil
+}
+
+// executeFlowInternalWithHub contains the core flow execution logic with Hub broadcasting.
+// It returns the total cost of the nodes that ran, even when a node fails.
+// Node starts and finishes are recorded in events.
+func executeFlowInternalWithHub(flowID int, db *sql.DB, gateway *llm.Gateway, wsSignaler, fileSignaler Signaler, hub Broadcaster, opts ExecuteOptions, events *eventLog) (float64, error) {
+	log := logging.ForRequest(opts.RequestID)
+
 	// 1. Fetch flow data
 	var flowData string
-	query := `SELECT data FROM forge_flows WHERE id = ?`
-	err := db.QueryRow(query, flowID).Scan(&flowData)
+	var maxCost float64
+	query := `SELECT data, COALESCE(max_cost_usd, 0) FROM forge_flows WHERE id = ? AND deleted_at IS NULL`
+	err := db.QueryRow(query, flowID).Scan(&flowData, &maxCost)
 	if err != nil {
-		return fmt.Errorf("failed to fetch flow: %w", err)
+		return 0, fmt.Errorf("failed to fetch flow: %w", err)
 	}
 
 	// 2. Parse JSON graph
 	var graph FlowGraph
 	if err := json.Unmarshal([]byte(flowData), &graph); err != nil {
-		return fmt.Errorf("failed to parse flow data: %w", err)
+		return 0, fmt.Errorf("failed to parse flow data: %w", err)
+	}
+	if err := substituteVariables(&graph, opts.Variables); err != nil {
+		return 0, err
+	}
+	chains, err := fallbackChains(graph)
+	if err != nil {
+		return 0, err
+	}
+
+	// Nodes that already finished, when resuming; a fresh run forgets earlier results
+	completed := map[string]string{}
+	if opts.Resume {
+		if completed, err = completedNodes(db, flowID); err != nil {
+			return 0, err
+		}
+	} else if err := clearNodeResults(db, flowID); err != nil {
+		return 0, err
 	}
 
 	// 3. Execute nodes (Sequential for now)
+	var totalCost float64
+	delay := interNodeDelay()
+	executed := 0
 	for _, node := range graph.Nodes {
 		if node.Type != "agent" {
 			continue // Skip non-agent nodes if any
 		}
+		if output, done := completed[node.ID]; done {
+			log.Infof("Resuming flow %d: node %s already completed, reusing its output", flowID, node.ID)
+			events.record(EventNodeSkipped, node.ID, "completed in an earlier run", 0)
+			opts.reportNode(NodeResult{NodeID: node.ID, Status: "SKIPPED", Output: output})
+			continue
+		}
+
+		// Stop before a node that would take the run past the flow's cost cap.
+		// We can't know a node's cost until it has run, so assume it costs what the
+		// nodes so far have cost on average.
+		var projected float64
+		if executed > 0 {
+			projected = totalCost / float64(executed)
+		}
+		if err := budget.CheckCostCap(totalCost, projected, maxCost); err != nil {
+			return totalCost, fmt.Errorf("stopped before node %s: %w", node.ID, err)
+		}
+
+		// Give rate-limited providers a breather between calls (not before the first one)
+		if executed > 0 && delay > 0 {
+			sleep(delay)
+		}
+		executed++
+		nodeStart := time.Now()
+		events.record(EventNodeStarted, node.ID, node.Data.Label, 0)
+		// What the node's calls were billed, reported with the node's outcome
+		var inputTokens, outputTokens int
+		var cost float64
+		nodeFailed := func(err error) error {
+			if hub != nil {
+				hub.Broadcast(NewNodeFailedMessage(flowID, opts.RequestID, node.ID, err.Error(), inputTokens, outputTokens, cost))
+			}
+			events.record(EventNodeFailed, node.ID, err.Error(), time.Since(nodeStart))
+			opts.reportNode(NodeResult{NodeID: node.ID, Status: "FAILED", Error: err.Error()})
+			return err
+		}
 
 		// Broadcast NODE_STARTED
 		if hub != nil {
-			hub.Broadcast(NewNodeStartedMessage(flowID, node.ID, node.Data.Label))
+			hub.Broadcast(NewNodeStartedMessage(flowID, opts.RequestID, node.ID, node.Data.Label))
 		}
 
 		// Notify node starting (legacy signaler)
@@ -162,68 +396,161 @@ func executeFlowInternalWithHub(flowID int, db *sql.DB, gateway *llm.Gateway, ws
 		// Get API Key
 		apiKey, err := security.GetAPIKey(node.Data.Provider)
 		if err != nil {
-			log.Printf("Error getting API key for provider %s: %v", node.Data.Provider, err)
-			return fmt.Errorf("missing API key for provider %s: %w", node.Data.Provider, err)
+			if graph.OnMissingKey != MissingKeySkip {
+				log.Errorf("Error getting API key for provider %s: %v", node.Data.Provider, err)
+				return totalCost, nodeFailed(fmt.Errorf("missing API key for provider %s: %w", node.Data.Provider, err))
+			}
+			// Let a partly configured flow produce what it can. The skip isn't saved as a
+			// result, so resuming once the key is added runs the node.
+			reason := fmt.Sprintf("no API key for provider %s", node.Data.Provider)
+			log.Warnf("Flow %d: skipping node %s: %s", flowID, node.ID, reason)
+			logNodeAttempt(db, flowID, node, node.Data.Provider, opts, "", 0, 0, 0, 0, "SKIPPED", reason)
+			if hub != nil {
+				hub.Broadcast(NewNodeCompletedMessage(flowID, opts.RequestID, node.ID, 0, 0, 0))
+			}
+			events.record(EventNodeSkipped, node.ID, reason, time.Since(nodeStart))
+			opts.reportNode(NodeResult{NodeID: node.ID, Status: "SKIPPED", Error: reason})
+			continue
 		}
 
-		// Execute Prompt
-		providerType := llm.ProviderType(node.Data.Provider)
+		// Stop before the call if today's token quota is used up
+		if err := budget.CheckDailyTokenLimit(db); err != nil {
+			return totalCost, nodeFailed(fmt.Errorf("node %s: %w", node.ID, err))
+		}
 
-		start := time.Now()
-		resp, err := gateway.ExecutePrompt(node.Data.Role, node.Data.Prompt, apiKey, providerType)
-		latency := time.Since(start).Milliseconds()
+		// Build the prompt, including any run-level attachments
+		prompt, err := llm.AppendAttachments(node.Data.Prompt, opts.Attachments)
+		if err != nil {
+			return totalCost, nodeFailed(fmt.Errorf("node %s: %w", node.ID, err))
+		}
 
-		status := "SUCCESS"
-		var errMsg string
-		var inputTokens, outputTokens int
-		var cost float64
-		var promptHash string = "hash_placeholder"
+		// Execute Prompt, retrying transient failures if the flow or node asks for it
+		chain := chains[node.ID]
+		retry := nodeRetryConfig(graph.RetryConfig, node.Data.RetryConfig)
+		promptOpts := llm.PromptOptions{
+			SystemOverride:  node.Data.SystemPrompt,
+			MaxOutputTokens: node.Data.MaxOutputTokens,
+			Temperature:     node.Data.Temperature,
+			TopP:            node.Data.TopP,
+			ResponseFormat:  node.Data.ResponseFormat,
+		}
 
-		if err != nil {
-			status = "FAILED"
-			errMsg = err.Error()
-			log.Printf("Node %s execution failed: %v", node.ID, err)
-		} else {
-			inputTokens = resp.InputTokens
-			outputTokens = resp.OutputTokens
-			cost = resp.Cost
+		var output string
+		for attempt := 0; ; attempt++ {
+			if attempt > 0 {
+				wait := retry.backoff(attempt, err)
+				log.Warnf("Retrying node %s in %s (retry %d of %d)", node.ID, wait, attempt, retry.retries())
+				sleep(wait)
+			}
+
+			// 4. Log every call to token_ledger, so retries and fallbacks show up in the history
+			var resp *llm.LLMResponse
+			resp, _, err = gateway.ExecuteWithFallback(node.Data.Role, prompt, promptOpts, chain, nodeAPIKey(node, apiKey), func(call llm.FallbackAttempt) {
+				status := "SUCCESS"
+				var errMsg string
+				var in, out int
+				var callCost float64
+				if call.Err != nil {
+					status = "FAILED"
+					if llm.IsCapped(call.Err) {
+						status = "CAPPED"
+					} else if errors.Is(call.Err, llm.ErrMalformedJSON) {
+						status = "MALFORMED_JSON"
+					}
+					errMsg = call.Err.Error()
+					log.Errorf("Node %s execution failed on %s: %v", node.ID, call.Provider, call.Err)
+				}
+				if call.Response != nil {
+					in, out, callCost = call.Response.InputTokens, call.Response.OutputTokens, call.Response.Cost
+					if call.Response.Cached {
+						status = "CACHE_HIT"
+					}
+				}
+				logNodeAttempt(db, flowID, node, string(call.Provider), opts, prompt, in, out, callCost, call.Latency.Milliseconds(), status, errMsg)
+			})
+
+			// A malformed JSON answer failed the node but was still paid for
+			inputTokens, outputTokens, cost = 0, 0, 0
+			if resp != nil {
+				inputTokens = resp.InputTokens
+				outputTokens = resp.OutputTokens
+				cost = resp.Cost
+				totalCost += cost
+			}
+			if err == nil {
+				output = resp.Content
+			}
+
+			if err == nil || attempt >= retry.retries() || !retryable(err) {
+				break
+			}
 		}
 
-		// Broadcast NODE_COMPLETED (even if failed, we report the tokens used)
+		// A failed node broadcasts NODE_FAILED with the reason, still reporting the tokens used
+		if err != nil {
+			return totalCost, nodeFailed(fmt.Errorf("node %s failed: %w", node.ID, err))
+		}
 		if hub != nil {
-			hub.Broadcast(NewNodeCompletedMessage(flowID, node.ID, inputTokens, outputTokens, cost))
-		}
-
-		// 4. Log to token_ledger
-		insertQuery := `
-			INSERT INTO token_ledger (
-				flow_id, model_used, agent_role, prompt_hash, 
-				input_tokens, output_tokens, total_cost_usd, 
-				latency_ms, status, error_message
-			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-		`
-		_, dbErr := db.Exec(insertQuery,
-			fmt.Sprintf("%d", flowID),
-			node.Data.Provider,
-			node.Data.Role,
-			promptHash,
-			inputTokens,
-			outputTokens,
-			cost,
-			latency,
-			status,
-			errMsg,
-		)
-		if dbErr != nil {
-			log.Printf("Failed to log to ledger: %v", dbErr)
+			hub.Broadcast(NewNodeCompletedMessage(flowID, opts.RequestID, node.ID, inputTokens, outputTokens, cost))
 		}
+		events.record(EventNodeCompleted, node.ID, "", time.Since(nodeStart))
+		opts.reportNode(NodeResult{NodeID: node.ID, Status: "COMPLETED", Output: output, CostUSD: cost})
 
-		if err != nil {
-			return fmt.Errorf("node %s failed: %w", node.ID, err)
+		// Remember the node finished, so a resumed run can skip it
+		if err := saveNodeResult(db, flowID, node.ID, output, cost); err != nil {
+			log.Warnf("Flow %d: %v", flowID, err)
 		}
 	}
 
-	return nil
+	// Nothing left to resume once every node has finished
+	if err := clearNodeResults(db, flowID); err != nil {
+		log.Warnf("Flow %d: %v", flowID, err)
+	}
+	return totalCost, nil
+}
+
+// nodeAPIKey looks up provider keys for a node's fallback chain, reusing the key
+// already fetched for the node's own provider.
+func nodeAPIKey(node Node, primaryKey string) func(llm.ProviderType) (string, error) {
+	return func(provider llm.ProviderType) (string, error) {
+		if string(provider) == node.Data.Provider {
+			return primaryKey, nil
+		}
+		return security.GetAPIKey(string(provider))
+	}
+}
+
+// logNodeAttempt records one call made for a node in token_ledger, against the provider that handled it.
+// The prompt sent is recorded as its hash, and with Ledger.StorePrompts on also kept in
+// ledger_prompts; "" means nothing was sent.
+func logNodeAttempt(db *sql.DB, flowID int, node Node, provider string, opts ExecuteOptions, prompt string, inputTokens, outputTokens int, cost float64, latency int64, status, errMsg string) {
+	entry := data.TokenLedgerEntry{
+		FlowID:       fmt.Sprintf("%d", flowID),
+		ModelUsed:    provider,
+		AgentRole:    agents.CanonicalRole(node.Data.Role),
+		InputTokens:  inputTokens,
+		OutputTokens: outputTokens,
+		TotalCostUSD: cost,
+		LatencyMs:    int(latency),
+		Status:       status,
+		ErrorMessage: errMsg,
+		Environment:  opts.Environment,
+		RequestID:    opts.RequestID,
+		NodeID:       node.ID,
+	}
+	if prompt != "" {
+		systemPrompt, _ := llm.ResolveSystemPrompt(node.Data.Role, node.Data.SystemPrompt)
+		entry.PromptHash = llm.HashPrompt(systemPrompt, prompt)
+		entry.Prompt = &data.LedgerPrompt{SystemPrompt: systemPrompt, UserPrompt: prompt}
+	}
+
+	// LedgerService serializes writes, so concurrent runs don't contend for SQLite's write lock
+	id, err := data.NewLedgerService(db).Insert(entry)
+	if err != nil && id == 0 {
+		logging.ForRequest(opts.RequestID).Errorf("Failed to log to ledger: %v", err)
+	} else if err != nil {
+		logging.ForRequest(opts.RequestID).Warnf("Failed to store the prompt for the ledger: %v", err)
+	}
 }
 
 // ExecuteFlow runs the flow with the given ID (backwards compatible version without signaling)
@@ -2,10 +2,18 @@ package flows
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	_ "modernc.org/sqlite" // Use mattn/go-sqlite3
+	"github.com/mikejsmith1985/forge-orchestrator/internal/agents"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/budget"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/config"
 	"github.com/mikejsmith1985/forge-orchestrator/internal/data"
 	"github.com/mikejsmith1985/forge-orchestrator/internal/llm"
 	"github.com/mikejsmith1985/forge-orchestrator/internal/security"
@@ -102,6 +110,271 @@ func TestExecuteFlow(t *testing.T) {
 	}
 }
 
+// useFlowsConfig saves a config with the given flow settings in a temp config dir,
+// restoring the defaults when the test ends.
+func useFlowsConfig(t *testing.T, flowsCfg config.FlowsConfig) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	cfg := config.DefaultConfig()
+	cfg.Flows = flowsCfg
+	if err := config.Save(cfg); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	t.Cleanup(func() { config.Save(config.DefaultConfig()) })
+}
+
+// useInterNodeDelay configures the given delay and swaps the engine's sleep
+// for a fake clock that records each pause.
+func useInterNodeDelay(t *testing.T, delayMs int) *[]time.Duration {
+	useFlowsConfig(t, config.FlowsConfig{InterNodeDelayMs: delayMs})
+
+	var sleeps []time.Duration
+	originalSleep := sleep
+	sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+	t.Cleanup(func() { sleep = originalSleep })
+	return &sleeps
+}
+
+// insertThreeNodeFlow stores a flow with three agent nodes and one non-agent node.
+func insertThreeNodeFlow(t *testing.T, db *sql.DB) {
+	flowJSON := `{
+		"nodes": [
+			{"id": "1", "type": "agent", "data": {"role": "Architect", "prompt": "plan", "provider": "Anthropic"}},
+			{"id": "note", "type": "comment", "data": {}},
+			{"id": "2", "type": "agent", "data": {"role": "Implementation", "prompt": "code", "provider": "Anthropic"}},
+			{"id": "3", "type": "agent", "data": {"role": "Test", "prompt": "test", "provider": "Anthropic"}}
+		],
+		"edges": []
+	}`
+	if _, err := db.Exec(`INSERT INTO forge_flows (name, data, status) VALUES (?, ?, ?)`, "Delay Flow", flowJSON, "active"); err != nil {
+		t.Fatalf("Failed to insert flow: %v", err)
+	}
+}
+
+func TestExecuteFlow_InterNodeDelay(t *testing.T) {
+	keyring.MockInit()
+	security.SetAPIKey("Anthropic", "dummy-key")
+	sleeps := useInterNodeDelay(t, 250)
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(data.SQLiteSchema); err != nil {
+		t.Fatalf("Failed to init schema: %v", err)
+	}
+	insertThreeNodeFlow(t, db)
+
+	gateway := &llm.Gateway{AnthropicClient: &MockLLMProvider{ReturnValue: "ok"}, OpenAIClient: &MockLLMProvider{}}
+	if err := ExecuteFlow(1, db, gateway); err != nil {
+		t.Fatalf("ExecuteFlow failed: %v", err)
+	}
+
+	// Three agent nodes means two gaps; the comment node doesn't count.
+	if len(*sleeps) != 2 {
+		t.Fatalf("Expected 2 delays between 3 agent nodes, got %d", len(*sleeps))
+	}
+	for _, d := range *sleeps {
+		if d != 250*time.Millisecond {
+			t.Errorf("Expected 250ms delay, got %v", d)
+		}
+	}
+}
+
+func TestExecuteFlow_NoInterNodeDelayByDefault(t *testing.T) {
+	keyring.MockInit()
+	security.SetAPIKey("Anthropic", "dummy-key")
+	sleeps := useInterNodeDelay(t, 0)
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(data.SQLiteSchema); err != nil {
+		t.Fatalf("Failed to init schema: %v", err)
+	}
+	insertThreeNodeFlow(t, db)
+
+	gateway := &llm.Gateway{AnthropicClient: &MockLLMProvider{ReturnValue: "ok"}, OpenAIClient: &MockLLMProvider{}}
+	if err := ExecuteFlow(1, db, gateway); err != nil {
+		t.Fatalf("ExecuteFlow failed: %v", err)
+	}
+
+	if len(*sleeps) != 0 {
+		t.Errorf("Expected no delays when InterNodeDelayMs is 0, got %v", *sleeps)
+	}
+}
+
+func TestExecuteFlow_WithAttachments(t *testing.T) {
+	keyring.MockInit()
+	security.SetAPIKey("Anthropic", "dummy-key")
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(data.SQLiteSchema); err != nil {
+		t.Fatalf("Failed to init schema: %v", err)
+	}
+
+	flowJSON := `{"nodes": [{"id": "1", "type": "agent", "data": {"role": "Implementation", "prompt": "Fix the bug", "provider": "Anthropic"}}], "edges": []}`
+	if _, err := db.Exec(`INSERT INTO forge_flows (name, data, status) VALUES (?, ?, ?)`, "Attachment Flow", flowJSON, "active"); err != nil {
+		t.Fatalf("Failed to insert flow: %v", err)
+	}
+
+	mockProvider := &MockLLMProvider{ReturnValue: "fixed"}
+	gateway := &llm.Gateway{AnthropicClient: mockProvider, OpenAIClient: &MockLLMProvider{}}
+	opts := ExecuteOptions{Attachments: []llm.Attachment{{Filename: "bug.go", Content: "x := 1 / 0"}}}
+
+	if err := ExecuteFlowWithOptions(1, db, gateway, nil, nil, nil, opts); err != nil {
+		t.Fatalf("ExecuteFlowWithOptions failed: %v", err)
+	}
+
+	if !strings.HasPrefix(mockProvider.LastPrompt, "Fix the bug") {
+		t.Errorf("Expected node prompt first, got %q", mockProvider.LastPrompt)
+	}
+	if !strings.Contains(mockProvider.LastPrompt, `filename="bug.go"`) || !strings.Contains(mockProvider.LastPrompt, "x := 1 / 0") {
+		t.Errorf("Expected attachment in provider prompt, got %q", mockProvider.LastPrompt)
+	}
+}
+
+func TestExecuteFlow_NodeSystemPrompt(t *testing.T) {
+	keyring.MockInit()
+	security.SetAPIKey("Anthropic", "dummy-key")
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	cfg := config.DefaultConfig()
+	cfg.Ledger.StorePrompts = true
+	if err := config.Save(cfg); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	t.Cleanup(func() { config.Save(config.DefaultConfig()) })
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(data.SQLiteSchema); err != nil {
+		t.Fatalf("Failed to init schema: %v", err)
+	}
+
+	flowJSON := `{"nodes": [
+		{"id": "1", "type": "agent", "data": {"role": "Implementation", "prompt": "Fix the bug", "provider": "Anthropic", "system_prompt": "You are a terse reviewer."}},
+		{"id": "2", "type": "agent", "data": {"role": "Implementation", "prompt": "Fix the bug", "provider": "Anthropic"}}
+	], "edges": []}`
+	if _, err := db.Exec(`INSERT INTO forge_flows (name, data, status) VALUES (?, ?, ?)`, "System Prompt Flow", flowJSON, "active"); err != nil {
+		t.Fatalf("Failed to insert flow: %v", err)
+	}
+
+	var systems []string
+	mockProvider := &recordingProvider{send: func(system string) { systems = append(systems, system) }}
+	gateway := &llm.Gateway{AnthropicClient: mockProvider, OpenAIClient: &MockLLMProvider{}}
+
+	if err := ExecuteFlow(1, db, gateway); err != nil {
+		t.Fatalf("ExecuteFlow failed: %v", err)
+	}
+
+	persona, _ := agents.GetAgentPrompt("Implementation")
+	if len(systems) != 2 {
+		t.Fatalf("Expected 2 provider calls, got %d", len(systems))
+	}
+	if systems[0] != "You are a terse reviewer." {
+		t.Errorf("Expected the node's system prompt, got %q", systems[0])
+	}
+	if systems[1] != persona {
+		t.Errorf("Expected the Implementation persona without an override, got %q", systems[1])
+	}
+
+	// With store_prompts on, each call's prompt is kept with the system prompt it was sent
+	ledger := data.NewLedgerService(db)
+	first, err := ledger.GetPrompt(1)
+	if err != nil || first.SystemPrompt != "You are a terse reviewer." || first.UserPrompt != "Fix the bug" {
+		t.Errorf("Expected node 1's prompt and override to be stored, got %+v (%v)", first, err)
+	}
+	second, err := ledger.GetPrompt(2)
+	if err != nil || second.SystemPrompt != persona || second.UserPrompt != "Fix the bug" {
+		t.Errorf("Expected node 2's prompt and the role persona to be stored, got %+v (%v)", second, err)
+	}
+}
+
+// recordingProvider reports the system prompt of every call.
+type recordingProvider struct {
+	send func(system string)
+}
+
+func (p *recordingProvider) Send(system, user, key string) (string, int, int, error) {
+	p.send(system)
+	return "ok", 10, 20, nil
+}
+
+func TestExecuteFlow_CostCapStopsRun(t *testing.T) {
+	keyring.MockInit()
+	security.SetAPIKey("Anthropic", "dummy-key")
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(data.SQLiteSchema); err != nil {
+		t.Fatalf("Failed to init schema: %v", err)
+	}
+	insertThreeNodeFlow(t, db)
+
+	// Each mock call costs $0.00033, so a second node would take the run past $0.0005
+	if _, err := db.Exec(`UPDATE forge_flows SET max_cost_usd = 0.0005 WHERE id = 1`); err != nil {
+		t.Fatalf("Failed to set cap: %v", err)
+	}
+
+	signaler := NewDBSignaler(db)
+	gateway := &llm.Gateway{AnthropicClient: &MockLLMProvider{ReturnValue: "ok"}, OpenAIClient: &MockLLMProvider{}}
+	err = ExecuteFlowWithOptions(1, db, gateway, signaler, nil, nil, ExecuteOptions{})
+	if !errors.Is(err, budget.ErrBudgetExceeded) {
+		t.Fatalf("Expected ErrBudgetExceeded, got %v", err)
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM token_ledger").Scan(&count); err != nil {
+		t.Fatalf("Failed to query ledger: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected the run to stop after the first node, got %d ledger entries", count)
+	}
+
+	status, err := signaler.GetStatus(1)
+	if err != nil {
+		t.Fatalf("Failed to read status: %v", err)
+	}
+	if status.Status != budget.CodeBudgetExceeded {
+		t.Errorf("Expected status %s, got %s", budget.CodeBudgetExceeded, status.Status)
+	}
+}
+
+func TestExecuteFlow_CostCapNotReached(t *testing.T) {
+	keyring.MockInit()
+	security.SetAPIKey("Anthropic", "dummy-key")
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(data.SQLiteSchema); err != nil {
+		t.Fatalf("Failed to init schema: %v", err)
+	}
+	insertThreeNodeFlow(t, db)
+	if _, err := db.Exec(`UPDATE forge_flows SET max_cost_usd = 1 WHERE id = 1`); err != nil {
+		t.Fatalf("Failed to set cap: %v", err)
+	}
+
+	gateway := &llm.Gateway{AnthropicClient: &MockLLMProvider{ReturnValue: "ok"}, OpenAIClient: &MockLLMProvider{}}
+	if err := ExecuteFlowWithOptions(1, db, gateway, nil, nil, nil, ExecuteOptions{}); err != nil {
+		t.Fatalf("Expected a generous cap to let every node run, got %v", err)
+	}
+}
+
 // ========== ERROR HANDLING TESTS ==========
 
 func TestExecuteFlow_EmptyNodes(t *testing.T) {
@@ -307,3 +580,350 @@ func TestExecuteFlow_MalformedFlowData(t *testing.T) {
 		t.Errorf("error should mention parse failure: %v", err)
 	}
 }
+
+func TestExecuteFlow_LogsCanonicalRole(t *testing.T) {
+	flowJSON := `{"nodes": [{"id": "1", "type": "agent", "data": {"role": "coder", "prompt": "code", "provider": "Anthropic"}}], "edges": []}`
+	keyring.MockInit()
+	security.SetAPIKey("Anthropic", "dummy-key")
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(data.SQLiteSchema); err != nil {
+		t.Fatalf("Failed to init schema: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO forge_flows (name, data, status) VALUES (?, ?, ?)`, "Alias Flow", flowJSON, "active"); err != nil {
+		t.Fatalf("Failed to insert flow: %v", err)
+	}
+
+	gateway := &llm.Gateway{AnthropicClient: &MockLLMProvider{ReturnValue: "ok"}, OpenAIClient: &MockLLMProvider{}}
+	if err := ExecuteFlow(1, db, gateway); err != nil {
+		t.Fatalf("ExecuteFlow failed: %v", err)
+	}
+
+	var role, promptHash string
+	if err := db.QueryRow("SELECT agent_role, prompt_hash FROM token_ledger").Scan(&role, &promptHash); err != nil {
+		t.Fatalf("Failed to query ledger: %v", err)
+	}
+	if role != "Implementation" {
+		t.Errorf("Expected the alias to be logged as Implementation, got %q", role)
+	}
+	systemPrompt, _ := llm.ResolveSystemPrompt("coder", "")
+	if want := llm.HashPrompt(systemPrompt, "code"); promptHash != want {
+		t.Errorf("Expected prompt hash %s, got %q", want, promptHash)
+	}
+}
+
+func TestExecuteFlow_SharesRequestIDAcrossRun(t *testing.T) {
+	flowJSON := `{"nodes": [
+		{"id": "1", "type": "agent", "data": {"role": "Architect", "prompt": "one", "provider": "Anthropic"}},
+		{"id": "2", "type": "agent", "data": {"role": "Test", "prompt": "two", "provider": "Anthropic"}}
+	], "edges": []}`
+	keyring.MockInit()
+	security.SetAPIKey("Anthropic", "dummy-key")
+	useInterNodeDelay(t, 0)
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(data.SQLiteSchema); err != nil {
+		t.Fatalf("Failed to init schema: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO forge_flows (name, data, status) VALUES (?, ?, ?)`, "Request Flow", flowJSON, "active"); err != nil {
+		t.Fatalf("Failed to insert flow: %v", err)
+	}
+
+	gateway := &llm.Gateway{AnthropicClient: &MockLLMProvider{ReturnValue: "ok"}, OpenAIClient: &MockLLMProvider{}}
+	hub := &MockBroadcaster{}
+	if err := ExecuteFlowWithOptions(1, db, gateway, nil, nil, hub, ExecuteOptions{RequestID: "run-1"}); err != nil {
+		t.Fatalf("First run failed: %v", err)
+	}
+	if err := ExecuteFlowWithOptions(1, db, gateway, nil, nil, nil, ExecuteOptions{}); err != nil {
+		t.Fatalf("Second run failed: %v", err)
+	}
+
+	rows, err := db.Query("SELECT request_id FROM token_ledger ORDER BY id")
+	if err != nil {
+		t.Fatalf("Failed to query ledger: %v", err)
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		rows.Scan(&id)
+		ids = append(ids, id)
+	}
+	if len(ids) != 4 || ids[0] != "run-1" || ids[1] != "run-1" {
+		t.Fatalf("Expected both of the first run's entries to carry run-1, got %v", ids)
+	}
+	if ids[2] == "" || ids[2] == "run-1" || ids[2] != ids[3] {
+		t.Errorf("Expected the second run to share a new generated ID, got %v", ids)
+	}
+
+	// Every broadcast of the first run carries its ID too
+	for _, raw := range hub.Messages {
+		var msg struct {
+			Type    string `json:"type"`
+			Payload struct {
+				RequestID string `json:"requestId"`
+			} `json:"payload"`
+		}
+		json.Unmarshal(raw, &msg)
+		if msg.Payload.RequestID != "run-1" {
+			t.Errorf("Expected %s to carry requestId run-1, got %q", msg.Type, msg.Payload.RequestID)
+		}
+	}
+	if len(hub.Messages) == 0 {
+		t.Error("Expected broadcasts from the first run")
+	}
+}
+
+func TestExecuteFlow_MissingKeyPolicy(t *testing.T) {
+	for _, mode := range []string{MissingKeySkip, MissingKeyFail} {
+		t.Run(mode, func(t *testing.T) {
+			// Node 2 uses OpenAI, which has no key
+			flowJSON := `{"onMissingKey": "` + mode + `", "nodes": [
+				{"id": "1", "type": "agent", "data": {"role": "Architect", "prompt": "one", "provider": "Anthropic"}},
+				{"id": "2", "type": "agent", "data": {"role": "Test", "prompt": "two", "provider": "OpenAI"}},
+				{"id": "3", "type": "agent", "data": {"role": "Implementation", "prompt": "three", "provider": "Anthropic"}}
+			], "edges": []}`
+			keyring.MockInit()
+			security.SetAPIKey("Anthropic", "dummy-key")
+			useInterNodeDelay(t, 0)
+
+			db, err := sql.Open("sqlite", ":memory:")
+			if err != nil {
+				t.Fatalf("Failed to open in-memory db: %v", err)
+			}
+			defer db.Close()
+			if _, err := db.Exec(data.SQLiteSchema); err != nil {
+				t.Fatalf("Failed to init schema: %v", err)
+			}
+			if _, err := db.Exec(`INSERT INTO forge_flows (name, data, status) VALUES (?, ?, ?)`, "Partial Flow", flowJSON, "active"); err != nil {
+				t.Fatalf("Failed to insert flow: %v", err)
+			}
+
+			openAI := &MockLLMProvider{ReturnValue: "never"}
+			gateway := &llm.Gateway{AnthropicClient: &MockLLMProvider{ReturnValue: "ok"}, OpenAIClient: openAI}
+			var results []string
+			err = ExecuteFlowWithOptions(1, db, gateway, nil, nil, nil, ExecuteOptions{
+				OnNodeResult: func(r NodeResult) { results = append(results, r.NodeID+":"+r.Status) },
+			})
+			if openAI.Called {
+				t.Error("Expected no call to the provider without a key")
+			}
+
+			var statuses []string
+			rows, _ := db.Query("SELECT model_used || ':' || status FROM token_ledger ORDER BY id")
+			defer rows.Close()
+			for rows.Next() {
+				var s string
+				rows.Scan(&s)
+				statuses = append(statuses, s)
+			}
+
+			if mode == MissingKeySkip {
+				if err != nil {
+					t.Fatalf("Expected the run to carry on past the missing key, got %v", err)
+				}
+				if got := strings.Join(results, ","); got != "1:COMPLETED,2:SKIPPED,3:COMPLETED" {
+					t.Errorf("Expected node 2 skipped between two completed nodes, got %s", got)
+				}
+				if got := strings.Join(statuses, ","); got != "Anthropic:SUCCESS,OpenAI:SKIPPED,Anthropic:SUCCESS" {
+					t.Errorf("Expected a SKIPPED ledger entry for node 2, got %s", got)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), "API key") {
+				t.Fatalf("Expected the run to stop on the missing key, got %v", err)
+			}
+			if got := strings.Join(results, ","); got != "1:COMPLETED,2:FAILED" {
+				t.Errorf("Expected the run to stop at node 2, got %s", got)
+			}
+			if got := strings.Join(statuses, ","); got != "Anthropic:SUCCESS" {
+				t.Errorf("Expected only node 1 in the ledger, got %s", got)
+			}
+		})
+	}
+}
+
+func TestExecuteFlow_NodeMaxOutputTokens(t *testing.T) {
+	flowJSON := `{"nodes": [
+		{"id": "1", "type": "agent", "data": {"role": "Architect", "prompt": "short answer", "provider": "OpenAI", "maxOutputTokens": 64}}
+	], "edges": []}`
+	keyring.MockInit()
+	security.SetAPIKey("OpenAI", "dummy-key")
+	useInterNodeDelay(t, 0)
+
+	var maxTokens interface{}
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		maxTokens = body["max_tokens"]
+		w.Write([]byte(`{"choices": [{"message": {"content": "ok"}}]}`))
+	}))
+	defer provider.Close()
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(data.SQLiteSchema); err != nil {
+		t.Fatalf("Failed to init schema: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO forge_flows (name, data, status) VALUES (?, ?, ?)`, "Capped Flow", flowJSON, "active"); err != nil {
+		t.Fatalf("Failed to insert flow: %v", err)
+	}
+
+	gateway := &llm.Gateway{AnthropicClient: &MockLLMProvider{}, OpenAIClient: &llm.OpenAIClient{Endpoint: provider.URL}}
+	if err := ExecuteFlowWithOptions(1, db, gateway, nil, nil, nil, ExecuteOptions{}); err != nil {
+		t.Fatalf("Flow failed: %v", err)
+	}
+	if maxTokens != float64(64) {
+		t.Errorf("Expected the node's max_tokens of 64 in the request body, got %v", maxTokens)
+	}
+}
+
+func TestExecuteFlow_NodeSampling(t *testing.T) {
+	flowJSON := `{"nodes": [
+		{"id": "1", "type": "agent", "data": {"role": "Architect", "prompt": "brainstorm", "provider": "OpenAI", "temperature": 1.2, "topP": 0.5}}
+	], "edges": []}`
+	keyring.MockInit()
+	security.SetAPIKey("OpenAI", "dummy-key")
+	useInterNodeDelay(t, 0)
+
+	var body map[string]interface{}
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Write([]byte(`{"choices": [{"message": {"content": "ok"}}]}`))
+	}))
+	defer provider.Close()
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(data.SQLiteSchema); err != nil {
+		t.Fatalf("Failed to init schema: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO forge_flows (name, data, status) VALUES (?, ?, ?)`, "Sampling Flow", flowJSON, "active"); err != nil {
+		t.Fatalf("Failed to insert flow: %v", err)
+	}
+
+	gateway := &llm.Gateway{AnthropicClient: &MockLLMProvider{}, OpenAIClient: &llm.OpenAIClient{Endpoint: provider.URL}}
+	if err := ExecuteFlowWithOptions(1, db, gateway, nil, nil, nil, ExecuteOptions{}); err != nil {
+		t.Fatalf("Flow failed: %v", err)
+	}
+	if body["temperature"] != 1.2 || body["top_p"] != 0.5 {
+		t.Errorf("Expected the node's temperature and top_p in the request body, got %v", body)
+	}
+}
+
+func TestExecuteFlow_MalformedJSONResponse(t *testing.T) {
+	flowJSON := `{"nodes": [
+		{"id": "1", "type": "agent", "data": {"role": "Architect", "prompt": "write the contract", "provider": "OpenAI", "responseFormat": "json"}}
+	], "edges": []}`
+	keyring.MockInit()
+	security.SetAPIKey("OpenAI", "dummy-key")
+	useInterNodeDelay(t, 0)
+
+	var body map[string]interface{}
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Write([]byte(`{"choices": [{"message": {"content": "Sure! Here is the contract: {\"api\": "}}], "usage": {"prompt_tokens": 20, "completion_tokens": 9}}`))
+	}))
+	defer provider.Close()
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(data.SQLiteSchema); err != nil {
+		t.Fatalf("Failed to init schema: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO forge_flows (name, data, status) VALUES (?, ?, ?)`, "Contract Flow", flowJSON, "active"); err != nil {
+		t.Fatalf("Failed to insert flow: %v", err)
+	}
+
+	gateway := &llm.Gateway{AnthropicClient: &MockLLMProvider{}, OpenAIClient: &llm.OpenAIClient{Endpoint: provider.URL}}
+	err = ExecuteFlowWithOptions(1, db, gateway, nil, nil, nil, ExecuteOptions{})
+	if !errors.Is(err, llm.ErrMalformedJSON) {
+		t.Fatalf("Expected the flow to fail with ErrMalformedJSON, got %v", err)
+	}
+	if format, _ := body["response_format"].(map[string]interface{}); format["type"] != "json_object" {
+		t.Errorf("Expected JSON mode in the request body, got %v", body)
+	}
+
+	// The call is logged as malformed, with the tokens it was billed for
+	var status string
+	var outputTokens int
+	if err := db.QueryRow(`SELECT status, output_tokens FROM token_ledger WHERE flow_id = '1'`).Scan(&status, &outputTokens); err != nil {
+		t.Fatalf("Failed to read ledger entry: %v", err)
+	}
+	if status != "MALFORMED_JSON" || outputTokens != 9 {
+		t.Errorf("Expected a MALFORMED_JSON entry with 9 output tokens, got %s with %d", status, outputTokens)
+	}
+}
+
+func TestExecuteFlow_CacheHitsLogged(t *testing.T) {
+	flowJSON := `{"nodes": [
+		{"id": "1", "type": "agent", "data": {"role": "Architect", "prompt": "plan", "provider": "OpenAI"}}
+	], "edges": []}`
+	keyring.MockInit()
+	security.SetAPIKey("OpenAI", "dummy-key")
+	useInterNodeDelay(t, 0)
+	cfg, _ := config.Get()
+	cfg.Cache.Enabled = true
+	if err := config.Save(cfg); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(data.SQLiteSchema); err != nil {
+		t.Fatalf("Failed to init schema: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO forge_flows (name, data, status) VALUES (?, ?, ?)`, "Cached Flow", flowJSON, "active"); err != nil {
+		t.Fatalf("Failed to insert flow: %v", err)
+	}
+
+	provider := &MockLLMProvider{ReturnValue: "the plan"}
+	gateway := &llm.Gateway{AnthropicClient: &MockLLMProvider{}, OpenAIClient: provider}
+	for run := 0; run < 2; run++ {
+		provider.Called = false
+		if err := ExecuteFlowWithOptions(1, db, gateway, nil, nil, nil, ExecuteOptions{}); err != nil {
+			t.Fatalf("Run %d failed: %v", run+1, err)
+		}
+	}
+	if provider.Called {
+		t.Error("Expected the second run to be answered from the cache")
+	}
+
+	var statuses []string
+	var costs []float64
+	rows, err := db.Query(`SELECT status, total_cost_usd FROM token_ledger ORDER BY id`)
+	if err != nil {
+		t.Fatalf("Failed to read ledger: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var status string
+		var cost float64
+		rows.Scan(&status, &cost)
+		statuses, costs = append(statuses, status), append(costs, cost)
+	}
+	if len(statuses) != 2 || statuses[0] != "SUCCESS" || statuses[1] != "CACHE_HIT" || costs[1] != 0 {
+		t.Errorf("Expected a SUCCESS then a free CACHE_HIT entry, got %v costing %v", statuses, costs)
+	}
+}
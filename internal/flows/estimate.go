@@ -0,0 +1,55 @@
+package flows
+
+import (
+	"fmt"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/llm"
+)
+
+// NodeEstimate is the projected input size and cost of one agent node.
+type NodeEstimate struct {
+	NodeID      string  `json:"node_id"`
+	Label       string  `json:"label,omitempty"`
+	Role        string  `json:"role"`
+	Provider    string  `json:"provider"`
+	InputTokens int     `json:"input_tokens"`
+	CostUSD     float64 `json:"cost_usd"`
+}
+
+// FlowEstimate is the projected cost of running a flow once.
+// Only input tokens are counted, since responses can't be sized in advance.
+type FlowEstimate struct {
+	FlowID           int            `json:"flow_id"`
+	Nodes            []NodeEstimate `json:"nodes"`
+	InputTokens      int            `json:"input_tokens"`
+	EstimatedCostUSD float64        `json:"estimated_cost_usd"`
+}
+
+// EstimateGraph projects the cost of every agent node in the graph, in the order
+// the engine would run them, pricing each node at its own provider's rate.
+// No provider is called.
+func EstimateGraph(graph FlowGraph) (*FlowEstimate, error) {
+	estimate := &FlowEstimate{Nodes: []NodeEstimate{}}
+	for _, node := range graph.Nodes {
+		if node.Type != "agent" {
+			continue // The engine skips these too
+		}
+
+		prompt, err := llm.EstimatePromptWithSystem(node.Data.Role, node.Data.SystemPrompt, node.Data.Prompt, llm.ProviderType(node.Data.Provider))
+		if err != nil {
+			return nil, fmt.Errorf("node %s: %w", node.ID, err)
+		}
+
+		estimate.Nodes = append(estimate.Nodes, NodeEstimate{
+			NodeID:      node.ID,
+			Label:       node.Data.Label,
+			Role:        node.Data.Role,
+			Provider:    node.Data.Provider,
+			InputTokens: prompt.InputTokens,
+			CostUSD:     prompt.CostUSD,
+		})
+		estimate.InputTokens += prompt.InputTokens
+		estimate.EstimatedCostUSD += prompt.CostUSD
+	}
+	return estimate, nil
+}
@@ -0,0 +1,91 @@
+package flows
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/logging"
+)
+
+// Event types recorded in flow_events. Most match the WebSocket messages of the same name.
+const (
+	EventFlowStarted   = "FLOW_STARTED"
+	EventFlowCompleted = "FLOW_COMPLETED"
+	EventFlowFailed    = "FLOW_FAILED"
+	EventNodeStarted   = "NODE_STARTED"
+	EventNodeCompleted = "NODE_COMPLETED"
+	EventNodeFailed    = "NODE_FAILED"
+	EventNodeSkipped   = "NODE_SKIPPED" // finished in an earlier run that this one resumed, or had no API key
+)
+
+// FlowEvent is one entry in a flow's audit trail.
+type FlowEvent struct {
+	ID         int64     `json:"id"`
+	FlowID     int       `json:"flowId"`
+	RunID      string    `json:"runId"`
+	Type       string    `json:"type"`
+	NodeID     string    `json:"nodeId,omitempty"`
+	Message    string    `json:"message,omitempty"`
+	DurationMs int64     `json:"durationMs"` // how long the node or run took, on events that end one
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// eventLog writes the events of one flow run to flow_events.
+// Unlike the WebSocket broadcasts, these outlive the run, so a failure can be examined afterwards.
+type eventLog struct {
+	db     *sql.DB
+	flowID int
+	runID  string
+}
+
+// newEventLog starts the audit trail for a run of the flow. The run's request ID is its run ID.
+func newEventLog(db *sql.DB, flowID int, runID string) *eventLog {
+	return &eventLog{db: db, flowID: flowID, runID: runID}
+}
+
+// record writes one event. A failed write is logged rather than failing the run.
+func (l *eventLog) record(eventType, nodeID, message string, duration time.Duration) {
+	if l == nil || l.db == nil {
+		return
+	}
+	query := `INSERT INTO flow_events (flow_id, run_id, event_type, node_id, message, duration_ms, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`
+	if _, err := l.db.Exec(query, l.flowID, l.runID, eventType, nodeID, message, duration.Milliseconds(), time.Now().UTC()); err != nil {
+		logging.ForRequest(l.runID).Warnf("Flow %d: failed to record %s event: %v", l.flowID, eventType, err)
+	}
+}
+
+// ListFlowEvents returns up to limit of the flow's most recent events, oldest first.
+// With a runID, only that run's events are returned.
+func ListFlowEvents(db *sql.DB, flowID int, runID string, limit int) ([]FlowEvent, error) {
+	query := `
+		SELECT id, flow_id, run_id, event_type, COALESCE(node_id, ''), COALESCE(message, ''), duration_ms, created_at
+		FROM flow_events
+		WHERE flow_id = ? AND (? = '' OR run_id = ?)
+		ORDER BY id DESC
+		LIMIT ?
+	`
+	rows, err := db.Query(query, flowID, runID, runID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list flow events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []FlowEvent{}
+	for rows.Next() {
+		var e FlowEvent
+		if err := rows.Scan(&e.ID, &e.FlowID, &e.RunID, &e.Type, &e.NodeID, &e.Message, &e.DurationMs, &e.Timestamp); err != nil {
+			return nil, fmt.Errorf("failed to scan flow event: %w", err)
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list flow events: %w", err)
+	}
+
+	// Newest were read first so the limit keeps the latest runs; hand them back in order
+	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+		events[i], events[j] = events[j], events[i]
+	}
+	return events, nil
+}
@@ -0,0 +1,105 @@
+package flows
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/data"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/llm"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/security"
+	"github.com/zalando/go-keyring"
+)
+
+func eventTypes(events []FlowEvent) []string {
+	types := make([]string, len(events))
+	for i, e := range events {
+		types[i] = e.Type
+		if e.NodeID != "" {
+			types[i] += ":" + e.NodeID
+		}
+	}
+	return types
+}
+
+func assertEventTypes(t *testing.T, events []FlowEvent, want []string) {
+	t.Helper()
+	got := eventTypes(events)
+	if len(got) != len(want) {
+		t.Fatalf("Expected events %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Expected events %v, got %v", want, got)
+		}
+	}
+}
+
+func TestExecuteFlow_RecordsEvents(t *testing.T) {
+	keyring.MockInit()
+	security.SetAPIKey("Anthropic", "dummy-key")
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(data.SQLiteSchema); err != nil {
+		t.Fatalf("Failed to init schema: %v", err)
+	}
+
+	flowJSON := `{"nodes": [
+		{"id": "1", "type": "agent", "data": {"role": "Architect", "prompt": "one", "provider": "Anthropic"}},
+		{"id": "2", "type": "agent", "data": {"role": "Test", "prompt": "two", "provider": "Anthropic"}}
+	], "edges": []}`
+	if _, err := db.Exec(`INSERT INTO forge_flows (name, data, status) VALUES (?, ?, ?)`, "Event Flow", flowJSON, "active"); err != nil {
+		t.Fatalf("Failed to insert flow: %v", err)
+	}
+
+	provider := &promptCountingProvider{calls: map[string]int{}, failing: map[string]bool{"two": true}}
+	gateway := &llm.Gateway{AnthropicClient: provider, OpenAIClient: &MockLLMProvider{}}
+
+	// A failing run, then a resumed run that completes
+	if err := ExecuteFlowWithOptions(1, db, gateway, nil, nil, nil, ExecuteOptions{}); err == nil {
+		t.Fatal("Expected the first run to fail on node 2")
+	}
+	provider.failing = map[string]bool{}
+	if err := ExecuteFlowWithOptions(1, db, gateway, nil, nil, nil, ExecuteOptions{Resume: true}); err != nil {
+		t.Fatalf("Expected the resumed run to succeed, got %v", err)
+	}
+
+	events, err := ListFlowEvents(db, 1, "", 100)
+	if err != nil {
+		t.Fatalf("ListFlowEvents: %v", err)
+	}
+	assertEventTypes(t, events, []string{
+		"FLOW_STARTED", "NODE_STARTED:1", "NODE_COMPLETED:1", "NODE_STARTED:2", "NODE_FAILED:2", "FLOW_FAILED",
+		"FLOW_STARTED", "NODE_SKIPPED:1", "NODE_STARTED:2", "NODE_COMPLETED:2", "FLOW_COMPLETED",
+	})
+
+	firstRun, secondRun := events[0].RunID, events[6].RunID
+	if firstRun == "" || firstRun == secondRun {
+		t.Errorf("Expected each run to have its own run ID, got %q and %q", firstRun, secondRun)
+	}
+	for _, e := range events {
+		if e.Timestamp.IsZero() {
+			t.Errorf("Expected a timestamp on %s", e.Type)
+		}
+	}
+	if events[4].Message == "" {
+		t.Error("Expected NODE_FAILED to carry the error")
+	}
+
+	// Filtering by run returns just that run's events
+	runEvents, err := ListFlowEvents(db, 1, secondRun, 100)
+	if err != nil {
+		t.Fatalf("ListFlowEvents: %v", err)
+	}
+	assertEventTypes(t, runEvents, []string{"FLOW_STARTED", "NODE_SKIPPED:1", "NODE_STARTED:2", "NODE_COMPLETED:2", "FLOW_COMPLETED"})
+
+	// The limit keeps the latest events, still oldest first
+	latest, err := ListFlowEvents(db, 1, "", 2)
+	if err != nil {
+		t.Fatalf("ListFlowEvents: %v", err)
+	}
+	assertEventTypes(t, latest, []string{"NODE_COMPLETED:2", "FLOW_COMPLETED"})
+}
@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 const statusDir = ".forge/status"
@@ -57,3 +60,31 @@ func (f *FileSignaler) GetStatus(flowID int) (*FlowStatus, error) {
 
 	return &status, nil
 }
+
+// ListStatuses reads every status file in the base directory, ordered by flow ID
+func (f *FileSignaler) ListStatuses() ([]FlowStatus, error) {
+	entries, err := os.ReadDir(f.baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read status directory: %w", err)
+	}
+
+	statuses := []FlowStatus{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		flowID, err := strconv.Atoi(strings.TrimSuffix(name, ".json"))
+		if err != nil {
+			continue // Not a status file
+		}
+		status, err := f.GetStatus(flowID)
+		if err != nil {
+			continue // Skip unreadable files rather than failing the whole list
+		}
+		statuses = append(statuses, *status)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].FlowID < statuses[j].FlowID })
+	return statuses, nil
+}
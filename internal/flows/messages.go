@@ -5,7 +5,8 @@ import (
 	"time"
 )
 
-// FlowMessage represents a WebSocket message for flow events
+// FlowMessage represents a WebSocket message for flow events.
+// Every payload carries the run's requestId, which also tags its ledger rows and log lines.
 type FlowMessage struct {
 	Type    string      `json:"type"`
 	Payload interface{} `json:"payload"`
@@ -14,12 +15,14 @@ type FlowMessage struct {
 // FlowStartedPayload is sent when a flow begins execution
 type FlowStartedPayload struct {
 	FlowID    int       `json:"flowId"`
+	RequestID string    `json:"requestId,omitempty"`
 	Timestamp time.Time `json:"timestamp"`
 }
 
 // NodeStartedPayload is sent before a node executes
 type NodeStartedPayload struct {
 	FlowID    int       `json:"flowId"`
+	RequestID string    `json:"requestId,omitempty"`
 	NodeID    string    `json:"nodeId"`
 	Label     string    `json:"label"`
 	Timestamp time.Time `json:"timestamp"`
@@ -28,6 +31,7 @@ type NodeStartedPayload struct {
 // NodeCompletedPayload is sent after a node executes
 type NodeCompletedPayload struct {
 	FlowID       int       `json:"flowId"`
+	RequestID    string    `json:"requestId,omitempty"`
 	NodeID       string    `json:"nodeId"`
 	InputTokens  int       `json:"inputTokens"`
 	OutputTokens int       `json:"outputTokens"`
@@ -35,26 +39,44 @@ type NodeCompletedPayload struct {
 	Timestamp    time.Time `json:"timestamp"`
 }
 
+// NodeFailedPayload is sent instead of NodeCompletedPayload when a node fails, with the
+// reason and whatever its failed calls were billed (zero if it failed before calling out)
+type NodeFailedPayload struct {
+	FlowID       int       `json:"flowId"`
+	RequestID    string    `json:"requestId,omitempty"`
+	NodeID       string    `json:"nodeId"`
+	Error        string    `json:"error"`
+	InputTokens  int       `json:"inputTokens"`
+	OutputTokens int       `json:"outputTokens"`
+	Cost         float64   `json:"cost"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
 // FlowCompletedPayload is sent when a flow finishes successfully
 type FlowCompletedPayload struct {
 	FlowID        int       `json:"flowId"`
+	RequestID     string    `json:"requestId,omitempty"`
 	Timestamp     time.Time `json:"timestamp"`
 	ExecutionTime int64     `json:"executionTimeMs"`
+	Cost          *RunCost  `json:"cost,omitempty"` // per-node and total tokens and cost of the run
 }
 
 // FlowFailedPayload is sent when a flow fails
 type FlowFailedPayload struct {
 	FlowID    int       `json:"flowId"`
+	RequestID string    `json:"requestId,omitempty"`
 	Timestamp time.Time `json:"timestamp"`
 	Error     string    `json:"error"`
+	Cost      *RunCost  `json:"cost,omitempty"` // what the run spent before it failed
 }
 
 // NewFlowStartedMessage creates a FLOW_STARTED message
-func NewFlowStartedMessage(flowID int) []byte {
+func NewFlowStartedMessage(flowID int, requestID string) []byte {
 	msg := FlowMessage{
 		Type: "FLOW_STARTED",
 		Payload: FlowStartedPayload{
 			FlowID:    flowID,
+			RequestID: requestID,
 			Timestamp: time.Now(),
 		},
 	}
@@ -63,11 +85,12 @@ func NewFlowStartedMessage(flowID int) []byte {
 }
 
 // NewNodeStartedMessage creates a NODE_STARTED message
-func NewNodeStartedMessage(flowID int, nodeID, label string) []byte {
+func NewNodeStartedMessage(flowID int, requestID, nodeID, label string) []byte {
 	msg := FlowMessage{
 		Type: "NODE_STARTED",
 		Payload: NodeStartedPayload{
 			FlowID:    flowID,
+			RequestID: requestID,
 			NodeID:    nodeID,
 			Label:     label,
 			Timestamp: time.Now(),
@@ -78,12 +101,32 @@ func NewNodeStartedMessage(flowID int, nodeID, label string) []byte {
 }
 
 // NewNodeCompletedMessage creates a NODE_COMPLETED message
-func NewNodeCompletedMessage(flowID int, nodeID string, inputTokens, outputTokens int, cost float64) []byte {
+func NewNodeCompletedMessage(flowID int, requestID, nodeID string, inputTokens, outputTokens int, cost float64) []byte {
 	msg := FlowMessage{
 		Type: "NODE_COMPLETED",
 		Payload: NodeCompletedPayload{
 			FlowID:       flowID,
+			RequestID:    requestID,
+			NodeID:       nodeID,
+			InputTokens:  inputTokens,
+			OutputTokens: outputTokens,
+			Cost:         cost,
+			Timestamp:    time.Now(),
+		},
+	}
+	data, _ := json.Marshal(msg)
+	return data
+}
+
+// NewNodeFailedMessage creates a NODE_FAILED message
+func NewNodeFailedMessage(flowID int, requestID, nodeID, err string, inputTokens, outputTokens int, cost float64) []byte {
+	msg := FlowMessage{
+		Type: "NODE_FAILED",
+		Payload: NodeFailedPayload{
+			FlowID:       flowID,
+			RequestID:    requestID,
 			NodeID:       nodeID,
+			Error:        err,
 			InputTokens:  inputTokens,
 			OutputTokens: outputTokens,
 			Cost:         cost,
@@ -95,13 +138,15 @@ func NewNodeCompletedMessage(flowID int, nodeID string, inputTokens, outputToken
 }
 
 // NewFlowCompletedMessage creates a FLOW_COMPLETED message
-func NewFlowCompletedMessage(flowID int, executionTimeMs int64) []byte {
+func NewFlowCompletedMessage(flowID int, requestID string, executionTimeMs int64, cost *RunCost) []byte {
 	msg := FlowMessage{
 		Type: "FLOW_COMPLETED",
 		Payload: FlowCompletedPayload{
 			FlowID:        flowID,
+			RequestID:     requestID,
 			Timestamp:     time.Now(),
 			ExecutionTime: executionTimeMs,
+			Cost:          cost,
 		},
 	}
 	data, _ := json.Marshal(msg)
@@ -109,13 +154,15 @@ func NewFlowCompletedMessage(flowID int, executionTimeMs int64) []byte {
 }
 
 // NewFlowFailedMessage creates a FLOW_FAILED message
-func NewFlowFailedMessage(flowID int, err string) []byte {
+func NewFlowFailedMessage(flowID int, requestID, err string, cost *RunCost) []byte {
 	msg := FlowMessage{
 		Type: "FLOW_FAILED",
 		Payload: FlowFailedPayload{
 			FlowID:    flowID,
+			RequestID: requestID,
 			Timestamp: time.Now(),
 			Error:     err,
+			Cost:      cost,
 		},
 	}
 	data, _ := json.Marshal(msg)
@@ -1,13 +1,20 @@
 package flows
 
 import (
+	"database/sql"
 	"encoding/json"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/data"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/llm"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/security"
+	"github.com/zalando/go-keyring"
 )
 
 func TestNewFlowStartedMessage(t *testing.T) {
-	msg := NewFlowStartedMessage(123)
+	msg := NewFlowStartedMessage(123, "req-1")
 
 	var result FlowMessage
 	if err := json.Unmarshal(msg, &result); err != nil {
@@ -26,10 +33,13 @@ func TestNewFlowStartedMessage(t *testing.T) {
 	if _, ok := payload["timestamp"]; !ok {
 		t.Error("Expected timestamp in payload")
 	}
+	if payload["requestId"] != "req-1" {
+		t.Errorf("Expected requestId req-1, got %v", payload["requestId"])
+	}
 }
 
 func TestNewNodeStartedMessage(t *testing.T) {
-	msg := NewNodeStartedMessage(123, "node-1", "Test Node")
+	msg := NewNodeStartedMessage(123, "req-1", "node-1", "Test Node")
 
 	var result FlowMessage
 	if err := json.Unmarshal(msg, &result); err != nil {
@@ -53,7 +63,7 @@ func TestNewNodeStartedMessage(t *testing.T) {
 }
 
 func TestNewNodeCompletedMessage(t *testing.T) {
-	msg := NewNodeCompletedMessage(123, "node-1", 100, 50, 0.0025)
+	msg := NewNodeCompletedMessage(123, "req-1", "node-1", 100, 50, 0.0025)
 
 	var result FlowMessage
 	if err := json.Unmarshal(msg, &result); err != nil {
@@ -79,8 +89,29 @@ func TestNewNodeCompletedMessage(t *testing.T) {
 	}
 }
 
+func TestNewNodeFailedMessage(t *testing.T) {
+	msg := NewNodeFailedMessage(123, "req-1", "node-1", "node node-1 failed: upstream unavailable", 100, 0, 0.0003)
+
+	var result FlowMessage
+	if err := json.Unmarshal(msg, &result); err != nil {
+		t.Fatalf("Failed to unmarshal message: %v", err)
+	}
+
+	if result.Type != "NODE_FAILED" {
+		t.Errorf("Expected type NODE_FAILED, got %s", result.Type)
+	}
+
+	payload := result.Payload.(map[string]interface{})
+	if payload["nodeId"] != "node-1" || payload["error"] != "node node-1 failed: upstream unavailable" {
+		t.Errorf("Expected the node and its error, got %v", payload)
+	}
+	if int(payload["inputTokens"].(float64)) != 100 || payload["cost"].(float64) != 0.0003 {
+		t.Errorf("Expected the billed tokens and cost, got %v", payload)
+	}
+}
+
 func TestNewFlowCompletedMessage(t *testing.T) {
-	msg := NewFlowCompletedMessage(123, 5000)
+	msg := NewFlowCompletedMessage(123, "req-1", 5000, nil)
 
 	var result FlowMessage
 	if err := json.Unmarshal(msg, &result); err != nil {
@@ -101,7 +132,7 @@ func TestNewFlowCompletedMessage(t *testing.T) {
 }
 
 func TestNewFlowFailedMessage(t *testing.T) {
-	msg := NewFlowFailedMessage(123, "test error message")
+	msg := NewFlowFailedMessage(123, "req-1", "test error message", nil)
 
 	var result FlowMessage
 	if err := json.Unmarshal(msg, &result); err != nil {
@@ -132,7 +163,7 @@ func (m *MockBroadcaster) Broadcast(message []byte) {
 
 func TestMessageTimestamps(t *testing.T) {
 	before := time.Now()
-	msg := NewFlowStartedMessage(1)
+	msg := NewFlowStartedMessage(1, "req-1")
 	after := time.Now()
 
 	var result FlowMessage
@@ -148,3 +179,55 @@ func TestMessageTimestamps(t *testing.T) {
 		t.Errorf("Timestamp %v is not between %v and %v", timestamp, before, after)
 	}
 }
+
+func TestExecuteFlow_BroadcastsNodeFailure(t *testing.T) {
+	keyring.MockInit()
+	security.SetAPIKey("Anthropic", "dummy-key")
+	useInterNodeDelay(t, 0)
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(data.SQLiteSchema); err != nil {
+		t.Fatalf("Failed to init schema: %v", err)
+	}
+	flowJSON := `{"nodes": [
+		{"id": "1", "type": "agent", "data": {"role": "Architect", "prompt": "one", "provider": "Anthropic"}},
+		{"id": "2", "type": "agent", "data": {"role": "Implementation", "prompt": "two", "provider": "Anthropic"}}
+	], "edges": []}`
+	if _, err := db.Exec(`INSERT INTO forge_flows (name, data, status) VALUES (?, ?, ?)`, "Failing Flow", flowJSON, "active"); err != nil {
+		t.Fatalf("Failed to insert flow: %v", err)
+	}
+
+	provider := &promptCountingProvider{calls: map[string]int{}, failing: map[string]bool{"two": true}}
+	gateway := &llm.Gateway{AnthropicClient: provider, OpenAIClient: &MockLLMProvider{}}
+	hub := &MockBroadcaster{}
+	if err := ExecuteFlowWithOptions(1, db, gateway, nil, nil, hub, ExecuteOptions{RequestID: "req-1"}); err == nil {
+		t.Fatal("Expected the run to fail on node 2")
+	}
+
+	// Node 1 completes; node 2 reports why it failed, and never reports completion
+	var outcomes []string
+	var failure map[string]interface{}
+	for _, raw := range hub.Messages {
+		var msg struct {
+			Type    string                 `json:"type"`
+			Payload map[string]interface{} `json:"payload"`
+		}
+		json.Unmarshal(raw, &msg)
+		if msg.Type == "NODE_COMPLETED" || msg.Type == "NODE_FAILED" {
+			outcomes = append(outcomes, msg.Type+":"+msg.Payload["nodeId"].(string))
+		}
+		if msg.Type == "NODE_FAILED" {
+			failure = msg.Payload
+		}
+	}
+	if strings.Join(outcomes, ",") != "NODE_COMPLETED:1,NODE_FAILED:2" {
+		t.Fatalf("Expected node 1 to complete and node 2 to fail, got %v", outcomes)
+	}
+	if reason, _ := failure["error"].(string); !strings.Contains(reason, "upstream unavailable") || failure["requestId"] != "req-1" {
+		t.Errorf("Expected NODE_FAILED to carry the reason, got %v", failure)
+	}
+}
@@ -0,0 +1,55 @@
+package flows
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Node results record which nodes of a flow's latest run finished, so a failed run can be
+// resumed (ExecuteOptions.Resume) without paying for those nodes again. A fresh run starts
+// with no results, and a run that completes clears them, so resuming only ever picks up
+// from a failure.
+
+// clearNodeResults forgets every node result for the flow.
+func clearNodeResults(db *sql.DB, flowID int) error {
+	if _, err := db.Exec(`DELETE FROM flow_node_results WHERE flow_id = ?`, flowID); err != nil {
+		return fmt.Errorf("failed to clear node results: %w", err)
+	}
+	return nil
+}
+
+// completedNodes returns the cached output of each node that finished in the flow's last run.
+func completedNodes(db *sql.DB, flowID int) (map[string]string, error) {
+	rows, err := db.Query(`SELECT node_id, COALESCE(output, '') FROM flow_node_results WHERE flow_id = ? AND status = 'COMPLETED'`, flowID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load node results: %w", err)
+	}
+	defer rows.Close()
+
+	outputs := make(map[string]string)
+	for rows.Next() {
+		var nodeID, output string
+		if err := rows.Scan(&nodeID, &output); err != nil {
+			return nil, fmt.Errorf("failed to load node results: %w", err)
+		}
+		outputs[nodeID] = output
+	}
+	return outputs, rows.Err()
+}
+
+// saveNodeResult records that a node finished, with its output and cost.
+func saveNodeResult(db *sql.DB, flowID int, nodeID, output string, cost float64) error {
+	query := `
+		INSERT INTO flow_node_results (flow_id, node_id, status, output, cost_usd, completed_at)
+		VALUES (?, ?, 'COMPLETED', ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(flow_id, node_id) DO UPDATE SET
+			status = excluded.status,
+			output = excluded.output,
+			cost_usd = excluded.cost_usd,
+			completed_at = excluded.completed_at
+	`
+	if _, err := db.Exec(query, flowID, nodeID, output, cost); err != nil {
+		return fmt.Errorf("failed to save node result: %w", err)
+	}
+	return nil
+}
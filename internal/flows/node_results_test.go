@@ -0,0 +1,118 @@
+package flows
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/data"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/llm"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/security"
+	"github.com/zalando/go-keyring"
+)
+
+// promptCountingProvider counts calls per prompt and fails any prompt in failing.
+type promptCountingProvider struct {
+	calls   map[string]int
+	failing map[string]bool
+}
+
+func (p *promptCountingProvider) Send(system, user, key string) (string, int, int, error) {
+	p.calls[user]++
+	if p.failing[user] {
+		return "", 0, 0, errors.New("upstream unavailable")
+	}
+	return "done: " + user, 10, 20, nil
+}
+
+func TestExecuteFlow_ResumeFromFailedNode(t *testing.T) {
+	keyring.MockInit()
+	security.SetAPIKey("Anthropic", "dummy-key")
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(data.SQLiteSchema); err != nil {
+		t.Fatalf("Failed to init schema: %v", err)
+	}
+
+	flowJSON := `{"nodes": [
+		{"id": "1", "type": "agent", "data": {"role": "Architect", "prompt": "one", "provider": "Anthropic"}},
+		{"id": "2", "type": "agent", "data": {"role": "Implementation", "prompt": "two", "provider": "Anthropic"}},
+		{"id": "3", "type": "agent", "data": {"role": "Test", "prompt": "three", "provider": "Anthropic"}}
+	], "edges": []}`
+	if _, err := db.Exec(`INSERT INTO forge_flows (name, data, status) VALUES (?, ?, ?)`, "Resume Flow", flowJSON, "active"); err != nil {
+		t.Fatalf("Failed to insert flow: %v", err)
+	}
+
+	provider := &promptCountingProvider{calls: map[string]int{}, failing: map[string]bool{"two": true}}
+	gateway := &llm.Gateway{AnthropicClient: provider, OpenAIClient: &MockLLMProvider{}}
+
+	// The first run fails on node 2, after node 1 has finished
+	if err := ExecuteFlowWithOptions(1, db, gateway, nil, nil, nil, ExecuteOptions{}); err == nil {
+		t.Fatal("Expected the first run to fail on node 2")
+	}
+	outputs, err := completedNodes(db, 1)
+	if err != nil {
+		t.Fatalf("completedNodes: %v", err)
+	}
+	if len(outputs) != 1 || outputs["1"] != "done: one" {
+		t.Errorf("Expected only node 1 to be recorded with its output, got %v", outputs)
+	}
+
+	// Fix the failure and resume: node 1 is skipped, nodes 2 and 3 run
+	provider.failing = map[string]bool{}
+	if err := ExecuteFlowWithOptions(1, db, gateway, nil, nil, nil, ExecuteOptions{Resume: true}); err != nil {
+		t.Fatalf("Expected the resumed run to succeed, got %v", err)
+	}
+	if provider.calls["one"] != 1 {
+		t.Errorf("Expected node 1 not to run again, got %d calls", provider.calls["one"])
+	}
+	if provider.calls["two"] != 2 || provider.calls["three"] != 1 {
+		t.Errorf("Expected nodes 2 and 3 to run on resume, got %v", provider.calls)
+	}
+
+	// A completed run leaves nothing to resume, so resuming again runs everything
+	if outputs, _ := completedNodes(db, 1); len(outputs) != 0 {
+		t.Errorf("Expected results to be cleared after the flow completed, got %v", outputs)
+	}
+	if err := ExecuteFlowWithOptions(1, db, gateway, nil, nil, nil, ExecuteOptions{Resume: true}); err != nil {
+		t.Fatalf("Resume with nothing to resume failed: %v", err)
+	}
+	if provider.calls["one"] != 2 {
+		t.Errorf("Expected a full run when there is nothing to resume, got %v", provider.calls)
+	}
+}
+
+func TestExecuteFlow_FreshRunIgnoresEarlierResults(t *testing.T) {
+	keyring.MockInit()
+	security.SetAPIKey("Anthropic", "dummy-key")
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(data.SQLiteSchema); err != nil {
+		t.Fatalf("Failed to init schema: %v", err)
+	}
+
+	flowJSON := `{"nodes": [{"id": "1", "type": "agent", "data": {"role": "Architect", "prompt": "one", "provider": "Anthropic"}}], "edges": []}`
+	if _, err := db.Exec(`INSERT INTO forge_flows (name, data, status) VALUES (?, ?, ?)`, "Fresh Flow", flowJSON, "active"); err != nil {
+		t.Fatalf("Failed to insert flow: %v", err)
+	}
+	if err := saveNodeResult(db, 1, "1", "stale", 0); err != nil {
+		t.Fatalf("saveNodeResult: %v", err)
+	}
+
+	provider := &promptCountingProvider{calls: map[string]int{}, failing: map[string]bool{}}
+	gateway := &llm.Gateway{AnthropicClient: provider, OpenAIClient: &MockLLMProvider{}}
+	if err := ExecuteFlowWithOptions(1, db, gateway, nil, nil, nil, ExecuteOptions{}); err != nil {
+		t.Fatalf("ExecuteFlowWithOptions: %v", err)
+	}
+	if provider.calls["one"] != 1 {
+		t.Errorf("Expected a run without resume to execute every node, got %v", provider.calls)
+	}
+}
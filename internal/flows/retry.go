@@ -0,0 +1,62 @@
+package flows
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/llm"
+)
+
+// maxNodeRetries bounds MaxRetries so a hand-edited config can't retry forever.
+const maxNodeRetries = 10
+
+// RetryConfig controls how a failed node is retried. It can be set for the whole
+// flow (the "retryConfig" key the optimizer's retry suggestion writes) or on a node,
+// where it replaces the flow's setting.
+// Retries back off exponentially: BaseDelayMs, then twice that, then four times, and so on.
+type RetryConfig struct {
+	Enabled     bool   `json:"enabled"`
+	Strategy    string `json:"strategy,omitempty"` // "exponential_backoff" is the only strategy so far
+	MaxRetries  int    `json:"maxRetries"`
+	BaseDelayMs int    `json:"baseDelayMs"`
+}
+
+// nodeRetryConfig returns the retry settings for a node: its own if it has any, else the flow's.
+func nodeRetryConfig(flow, node *RetryConfig) RetryConfig {
+	if node != nil {
+		return *node
+	}
+	if flow != nil {
+		return *flow
+	}
+	return RetryConfig{}
+}
+
+// retries is how many times a failed call may be retried (0 when retries are disabled).
+func (c RetryConfig) retries() int {
+	if !c.Enabled || c.MaxRetries <= 0 {
+		return 0
+	}
+	return min(c.MaxRetries, maxNodeRetries)
+}
+
+// backoff is the wait before the given retry (1 for the first), honouring a
+// provider's Retry-After hint when it asks for longer.
+func (c RetryConfig) backoff(retry int, err error) time.Duration {
+	wait := time.Duration(max(c.BaseDelayMs, 0)) * time.Millisecond << (retry - 1)
+	if hint := llm.RetryAfter(err); hint > wait {
+		wait = hint
+	}
+	return wait
+}
+
+// retryable reports whether a failed call might succeed if tried again.
+// Bad keys, paused spending and over-cap prompts fail the same way every time.
+func retryable(err error) bool {
+	switch llm.HTTPStatusForError(err) {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusServiceUnavailable, http.StatusRequestEntityTooLarge:
+		return false
+	default:
+		return true
+	}
+}
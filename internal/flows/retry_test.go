@@ -0,0 +1,217 @@
+package flows
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/data"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/llm"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/security"
+	"github.com/zalando/go-keyring"
+)
+
+// flakyProvider fails its first failures calls, then succeeds.
+type flakyProvider struct {
+	failures int
+	calls    int
+}
+
+func (p *flakyProvider) Send(system, user, key string) (string, int, int, error) {
+	p.calls++
+	if p.calls <= p.failures {
+		return "", 0, 0, errors.New("connection reset by peer")
+	}
+	return "ok", 10, 20, nil
+}
+
+// runRetryFlow stores flowJSON as flow 1 and runs it against provider, returning the
+// ledger statuses in the order they were logged, the run's signaler and its error.
+func runRetryFlow(t *testing.T, flowJSON string, provider llm.LLMProvider) ([]string, *DBSignaler, error) {
+	t.Helper()
+	keyring.MockInit()
+	security.SetAPIKey("Anthropic", "dummy-key")
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec(data.SQLiteSchema); err != nil {
+		t.Fatalf("Failed to init schema: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO forge_flows (name, data, status) VALUES (?, ?, ?)`, "Retry Flow", flowJSON, "active"); err != nil {
+		t.Fatalf("Failed to insert flow: %v", err)
+	}
+
+	signaler := NewDBSignaler(db)
+	gateway := &llm.Gateway{AnthropicClient: provider, OpenAIClient: &MockLLMProvider{}}
+	runErr := ExecuteFlowWithOptions(1, db, gateway, signaler, nil, nil, ExecuteOptions{})
+
+	rows, err := db.Query("SELECT status FROM token_ledger ORDER BY id")
+	if err != nil {
+		t.Fatalf("Failed to query ledger: %v", err)
+	}
+	defer rows.Close()
+	var statuses []string
+	for rows.Next() {
+		var status string
+		rows.Scan(&status)
+		statuses = append(statuses, status)
+	}
+	return statuses, signaler, runErr
+}
+
+func TestExecuteFlow_RetriesFailedNode(t *testing.T) {
+	sleeps := useInterNodeDelay(t, 0)
+
+	// The retryConfig the optimizer's retry suggestion writes
+	flowJSON := `{
+		"nodes": [{"id": "1", "type": "agent", "data": {"role": "Implementation", "prompt": "code", "provider": "Anthropic"}}],
+		"edges": [],
+		"retryConfig": {"enabled": true, "strategy": "exponential_backoff", "maxRetries": 3, "baseDelayMs": 1000}
+	}`
+	provider := &flakyProvider{failures: 1}
+	statuses, signaler, err := runRetryFlow(t, flowJSON, provider)
+	if err != nil {
+		t.Fatalf("Expected the retry to succeed, got %v", err)
+	}
+
+	if provider.calls != 2 {
+		t.Errorf("Expected 2 calls (one failure, one retry), got %d", provider.calls)
+	}
+	if len(statuses) != 2 || statuses[0] != "FAILED" || statuses[1] != "SUCCESS" {
+		t.Errorf("Expected ledger FAILED then SUCCESS, got %v", statuses)
+	}
+	if len(*sleeps) != 1 || (*sleeps)[0] != time.Second {
+		t.Errorf("Expected one 1s backoff, got %v", *sleeps)
+	}
+	if status, _ := signaler.GetStatus(1); status == nil || status.Status != "COMPLETED" {
+		t.Errorf("Expected the flow to complete, got %+v", status)
+	}
+}
+
+func TestExecuteFlow_RetryBackoffAndGiveUp(t *testing.T) {
+	sleeps := useInterNodeDelay(t, 0)
+
+	flowJSON := `{
+		"nodes": [{"id": "1", "type": "agent", "data": {"role": "Implementation", "prompt": "code", "provider": "Anthropic",
+			"retryConfig": {"enabled": true, "maxRetries": 2, "baseDelayMs": 100}}}],
+		"edges": []
+	}`
+	provider := &flakyProvider{failures: 10}
+	statuses, _, err := runRetryFlow(t, flowJSON, provider)
+	if err == nil {
+		t.Fatal("Expected the node to fail once its retries ran out")
+	}
+
+	if provider.calls != 3 || len(statuses) != 3 {
+		t.Errorf("Expected 3 attempts logged, got %d calls and ledger %v", provider.calls, statuses)
+	}
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond}
+	if len(*sleeps) != len(want) || (*sleeps)[0] != want[0] || (*sleeps)[1] != want[1] {
+		t.Errorf("Expected exponential backoff %v, got %v", want, *sleeps)
+	}
+}
+
+func TestExecuteFlow_NodeRetryConfigOverridesFlow(t *testing.T) {
+	useInterNodeDelay(t, 0)
+
+	flowJSON := `{
+		"nodes": [{"id": "1", "type": "agent", "data": {"role": "Implementation", "prompt": "code", "provider": "Anthropic",
+			"retryConfig": {"enabled": false}}}],
+		"edges": [],
+		"retryConfig": {"enabled": true, "maxRetries": 3, "baseDelayMs": 10}
+	}`
+	provider := &flakyProvider{failures: 1}
+	if _, _, err := runRetryFlow(t, flowJSON, provider); err == nil {
+		t.Error("Expected the node to fail without retrying")
+	}
+	if provider.calls != 1 {
+		t.Errorf("Expected a single call with retries disabled on the node, got %d", provider.calls)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	if retryable(llm.ErrInputTokenCap) || retryable(llm.ErrSpendingPaused) {
+		t.Error("Capped and paused calls fail the same way every time and shouldn't be retried")
+	}
+	if !retryable(llm.ErrRateLimited) || !retryable(errors.New("connection reset")) {
+		t.Error("Rate limits and network errors should be retried")
+	}
+}
+
+func TestExecuteFlow_FallsBackWhenRateLimited(t *testing.T) {
+	keyring.MockInit()
+	security.SetAPIKey("Anthropic", "dummy-key")
+	security.SetAPIKey("OpenAI", "dummy-key")
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(data.SQLiteSchema); err != nil {
+		t.Fatalf("Failed to init schema: %v", err)
+	}
+	flowJSON := `{"nodes": [{"id": "1", "type": "agent", "data": {"role": "Implementation", "prompt": "code", "provider": "Anthropic", "fallbackModels": ["OpenAI"]}}], "edges": []}`
+	if _, err := db.Exec(`INSERT INTO forge_flows (name, data, status) VALUES (?, ?, ?)`, "Fallback Flow", flowJSON, "active"); err != nil {
+		t.Fatalf("Failed to insert flow: %v", err)
+	}
+
+	rateLimited := &MockLLMProvider{Err: &llm.APIError{Provider: llm.ProviderAnthropic, StatusCode: 429, Message: "slow down"}}
+	fallback := &MockLLMProvider{ReturnValue: "ok"}
+	gateway := &llm.Gateway{AnthropicClient: rateLimited, OpenAIClient: fallback}
+	if err := ExecuteFlowWithOptions(1, db, gateway, nil, nil, nil, ExecuteOptions{}); err != nil {
+		t.Fatalf("Expected the fallback to succeed, got %v", err)
+	}
+	if !fallback.Called {
+		t.Error("Expected the fallback provider to be called")
+	}
+
+	// Both calls are in the ledger, each against the provider that handled it
+	rows, err := db.Query("SELECT model_used, status FROM token_ledger ORDER BY id")
+	if err != nil {
+		t.Fatalf("Failed to query ledger: %v", err)
+	}
+	defer rows.Close()
+	var logged []string
+	for rows.Next() {
+		var model, status string
+		rows.Scan(&model, &status)
+		logged = append(logged, model+" "+status)
+	}
+	if len(logged) != 2 || logged[0] != "Anthropic FAILED" || logged[1] != "OpenAI SUCCESS" {
+		t.Errorf("Expected a failed Anthropic call then a successful OpenAI one, got %v", logged)
+	}
+}
+
+func TestExecuteFlow_UnknownFallbackFailsBeforeAnyCall(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(data.SQLiteSchema); err != nil {
+		t.Fatalf("Failed to init schema: %v", err)
+	}
+	// The second node's fallback is unknown, so not even the first node runs
+	flowJSON := `{"nodes": [
+		{"id": "1", "type": "agent", "data": {"role": "Architect", "prompt": "plan", "provider": "Anthropic"}},
+		{"id": "2", "type": "agent", "data": {"role": "Implementation", "prompt": "code", "provider": "Anthropic", "fallbackModels": ["Gemini"]}}
+	], "edges": []}`
+	if _, err := db.Exec(`INSERT INTO forge_flows (name, data, status) VALUES (?, ?, ?)`, "Fallback Flow", flowJSON, "active"); err != nil {
+		t.Fatalf("Failed to insert flow: %v", err)
+	}
+
+	provider := &MockLLMProvider{ReturnValue: "ok"}
+	gateway := &llm.Gateway{AnthropicClient: provider, OpenAIClient: provider}
+	err = ExecuteFlowWithOptions(1, db, gateway, nil, nil, nil, ExecuteOptions{})
+	if !errors.Is(err, llm.ErrUnknownFallback) {
+		t.Fatalf("Expected ErrUnknownFallback, got %v", err)
+	}
+	if provider.Called {
+		t.Error("Expected no provider call")
+	}
+}
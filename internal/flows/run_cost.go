@@ -0,0 +1,54 @@
+package flows
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// NodeCost is what one node used during a run, summed over all its calls
+// (retries and fallbacks each log a call of their own).
+type NodeCost struct {
+	NodeID       string  `json:"node_id"`
+	Calls        int     `json:"calls"`
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	CostUSD      float64 `json:"cost_usd"`
+}
+
+// RunCost is the token and cost breakdown of one flow run, read back from its ledger rows.
+type RunCost struct {
+	RequestID    string     `json:"request_id"`
+	Nodes        []NodeCost `json:"nodes"` // in the order the nodes first called out
+	InputTokens  int        `json:"input_tokens"`
+	OutputTokens int        `json:"output_tokens"`
+	TotalCostUSD float64    `json:"total_cost_usd"`
+}
+
+// RunCostSummary totals the ledger rows of the run with the given request ID, per node
+// and overall. A run that made no calls has no nodes and a zero total.
+// Educational Comment: The totals come from token_ledger rather than from the engine's
+// running sum, so they always agree with what the ledger reports for the run.
+func RunCostSummary(db *sql.DB, requestID string) (*RunCost, error) {
+	rows, err := db.Query(`
+		SELECT COALESCE(node_id, ''), COUNT(*), SUM(input_tokens), SUM(output_tokens), SUM(total_cost_usd)
+		FROM token_ledger WHERE request_id = ?
+		GROUP BY node_id ORDER BY MIN(id)
+	`, requestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read run costs: %w", err)
+	}
+	defer rows.Close()
+
+	summary := &RunCost{RequestID: requestID, Nodes: []NodeCost{}}
+	for rows.Next() {
+		var node NodeCost
+		if err := rows.Scan(&node.NodeID, &node.Calls, &node.InputTokens, &node.OutputTokens, &node.CostUSD); err != nil {
+			return nil, fmt.Errorf("failed to read run costs: %w", err)
+		}
+		summary.Nodes = append(summary.Nodes, node)
+		summary.InputTokens += node.InputTokens
+		summary.OutputTokens += node.OutputTokens
+		summary.TotalCostUSD += node.CostUSD
+	}
+	return summary, rows.Err()
+}
@@ -0,0 +1,42 @@
+package flows
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrFlowAlreadyRunning is returned when a flow is executed while a previous run is still in progress.
+var ErrFlowAlreadyRunning = errors.New("flow is already running")
+
+// runningFlows tracks which flow IDs are currently executing.
+// Educational Comment: Two runs of the same flow would interleave their ledger
+// writes and status broadcasts, so each flow ID may only be "checked out" once.
+var (
+	runningMu    sync.Mutex
+	runningFlows = make(map[int]bool)
+)
+
+// acquireFlow marks a flow as running. It returns false if it already is.
+func acquireFlow(flowID int) bool {
+	runningMu.Lock()
+	defer runningMu.Unlock()
+	if runningFlows[flowID] {
+		return false
+	}
+	runningFlows[flowID] = true
+	return true
+}
+
+// releaseFlow marks a flow as no longer running.
+func releaseFlow(flowID int) {
+	runningMu.Lock()
+	defer runningMu.Unlock()
+	delete(runningFlows, flowID)
+}
+
+// IsFlowRunning reports whether a flow is currently executing.
+func IsFlowRunning(flowID int) bool {
+	runningMu.Lock()
+	defer runningMu.Unlock()
+	return runningFlows[flowID]
+}
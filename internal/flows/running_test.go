@@ -0,0 +1,104 @@
+package flows
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/data"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/llm"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/security"
+	"github.com/zalando/go-keyring"
+)
+
+// blockingProvider holds each Send until release is closed, announcing entry on started.
+type blockingProvider struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingProvider) Send(system, user, key string) (string, int, int, error) {
+	b.started <- struct{}{}
+	<-b.release
+	return "done", 1, 1, nil
+}
+
+func TestExecuteFlow_RejectsConcurrentRun(t *testing.T) {
+	keyring.MockInit()
+	security.SetAPIKey("Anthropic", "dummy-key")
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db: %v", err)
+	}
+	defer db.Close()
+	// Both runs share the in-memory database, so keep to a single connection
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(data.SQLiteSchema); err != nil {
+		t.Fatalf("Failed to init schema: %v", err)
+	}
+	flowJSON := `{"nodes": [{"id": "1", "type": "agent", "data": {"role": "Implementation", "prompt": "work", "provider": "Anthropic"}}], "edges": []}`
+	if _, err := db.Exec(`INSERT INTO forge_flows (name, data, status) VALUES (?, ?, ?)`, "Guarded Flow", flowJSON, "active"); err != nil {
+		t.Fatalf("Failed to insert flow: %v", err)
+	}
+
+	provider := &blockingProvider{started: make(chan struct{}, 2), release: make(chan struct{})}
+	gateway := &llm.Gateway{AnthropicClient: provider, OpenAIClient: provider}
+
+	// First run: blocks inside the provider call
+	firstDone := make(chan error, 1)
+	go func() {
+		firstDone <- ExecuteFlowWithSignaling(1, db, gateway, nil, nil)
+	}()
+
+	select {
+	case <-provider.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("First run never reached the provider")
+	}
+	if !IsFlowRunning(1) {
+		t.Error("Expected flow 1 to be marked as running")
+	}
+
+	// Second run while the first is still in flight must be rejected
+	err = ExecuteFlowWithSignaling(1, db, gateway, nil, nil)
+	if !errors.Is(err, ErrFlowAlreadyRunning) {
+		t.Errorf("Expected ErrFlowAlreadyRunning, got %v", err)
+	}
+
+	close(provider.release)
+	if err := <-firstDone; err != nil {
+		t.Fatalf("First run failed: %v", err)
+	}
+
+	// The lock is released on completion, so the flow can run again
+	if IsFlowRunning(1) {
+		t.Error("Expected flow 1 lock to be released after completion")
+	}
+	if err := ExecuteFlowWithSignaling(1, db, gateway, nil, nil); err != nil {
+		t.Errorf("Expected a fresh run to succeed after completion, got %v", err)
+	}
+}
+
+func TestExecuteFlow_ReleasesLockOnFailure(t *testing.T) {
+	keyring.MockInit()
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(data.SQLiteSchema); err != nil {
+		t.Fatalf("Failed to init schema: %v", err)
+	}
+
+	// Flow 42 doesn't exist, so execution fails immediately
+	gateway := &llm.Gateway{AnthropicClient: &MockLLMProvider{}, OpenAIClient: &MockLLMProvider{}}
+	if err := ExecuteFlowWithSignaling(42, db, gateway, nil, nil); err == nil {
+		t.Fatal("Expected missing flow to fail")
+	}
+	if IsFlowRunning(42) {
+		t.Error("Expected lock to be released after a failed run")
+	}
+}
@@ -5,7 +5,7 @@ import "time"
 // FlowStatus represents the current status of a flow execution
 type FlowStatus struct {
 	FlowID    int       `json:"flowId"`
-	Status    string    `json:"status"` // PENDING, RUNNING, COMPLETED, FAILED
+	Status    string    `json:"status"` // PENDING, RUNNING, COMPLETED, FAILED, BUDGET_EXCEEDED
 	LastNode  string    `json:"lastNode,omitempty"`
 	UpdatedAt time.Time `json:"updatedAt"`
 	Error     string    `json:"error,omitempty"`
@@ -0,0 +1,58 @@
+package flows
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ErrUnresolvedVariable is returned when a node's prompt references a variable the run wasn't given.
+var ErrUnresolvedVariable = errors.New("unresolved flow variable")
+
+// variablePattern matches a {{env.NAME}} reference; spaces inside the braces are allowed.
+var variablePattern = regexp.MustCompile(`\{\{\s*env\.([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// substituteVariables replaces every {{env.NAME}} in the agent nodes' prompts and system
+// prompts with the run's value for NAME. It checks every node before changing any, so a
+// run with a missing variable fails before the first call is made.
+// Educational Comment: Variables let one flow serve several repos or tickets, e.g. a prompt
+// of "Review {{env.REPO_NAME}}" run with {"REPO_NAME": "forge"}. Values are inserted as is.
+func substituteVariables(graph *FlowGraph, vars map[string]string) error {
+	missing := map[string]bool{}
+	for _, node := range graph.Nodes {
+		if node.Type != "agent" {
+			continue
+		}
+		for _, text := range []string{node.Data.Prompt, node.Data.SystemPrompt} {
+			for _, match := range variablePattern.FindAllStringSubmatch(text, -1) {
+				if _, ok := vars[match[1]]; !ok {
+					missing[match[1]] = true
+				}
+			}
+		}
+	}
+	if len(missing) > 0 {
+		names := make([]string, 0, len(missing))
+		for name := range missing {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("%w: %s (pass it in the run's variables)", ErrUnresolvedVariable, strings.Join(names, ", "))
+	}
+
+	replace := func(text string) string {
+		return variablePattern.ReplaceAllStringFunc(text, func(ref string) string {
+			return vars[variablePattern.FindStringSubmatch(ref)[1]]
+		})
+	}
+	for i, node := range graph.Nodes {
+		if node.Type != "agent" {
+			continue
+		}
+		graph.Nodes[i].Data.Prompt = replace(node.Data.Prompt)
+		graph.Nodes[i].Data.SystemPrompt = replace(node.Data.SystemPrompt)
+	}
+	return nil
+}
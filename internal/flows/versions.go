@@ -0,0 +1,87 @@
+package flows
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Flow versions keep the graph a flow had before each edit, so an accidental change can be
+// reverted. Only the newest MaxFlowVersions are kept per flow.
+
+// MaxFlowVersions is how many earlier versions are kept per flow; older ones are pruned.
+const MaxFlowVersions = 20
+
+// FlowVersion is an earlier state of a flow, numbered from 1 per flow.
+type FlowVersion struct {
+	Version   int       `json:"version"`
+	Name      string    `json:"name"`
+	Data      string    `json:"data"` // JSON string of the graph
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SaveVersion records a flow's current name and data as its next version, before the
+// data is replaced by newData, and prunes versions past MaxFlowVersions. It does nothing
+// when the flow doesn't exist or newData leaves its graph unchanged, so repeated saves of
+// the same graph don't push real history out.
+// Pass a transaction so the snapshot and the edit that follows it land together.
+func SaveVersion(tx *sql.Tx, flowID int, newData string) error {
+	var name, data string
+	err := tx.QueryRow(`SELECT name, data FROM forge_flows WHERE id = ?`, flowID).Scan(&name, &data)
+	if err == sql.ErrNoRows || (err == nil && data == newData) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to read flow for versioning: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO flow_versions (flow_id, version, name, data, created_at)
+		VALUES (?, (SELECT COALESCE(MAX(version), 0) + 1 FROM flow_versions WHERE flow_id = ?), ?, ?, CURRENT_TIMESTAMP)
+	`, flowID, flowID, name, data)
+	if err != nil {
+		return fmt.Errorf("failed to save flow version: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		DELETE FROM flow_versions WHERE flow_id = ? AND version NOT IN (
+			SELECT version FROM flow_versions WHERE flow_id = ? ORDER BY version DESC LIMIT ?
+		)
+	`, flowID, flowID, MaxFlowVersions)
+	if err != nil {
+		return fmt.Errorf("failed to prune flow versions: %w", err)
+	}
+	return nil
+}
+
+// ListVersions returns a flow's kept versions, newest first.
+func ListVersions(db *sql.DB, flowID int) ([]FlowVersion, error) {
+	rows, err := db.Query(`
+		SELECT version, name, data, created_at FROM flow_versions
+		WHERE flow_id = ? ORDER BY version DESC
+	`, flowID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list flow versions: %w", err)
+	}
+	defer rows.Close()
+
+	versions := []FlowVersion{}
+	for rows.Next() {
+		var v FlowVersion
+		if err := rows.Scan(&v.Version, &v.Name, &v.Data, &v.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to list flow versions: %w", err)
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// GetVersion returns one version of a flow, or sql.ErrNoRows if it isn't kept.
+func GetVersion(db *sql.DB, flowID, version int) (*FlowVersion, error) {
+	v := FlowVersion{Version: version}
+	err := db.QueryRow(`SELECT name, data, created_at FROM flow_versions WHERE flow_id = ? AND version = ?`, flowID, version).
+		Scan(&v.Name, &v.Data, &v.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
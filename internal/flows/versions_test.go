@@ -0,0 +1,55 @@
+package flows
+
+import (
+	"database/sql"
+	"strconv"
+	"testing"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/data"
+)
+
+func TestSaveVersion_PrunesToMaxFlowVersions(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(data.SQLiteSchema); err != nil {
+		t.Fatalf("Failed to init schema: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO forge_flows (name, data, status) VALUES ('Busy', '0', 'active')`); err != nil {
+		t.Fatalf("Failed to insert flow: %v", err)
+	}
+
+	edits := MaxFlowVersions + 5
+	for i := 1; i <= edits; i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatalf("Failed to begin: %v", err)
+		}
+		if err := SaveVersion(tx, 1, strconv.Itoa(i)); err != nil {
+			t.Fatalf("SaveVersion failed: %v", err)
+		}
+		if _, err := tx.Exec(`UPDATE forge_flows SET data = ? WHERE id = 1`, strconv.Itoa(i)); err != nil {
+			t.Fatalf("Failed to update flow: %v", err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("Failed to commit: %v", err)
+		}
+	}
+
+	versions, err := ListVersions(db, 1)
+	if err != nil {
+		t.Fatalf("ListVersions failed: %v", err)
+	}
+	if len(versions) != MaxFlowVersions {
+		t.Fatalf("Expected %d versions kept, got %d", MaxFlowVersions, len(versions))
+	}
+	// Version n holds the graph before edit n, i.e. data n-1; the oldest are pruned
+	if newest := versions[0]; newest.Version != edits || newest.Data != strconv.Itoa(edits-1) {
+		t.Errorf("Expected version %d holding %d, got %+v", edits, edits-1, newest)
+	}
+	if oldest := versions[len(versions)-1]; oldest.Version != edits-MaxFlowVersions+1 {
+		t.Errorf("Expected the oldest kept version to be %d, got %d", edits-MaxFlowVersions+1, oldest.Version)
+	}
+}
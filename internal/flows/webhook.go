@@ -0,0 +1,88 @@
+package flows
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/config"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/logging"
+)
+
+// Webhook payload formats supported by FlowsConfig.WebhookFormat.
+const (
+	WebhookFormatJSON  = "json"
+	WebhookFormatSlack = "slack"
+)
+
+// webhookClient sends webhook requests. The timeout keeps a slow receiver
+// from holding a goroutine open indefinitely.
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// WebhookPayload is POSTed to the configured webhook when a flow finishes.
+type WebhookPayload struct {
+	Event        string    `json:"event"` // FLOW_COMPLETED or FLOW_FAILED
+	FlowID       int       `json:"flowId"`
+	Status       string    `json:"status"`
+	DurationMs   int64     `json:"durationMs"`
+	TotalCostUSD float64   `json:"totalCostUsd"`
+	Error        string    `json:"error,omitempty"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// slackPayload is the minimal shape accepted by Slack incoming webhooks.
+type slackPayload struct {
+	Text string `json:"text"`
+}
+
+// buildWebhookBody renders the payload in the requested format.
+func buildWebhookBody(payload WebhookPayload, format string) ([]byte, error) {
+	if format != WebhookFormatSlack {
+		return json.Marshal(payload)
+	}
+
+	text := fmt.Sprintf("✅ Forge flow %d completed in %.1fs (cost $%.4f)",
+		payload.FlowID, float64(payload.DurationMs)/1000, payload.TotalCostUSD)
+	// Any run that didn't complete failed, e.g. one stopped by its cost cap (BUDGET_EXCEEDED)
+	if payload.Event == "FLOW_FAILED" || payload.Status != "COMPLETED" {
+		failed := "failed"
+		if payload.Status != "" && payload.Status != "FAILED" {
+			failed = fmt.Sprintf("failed (%s)", payload.Status)
+		}
+		text = fmt.Sprintf("❌ Forge flow %d %s after %.1fs (cost $%.4f): %s",
+			payload.FlowID, failed, float64(payload.DurationMs)/1000, payload.TotalCostUSD, payload.Error)
+	}
+	return json.Marshal(slackPayload{Text: text})
+}
+
+// sendWebhook POSTs a flow result to the configured webhook URL, if any.
+// It is best-effort and non-blocking: delivery happens in a goroutine, and
+// failures are logged without affecting the flow result.
+func sendWebhook(payload WebhookPayload) {
+	cfg, err := config.Get()
+	if err != nil || cfg.Flows.WebhookURL == "" {
+		return
+	}
+	url, format := cfg.Flows.WebhookURL, cfg.Flows.WebhookFormat
+
+	go func() {
+		body, err := buildWebhookBody(payload, format)
+		if err != nil {
+			logging.Errorf("Webhook: failed to build payload for flow %d: %v", payload.FlowID, err)
+			return
+		}
+
+		resp, err := webhookClient.Post(url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			logging.Errorf("Webhook: delivery for flow %d failed: %v", payload.FlowID, err)
+			return
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode >= 300 {
+			logging.Warnf("Webhook: receiver returned %d for flow %d", resp.StatusCode, payload.FlowID)
+		}
+	}()
+}
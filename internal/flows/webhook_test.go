@@ -0,0 +1,145 @@
+package flows
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/config"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/data"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/llm"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/security"
+	"github.com/zalando/go-keyring"
+)
+
+// startWebhookReceiver returns a test server that forwards each request body to the channel.
+func startWebhookReceiver(t *testing.T) (*httptest.Server, chan []byte) {
+	received := make(chan []byte, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- body
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(receiver.Close)
+	return receiver, received
+}
+
+// runWebhookFlow executes a single-node flow whose provider returns the given error.
+func runWebhookFlow(t *testing.T, providerErr error) error {
+	keyring.MockInit()
+	security.SetAPIKey("Anthropic", "dummy-key")
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open in-memory db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec(data.SQLiteSchema); err != nil {
+		t.Fatalf("Failed to init schema: %v", err)
+	}
+
+	flowJSON := `{"nodes": [{"id": "1", "type": "agent", "data": {"role": "Implementation", "prompt": "code", "provider": "Anthropic"}}], "edges": []}`
+	if _, err := db.Exec(`INSERT INTO forge_flows (name, data, status) VALUES (?, ?, ?)`, "Webhook Flow", flowJSON, "active"); err != nil {
+		t.Fatalf("Failed to insert flow: %v", err)
+	}
+
+	gateway := &llm.Gateway{
+		AnthropicClient: &MockLLMProvider{ReturnValue: "done", Err: providerErr},
+		OpenAIClient:    &MockLLMProvider{},
+	}
+	return ExecuteFlow(1, db, gateway)
+}
+
+// waitForWebhook waits for the receiver to get a request.
+func waitForWebhook(t *testing.T, received chan []byte) []byte {
+	select {
+	case body := <-received:
+		return body
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for webhook")
+		return nil
+	}
+}
+
+func TestWebhookOnFlowCompleted(t *testing.T) {
+	receiver, received := startWebhookReceiver(t)
+	useFlowsConfig(t, config.FlowsConfig{WebhookURL: receiver.URL})
+
+	if err := runWebhookFlow(t, nil); err != nil {
+		t.Fatalf("ExecuteFlow failed: %v", err)
+	}
+
+	var payload WebhookPayload
+	if err := json.Unmarshal(waitForWebhook(t, received), &payload); err != nil {
+		t.Fatalf("Failed to decode webhook payload: %v", err)
+	}
+	if payload.Event != "FLOW_COMPLETED" || payload.Status != "COMPLETED" || payload.FlowID != 1 {
+		t.Errorf("Unexpected webhook payload: %+v", payload)
+	}
+	// The mock provider reports 10 input and 20 output tokens on Anthropic rates
+	if payload.TotalCostUSD <= 0 {
+		t.Errorf("Expected total cost to be reported, got %v", payload.TotalCostUSD)
+	}
+	if payload.DurationMs < 0 || payload.Timestamp.IsZero() {
+		t.Errorf("Expected duration and timestamp, got %+v", payload)
+	}
+}
+
+func TestWebhookOnFlowFailedSlackFormat(t *testing.T) {
+	receiver, received := startWebhookReceiver(t)
+	useFlowsConfig(t, config.FlowsConfig{WebhookURL: receiver.URL, WebhookFormat: WebhookFormatSlack})
+
+	if err := runWebhookFlow(t, io.ErrUnexpectedEOF); err == nil {
+		t.Fatal("Expected flow to fail")
+	}
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal(waitForWebhook(t, received), &payload); err != nil {
+		t.Fatalf("Failed to decode webhook payload: %v", err)
+	}
+	text, ok := payload["text"].(string)
+	if !ok || !strings.Contains(text, "flow 1 failed") || !strings.Contains(text, io.ErrUnexpectedEOF.Error()) {
+		t.Errorf("Expected Slack text describing the failure, got %v", payload)
+	}
+}
+
+func TestBuildWebhookBody_SlackBudgetExceeded(t *testing.T) {
+	payload := WebhookPayload{
+		Event:        "FLOW_FAILED",
+		FlowID:       7,
+		Status:       "BUDGET_EXCEEDED",
+		DurationMs:   1500,
+		TotalCostUSD: 2.5,
+		Error:        "cost cap of $2.00 reached",
+	}
+	body, err := buildWebhookBody(payload, WebhookFormatSlack)
+	if err != nil {
+		t.Fatalf("buildWebhookBody failed: %v", err)
+	}
+
+	var slack slackPayload
+	if err := json.Unmarshal(body, &slack); err != nil {
+		t.Fatalf("Failed to decode Slack payload: %v", err)
+	}
+	if strings.Contains(slack.Text, "completed") || !strings.Contains(slack.Text, "flow 7 failed (BUDGET_EXCEEDED)") ||
+		!strings.Contains(slack.Text, payload.Error) {
+		t.Errorf("Expected Slack text reporting the budget stop, got %q", slack.Text)
+	}
+}
+
+func TestWebhookFailureDoesNotFailFlow(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	receiver.Close() // Closed server: delivery will fail to connect
+	useFlowsConfig(t, config.FlowsConfig{WebhookURL: receiver.URL})
+
+	if err := runWebhookFlow(t, nil); err != nil {
+		t.Errorf("Webhook failures must not fail the flow, got %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+// Package lifecycle coordinates background goroutines so they stop cleanly on shutdown.
+// Every long-running task (hub loop, update checker, schedulers, ...) is started through
+// a Manager, which hands it a context that is cancelled when the app shuts down.
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Task is a background function. It must return once ctx is cancelled.
+type Task func(ctx context.Context)
+
+// Manager owns the root context and tracks running tasks.
+// Think of it as a foreman: it starts workers, and at closing time it tells
+// everyone to stop and waits until they have all left.
+type Manager struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	running map[string]int
+}
+
+// New creates a Manager with a fresh root context.
+func New() *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		ctx:     ctx,
+		cancel:  cancel,
+		running: make(map[string]int),
+	}
+}
+
+// Context returns the root context. It is cancelled when Shutdown is called.
+func (m *Manager) Context() context.Context {
+	return m.ctx
+}
+
+// Go starts a named background task. The name is used for logging only.
+func (m *Manager) Go(name string, task Task) {
+	m.mu.Lock()
+	m.running[name]++
+	m.mu.Unlock()
+
+	m.wg.Add(1)
+	go func() {
+		defer func() {
+			m.mu.Lock()
+			m.running[name]--
+			if m.running[name] <= 0 {
+				delete(m.running, name)
+			}
+			m.mu.Unlock()
+			m.wg.Done()
+		}()
+		task(m.ctx)
+	}()
+}
+
+// Running returns the names of tasks that have not yet returned.
+func (m *Manager) Running() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.running))
+	for name := range m.running {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Shutdown cancels the root context and waits up to timeout for all tasks to return.
+// It returns an error naming the tasks that were still running when the timeout expired.
+func (m *Manager) Shutdown(timeout time.Duration) error {
+	m.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Println("Lifecycle: all background tasks stopped")
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("timed out waiting for background tasks: %v", m.Running())
+	}
+}
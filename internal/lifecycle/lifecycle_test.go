@@ -0,0 +1,63 @@
+package lifecycle
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShutdownCancelsRegisteredTasks(t *testing.T) {
+	m := New()
+
+	var cancelled int32
+	for _, name := range []string{"hub", "update-checker", "reaper"} {
+		m.Go(name, func(ctx context.Context) {
+			<-ctx.Done()
+			atomic.AddInt32(&cancelled, 1)
+		})
+	}
+
+	if err := m.Shutdown(time.Second); err != nil {
+		t.Fatalf("Shutdown returned error: %v", err)
+	}
+
+	if got := atomic.LoadInt32(&cancelled); got != 3 {
+		t.Errorf("Expected 3 tasks to observe cancellation, got %d", got)
+	}
+	if running := m.Running(); len(running) != 0 {
+		t.Errorf("Expected no running tasks, got %v", running)
+	}
+}
+
+func TestShutdownTimesOutOnStuckTask(t *testing.T) {
+	m := New()
+
+	release := make(chan struct{})
+	defer close(release)
+	m.Go("stuck", func(ctx context.Context) {
+		<-release // Ignores ctx on purpose
+	})
+
+	err := m.Shutdown(50 * time.Millisecond)
+	if err == nil {
+		t.Fatal("Expected timeout error for stuck task")
+	}
+	if !strings.Contains(err.Error(), "stuck") {
+		t.Errorf("Expected error to name the stuck task, got: %v", err)
+	}
+}
+
+func TestContextCancelledAfterShutdown(t *testing.T) {
+	m := New()
+	if err := m.Context().Err(); err != nil {
+		t.Fatalf("Context should be live before shutdown, got %v", err)
+	}
+
+	m.Shutdown(time.Second)
+
+	if m.Context().Err() == nil {
+		t.Error("Expected context to be cancelled after shutdown")
+	}
+}
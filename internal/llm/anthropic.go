@@ -15,6 +15,10 @@ const DefaultAnthropicEndpoint = "https://api.anthropic.com/v1/messages"
 // DefaultTimeoutSeconds is the default HTTP client timeout.
 const DefaultTimeoutSeconds = 30
 
+// DefaultAnthropicMaxTokens is the response cap sent to Anthropic, which requires one on every request.
+// It is the most Claude 3.5 Sonnet will return.
+const DefaultAnthropicMaxTokens = 4096
+
 // AnthropicClient implements the LLMProvider interface for Anthropic.
 // It supports configurable endpoints and timeouts for testing and production use.
 type AnthropicClient struct {
@@ -23,6 +27,20 @@ type AnthropicClient struct {
 
 	// TimeoutSeconds is the HTTP client timeout. If 0, uses DefaultTimeoutSeconds.
 	TimeoutSeconds int
+
+	// MaxTokens caps the response length. If 0, uses Budget.MaxOutputTokens, then DefaultAnthropicMaxTokens.
+	MaxTokens int
+}
+
+// getMaxTokens returns the response token cap for this request.
+func (c *AnthropicClient) getMaxTokens() int {
+	if c.MaxTokens > 0 {
+		return c.MaxTokens
+	}
+	if max := configuredMaxOutputTokens(); max > 0 {
+		return max
+	}
+	return DefaultAnthropicMaxTokens
 }
 
 // getEndpoint returns the configured endpoint or the default.
@@ -43,10 +61,12 @@ func (c *AnthropicClient) getTimeout() time.Duration {
 
 // anthropicRequest represents the payload for the Anthropic API.
 type anthropicRequest struct {
-	Model     string    `json:"model"`
-	MaxTokens int       `json:"max_tokens"`
-	System    string    `json:"system"`
-	Messages  []message `json:"messages"`
+	Model       string    `json:"model"`
+	MaxTokens   int       `json:"max_tokens"`
+	System      string    `json:"system"`
+	Messages    []message `json:"messages"`
+	Temperature *float64  `json:"temperature,omitempty"`
+	TopP        *float64  `json:"top_p,omitempty"`
 }
 
 type message struct {
@@ -71,13 +91,31 @@ type anthropicResponse struct {
 // Send sends a prompt to Anthropic's Claude 3.5 Sonnet model.
 // It uses configurable endpoint and timeout for testability.
 func (c *AnthropicClient) Send(systemPrompt, userPrompt, apiKey string) (string, int, int, error) {
+	return c.SendWithOptions(systemPrompt, userPrompt, apiKey, SendOptions{})
+}
+
+// SendWithOptions is Send with request settings for this call only.
+func (c *AnthropicClient) SendWithOptions(systemPrompt, userPrompt, apiKey string, opts SendOptions) (string, int, int, error) {
+	maxTokens := opts.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = c.getMaxTokens()
+	}
 	reqBody := anthropicRequest{
-		Model:     "claude-3-5-sonnet-20240620",
-		MaxTokens: 4096,
+		Model:     AnthropicModel,
+		MaxTokens: maxTokens,
 		System:    systemPrompt,
 		Messages: []message{
 			{Role: "user", Content: userPrompt},
 		},
+		Temperature: opts.Temperature,
+		TopP:        opts.TopP,
+	}
+	// Anthropic has no JSON mode. Prefilling the assistant's turn with "{" makes the
+	// model continue an object; the brace is put back on the text it returns.
+	prefill := ""
+	if opts.JSONMode {
+		prefill = "{"
+		reqBody.Messages = append(reqBody.Messages, message{Role: "assistant", Content: prefill})
 	}
 
 	jsonData, err := json.Marshal(reqBody)
@@ -109,7 +147,7 @@ func (c *AnthropicClient) Send(systemPrompt, userPrompt, apiKey string) (string,
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", 0, 0, fmt.Errorf("anthropic api error (status %d): %s", resp.StatusCode, string(body))
+		return "", 0, 0, newAPIError(ProviderAnthropic, resp, body)
 	}
 
 	var response anthropicResponse
@@ -118,12 +156,12 @@ func (c *AnthropicClient) Send(systemPrompt, userPrompt, apiKey string) (string,
 	}
 
 	if response.Error != nil {
-		return "", 0, 0, fmt.Errorf("anthropic error: %s", response.Error.Message)
+		return "", 0, 0, &APIError{Provider: ProviderAnthropic, StatusCode: resp.StatusCode, Message: response.Error.Message}
 	}
 
 	if len(response.Content) == 0 {
-		return "", 0, 0, fmt.Errorf("empty response content")
+		return "", 0, 0, fmt.Errorf("%w: empty response content", ErrEmptyResponse)
 	}
 
-	return response.Content[0].Text, response.Usage.InputTokens, response.Usage.OutputTokens, nil
+	return prefill + response.Content[0].Text, response.Usage.InputTokens, response.Usage.OutputTokens, nil
 }
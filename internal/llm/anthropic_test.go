@@ -4,10 +4,13 @@ package llm
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/config"
 )
 
 // TestAnthropicClient_SuccessfulResponse verifies that AnthropicClient correctly parses
@@ -104,6 +107,14 @@ func TestAnthropicClient_APIError(t *testing.T) {
 	if !strings.Contains(err.Error(), "401") {
 		t.Errorf("Expected error to contain '401', got: %v", err)
 	}
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Expected *APIError, got %T", err)
+	}
+	if apiErr.StatusCode != http.StatusUnauthorized || apiErr.Provider != ProviderAnthropic {
+		t.Errorf("Unexpected APIError: %+v", apiErr)
+	}
 }
 
 // TestAnthropicClient_EmptyResponse verifies handling of empty content array.
@@ -132,6 +143,9 @@ func TestAnthropicClient_EmptyResponse(t *testing.T) {
 	if !strings.Contains(err.Error(), "empty response") {
 		t.Errorf("Expected error to contain 'empty response', got: %v", err)
 	}
+	if !errors.Is(err, ErrEmptyResponse) {
+		t.Errorf("Expected ErrEmptyResponse, got: %v", err)
+	}
 }
 
 // TestAnthropicClient_NetworkError verifies handling of network failures.
@@ -243,3 +257,34 @@ func TestAnthropicClient_Timeout(t *testing.T) {
 		t.Fatalf("Expected no error, got: %v", err)
 	}
 }
+
+// TestAnthropicClient_MaxTokens verifies the response cap comes from config, falling back to the default.
+func TestAnthropicClient_MaxTokens(t *testing.T) {
+	useBudgetConfig(t, config.BudgetConfig{})
+
+	var gotMaxTokens int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reqBody anthropicRequest
+		json.NewDecoder(r.Body).Decode(&reqBody)
+		gotMaxTokens = reqBody.MaxTokens
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"content": [{"text": "ok"}]}`))
+	}))
+	defer server.Close()
+
+	client := &AnthropicClient{Endpoint: server.URL}
+	if _, _, _, err := client.Send("system", "user", "key"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if gotMaxTokens != DefaultAnthropicMaxTokens {
+		t.Errorf("Expected default max_tokens %d, got %d", DefaultAnthropicMaxTokens, gotMaxTokens)
+	}
+
+	useBudgetConfig(t, config.BudgetConfig{MaxOutputTokens: 512})
+	if _, _, _, err := client.Send("system", "user", "key"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if gotMaxTokens != 512 {
+		t.Errorf("Expected max_tokens 512 from config, got %d", gotMaxTokens)
+	}
+}
@@ -0,0 +1,61 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MaxAttachmentBytes caps the combined size of all attachments on one prompt (200 KB).
+// Educational Comment: Attachments are sent on every call, so a large file silently
+// multiplies cost. A hard cap keeps an accidental paste of a log file from burning budget.
+const MaxAttachmentBytes = 200 << 10
+
+// ErrAttachmentsTooLarge is returned when attachments exceed MaxAttachmentBytes.
+var ErrAttachmentsTooLarge = errors.New("attachments exceed size limit")
+
+// Attachment is a file (or any named text) supplied as extra context for a prompt.
+type Attachment struct {
+	Filename string `json:"filename"`
+	Content  string `json:"content"`
+}
+
+// ValidateAttachments checks that every attachment has a name and that the total size is within the cap.
+func ValidateAttachments(attachments []Attachment) error {
+	total := 0
+	for i, a := range attachments {
+		if strings.TrimSpace(a.Filename) == "" {
+			return fmt.Errorf("attachment %d is missing a filename", i)
+		}
+		total += len(a.Content)
+	}
+	if total > MaxAttachmentBytes {
+		return fmt.Errorf("%w: %d bytes (max %d)", ErrAttachmentsTooLarge, total, MaxAttachmentBytes)
+	}
+	return nil
+}
+
+// AppendAttachments returns the prompt with each attachment appended in a delimited block,
+// so the model can tell the task apart from the supporting files.
+// The prompt is returned unchanged when there are no attachments.
+func AppendAttachments(prompt string, attachments []Attachment) (string, error) {
+	if len(attachments) == 0 {
+		return prompt, nil
+	}
+	if err := ValidateAttachments(attachments); err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(prompt)
+	sb.WriteString("\n\n--- Attached files ---")
+	for _, a := range attachments {
+		fmt.Fprintf(&sb, "\n\n<attachment filename=%q>\n", a.Filename)
+		sb.WriteString(a.Content)
+		if !strings.HasSuffix(a.Content, "\n") {
+			sb.WriteString("\n")
+		}
+		sb.WriteString("</attachment>")
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,49 @@
+package llm
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestAppendAttachments(t *testing.T) {
+	prompt, err := AppendAttachments("Review this code", []Attachment{
+		{Filename: "main.go", Content: "package main\n"},
+		{Filename: "notes.txt", Content: "no trailing newline"},
+	})
+	if err != nil {
+		t.Fatalf("AppendAttachments failed: %v", err)
+	}
+
+	if !strings.HasPrefix(prompt, "Review this code") {
+		t.Errorf("Expected original prompt first, got %q", prompt)
+	}
+	for _, want := range []string{
+		"<attachment filename=\"main.go\">\npackage main\n</attachment>",
+		"<attachment filename=\"notes.txt\">\nno trailing newline\n</attachment>",
+	} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("Expected prompt to contain %q, got %q", want, prompt)
+		}
+	}
+}
+
+func TestAppendAttachments_NoneLeavesPromptUnchanged(t *testing.T) {
+	prompt, err := AppendAttachments("Just the task", nil)
+	if err != nil || prompt != "Just the task" {
+		t.Errorf("Expected unchanged prompt, got %q (err %v)", prompt, err)
+	}
+}
+
+func TestValidateAttachments(t *testing.T) {
+	if err := ValidateAttachments([]Attachment{{Filename: "", Content: "x"}}); err == nil {
+		t.Error("Expected error for missing filename")
+	}
+
+	// Two files that are each under the cap but together exceed it
+	half := strings.Repeat("a", MaxAttachmentBytes/2+1)
+	err := ValidateAttachments([]Attachment{{Filename: "a", Content: half}, {Filename: "b", Content: half}})
+	if !errors.Is(err, ErrAttachmentsTooLarge) {
+		t.Errorf("Expected ErrAttachmentsTooLarge, got %v", err)
+	}
+}
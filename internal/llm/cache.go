@@ -0,0 +1,89 @@
+package llm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/config"
+)
+
+// HashPrompt returns a stable hex digest of a system and user prompt pair.
+// The two are separated by a NUL byte, so moving text from one to the other changes the hash.
+func HashPrompt(systemPrompt, userPrompt string) string {
+	sum := sha256.Sum256([]byte(systemPrompt + "\x00" + userPrompt))
+	return hex.EncodeToString(sum[:])
+}
+
+// responseCache holds successful responses in memory, keyed by prompt, model and settings.
+// The zero value is ready to use and safe for concurrent calls.
+type responseCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedResponse
+}
+
+type cachedResponse struct {
+	content string
+	expires time.Time
+}
+
+// cacheKey identifies a call: the prompt hash, the model it runs on and every setting
+// that can change the answer.
+func cacheKey(systemPrompt, userPrompt string, provider ProviderType, opts SendOptions) string {
+	model := ""
+	if models := providerModels[provider]; len(models) > 0 {
+		model = models[0]
+	}
+	return fmt.Sprintf("%s|%s/%s|max=%d|temp=%s|top_p=%s|json=%t", HashPrompt(systemPrompt, userPrompt),
+		provider, model, opts.MaxTokens, formatOptional(opts.Temperature), formatOptional(opts.TopP), opts.JSONMode)
+}
+
+func formatOptional(v *float64) string {
+	if v == nil {
+		return "default"
+	}
+	return fmt.Sprint(*v)
+}
+
+// get returns the cached content for key, if it is there and hasn't expired.
+func (c *responseCache) get(key string, now time.Time) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return "", false
+	}
+	if now.After(entry.expires) {
+		delete(c.entries, key)
+		return "", false
+	}
+	return entry.content, true
+}
+
+// put caches content under key for ttl, clearing out expired entries as it goes
+// so the cache only ever holds live responses.
+func (c *responseCache) put(key, content string, now time.Time, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = map[string]cachedResponse{}
+	}
+	for k, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = cachedResponse{content: content, expires: now.Add(ttl)}
+}
+
+// cacheSettings returns whether Cache.Enabled is on, and the TTL to cache for.
+// An unreadable config leaves caching off.
+func cacheSettings() (bool, time.Duration) {
+	cfg, err := config.Get()
+	if err != nil || !cfg.Cache.Enabled {
+		return false, 0
+	}
+	return true, cfg.Cache.TTL()
+}
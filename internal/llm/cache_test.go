@@ -0,0 +1,110 @@
+package llm
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/config"
+)
+
+// useCacheConfig saves a config with the given cache settings for the test.
+func useCacheConfig(t *testing.T, cache config.CacheConfig) {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	cfg := config.DefaultConfig()
+	cfg.Cache = cache
+	if err := config.Save(cfg); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	t.Cleanup(func() { config.Save(config.DefaultConfig()) })
+}
+
+// countingGateway returns a gateway whose OpenAI client counts its calls.
+func countingGateway() (*Gateway, *int) {
+	calls := 0
+	var mu sync.Mutex
+	return &Gateway{OpenAIClient: &MockProvider{SendFunc: func(systemPrompt, userPrompt, apiKey string) (string, int, int, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		return "answer to " + userPrompt, 100, 50, nil
+	}}}, &calls
+}
+
+func TestExecutePrompt_ResponseCache(t *testing.T) {
+	useCacheConfig(t, config.CacheConfig{Enabled: true})
+	gateway, calls := countingGateway()
+
+	first, err := gateway.ExecutePrompt("Architect", "plan it", "key", ProviderOpenAI)
+	if err != nil || first.Cached || first.Cost == 0 {
+		t.Fatalf("Expected a billed provider call first, got %+v (%v)", first, err)
+	}
+
+	// The identical call is answered from the cache, free
+	second, err := gateway.ExecutePrompt("Architect", "plan it", "key", ProviderOpenAI)
+	if err != nil {
+		t.Fatalf("Cached call failed: %v", err)
+	}
+	if *calls != 1 {
+		t.Errorf("Expected the provider to be called once, got %d calls", *calls)
+	}
+	if !second.Cached || second.Content != first.Content || second.Cost != 0 || second.InputTokens != 0 || second.OutputTokens != 0 {
+		t.Errorf("Expected the cached content at zero cost, got %+v", second)
+	}
+
+	// A different prompt, role (system prompt) or setting is a different call
+	temperature := 0.2
+	gateway.ExecutePrompt("Architect", "plan something else", "key", ProviderOpenAI)
+	gateway.ExecutePrompt("Implementation", "plan it", "key", ProviderOpenAI)
+	gateway.ExecutePromptWithOptions("Architect", "plan it", "key", ProviderOpenAI, PromptOptions{Temperature: &temperature})
+	if *calls != 4 {
+		t.Errorf("Expected 3 more provider calls for the differing calls, got %d in total", *calls)
+	}
+
+	// NoCache always goes to the provider
+	if resp, _ := gateway.ExecutePromptWithOptions("Architect", "plan it", "key", ProviderOpenAI, PromptOptions{NoCache: true}); resp.Cached || *calls != 5 {
+		t.Errorf("Expected NoCache to call the provider, got %+v after %d calls", resp, *calls)
+	}
+}
+
+func TestExecutePrompt_ResponseCacheOffByDefault(t *testing.T) {
+	useCacheConfig(t, config.CacheConfig{})
+	gateway, calls := countingGateway()
+
+	gateway.ExecutePrompt("Architect", "plan it", "key", ProviderOpenAI)
+	resp, _ := gateway.ExecutePrompt("Architect", "plan it", "key", ProviderOpenAI)
+	if *calls != 2 || resp.Cached {
+		t.Errorf("Expected every call to reach the provider with caching off, got %d calls", *calls)
+	}
+}
+
+func TestResponseCache_Expiry(t *testing.T) {
+	var cache responseCache
+	now := time.Now()
+	cache.put("key", "cached", now, time.Minute)
+
+	if content, ok := cache.get("key", now.Add(59*time.Second)); !ok || content != "cached" {
+		t.Errorf("Expected the entry within its TTL, got %q (%v)", content, ok)
+	}
+	if _, ok := cache.get("key", now.Add(61*time.Second)); ok {
+		t.Error("Expected the entry to expire after its TTL")
+	}
+}
+
+func TestResponseCache_ConcurrentUse(t *testing.T) {
+	useCacheConfig(t, config.CacheConfig{Enabled: true})
+	gateway, _ := countingGateway()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := gateway.ExecutePrompt("Architect", "plan it", "key", ProviderOpenAI); err != nil {
+				t.Errorf("Concurrent call failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,171 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrRateLimited is matched (via errors.Is) by any APIError with status 429.
+var ErrRateLimited = errors.New("rate limited by provider")
+
+// ErrEmptyResponse is returned when a provider answers successfully but with no content.
+var ErrEmptyResponse = errors.New("empty response from provider")
+
+// ErrInputTokenCap is returned when a prompt is estimated above Budget.MaxInputTokens.
+// It is raised before anything is sent, so the call costs nothing.
+var ErrInputTokenCap = errors.New("prompt exceeds input token cap")
+
+// ErrContextWindow is matched (via errors.Is) by every ContextWindowError.
+var ErrContextWindow = errors.New("prompt and response don't fit the model's context window")
+
+// ContextWindowError is returned when a prompt plus the response it asks for is estimated
+// above the model's context window. Like ErrInputTokenCap it is raised before anything is
+// sent, in place of the provider's own, less helpful, error.
+type ContextWindowError struct {
+	Provider     ProviderType
+	Model        string
+	InputTokens  int // estimated tokens of the prompt, system prompt included
+	OutputTokens int // response tokens requested (max_tokens); 0 when none were
+	Window       int // the model's context window in tokens
+}
+
+// Error says by how much the call is over, so the user knows how much to trim.
+func (e *ContextWindowError) Error() string {
+	return fmt.Sprintf("%s: ~%d prompt tokens + %d response tokens is over %s's %d-token window by ~%d; shorten the prompt or lower max output tokens",
+		ErrContextWindow, e.InputTokens, e.OutputTokens, e.Model, e.Window, e.InputTokens+e.OutputTokens-e.Window)
+}
+
+// Unwrap lets errors.Is(err, ErrContextWindow) recognise it.
+func (e *ContextWindowError) Unwrap() error {
+	return ErrContextWindow
+}
+
+// IsCapped reports whether err refused a call for its size before it was sent, either by
+// Budget.MaxInputTokens or the model's context window. Such calls are logged as CAPPED.
+func IsCapped(err error) bool {
+	return errors.Is(err, ErrInputTokenCap) || errors.Is(err, ErrContextWindow)
+}
+
+// ErrMalformedJSON is returned when a JSON-mode call answers with something that doesn't
+// parse as JSON. The call was still billed, so its LLMResponse is returned alongside it.
+var ErrMalformedJSON = errors.New("response is not valid JSON")
+
+// ErrSpendingPaused is returned for every provider call while Budget.SpendingPaused is set.
+var ErrSpendingPaused = errors.New("spending is paused; resume it to make LLM calls")
+
+// APIError is an error reported by a provider's API, either as a non-200 status
+// or as an error object in the response body.
+// Educational Comment: Callers used to string-match "401" in error messages.
+// With a typed error they can use errors.As and read the status code directly.
+type APIError struct {
+	Provider   ProviderType
+	StatusCode int    // HTTP status the provider returned
+	Message    string // The provider's error message, or the raw body if it had none
+
+	// RetryAfter is how long the provider asked us to wait (from its Retry-After header), or 0
+	RetryAfter time.Duration
+}
+
+// Error keeps the "<provider> api error (status N): message" shape existing logs rely on.
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s api error (status %d): %s", strings.ToLower(string(e.Provider)), e.StatusCode, e.Message)
+}
+
+// Unwrap lets errors.Is(err, ErrRateLimited) recognise 429 responses.
+func (e *APIError) Unwrap() error {
+	if e.StatusCode == http.StatusTooManyRequests {
+		return ErrRateLimited
+	}
+	return nil
+}
+
+// newAPIError builds an APIError from a failed response, pulling out the
+// provider's error.message when the body is the usual JSON error envelope.
+func newAPIError(provider ProviderType, resp *http.Response, body []byte) *APIError {
+	message := strings.TrimSpace(string(body))
+
+	var envelope struct {
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &envelope) == nil && envelope.Error != nil && envelope.Error.Message != "" {
+		message = envelope.Error.Message
+	}
+
+	return &APIError{
+		Provider:   provider,
+		StatusCode: resp.StatusCode,
+		Message:    message,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+}
+
+// parseRetryAfter reads a Retry-After header, which may be either a number of
+// seconds or an HTTP date. Anything unparseable (or in the past) yields 0.
+func parseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if when, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(when); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
+
+// RetryAfter returns how long the provider asked callers to wait, if the error carries that hint.
+func RetryAfter(err error) time.Duration {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.RetryAfter
+	}
+	return 0
+}
+
+// isTimeout reports whether err is a request that ran out of time before the provider answered.
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// HTTPStatusForError picks the status a handler should return for an LLM failure.
+// Auth and rate-limit problems are passed through so the user can act on them;
+// timeouts are a 504; any other provider failure is a 502 because the fault is upstream, not in Forge.
+func HTTPStatusForError(err error) int {
+	var apiErr *APIError
+	switch {
+	case errors.Is(err, ErrRateLimited):
+		return http.StatusTooManyRequests
+	case errors.As(err, &apiErr):
+		if apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden {
+			return apiErr.StatusCode
+		}
+		return http.StatusBadGateway
+	case errors.Is(err, ErrSpendingPaused):
+		return http.StatusServiceUnavailable
+	case IsCapped(err):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, ErrEmptyResponse), errors.Is(err, ErrMalformedJSON):
+		return http.StatusBadGateway
+	case isTimeout(err):
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
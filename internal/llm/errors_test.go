@@ -0,0 +1,79 @@
+// Package llm provides interfaces and implementations for communicating with Large Language Models.
+// This test file verifies the typed provider errors and how they map to HTTP statuses.
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestNewAPIError_ExtractsMessage(t *testing.T) {
+	err := newAPIError(ProviderOpenAI, &http.Response{StatusCode: 401}, []byte(`{"error": {"message": "Incorrect API key provided"}}`))
+	if err.Message != "Incorrect API key provided" {
+		t.Errorf("Expected provider message, got %q", err.Message)
+	}
+	if err.Error() != "openai api error (status 401): Incorrect API key provided" {
+		t.Errorf("Unexpected error string: %s", err.Error())
+	}
+
+	raw := newAPIError(ProviderAnthropic, &http.Response{StatusCode: 502}, []byte("Bad Gateway"))
+	if raw.Message != "Bad Gateway" {
+		t.Errorf("Expected raw body as message, got %q", raw.Message)
+	}
+}
+
+func TestAPIError_RateLimitedIs(t *testing.T) {
+	rateLimited := error(&APIError{Provider: ProviderOpenAI, StatusCode: 429})
+	if !errors.Is(rateLimited, ErrRateLimited) {
+		t.Error("Expected a 429 APIError to match ErrRateLimited")
+	}
+
+	unauthorized := error(&APIError{Provider: ProviderOpenAI, StatusCode: 401})
+	if errors.Is(unauthorized, ErrRateLimited) {
+		t.Error("A 401 APIError should not match ErrRateLimited")
+	}
+}
+
+func TestHTTPStatusForError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected int
+	}{
+		{"unauthorized", &APIError{Provider: ProviderOpenAI, StatusCode: 401}, http.StatusUnauthorized},
+		{"forbidden", &APIError{Provider: ProviderAnthropic, StatusCode: 403}, http.StatusForbidden},
+		{"rate limited", &APIError{Provider: ProviderAnthropic, StatusCode: 429}, http.StatusTooManyRequests},
+		{"wrapped rate limit", fmt.Errorf("node failed: %w", &APIError{StatusCode: 429}), http.StatusTooManyRequests},
+		{"provider outage", &APIError{Provider: ProviderOpenAI, StatusCode: 503}, http.StatusBadGateway},
+		{"empty response", fmt.Errorf("%w: empty response choices", ErrEmptyResponse), http.StatusBadGateway},
+		{"timeout", fmt.Errorf("failed to send request: %w", context.DeadlineExceeded), http.StatusGatewayTimeout},
+		{"unknown", errors.New("boom"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HTTPStatusForError(tt.err); got != tt.expected {
+				t.Errorf("Expected %d, got %d", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if got := parseRetryAfter("60"); got != 60*time.Second {
+		t.Errorf("Expected 60s, got %v", got)
+	}
+	future := time.Now().Add(2 * time.Minute).UTC().Format(http.TimeFormat)
+	if got := parseRetryAfter(future); got <= time.Minute || got > 2*time.Minute {
+		t.Errorf("Expected roughly 2m from an HTTP date, got %v", got)
+	}
+	for _, value := range []string{"", "soon", "-5"} {
+		if got := parseRetryAfter(value); got != 0 {
+			t.Errorf("Expected 0 for %q, got %v", value, got)
+		}
+	}
+}
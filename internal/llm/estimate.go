@@ -0,0 +1,36 @@
+package llm
+
+import (
+	"fmt"
+)
+
+// PromptEstimate is the projected input size and cost of one call, worked out locally.
+type PromptEstimate struct {
+	InputTokens int
+	CostUSD     float64 // Cost of the input tokens at the provider's rate
+}
+
+// EstimatePrompt projects what ExecutePrompt would send for this role and prompt,
+// and what those input tokens would cost, without calling the provider.
+// Educational Comment: The response length can't be known until the model answers,
+// so this is a floor on the real cost rather than a quote.
+func EstimatePrompt(agentRole, userPrompt string, provider ProviderType) (PromptEstimate, error) {
+	return EstimatePromptWithSystem(agentRole, "", userPrompt, provider)
+}
+
+// EstimatePromptWithSystem is EstimatePrompt for a call made with ExecutePromptWithSystem.
+func EstimatePromptWithSystem(agentRole, systemOverride, userPrompt string, provider ProviderType) (PromptEstimate, error) {
+	systemPrompt, err := ResolveSystemPrompt(agentRole, systemOverride)
+	if err != nil {
+		return PromptEstimate{}, err
+	}
+	if provider != ProviderAnthropic && provider != ProviderOpenAI {
+		return PromptEstimate{}, fmt.Errorf("unsupported provider: %s", provider)
+	}
+
+	tokens := estimateInputTokens(systemPrompt, userPrompt, provider)
+	return PromptEstimate{
+		InputTokens: tokens,
+		CostUSD:     calculateCost(provider, tokens, 0),
+	}, nil
+}
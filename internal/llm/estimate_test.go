@@ -0,0 +1,35 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEstimatePrompt(t *testing.T) {
+	short, err := EstimatePrompt("Implementation", "Write a function", ProviderAnthropic)
+	if err != nil {
+		t.Fatalf("EstimatePrompt failed: %v", err)
+	}
+	if short.InputTokens <= 0 {
+		t.Fatalf("Expected a positive token count, got %d", short.InputTokens)
+	}
+	if want := calculateCost(ProviderAnthropic, short.InputTokens, 0); short.CostUSD != want {
+		t.Errorf("Expected input cost %v, got %v", want, short.CostUSD)
+	}
+
+	// A longer prompt costs more, and the system prompt is counted even for an empty one
+	long, _ := EstimatePrompt("Implementation", strings.Repeat("refactor the parser ", 200), ProviderAnthropic)
+	empty, _ := EstimatePrompt("Implementation", "", ProviderAnthropic)
+	if long.InputTokens <= short.InputTokens || empty.InputTokens <= 0 {
+		t.Errorf("Expected empty (%d) > 0 and long (%d) > short (%d)", empty.InputTokens, long.InputTokens, short.InputTokens)
+	}
+}
+
+func TestEstimatePrompt_Errors(t *testing.T) {
+	if _, err := EstimatePrompt("Implementation", "hi", ProviderType("Gemini")); err == nil {
+		t.Error("Expected an error for an unsupported provider")
+	}
+	if _, err := EstimatePrompt("NotARole", "hi", ProviderOpenAI); err == nil {
+		t.Error("Expected an error for an unknown role")
+	}
+}
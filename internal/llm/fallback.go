@@ -0,0 +1,104 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// FallbackAttempt is one call made while working down a fallback chain.
+type FallbackAttempt struct {
+	Provider ProviderType
+	Response *LLMResponse // nil when the call failed, except with ErrMalformedJSON
+	Err      error
+	Latency  time.Duration
+}
+
+// ShouldFallBack reports whether a failure means the provider can't take the call
+// right now (rate limited or overloaded), so the next one in the chain is worth trying.
+func ShouldFallBack(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode == http.StatusServiceUnavailable
+}
+
+// ErrUnknownFallback means a fallback entry names neither a provider nor a model one runs.
+var ErrUnknownFallback = errors.New("unknown fallback")
+
+// ResolveFallback maps a fallback entry onto the provider to try. An entry is a provider
+// name ("OpenAI") or a model that a provider's client runs ("gpt-4o"), matched regardless
+// of case. Any other model is refused rather than quietly answered by a different one.
+func ResolveFallback(name string) (ProviderType, error) {
+	var accepted []string
+	for _, provider := range providerOrder {
+		if strings.EqualFold(name, string(provider)) {
+			return provider, nil
+		}
+		for _, model := range providerModels[provider] {
+			if strings.EqualFold(name, model) {
+				return provider, nil
+			}
+		}
+		accepted = append(accepted, string(provider))
+		accepted = append(accepted, providerModels[provider]...)
+	}
+	return "", fmt.Errorf("%w %q: use one of %s", ErrUnknownFallback, name, strings.Join(accepted, ", "))
+}
+
+// FallbackChain is the primary provider followed by its fallbacks, without repeats or blanks.
+// Each fallback is resolved with ResolveFallback; the first it doesn't know fails the chain.
+func FallbackChain(primary ProviderType, fallbacks []string) ([]ProviderType, error) {
+	chain := []ProviderType{primary}
+	seen := map[ProviderType]bool{primary: true}
+	for _, name := range fallbacks {
+		if name == "" {
+			continue
+		}
+		provider, err := ResolveFallback(name)
+		if err != nil {
+			return nil, err
+		}
+		if seen[provider] {
+			continue
+		}
+		seen[provider] = true
+		chain = append(chain, provider)
+	}
+	return chain, nil
+}
+
+// ExecuteWithFallback runs the prompt on the first provider of chain, moving on to the
+// next whenever one answers 429 or 503. It stops at the first success, at any other
+// error, or when the chain runs out, and returns the provider that produced the result.
+// apiKey looks up each provider's key; a fallback without a key is skipped.
+// onAttempt, if set, is told about every call made so callers can log it.
+// Educational Comment: Each provider is billed at its own rate, so list cheaper providers
+// first among the fallbacks to keep a rate-limited run from getting more expensive.
+func (g *Gateway) ExecuteWithFallback(agentRole, userPrompt string, opts PromptOptions, chain []ProviderType, apiKey func(ProviderType) (string, error), onAttempt func(FallbackAttempt)) (*LLMResponse, ProviderType, error) {
+	var lastErr error
+	var lastProvider ProviderType
+	for i, provider := range chain {
+		key, err := apiKey(provider)
+		if err != nil {
+			if i == 0 {
+				return nil, provider, err
+			}
+			continue
+		}
+
+		start := time.Now()
+		resp, err := g.ExecutePromptWithOptions(agentRole, userPrompt, key, provider, opts)
+		if onAttempt != nil {
+			onAttempt(FallbackAttempt{Provider: provider, Response: resp, Err: err, Latency: time.Since(start)})
+		}
+		if err == nil || !ShouldFallBack(err) {
+			return resp, provider, err
+		}
+		lastErr, lastProvider = err, provider
+	}
+	return nil, lastProvider, lastErr
+}
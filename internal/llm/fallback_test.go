@@ -0,0 +1,107 @@
+package llm
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestShouldFallBack(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"rate limited", &APIError{Provider: ProviderAnthropic, StatusCode: http.StatusTooManyRequests}, true},
+		{"overloaded", &APIError{Provider: ProviderAnthropic, StatusCode: http.StatusServiceUnavailable}, true},
+		{"bad key", &APIError{Provider: ProviderAnthropic, StatusCode: http.StatusUnauthorized}, false},
+		{"server error", &APIError{Provider: ProviderAnthropic, StatusCode: http.StatusInternalServerError}, false},
+		{"spending paused", ErrSpendingPaused, false},
+		{"network", errors.New("connection reset"), false},
+	}
+	for _, tt := range tests {
+		if got := ShouldFallBack(tt.err); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestFallbackChain(t *testing.T) {
+	got, err := FallbackChain(ProviderAnthropic, []string{"OpenAI", "", "Anthropic", "OpenAI"})
+	want := []ProviderType{ProviderAnthropic, ProviderOpenAI}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v (%v)", want, got, err)
+	}
+
+	// Models the gateway runs stand for their provider
+	got, err = FallbackChain(ProviderOpenAI, []string{"GPT-4o", AnthropicModel, "anthropic"})
+	want = []ProviderType{ProviderOpenAI, ProviderAnthropic}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v (%v)", want, got, err)
+	}
+
+	// A provider or model the gateway can't run is refused, not swapped for another
+	for _, name := range []string{"Gemini", "gpt-4", "claude-3-haiku"} {
+		if _, err := FallbackChain(ProviderAnthropic, []string{name}); !errors.Is(err, ErrUnknownFallback) {
+			t.Errorf("%s: expected ErrUnknownFallback, got %v", name, err)
+		}
+	}
+}
+
+func TestExecuteWithFallback(t *testing.T) {
+	rateLimited := &MockProvider{SendFunc: func(systemPrompt, userPrompt, apiKey string) (string, int, int, error) {
+		return "", 0, 0, &APIError{Provider: ProviderAnthropic, StatusCode: http.StatusTooManyRequests, Message: "slow down"}
+	}}
+	var fallbackKey string
+	healthy := &MockProvider{SendFunc: func(systemPrompt, userPrompt, apiKey string) (string, int, int, error) {
+		fallbackKey = apiKey
+		return "fallback answer", 10, 20, nil
+	}}
+	gateway := &Gateway{AnthropicClient: rateLimited, OpenAIClient: healthy}
+	keys := func(p ProviderType) (string, error) { return "key-" + string(p), nil }
+
+	var attempts []FallbackAttempt
+	resp, provider, err := gateway.ExecuteWithFallback("Architect", "hello", PromptOptions{}, []ProviderType{ProviderAnthropic, ProviderOpenAI}, keys,
+		func(a FallbackAttempt) { attempts = append(attempts, a) })
+	if err != nil {
+		t.Fatalf("Expected the fallback to succeed, got %v", err)
+	}
+	if provider != ProviderOpenAI || resp.Content != "fallback answer" {
+		t.Errorf("Expected OpenAI's answer, got %s %q", provider, resp.Content)
+	}
+	if fallbackKey != "key-OpenAI" {
+		t.Errorf("Expected the fallback to use its own key, got %q", fallbackKey)
+	}
+	if len(attempts) != 2 || attempts[0].Provider != ProviderAnthropic || attempts[0].Err == nil ||
+		attempts[1].Provider != ProviderOpenAI || attempts[1].Response == nil {
+		t.Errorf("Expected a failed Anthropic attempt then a successful OpenAI one, got %+v", attempts)
+	}
+
+	// Errors other than 429/503 don't fall back
+	failing := &MockProvider{SendFunc: func(systemPrompt, userPrompt, apiKey string) (string, int, int, error) {
+		return "", 0, 0, &APIError{Provider: ProviderAnthropic, StatusCode: http.StatusUnauthorized, Message: "bad key"}
+	}}
+	gateway = &Gateway{AnthropicClient: failing, OpenAIClient: healthy}
+	attempts = nil
+	if _, _, err := gateway.ExecuteWithFallback("Architect", "hello", PromptOptions{}, []ProviderType{ProviderAnthropic, ProviderOpenAI}, keys,
+		func(a FallbackAttempt) { attempts = append(attempts, a) }); err == nil {
+		t.Error("Expected the 401 to be returned")
+	}
+	if len(attempts) != 1 {
+		t.Errorf("Expected no fallback after a 401, got %d attempts", len(attempts))
+	}
+
+	// An exhausted chain returns the last error; a fallback without a key is skipped
+	gateway = &Gateway{AnthropicClient: rateLimited, OpenAIClient: healthy}
+	noOpenAIKey := func(p ProviderType) (string, error) {
+		if p == ProviderOpenAI {
+			return "", errors.New("no key")
+		}
+		return "key", nil
+	}
+	_, _, err = gateway.ExecuteWithFallback("Architect", "hello", PromptOptions{}, []ProviderType{ProviderAnthropic, ProviderOpenAI}, noOpenAIKey, nil)
+	if !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Expected the rate limit error once the chain ran out, got %v", err)
+	}
+}
@@ -1,9 +1,13 @@
 package llm
 
 import (
+	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/mikejsmith1985/forge-orchestrator/internal/agents"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/metrics"
 )
 
 // ProviderType defines the supported LLM providers.
@@ -20,6 +24,8 @@ type LLMResponse struct {
 	InputTokens  int
 	OutputTokens int
 	Cost         float64
+	AgentRole    string // Canonical role the prompt ran as, e.g. "Implementation" for "coder"
+	Cached       bool   // answered from the response cache: no provider call, no tokens, no cost
 }
 
 // LLMProvider is the interface that specific provider clients must implement.
@@ -27,10 +33,61 @@ type LLMProvider interface {
 	Send(systemPrompt, userPrompt, apiKey string) (string, int, int, error)
 }
 
+// SendOptions are per-call request settings. Zero values leave the client's defaults.
+type SendOptions struct {
+	MaxTokens   int      // response cap; 0 = the client's cap
+	Temperature *float64 // nil = the provider's default
+	TopP        *float64 // nil = the provider's default
+	JSONMode    bool     // ask the provider for a JSON object as the whole response
+}
+
+// OptionSender is implemented by providers that accept per-call request settings.
+// The gateway falls back to Send for providers without it.
+type OptionSender interface {
+	SendWithOptions(systemPrompt, userPrompt, apiKey string, opts SendOptions) (string, int, int, error)
+}
+
+// ResponseFormatJSON is the PromptOptions.ResponseFormat that requires a JSON response.
+const ResponseFormatJSON = "json"
+
+// PromptOptions are the per-call settings of ExecutePromptWithOptions.
+type PromptOptions struct {
+	// SystemOverride, when set, is sent instead of the agent role's persona
+	SystemOverride string
+
+	// MaxOutputTokens caps this call's response, sent as max_tokens. It can't raise
+	// Budget.MaxOutputTokens. 0 leaves the client's cap, which defaults to the model's maximum.
+	MaxOutputTokens int
+
+	// Temperature and TopP tune sampling, sent as temperature and top_p. nil omits them
+	// so the provider's default applies.
+	Temperature *float64
+	TopP        *float64
+
+	// ResponseFormat set to ResponseFormatJSON asks the provider for a JSON object and
+	// checks the response parses, failing with ErrMalformedJSON if not. "" allows any text.
+	ResponseFormat string
+
+	// NoCache always calls the provider, even with Cache.Enabled, e.g. for a replay
+	NoCache bool
+}
+
+// sendOptions returns the request settings for the client, and whether any are set.
+func (o PromptOptions) sendOptions() (SendOptions, bool) {
+	opts := SendOptions{Temperature: o.Temperature, TopP: o.TopP, JSONMode: o.ResponseFormat == ResponseFormatJSON}
+	if o.MaxOutputTokens > 0 {
+		opts.MaxTokens = capMaxOutputTokens(o.MaxOutputTokens)
+	}
+	return opts, opts.MaxTokens > 0 || opts.Temperature != nil || opts.TopP != nil || opts.JSONMode
+}
+
 // Gateway handles routing prompts to the appropriate provider.
 type Gateway struct {
 	AnthropicClient LLMProvider
 	OpenAIClient    LLMProvider
+
+	// cache answers repeats of identical calls while Cache.Enabled is on
+	cache responseCache
 }
 
 // NewGateway creates a new Gateway with initialized clients.
@@ -44,25 +101,83 @@ func NewGateway() *Gateway {
 // ExecutePrompt routes the prompt to the specified provider and calculates cost.
 // It selects the system prompt based on the agentRole.
 func (g *Gateway) ExecutePrompt(agentRole, userPrompt, apiKey string, provider ProviderType) (*LLMResponse, error) {
-	systemPrompt, err := agents.GetAgentPrompt(agentRole)
+	return g.ExecutePromptWithSystem(agentRole, "", userPrompt, apiKey, provider)
+}
+
+// ExecutePromptWithSystem is ExecutePrompt with an optional system prompt override.
+// A non-empty systemOverride is sent instead of the agentRole's persona.
+func (g *Gateway) ExecutePromptWithSystem(agentRole, systemOverride, userPrompt, apiKey string, provider ProviderType) (*LLMResponse, error) {
+	return g.ExecutePromptWithOptions(agentRole, userPrompt, apiKey, provider, PromptOptions{SystemOverride: systemOverride})
+}
+
+// ExecutePromptWithOptions is ExecutePrompt with per-call settings such as a response cap.
+func (g *Gateway) ExecutePromptWithOptions(agentRole, userPrompt, apiKey string, provider ProviderType, opts PromptOptions) (*LLMResponse, error) {
+	systemPrompt, err := ResolveSystemPrompt(agentRole, opts.SystemOverride)
 	if err != nil {
 		return nil, err
 	}
+	if opts.ResponseFormat != "" && opts.ResponseFormat != ResponseFormatJSON {
+		return nil, fmt.Errorf("unsupported response format %q (use %q or leave it empty)", opts.ResponseFormat, ResponseFormatJSON)
+	}
+	sendOpts, sendOptsSet := opts.sendOptions()
+
+	// A repeat of a call that is still cached costs nothing, so it is answered even
+	// while spending is paused or the prompt is over the input cap
+	cacheEnabled, cacheTTL := cacheSettings()
+	cacheEnabled = cacheEnabled && !opts.NoCache
+	var key string
+	if cacheEnabled {
+		key = cacheKey(systemPrompt, userPrompt, provider, sendOpts)
+		if content, ok := g.cache.get(key, time.Now()); ok {
+			metrics.LLMCalls.Inc(string(provider), "cache_hit")
+			return &LLMResponse{Content: content, AgentRole: agents.CanonicalRole(agentRole), Cached: true}, nil
+		}
+	}
+
+	// Honour the emergency stop before anything is sent. The StubAdapter never
+	// goes through the gateway, so stubbed runs keep working while paused.
+	if err := checkSpendingPaused(); err != nil {
+		metrics.LLMCalls.Inc(string(provider), "paused")
+		return nil, err
+	}
+
+	// Refuse oversized prompts before they reach (and are billed by) the provider
+	if err := checkInputTokenCap(systemPrompt, userPrompt, provider); err != nil {
+		metrics.LLMCalls.Inc(string(provider), "capped")
+		return nil, err
+	}
 
 	var content string
 	var inputTokens, outputTokens int
 	var sendErr error
 
+	var client LLMProvider
 	switch provider {
 	case ProviderAnthropic:
-		content, inputTokens, outputTokens, sendErr = g.AnthropicClient.Send(systemPrompt, userPrompt, apiKey)
+		client = g.AnthropicClient
 	case ProviderOpenAI:
-		content, inputTokens, outputTokens, sendErr = g.OpenAIClient.Send(systemPrompt, userPrompt, apiKey)
+		client = g.OpenAIClient
 	default:
 		return nil, fmt.Errorf("unsupported provider: %s", provider)
 	}
 
+	// Refuse a call the model couldn't take anyway, with an error saying how far over it is
+	if err := checkContextWindow(systemPrompt, userPrompt, provider, requestedOutputTokens(client, sendOpts)); err != nil {
+		metrics.LLMCalls.Inc(string(provider), "capped")
+		return nil, err
+	}
+
+	startTime := time.Now()
+	sender, canSendOptions := client.(OptionSender)
+	if canSendOptions && sendOptsSet {
+		content, inputTokens, outputTokens, sendErr = sender.SendWithOptions(systemPrompt, userPrompt, apiKey, sendOpts)
+	} else {
+		content, inputTokens, outputTokens, sendErr = client.Send(systemPrompt, userPrompt, apiKey)
+	}
+	metrics.LLMLatency.Observe(time.Since(startTime).Seconds(), string(provider))
+
 	if sendErr != nil {
+		metrics.LLMCalls.Inc(string(provider), "error")
 		return nil, sendErr
 	}
 
@@ -71,7 +186,11 @@ func (g *Gateway) ExecutePrompt(agentRole, userPrompt, apiKey string, provider P
 
 	// Attempt to extract JSON. If successful, use the extracted JSON.
 	// If not, we keep the cleaned content as is (best effort).
-	if jsonContent, err := ExtractJSON(content); err == nil {
+	// JSON mode skips this: the whole answer must be the object, and extracting could pass
+	// off an inner object of a truncated answer as valid.
+	if opts.ResponseFormat == ResponseFormatJSON {
+		content = strings.TrimSpace(content)
+	} else if jsonContent, err := ExtractJSON(content); err == nil {
 		content = jsonContent
 	}
 
@@ -81,31 +200,46 @@ func (g *Gateway) ExecutePrompt(agentRole, userPrompt, apiKey string, provider P
 	}
 
 	cost := calculateCost(provider, inputTokens, outputTokens)
-
-	return &LLMResponse{
+	response := &LLMResponse{
 		Content:      content,
 		InputTokens:  inputTokens,
 		OutputTokens: outputTokens,
 		Cost:         cost,
-	}, nil
+		AgentRole:    agents.CanonicalRole(agentRole),
+	}
+
+	metrics.LLMTokens.Add(float64(inputTokens), string(provider), "input")
+	metrics.LLMTokens.Add(float64(outputTokens), string(provider), "output")
+	metrics.LLMCostUSD.Add(cost, string(provider))
+
+	// A JSON-mode call is only good if the answer parses; it's billed either way
+	if opts.ResponseFormat == ResponseFormatJSON && !json.Valid([]byte(content)) {
+		metrics.LLMCalls.Inc(string(provider), "malformed_json")
+		return response, fmt.Errorf("%w from %s", ErrMalformedJSON, provider)
+	}
+
+	if cacheEnabled {
+		g.cache.put(key, content, time.Now(), cacheTTL)
+	}
+	metrics.LLMCalls.Inc(string(provider), "success")
+	return response, nil
+}
+
+// ResolveSystemPrompt returns systemOverride if set, otherwise the persona for agentRole.
+func ResolveSystemPrompt(agentRole, systemOverride string) (string, error) {
+	if strings.TrimSpace(systemOverride) != "" {
+		return systemOverride, nil
+	}
+	return agents.GetAgentPrompt(agentRole)
 }
 
-// calculateCost estimates the cost based on provider pricing (as of late 2024/2025).
+// calculateCost estimates the cost of a call from the provider's Pricing.
 // Educational Comment: Token counting and cost estimation are crucial for budget management in LLM apps.
-// We use hardcoded rates here, but in production, these should be configurable.
+// Prompt-billed providers charge the same for every call, whatever its size.
 func calculateCost(provider ProviderType, input, output int) float64 {
-	var inputRate, outputRate float64
-
-	switch provider {
-	case ProviderAnthropic:
-		// Claude 3.5 Sonnet: ~$3.00/1M input, ~$15.00/1M output
-		inputRate = 3.00 / 1_000_000
-		outputRate = 15.00 / 1_000_000
-	case ProviderOpenAI:
-		// GPT-4o: ~$5.00/1M input, ~$15.00/1M output
-		inputRate = 5.00 / 1_000_000
-		outputRate = 15.00 / 1_000_000
+	pricing := Pricing(provider)
+	if pricing.PrimaryCostUnit == CostUnitPrompt {
+		return pricing.PromptRate
 	}
-
-	return (float64(input) * inputRate) + (float64(output) * outputRate)
+	return (float64(input)*pricing.InputRate + float64(output)*pricing.OutputRate) / 1_000_000
 }
@@ -1,11 +1,15 @@
 package llm
 
 import (
+	"encoding/json"
 	"errors"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 
 	"github.com/mikejsmith1985/forge-orchestrator/internal/agents"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/config"
 )
 
 // MockProvider implements LLMProvider for testing.
@@ -42,6 +46,31 @@ func TestExecutePrompt_SystemPromptSelection(t *testing.T) {
 	}
 }
 
+func TestExecutePromptWithSystem_Override(t *testing.T) {
+	var gotSystem string
+	gateway := &Gateway{
+		AnthropicClient: &MockProvider{SendFunc: func(systemPrompt, userPrompt, apiKey string) (string, int, int, error) {
+			gotSystem = systemPrompt
+			return "response", 10, 20, nil
+		}},
+	}
+
+	if _, err := gateway.ExecutePromptWithSystem("Architect", "Answer in haiku.", "hello", "key", ProviderAnthropic); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotSystem != "Answer in haiku." {
+		t.Errorf("Expected the override to be sent, got %q", gotSystem)
+	}
+
+	// An empty override falls back to the role's persona
+	if _, err := gateway.ExecutePromptWithSystem("Architect", "", "hello", "key", ProviderAnthropic); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if persona, _ := agents.GetAgentPrompt("Architect"); gotSystem != persona {
+		t.Errorf("Expected the Architect persona without an override, got %q", gotSystem)
+	}
+}
+
 func TestExecutePrompt_TokenCountingAndCost(t *testing.T) {
 	mockProvider := &MockProvider{
 		SendFunc: func(systemPrompt, userPrompt, apiKey string) (string, int, int, error) {
@@ -181,3 +210,339 @@ func TestCalculateCost_UnknownProvider(t *testing.T) {
 		t.Errorf("expected 0 cost for unknown provider, got %f", cost)
 	}
 }
+
+func TestPricing(t *testing.T) {
+	for _, provider := range []ProviderType{ProviderAnthropic, ProviderOpenAI} {
+		pricing := Pricing(provider)
+		if pricing.PrimaryCostUnit != CostUnitToken || pricing.InputRate <= 0 || pricing.OutputRate <= 0 {
+			t.Errorf("%s: expected per-token rates, got %+v", provider, pricing)
+		}
+		// 1M input and 1M output tokens cost exactly the two rates
+		if cost := calculateCost(provider, 1_000_000, 1_000_000); cost != pricing.InputRate+pricing.OutputRate {
+			t.Errorf("%s: expected $%v for 1M+1M tokens, got $%v", provider, pricing.InputRate+pricing.OutputRate, cost)
+		}
+	}
+}
+
+func TestCalculateCost_PromptBilledProvider(t *testing.T) {
+	useBudgetConfig(t, config.BudgetConfig{Pricing: map[string]config.ProviderPricing{
+		string(ProviderAnthropic): {CostUnit: "prompt", PromptRate: 0.02},
+	}})
+
+	if pricing := Pricing(ProviderAnthropic); pricing.PrimaryCostUnit != CostUnitPrompt || pricing.PromptRate != 0.02 {
+		t.Fatalf("Expected the override to bill Anthropic per prompt, got %+v", pricing)
+	}
+	// The flat rate applies whatever the call's size
+	for _, tokens := range []int{0, 1, 10, 4_000, 1_000_000} {
+		if cost := calculateCost(ProviderAnthropic, tokens, tokens); cost != 0.02 {
+			t.Errorf("%d tokens: expected $0.02, got $%v", tokens, cost)
+		}
+	}
+	// Providers without an override keep their built-in per-token prices
+	if pricing := Pricing(ProviderOpenAI); pricing.PrimaryCostUnit != CostUnitToken {
+		t.Errorf("Expected OpenAI to stay per token, got %+v", pricing)
+	}
+
+	gateway := &Gateway{
+		AnthropicClient: &MockProvider{SendFunc: func(systemPrompt, userPrompt, apiKey string) (string, int, int, error) {
+			return "ok", 123_456, 78_901, nil
+		}},
+	}
+	resp, err := gateway.ExecutePrompt("Implementation", "hello", "key", ProviderAnthropic)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Cost != 0.02 {
+		t.Errorf("Expected a prompt-billed call to cost $0.02, got $%v", resp.Cost)
+	}
+}
+
+// useBudgetConfig points the config at a temp dir with the given budget settings.
+func useBudgetConfig(t *testing.T, budget config.BudgetConfig) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	cfg := config.DefaultConfig()
+	cfg.Budget = budget
+	if err := config.Save(cfg); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	t.Cleanup(func() { config.Save(config.DefaultConfig()) })
+}
+
+func TestExecutePrompt_InputTokenCap(t *testing.T) {
+	// The Implementation system prompt alone is well over 50 tokens
+	useBudgetConfig(t, config.BudgetConfig{MaxInputTokens: 500})
+
+	called := false
+	gateway := &Gateway{
+		AnthropicClient: &MockProvider{SendFunc: func(systemPrompt, userPrompt, apiKey string) (string, int, int, error) {
+			called = true
+			return "ok", 1, 1, nil
+		}},
+	}
+
+	_, err := gateway.ExecutePrompt("Implementation", strings.Repeat("lorem ipsum dolor ", 1000), "key", ProviderAnthropic)
+	if !errors.Is(err, ErrInputTokenCap) {
+		t.Fatalf("Expected ErrInputTokenCap, got %v", err)
+	}
+	if called {
+		t.Error("Provider should not be called for an over-cap prompt")
+	}
+
+	if _, err := gateway.ExecutePrompt("Implementation", "small prompt", "key", ProviderAnthropic); err != nil {
+		t.Errorf("Expected a small prompt to pass the cap, got %v", err)
+	}
+	if !called {
+		t.Error("Expected provider to be called for an in-cap prompt")
+	}
+}
+
+func TestExecutePrompt_NoInputTokenCapByDefault(t *testing.T) {
+	useBudgetConfig(t, config.BudgetConfig{})
+
+	gateway := &Gateway{
+		AnthropicClient: &MockProvider{SendFunc: func(systemPrompt, userPrompt, apiKey string) (string, int, int, error) {
+			return "ok", 1, 1, nil
+		}},
+	}
+	if _, err := gateway.ExecutePrompt("Implementation", strings.Repeat("word ", 50000), "key", ProviderAnthropic); err != nil {
+		t.Errorf("Expected no cap when MaxInputTokens is 0, got %v", err)
+	}
+}
+
+func TestExecutePrompt_SpendingPaused(t *testing.T) {
+	useBudgetConfig(t, config.BudgetConfig{SpendingPaused: true})
+
+	called := false
+	gateway := &Gateway{
+		OpenAIClient: &MockProvider{SendFunc: func(systemPrompt, userPrompt, apiKey string) (string, int, int, error) {
+			called = true
+			return "ok", 1, 1, nil
+		}},
+	}
+
+	_, err := gateway.ExecutePrompt("Implementation", "hello", "key", ProviderOpenAI)
+	if !errors.Is(err, ErrSpendingPaused) {
+		t.Fatalf("Expected ErrSpendingPaused, got %v", err)
+	}
+	if called {
+		t.Error("Provider should not be called while spending is paused")
+	}
+	if HTTPStatusForError(err) != 503 {
+		t.Errorf("Expected paused calls to map to 503, got %d", HTTPStatusForError(err))
+	}
+}
+
+func TestExecutePromptWithOptions_MaxOutputTokens(t *testing.T) {
+	useBudgetConfig(t, config.BudgetConfig{})
+
+	// Both fake providers record the max_tokens they were sent (nil when omitted)
+	sent := map[ProviderType]interface{}{}
+	fakeProvider := func(provider ProviderType, reply string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var body map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			sent[provider] = body["max_tokens"]
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(reply))
+		}))
+	}
+	openAI := fakeProvider(ProviderOpenAI, `{"choices": [{"message": {"content": "ok"}}]}`)
+	defer openAI.Close()
+	anthropic := fakeProvider(ProviderAnthropic, `{"content": [{"text": "ok"}]}`)
+	defer anthropic.Close()
+	gateway := &Gateway{
+		OpenAIClient:    &OpenAIClient{Endpoint: openAI.URL},
+		AnthropicClient: &AnthropicClient{Endpoint: anthropic.URL},
+	}
+
+	run := func(maxOutputTokens int) {
+		t.Helper()
+		for _, provider := range []ProviderType{ProviderOpenAI, ProviderAnthropic} {
+			if _, err := gateway.ExecutePromptWithOptions("Architect", "hello", "key", provider, PromptOptions{MaxOutputTokens: maxOutputTokens}); err != nil {
+				t.Fatalf("%s call failed: %v", provider, err)
+			}
+		}
+	}
+
+	run(123)
+	if sent[ProviderOpenAI] != float64(123) || sent[ProviderAnthropic] != float64(123) {
+		t.Errorf("Expected max_tokens 123 in both request bodies, got %v", sent)
+	}
+
+	// Unset means the model's maximum: OpenAI's own default, Anthropic's documented cap
+	run(0)
+	if sent[ProviderOpenAI] != nil || sent[ProviderAnthropic] != float64(DefaultAnthropicMaxTokens) {
+		t.Errorf("Expected the model defaults without a cap, got %v", sent)
+	}
+
+	// A per-call cap can lower the global one but not lift it
+	useBudgetConfig(t, config.BudgetConfig{MaxOutputTokens: 100})
+	run(500)
+	if sent[ProviderOpenAI] != float64(100) || sent[ProviderAnthropic] != float64(100) {
+		t.Errorf("Expected the global cap of 100 to win, got %v", sent)
+	}
+	run(50)
+	if sent[ProviderOpenAI] != float64(50) || sent[ProviderAnthropic] != float64(50) {
+		t.Errorf("Expected the lower per-call cap of 50, got %v", sent)
+	}
+}
+
+func TestExecutePromptWithOptions_Sampling(t *testing.T) {
+	useBudgetConfig(t, config.BudgetConfig{})
+
+	// Both fake providers record the request body they were sent
+	sent := map[ProviderType]map[string]interface{}{}
+	fakeProvider := func(provider ProviderType, reply string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var body map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			sent[provider] = body
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(reply))
+		}))
+	}
+	openAI := fakeProvider(ProviderOpenAI, `{"choices": [{"message": {"content": "ok"}}]}`)
+	defer openAI.Close()
+	anthropic := fakeProvider(ProviderAnthropic, `{"content": [{"text": "ok"}]}`)
+	defer anthropic.Close()
+	gateway := &Gateway{
+		OpenAIClient:    &OpenAIClient{Endpoint: openAI.URL},
+		AnthropicClient: &AnthropicClient{Endpoint: anthropic.URL},
+	}
+
+	run := func(opts PromptOptions) {
+		t.Helper()
+		for _, provider := range []ProviderType{ProviderOpenAI, ProviderAnthropic} {
+			if _, err := gateway.ExecutePromptWithOptions("Architect", "hello", "key", provider, opts); err != nil {
+				t.Fatalf("%s call failed: %v", provider, err)
+			}
+		}
+	}
+
+	// Zero is a real setting, not "unset"
+	temperature, topP := 0.0, 0.9
+	run(PromptOptions{Temperature: &temperature, TopP: &topP})
+	for provider, body := range sent {
+		if body["temperature"] != float64(0) || body["top_p"] != 0.9 {
+			t.Errorf("Expected temperature 0 and top_p 0.9 in the %s request body, got %v", provider, body)
+		}
+	}
+
+	// Unset leaves the provider's defaults
+	run(PromptOptions{})
+	for provider, body := range sent {
+		if _, ok := body["temperature"]; ok {
+			t.Errorf("Expected no temperature in the %s request body, got %v", provider, body)
+		}
+		if _, ok := body["top_p"]; ok {
+			t.Errorf("Expected no top_p in the %s request body, got %v", provider, body)
+		}
+	}
+}
+
+func TestExecutePromptWithOptions_JSONMode(t *testing.T) {
+	useBudgetConfig(t, config.BudgetConfig{})
+
+	var openAIBody, anthropicBody map[string]interface{}
+	openAIReply, anthropicReply := `{"a": 1}`, `"b": 2}`
+	openAI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		openAIBody = nil
+		json.NewDecoder(r.Body).Decode(&openAIBody)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]string{"content": openAIReply}}},
+			"usage":   map[string]int{"prompt_tokens": 10, "completion_tokens": 5},
+		})
+	}))
+	defer openAI.Close()
+	anthropic := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		anthropicBody = nil
+		json.NewDecoder(r.Body).Decode(&anthropicBody)
+		json.NewEncoder(w).Encode(map[string]interface{}{"content": []map[string]string{{"text": anthropicReply}}})
+	}))
+	defer anthropic.Close()
+	gateway := &Gateway{
+		OpenAIClient:    &OpenAIClient{Endpoint: openAI.URL},
+		AnthropicClient: &AnthropicClient{Endpoint: anthropic.URL},
+	}
+	jsonMode := PromptOptions{ResponseFormat: ResponseFormatJSON}
+
+	// OpenAI gets response_format; Anthropic gets its turn prefilled with the opening brace
+	resp, err := gateway.ExecutePromptWithOptions("Architect", "hello", "key", ProviderOpenAI, jsonMode)
+	if err != nil || resp.Content != `{"a": 1}` {
+		t.Fatalf("Expected the OpenAI JSON back, got %+v (%v)", resp, err)
+	}
+	if format, _ := openAIBody["response_format"].(map[string]interface{}); format["type"] != "json_object" {
+		t.Errorf("Expected response_format json_object in the OpenAI request, got %v", openAIBody)
+	}
+	resp, err = gateway.ExecutePromptWithOptions("Architect", "hello", "key", ProviderAnthropic, jsonMode)
+	if err != nil || resp.Content != `{"b": 2}` {
+		t.Fatalf("Expected the prefilled brace restored on the Anthropic JSON, got %+v (%v)", resp, err)
+	}
+	messages, _ := anthropicBody["messages"].([]interface{})
+	if last, _ := messages[len(messages)-1].(map[string]interface{}); len(messages) != 2 || last["role"] != "assistant" || last["content"] != "{" {
+		t.Errorf("Expected an assistant prefill of \"{\", got %v", anthropicBody["messages"])
+	}
+
+	// An answer that doesn't parse fails, but still reports what the call cost
+	openAIReply = `Here you go: {"a": {"b": 1}`
+	resp, err = gateway.ExecutePromptWithOptions("Architect", "hello", "key", ProviderOpenAI, jsonMode)
+	if !errors.Is(err, ErrMalformedJSON) {
+		t.Fatalf("Expected ErrMalformedJSON, got %v", err)
+	}
+	if resp == nil || resp.InputTokens != 10 || resp.OutputTokens != 5 || resp.Cost == 0 {
+		t.Errorf("Expected the billed usage with the error, got %+v", resp)
+	}
+
+	// Without JSON mode neither is sent, and any text is fine
+	gateway.ExecutePromptWithOptions("Architect", "hello", "key", ProviderOpenAI, PromptOptions{})
+	gateway.ExecutePromptWithOptions("Architect", "hello", "key", ProviderAnthropic, PromptOptions{})
+	if _, ok := openAIBody["response_format"]; ok {
+		t.Errorf("Expected no response_format without JSON mode, got %v", openAIBody)
+	}
+	if messages, _ := anthropicBody["messages"].([]interface{}); len(messages) != 1 {
+		t.Errorf("Expected no prefill without JSON mode, got %v", anthropicBody["messages"])
+	}
+
+	if _, err := gateway.ExecutePromptWithOptions("Architect", "hello", "key", ProviderOpenAI, PromptOptions{ResponseFormat: "yaml"}); err == nil {
+		t.Error("Expected an unsupported response format to be refused")
+	}
+}
+
+func TestExecutePrompt_ContextWindow(t *testing.T) {
+	// A deployment whose OpenAI model only has a 400-token window
+	useBudgetConfig(t, config.BudgetConfig{Pricing: map[string]config.ProviderPricing{
+		"OpenAI": {InputRate: 5, OutputRate: 15, ContextWindow: 400},
+	}})
+
+	calls := 0
+	gateway := &Gateway{OpenAIClient: &MockProvider{SendFunc: func(systemPrompt, userPrompt, apiKey string) (string, int, int, error) {
+		calls++
+		return "ok", 1, 1, nil
+	}}}
+
+	// The prompt alone is over the window
+	_, err := gateway.ExecutePrompt("Architect", strings.Repeat("lorem ipsum dolor ", 200), "key", ProviderOpenAI)
+	var windowErr *ContextWindowError
+	if !errors.As(err, &windowErr) || !errors.Is(err, ErrContextWindow) || !IsCapped(err) {
+		t.Fatalf("Expected a ContextWindowError, got %v", err)
+	}
+	if windowErr.Window != 400 || windowErr.Model != "gpt-4o" || windowErr.InputTokens <= 400 || windowErr.OutputTokens != 0 {
+		t.Errorf("Expected the error to describe the 400-token gpt-4o window and the prompt size, got %+v", windowErr)
+	}
+	if status := HTTPStatusForError(err); status != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413 for an over-window prompt, got %d", status)
+	}
+
+	// A prompt that fits, but not with the response it asks for
+	_, err = gateway.ExecutePromptWithOptions("Architect", "plan it", "key", ProviderOpenAI, PromptOptions{MaxOutputTokens: 350})
+	if !errors.As(err, &windowErr) || windowErr.OutputTokens != 350 {
+		t.Fatalf("Expected the requested output to count against the window, got %v", err)
+	}
+	if calls != 0 {
+		t.Errorf("Expected the provider not to be called for calls over the window, got %d calls", calls)
+	}
+
+	if _, err := gateway.ExecutePromptWithOptions("Architect", "plan it", "key", ProviderOpenAI, PromptOptions{MaxOutputTokens: 50}); err != nil || calls != 1 {
+		t.Errorf("Expected a call that fits the window to be sent, got %v", err)
+	}
+}
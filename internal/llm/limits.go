@@ -0,0 +1,95 @@
+package llm
+
+import (
+	"fmt"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/config"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/tokenizer"
+)
+
+// configuredMaxOutputTokens returns Budget.MaxOutputTokens, or 0 when unset or unreadable.
+func configuredMaxOutputTokens() int {
+	cfg, err := config.Get()
+	if err != nil {
+		return 0
+	}
+	return cfg.Budget.MaxOutputTokens
+}
+
+// capMaxOutputTokens keeps a per-call response cap within Budget.MaxOutputTokens, so a
+// node or command can lower the global cap but never lift it.
+func capMaxOutputTokens(requested int) int {
+	if max := configuredMaxOutputTokens(); max > 0 && requested > max {
+		return max
+	}
+	return requested
+}
+
+// checkSpendingPaused returns ErrSpendingPaused while the global kill switch is on.
+// If the config can't be read the call is allowed, matching the other limit checks.
+func checkSpendingPaused() error {
+	cfg, err := config.Get()
+	if err == nil && cfg.Budget.SpendingPaused {
+		return ErrSpendingPaused
+	}
+	return nil
+}
+
+// checkInputTokenCap estimates the size of a prompt and returns ErrInputTokenCap
+// if it is over Budget.MaxInputTokens.
+// Educational Comment: A flow node with an accidentally pasted log file can cost
+// dollars per call. Estimating locally first means an oversized prompt is refused
+// before the provider ever sees (and bills for) it.
+func checkInputTokenCap(systemPrompt, userPrompt string, provider ProviderType) error {
+	cfg, err := config.Get()
+	if err != nil || cfg.Budget.MaxInputTokens <= 0 {
+		return nil
+	}
+
+	if count := estimateInputTokens(systemPrompt, userPrompt, provider); count > cfg.Budget.MaxInputTokens {
+		return fmt.Errorf("%w: ~%d tokens (max %d)", ErrInputTokenCap, count, cfg.Budget.MaxInputTokens)
+	}
+	return nil
+}
+
+// estimateInputTokens counts the tokens a call will send, system prompt and message overhead included.
+func estimateInputTokens(systemPrompt, userPrompt string, provider ProviderType) int {
+	return tokenizer.Default().EstimateMessages([]tokenizer.Message{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: userPrompt},
+	}, string(provider), "").Count
+}
+
+// checkContextWindow returns a *ContextWindowError if the prompt plus outputTokens of
+// response is estimated over the provider model's context window (LLMConfig.MaxTokens).
+// A model without a known window isn't checked.
+// Educational Comment: Providers reject an over-long request with a terse 400 only after
+// the round trip. Checking locally lets us say how far over the call is, and why.
+func checkContextWindow(systemPrompt, userPrompt string, provider ProviderType, outputTokens int) error {
+	model := Pricing(provider)
+	if model.MaxTokens <= 0 {
+		return nil
+	}
+
+	input := estimateInputTokens(systemPrompt, userPrompt, provider)
+	if input+outputTokens > model.MaxTokens {
+		return &ContextWindowError{Provider: provider, Model: model.Model, InputTokens: input, OutputTokens: outputTokens, Window: model.MaxTokens}
+	}
+	return nil
+}
+
+// requestedOutputTokens is the max_tokens a call will send: the per-call cap, else the
+// client's own (0 when the client sends none, leaving the rest of the window to the reply).
+func requestedOutputTokens(client LLMProvider, opts SendOptions) int {
+	if opts.MaxTokens > 0 {
+		return opts.MaxTokens
+	}
+	switch c := client.(type) {
+	case *AnthropicClient:
+		return c.getMaxTokens()
+	case *OpenAIClient:
+		return c.getMaxTokens()
+	default:
+		return configuredMaxOutputTokens()
+	}
+}
@@ -20,6 +20,18 @@ type OpenAIClient struct {
 
 	// TimeoutSeconds is the HTTP client timeout. If 0, uses DefaultTimeoutSeconds.
 	TimeoutSeconds int
+
+	// MaxTokens caps the response length. If 0, uses Budget.MaxOutputTokens;
+	// if that is unset too, max_tokens is omitted and OpenAI's default applies.
+	MaxTokens int
+}
+
+// getMaxTokens returns the response token cap for this request (0 = none).
+func (c *OpenAIClient) getMaxTokens() int {
+	if c.MaxTokens > 0 {
+		return c.MaxTokens
+	}
+	return configuredMaxOutputTokens()
 }
 
 // getEndpoint returns the configured endpoint or the default.
@@ -40,8 +52,18 @@ func (c *OpenAIClient) getTimeout() time.Duration {
 
 // openAIRequest represents the payload for the OpenAI API.
 type openAIRequest struct {
-	Model    string          `json:"model"`
-	Messages []openAIMessage `json:"messages"`
+	Model       string          `json:"model"`
+	Messages    []openAIMessage `json:"messages"`
+	MaxTokens   int             `json:"max_tokens,omitempty"`
+	Temperature *float64        `json:"temperature,omitempty"`
+	TopP        *float64        `json:"top_p,omitempty"`
+
+	ResponseFormat *openAIResponseFormat `json:"response_format,omitempty"`
+}
+
+// openAIResponseFormat constrains the response, e.g. {"type": "json_object"} for JSON mode.
+type openAIResponseFormat struct {
+	Type string `json:"type"`
 }
 
 type openAIMessage struct {
@@ -68,12 +90,27 @@ type openAIResponse struct {
 // Send sends a prompt to OpenAI's GPT-4o model.
 // It uses configurable endpoint and timeout for testability.
 func (c *OpenAIClient) Send(systemPrompt, userPrompt, apiKey string) (string, int, int, error) {
+	return c.SendWithOptions(systemPrompt, userPrompt, apiKey, SendOptions{})
+}
+
+// SendWithOptions is Send with request settings for this call only.
+func (c *OpenAIClient) SendWithOptions(systemPrompt, userPrompt, apiKey string, opts SendOptions) (string, int, int, error) {
+	maxTokens := opts.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = c.getMaxTokens()
+	}
 	reqBody := openAIRequest{
-		Model: "gpt-4o",
+		Model: OpenAIModel,
 		Messages: []openAIMessage{
 			{Role: "system", Content: systemPrompt},
 			{Role: "user", Content: userPrompt},
 		},
+		MaxTokens:   maxTokens,
+		Temperature: opts.Temperature,
+		TopP:        opts.TopP,
+	}
+	if opts.JSONMode {
+		reqBody.ResponseFormat = &openAIResponseFormat{Type: "json_object"}
 	}
 
 	jsonData, err := json.Marshal(reqBody)
@@ -104,7 +141,7 @@ func (c *OpenAIClient) Send(systemPrompt, userPrompt, apiKey string) (string, in
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", 0, 0, fmt.Errorf("openai api error (status %d): %s", resp.StatusCode, string(body))
+		return "", 0, 0, newAPIError(ProviderOpenAI, resp, body)
 	}
 
 	var response openAIResponse
@@ -113,11 +150,11 @@ func (c *OpenAIClient) Send(systemPrompt, userPrompt, apiKey string) (string, in
 	}
 
 	if response.Error != nil {
-		return "", 0, 0, fmt.Errorf("openai error: %s", response.Error.Message)
+		return "", 0, 0, &APIError{Provider: ProviderOpenAI, StatusCode: resp.StatusCode, Message: response.Error.Message}
 	}
 
 	if len(response.Choices) == 0 {
-		return "", 0, 0, fmt.Errorf("empty response choices")
+		return "", 0, 0, fmt.Errorf("%w: empty response choices", ErrEmptyResponse)
 	}
 
 	return response.Choices[0].Message.Content, response.Usage.PromptTokens, response.Usage.CompletionTokens, nil
@@ -4,10 +4,14 @@ package llm
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/config"
 )
 
 // TestOpenAIClient_SuccessfulResponse verifies that OpenAIClient correctly parses
@@ -102,6 +106,14 @@ func TestOpenAIClient_APIError(t *testing.T) {
 	if !strings.Contains(err.Error(), "401") {
 		t.Errorf("Expected error to contain '401', got: %v", err)
 	}
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Expected *APIError, got %T", err)
+	}
+	if apiErr.StatusCode != http.StatusUnauthorized || apiErr.Provider != ProviderOpenAI {
+		t.Errorf("Unexpected APIError: %+v", apiErr)
+	}
 }
 
 // TestOpenAIClient_EmptyChoices verifies handling of empty choices array.
@@ -132,6 +144,9 @@ func TestOpenAIClient_EmptyChoices(t *testing.T) {
 	if !strings.Contains(err.Error(), "empty response") {
 		t.Errorf("Expected error to contain 'empty response', got: %v", err)
 	}
+	if !errors.Is(err, ErrEmptyResponse) {
+		t.Errorf("Expected ErrEmptyResponse, got: %v", err)
+	}
 }
 
 // TestOpenAIClient_NetworkError verifies handling of network failures.
@@ -266,4 +281,50 @@ func TestOpenAIClient_RateLimitError(t *testing.T) {
 	if !strings.Contains(err.Error(), "429") {
 		t.Errorf("Expected error to contain '429', got: %v", err)
 	}
+	if !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Expected ErrRateLimited, got: %v", err)
+	}
+	if RetryAfter(err) != 60*time.Second {
+		t.Errorf("Expected Retry-After of 60s, got %v", RetryAfter(err))
+	}
+}
+
+// TestOpenAIClient_MaxTokens verifies max_tokens is only sent when a cap is configured.
+func TestOpenAIClient_MaxTokens(t *testing.T) {
+	useBudgetConfig(t, config.BudgetConfig{})
+
+	var gotMaxTokens interface{}
+	var present bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reqBody map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&reqBody)
+		gotMaxTokens, present = reqBody["max_tokens"]
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices": [{"message": {"content": "ok"}}]}`))
+	}))
+	defer server.Close()
+
+	client := &OpenAIClient{Endpoint: server.URL}
+	if _, _, _, err := client.Send("system", "user", "key"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if present {
+		t.Errorf("Expected max_tokens to be omitted by default, got %v", gotMaxTokens)
+	}
+
+	useBudgetConfig(t, config.BudgetConfig{MaxOutputTokens: 256})
+	if _, _, _, err := client.Send("system", "user", "key"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if gotMaxTokens != float64(256) {
+		t.Errorf("Expected max_tokens 256 from config, got %v", gotMaxTokens)
+	}
+
+	client.MaxTokens = 100
+	if _, _, _, err := client.Send("system", "user", "key"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if gotMaxTokens != float64(100) {
+		t.Errorf("Expected client MaxTokens to win, got %v", gotMaxTokens)
+	}
 }
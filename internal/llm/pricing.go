@@ -0,0 +1,51 @@
+package llm
+
+import (
+	"strings"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/config"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/models"
+)
+
+// Pricing returns how provider bills, with PrimaryCostUnit and the rates filled in
+// (InputRate and OutputRate are USD per 1M tokens, PromptRate is USD per call).
+// Unknown providers are priced at zero, per token, so they never block a run.
+// An entry for the provider in Budget.Pricing replaces the built-in prices, and its
+// context_window, if set, the model's context window (MaxTokens).
+// Educational Comment: The built-in prices come from the model registry. Keeping them in
+// one place means cost calculation and the budget meter can't disagree on the billing model.
+func Pricing(provider ProviderType) LLMConfig {
+	pricing := builtinPricing(provider)
+	if cfg, err := config.Get(); err == nil {
+		if override, ok := cfg.Budget.Pricing[string(provider)]; ok {
+			pricing.PrimaryCostUnit = CostUnitToken
+			if strings.EqualFold(override.CostUnit, string(CostUnitPrompt)) {
+				pricing.PrimaryCostUnit = CostUnitPrompt
+			}
+			pricing.InputRate = override.InputRate
+			pricing.OutputRate = override.OutputRate
+			pricing.PromptRate = override.PromptRate
+			if override.ContextWindow > 0 {
+				pricing.MaxTokens = override.ContextWindow
+			}
+		}
+	}
+	return pricing
+}
+
+// builtinPricing is the default price list: the rates and context window the model
+// registry has for the model the provider's client talks to.
+func builtinPricing(provider ProviderType) LLMConfig {
+	model, ok := models.Default(string(provider))
+	if !ok {
+		return LLMConfig{Provider: string(provider), PrimaryCostUnit: CostUnitToken}
+	}
+	return LLMConfig{
+		Provider:        string(provider),
+		Model:           model.Name,
+		PrimaryCostUnit: CostUnitToken,
+		InputRate:       model.InputRate,
+		OutputRate:      model.OutputRate,
+		MaxTokens:       model.ContextWindow,
+	}
+}
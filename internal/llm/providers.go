@@ -0,0 +1,47 @@
+package llm
+
+// Models the provider clients send requests to. Each is its provider's default in the
+// model registry (internal/models), which holds its pricing and context window.
+const (
+	AnthropicModel = "claude-3-5-sonnet-20240620"
+	OpenAIModel    = "gpt-4o"
+)
+
+// ProviderInfo describes a provider the gateway can route prompts to.
+type ProviderInfo struct {
+	Provider     ProviderType
+	Models       []string // models a request can run on
+	DefaultModel string
+}
+
+// providerOrder is the order Providers lists them in.
+var providerOrder = []ProviderType{ProviderAnthropic, ProviderOpenAI}
+
+// providerModels maps each provider to the models its client supports. Each client
+// currently talks to one model, which is therefore also the default.
+var providerModels = map[ProviderType][]string{
+	ProviderAnthropic: {AnthropicModel},
+	ProviderOpenAI:    {OpenAIModel},
+}
+
+// Providers lists the providers that have a client on this gateway.
+func (g *Gateway) Providers() []ProviderInfo {
+	clients := map[ProviderType]LLMProvider{
+		ProviderAnthropic: g.AnthropicClient,
+		ProviderOpenAI:    g.OpenAIClient,
+	}
+
+	providers := []ProviderInfo{}
+	for _, provider := range providerOrder {
+		if clients[provider] == nil {
+			continue
+		}
+		models := providerModels[provider]
+		providers = append(providers, ProviderInfo{
+			Provider:     provider,
+			Models:       append([]string{}, models...),
+			DefaultModel: models[0],
+		})
+	}
+	return providers
+}
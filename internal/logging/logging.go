@@ -0,0 +1,181 @@
+// Package logging provides leveled logging with an optional JSON output mode.
+// Educational Comment: By default lines look exactly like the standard log package's,
+// so the console stays readable. JSON mode emits one object per line with time, level
+// and msg fields, which log aggregators can parse without custom patterns.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Level is the severity of a log record.
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// String returns the upper-case level name used in JSON records.
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	default:
+		return "INFO"
+	}
+}
+
+// ParseLevel reads a level name (case-insensitive; "warning" is accepted for warn).
+func ParseLevel(s string) (Level, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return LevelDebug, true
+	case "info":
+		return LevelInfo, true
+	case "warn", "warning":
+		return LevelWarn, true
+	case "error":
+		return LevelError, true
+	default:
+		return LevelInfo, false
+	}
+}
+
+// Output formats
+const (
+	FormatConsole = "console"
+	FormatJSON    = "json"
+)
+
+var (
+	mu       sync.RWMutex
+	minLevel = LevelInfo
+	jsonOut  *slog.Logger // nil means console mode
+)
+
+// Init picks the format and minimum level, normally from the config file.
+// The FORGE_LOG_FORMAT and FORGE_LOG_LEVEL environment variables win over the arguments.
+// Unknown values fall back to console output at INFO.
+func Init(format, level string) {
+	if env := os.Getenv("FORGE_LOG_FORMAT"); env != "" {
+		format = env
+	}
+	if env := os.Getenv("FORGE_LOG_LEVEL"); env != "" {
+		level = env
+	}
+	lvl, _ := ParseLevel(level)
+	Setup(os.Stderr, strings.EqualFold(strings.TrimSpace(format), FormatJSON), lvl)
+}
+
+// Setup configures the logger directly. In console mode records go through the
+// standard log package (so its flags and output apply) and out is ignored.
+func Setup(out io.Writer, json bool, level Level) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	minLevel = level
+	jsonOut = nil
+	if json {
+		// Filtering happens in logf, so the handler accepts everything
+		jsonOut = slog.New(slog.NewJSONHandler(out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	}
+}
+
+// Debugf logs detail that is only useful when diagnosing a problem.
+func Debugf(format string, args ...any) { logf(LevelDebug, format, args...) }
+
+// Infof logs normal operation.
+func Infof(format string, args ...any) { logf(LevelInfo, format, args...) }
+
+// Warnf logs something unexpected that Forge recovered from.
+func Warnf(format string, args ...any) { logf(LevelWarn, format, args...) }
+
+// Errorf logs a failure the user may need to act on.
+func Errorf(format string, args ...any) { logf(LevelError, format, args...) }
+
+// RequestLogger tags every record with a request ID, so the lines of one flow or
+// command run can be picked out of the log and matched to its ledger rows.
+// Console lines start with "[request_id=<id>]"; JSON records get a request_id field.
+type RequestLogger struct {
+	requestID string
+}
+
+// ForRequest returns a logger for the given request ID. An empty ID logs untagged lines.
+func ForRequest(requestID string) RequestLogger {
+	return RequestLogger{requestID: requestID}
+}
+
+// Debugf logs detail that is only useful when diagnosing a problem.
+func (l RequestLogger) Debugf(format string, args ...any) {
+	logRequestf(LevelDebug, l.requestID, format, args...)
+}
+
+// Infof logs normal operation.
+func (l RequestLogger) Infof(format string, args ...any) {
+	logRequestf(LevelInfo, l.requestID, format, args...)
+}
+
+// Warnf logs something unexpected that Forge recovered from.
+func (l RequestLogger) Warnf(format string, args ...any) {
+	logRequestf(LevelWarn, l.requestID, format, args...)
+}
+
+// Errorf logs a failure the user may need to act on.
+func (l RequestLogger) Errorf(format string, args ...any) {
+	logRequestf(LevelError, l.requestID, format, args...)
+}
+
+func logf(level Level, format string, args ...any) {
+	logRequestf(level, "", format, args...)
+}
+
+func logRequestf(level Level, requestID, format string, args ...any) {
+	mu.RLock()
+	threshold, logger := minLevel, jsonOut
+	mu.RUnlock()
+
+	if level < threshold {
+		return
+	}
+	msg := fmt.Sprintf(format, args...)
+	if logger == nil {
+		if requestID != "" {
+			msg = "[request_id=" + requestID + "] " + msg
+		}
+		// Calldepth 4 points at the caller of Debugf/Infof/..., in case Lshortfile is set
+		log.Output(4, msg)
+		return
+	}
+	if requestID != "" {
+		logger.Log(context.Background(), slogLevel(level), msg, "request_id", requestID)
+		return
+	}
+	logger.Log(context.Background(), slogLevel(level), msg)
+}
+
+func slogLevel(level Level) slog.Level {
+	switch level {
+	case LevelDebug:
+		return slog.LevelDebug
+	case LevelWarn:
+		return slog.LevelWarn
+	case LevelError:
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
@@ -0,0 +1,139 @@
+package logging
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestJSONModeEmitsParseableRecords(t *testing.T) {
+	var buf bytes.Buffer
+	Setup(&buf, true, LevelDebug)
+	t.Cleanup(func() { Setup(nil, false, LevelInfo) })
+
+	Debugf("debug %d", 1)
+	Infof("info %s", "two")
+	Warnf("warn")
+	Errorf("error: %v", "boom")
+
+	want := []struct{ level, msg string }{
+		{"DEBUG", "debug 1"},
+		{"INFO", "info two"},
+		{"WARN", "warn"},
+		{"ERROR", "error: boom"},
+	}
+	scanner := bufio.NewScanner(&buf)
+	for i, w := range want {
+		if !scanner.Scan() {
+			t.Fatalf("Expected %d records, got %d", len(want), i)
+		}
+		var record map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("Record %d is not JSON: %v (%s)", i, err, scanner.Text())
+		}
+		if record["level"] != w.level || record["msg"] != w.msg {
+			t.Errorf("Record %d: expected %s %q, got %v %v", i, w.level, w.msg, record["level"], record["msg"])
+		}
+		if _, ok := record["time"]; !ok {
+			t.Errorf("Record %d has no time field", i)
+		}
+	}
+}
+
+func TestLevelFiltering(t *testing.T) {
+	var buf bytes.Buffer
+	Setup(&buf, true, LevelWarn)
+	t.Cleanup(func() { Setup(nil, false, LevelInfo) })
+
+	Debugf("hidden")
+	Infof("hidden")
+	Warnf("shown")
+
+	if lines := strings.Count(buf.String(), "\n"); lines != 1 || !strings.Contains(buf.String(), "shown") {
+		t.Errorf("Expected only the WARN record, got %q", buf.String())
+	}
+}
+
+func TestConsoleModeKeepsPlainLines(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	flags := log.Flags()
+	log.SetFlags(0)
+	t.Cleanup(func() {
+		log.SetOutput(os.Stderr)
+		log.SetFlags(flags)
+	})
+	Setup(nil, false, LevelInfo)
+
+	Infof("🔥 Forge starting on %s", "127.0.0.1:8080")
+	if got := buf.String(); got != "🔥 Forge starting on 127.0.0.1:8080\n" {
+		t.Errorf("Expected the message unchanged, got %q", got)
+	}
+}
+
+func TestRequestLoggerTagsRecords(t *testing.T) {
+	var buf bytes.Buffer
+	Setup(&buf, true, LevelInfo)
+	t.Cleanup(func() { Setup(nil, false, LevelInfo) })
+
+	ForRequest("req-1").Infof("node %s done", "a")
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Record is not JSON: %v (%s)", err, buf.String())
+	}
+	if record["request_id"] != "req-1" || record["msg"] != "node a done" {
+		t.Errorf("Expected a request_id field, got %v", record)
+	}
+
+	// Console lines carry the ID as a prefix
+	var console bytes.Buffer
+	log.SetOutput(&console)
+	flags := log.Flags()
+	log.SetFlags(0)
+	t.Cleanup(func() {
+		log.SetOutput(os.Stderr)
+		log.SetFlags(flags)
+	})
+	Setup(nil, false, LevelInfo)
+
+	ForRequest("req-1").Warnf("retrying")
+	ForRequest("").Warnf("untagged")
+	if got := console.String(); got != "[request_id=req-1] retrying\nuntagged\n" {
+		t.Errorf("Expected prefixed console lines, got %q", got)
+	}
+}
+
+func TestInitReadsEnvironment(t *testing.T) {
+	t.Setenv("FORGE_LOG_FORMAT", "JSON")
+	t.Setenv("FORGE_LOG_LEVEL", "error")
+	t.Cleanup(func() { Setup(nil, false, LevelInfo) })
+
+	Init(FormatConsole, "debug")
+	mu.RLock()
+	defer mu.RUnlock()
+	if jsonOut == nil || minLevel != LevelError {
+		t.Errorf("Expected the environment to select JSON at ERROR, got json=%v level=%s", jsonOut != nil, minLevel)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want Level
+		ok   bool
+	}{
+		{"debug", LevelDebug, true},
+		{"INFO", LevelInfo, true},
+		{"Warning", LevelWarn, true},
+		{"error", LevelError, true},
+		{"verbose", LevelInfo, false},
+	} {
+		if got, ok := ParseLevel(tt.in); got != tt.want || ok != tt.ok {
+			t.Errorf("ParseLevel(%q) = %s, %v; want %s, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
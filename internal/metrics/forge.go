@@ -0,0 +1,29 @@
+package metrics
+
+// Forge-wide metrics. They live here, rather than next to the code that updates them,
+// so the full list of exported series is easy to find in one place.
+var (
+	// LLMCalls counts gateway calls by provider and outcome: "success", "error", or one of
+	// "capped" / "paused" for calls refused before being sent.
+	// The error rate per provider is calls{status="error"} / calls.
+	LLMCalls = NewCounter("forge_llm_calls_total", "Total LLM calls made through the gateway.", "provider", "status")
+
+	// LLMTokens counts tokens by provider and direction ("input" or "output").
+	LLMTokens = NewCounter("forge_llm_tokens_total", "Total tokens consumed by LLM calls.", "provider", "direction")
+
+	// LLMCostUSD accumulates estimated spend by provider.
+	LLMCostUSD = NewCounter("forge_llm_cost_usd_total", "Estimated LLM spend in US dollars.", "provider")
+
+	// LLMLatency records how long each provider call took.
+	LLMLatency = NewHistogram("forge_llm_call_duration_seconds", "LLM call latency in seconds.", nil, "provider")
+
+	// LedgerEntries counts token ledger rows written, by status ("SUCCESS", "FAILED", ...).
+	// Unlike LLMCalls this includes entries posted directly to /api/ledger.
+	LedgerEntries = NewCounter("forge_ledger_entries_total", "Total token ledger entries recorded.", "status")
+
+	// PTYSessions is the number of open terminal sessions.
+	PTYSessions = NewGauge("forge_pty_sessions", "Number of active PTY sessions.")
+
+	// HTTPRequestDuration records API request latency by method, route pattern and status code.
+	HTTPRequestDuration = NewHistogram("forge_http_request_duration_seconds", "HTTP request latency in seconds.", nil, "method", "route", "status")
+)
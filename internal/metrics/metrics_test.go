@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistryRendersPrometheusFormat(t *testing.T) {
+	r := NewRegistry()
+	calls := r.NewCounter("test_calls_total", "Test calls.", "provider")
+	sessions := r.NewGauge("test_sessions", "Test sessions.")
+	latency := r.NewHistogram("test_latency_seconds", "Test latency.", []float64{0.1, 1})
+
+	calls.Inc("OpenAI")
+	calls.Add(2, "OpenAI")
+	calls.Add(-5, "OpenAI") // ignored: counters never decrease
+	sessions.Inc()
+	sessions.Inc()
+	sessions.Dec()
+	latency.Observe(0.05)
+	latency.Observe(0.5)
+	latency.Observe(3)
+
+	var buf bytes.Buffer
+	r.Write(&buf)
+	out := buf.String()
+
+	for _, want := range []string{
+		"# TYPE test_calls_total counter",
+		`test_calls_total{provider="OpenAI"} 3`,
+		"# TYPE test_sessions gauge",
+		"test_sessions 1",
+		"# TYPE test_latency_seconds histogram",
+		`test_latency_seconds_bucket{le="0.1"} 1`,
+		`test_latency_seconds_bucket{le="1"} 2`,
+		`test_latency_seconds_bucket{le="+Inf"} 3`,
+		"test_latency_seconds_sum 3.55",
+		"test_latency_seconds_count 3",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestRegistryRejectsDuplicates(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("dup_total", "First.")
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected duplicate registration to panic")
+		}
+	}()
+	r.NewGauge("dup_total", "Second.")
+}
+
+func TestHandlerServesDefaultRegistry(t *testing.T) {
+	LLMCalls.Inc("TestProvider", "success")
+
+	rr := httptest.NewRecorder()
+	Handler().ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Expected text/plain content type, got %s", ct)
+	}
+	if !strings.Contains(rr.Body.String(), `forge_llm_calls_total{provider="TestProvider",status="success"}`) {
+		t.Errorf("Expected forge_llm_calls_total in output, got:\n%s", rr.Body.String())
+	}
+}
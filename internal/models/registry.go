@@ -0,0 +1,63 @@
+// Package models is the single list of the LLM models Forge knows about: who serves each
+// one, what it costs and how much context it takes. The gateway's pricing, the optimizer
+// and the tokenizer all read from it, so they can't disagree about a model.
+package models
+
+import "strings"
+
+// Model describes one model.
+type Model struct {
+	Name          string  `json:"name"`           // as sent to the provider and logged in the ledger
+	Provider      string  `json:"provider"`       // as used in flow nodes, e.g. "Anthropic"
+	InputRate     float64 `json:"input_rate"`     // USD per 1M input tokens
+	OutputRate    float64 `json:"output_rate"`    // USD per 1M output tokens
+	ContextWindow int     `json:"context_window"` // tokens of prompt plus response the model accepts
+	// CheaperAlternative is the model the optimizer suggests switching to, if any.
+	CheaperAlternative string `json:"cheaper_alternative,omitempty"`
+}
+
+// BlendedRate is the average of the input and output rates, for comparing models.
+func (m Model) BlendedRate() float64 {
+	return (m.InputRate + m.OutputRate) / 2
+}
+
+// registry lists the known models. The first model of each provider is the one its
+// client talks to, and so the provider's default.
+// Educational Comment: These are list prices as of late 2024/2025. Budget.Pricing in the
+// config can override the rates of the models Forge actually calls.
+var registry = []Model{
+	{Name: "claude-3-5-sonnet-20240620", Provider: "Anthropic", InputRate: 3.00, OutputRate: 15.00, ContextWindow: 200_000},
+	{Name: "claude-3-opus", Provider: "Anthropic", InputRate: 15.00, OutputRate: 75.00, ContextWindow: 200_000, CheaperAlternative: "claude-3-haiku"},
+	{Name: "claude-3-sonnet", Provider: "Anthropic", InputRate: 3.00, OutputRate: 15.00, ContextWindow: 200_000},
+	{Name: "claude-3-haiku", Provider: "Anthropic", InputRate: 0.25, OutputRate: 1.25, ContextWindow: 200_000},
+	{Name: "claude-2", Provider: "Anthropic", InputRate: 8.00, OutputRate: 24.00, ContextWindow: 100_000, CheaperAlternative: "claude-3-haiku"},
+	{Name: "gpt-4o", Provider: "OpenAI", InputRate: 5.00, OutputRate: 15.00, ContextWindow: 128_000},
+	{Name: "gpt-4", Provider: "OpenAI", InputRate: 30.00, OutputRate: 60.00, ContextWindow: 8_192, CheaperAlternative: "gpt-3.5-turbo"},
+	{Name: "gpt-4-turbo", Provider: "OpenAI", InputRate: 10.00, OutputRate: 30.00, ContextWindow: 128_000, CheaperAlternative: "gpt-3.5-turbo"},
+	{Name: "gpt-3.5-turbo", Provider: "OpenAI", InputRate: 0.50, OutputRate: 1.50, ContextWindow: 16_385},
+}
+
+// All returns every known model, grouped by provider.
+func All() []Model {
+	return append([]Model{}, registry...)
+}
+
+// Lookup returns the model with the given name.
+func Lookup(name string) (Model, bool) {
+	for _, m := range registry {
+		if m.Name == name {
+			return m, true
+		}
+	}
+	return Model{}, false
+}
+
+// Default returns the model a provider's client talks to. Provider names match case-insensitively.
+func Default(provider string) (Model, bool) {
+	for _, m := range registry {
+		if strings.EqualFold(m.Provider, provider) {
+			return m, true
+		}
+	}
+	return Model{}, false
+}
@@ -0,0 +1,26 @@
+package models
+
+import "testing"
+
+func TestDefault_IsProvidersFirstModel(t *testing.T) {
+	for provider, want := range map[string]string{"Anthropic": "claude-3-5-sonnet-20240620", "openai": "gpt-4o"} {
+		if m, ok := Default(provider); !ok || m.Name != want {
+			t.Errorf("Default(%q) = %+v, want %s", provider, m, want)
+		}
+	}
+	if _, ok := Default("Mistral"); ok {
+		t.Error("Expected no default for an unknown provider")
+	}
+}
+
+func TestRegistry_AlternativesAreCheaperKnownModels(t *testing.T) {
+	for _, m := range All() {
+		if m.CheaperAlternative == "" {
+			continue
+		}
+		alt, ok := Lookup(m.CheaperAlternative)
+		if !ok || alt.Provider != m.Provider || alt.BlendedRate() >= m.BlendedRate() {
+			t.Errorf("%s suggests %q, which must be a cheaper %s model", m.Name, m.CheaperAlternative, m.Provider)
+		}
+	}
+}
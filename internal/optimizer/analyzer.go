@@ -4,19 +4,45 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"strings"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/config"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/models"
 )
 
-// modelCosts maps model names to their approximate cost per 1M tokens (input + output averaged).
-// Educational Comment: We use this lookup table to identify expensive models and suggest cheaper alternatives.
-// These are approximate costs and should be updated based on current pricing.
-var modelCosts = map[string]float64{
-	"gpt-4":           0.06,  // High-cost model
-	"gpt-4-turbo":     0.03,  // Medium-high cost
-	"gpt-3.5-turbo":   0.002, // Low-cost model
-	"claude-3-opus":   0.075, // High-cost model
-	"claude-3-sonnet": 0.015, // Medium cost
-	"claude-3-haiku":  0.001, // Low-cost model
-	"claude-2":        0.024, // Medium-high cost
+// Analyze runs AnalyzeLedger and reports how many ledger rows it had to work with.
+// Below Optimizer.MinLedgerEntries rows the analyzer is skipped, since patterns in
+// one or two calls are mostly noise; previously stored suggestions are still returned.
+func Analyze(db *sql.DB) (*Analysis, error) {
+	var rows int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM token_ledger`).Scan(&rows); err != nil {
+		return nil, fmt.Errorf("failed to count ledger rows: %w", err)
+	}
+
+	threshold := config.DefaultMinLedgerEntries
+	if cfg, err := config.Get(); err == nil {
+		threshold = cfg.Optimizer.SuggestionThreshold()
+	}
+
+	if rows < threshold {
+		existing, err := GetAllSuggestions(db)
+		if err != nil || existing == nil {
+			existing = []Suggestion{}
+		}
+		return &Analysis{
+			LedgerRows:    rows,
+			MinLedgerRows: threshold,
+			Message:       fmt.Sprintf("Suggestions start after %d ledger entries (%d so far).", threshold, rows),
+			Suggestions:   existing,
+		}, nil
+	}
+
+	suggestions, err := AnalyzeLedger(db)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Analysis{Analyzed: true, LedgerRows: rows, MinLedgerRows: threshold, Suggestions: suggestions}, nil
 }
 
 // AnalyzeLedger queries the token_ledger table and identifies optimization opportunities.
@@ -80,20 +106,32 @@ func AnalyzeLedger(db *sql.DB) ([]Suggestion, error) {
 }
 
 // detectHighCostModels identifies flows using expensive models and suggests cheaper alternatives.
+// Educational Comment: The model registry names a cheaper alternative for each expensive
+// model, and its blended rates give the share of the spend a switch would save.
 func detectHighCostModels(db *sql.DB) ([]Suggestion, error) {
 	suggestions := []Suggestion{}
 
+	expensive := []interface{}{}
+	for _, m := range models.All() {
+		if m.CheaperAlternative != "" {
+			expensive = append(expensive, m.Name)
+		}
+	}
+	if len(expensive) == 0 {
+		return suggestions, nil
+	}
+
 	query := `
 		SELECT flow_id, model_used, COUNT(*) as call_count, SUM(total_cost_usd) as total_cost
 		FROM token_ledger
-		WHERE model_used IN ('gpt-4', 'claude-3-opus', 'gpt-4-turbo', 'claude-2')
+		WHERE model_used IN (` + strings.TrimSuffix(strings.Repeat("?, ", len(expensive)), ", ") + `)
 		GROUP BY flow_id, model_used
 		HAVING call_count >= 2
 		ORDER BY total_cost DESC
 		LIMIT 10
 	`
 
-	rows, err := db.Query(query)
+	rows, err := db.Query(query, expensive...)
 	if err != nil {
 		return nil, err
 	}
@@ -108,16 +146,13 @@ func detectHighCostModels(db *sql.DB) ([]Suggestion, error) {
 			return nil, err
 		}
 
-		alternative := "gpt-3.5-turbo"
-		if modelUsed == "claude-3-opus" || modelUsed == "claude-2" {
-			alternative = "claude-3-haiku"
-		} else if modelUsed == "claude-3-sonnet" {
-			alternative = "claude-3-haiku"
+		current, _ := models.Lookup(modelUsed)
+		alternative := current.CheaperAlternative
+		alt, ok := models.Lookup(alternative)
+		if !ok || current.BlendedRate() <= 0 {
+			continue
 		}
-
-		currentCost := modelCosts[modelUsed]
-		altCost := modelCosts[alternative]
-		estimatedSavings := totalCost * ((currentCost - altCost) / currentCost)
+		estimatedSavings := totalCost * ((current.BlendedRate() - alt.BlendedRate()) / current.BlendedRate())
 
 		if estimatedSavings > 0.01 {
 			applyAction := map[string]interface{}{
@@ -2,8 +2,10 @@ package optimizer
 
 import (
 	"database/sql"
+	"strings"
 	"testing"
 
+	"github.com/mikejsmith1985/forge-orchestrator/internal/config"
 	_ "modernc.org/sqlite"
 )
 
@@ -27,7 +29,11 @@ func setupTestDB(t *testing.T) *sql.DB {
 		total_cost_usd REAL NOT NULL,
 		latency_ms INTEGER NOT NULL,
 		status TEXT NOT NULL,
-		error_message TEXT
+		error_message TEXT,
+		environment TEXT NOT NULL DEFAULT 'prod',
+		request_id TEXT,
+		idempotency_key TEXT UNIQUE,
+		replay_of INTEGER
 	);
 
 	CREATE TABLE IF NOT EXISTS optimization_suggestions (
@@ -133,3 +139,117 @@ func TestAnalyzeLedger(t *testing.T) {
 		t.Error("Missing retry_strategy suggestion")
 	}
 }
+
+// useMinLedgerEntries saves a config with the given Optimizer.MinLedgerEntries for the test.
+func useMinLedgerEntries(t *testing.T, min int) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	cfg := config.DefaultConfig()
+	cfg.Optimizer.MinLedgerEntries = min
+	if err := config.Save(cfg); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	t.Cleanup(func() { config.Save(config.DefaultConfig()) })
+}
+
+// insertExpensiveCalls adds n identical GPT-4 calls, enough (from 2) to trigger a model_switch suggestion.
+func insertExpensiveCalls(t *testing.T, db *sql.DB, n int) {
+	for i := 0; i < n; i++ {
+		if _, err := db.Exec(`INSERT INTO token_ledger (flow_id, model_used, agent_role, prompt_hash, input_tokens, output_tokens, total_cost_usd, latency_ms, status)
+			VALUES ('flow_expensive', 'gpt-4', 'coder', 'h', 1000, 500, 0.09, 1000, 'SUCCESS')`); err != nil {
+			t.Fatalf("Failed to insert test data: %v", err)
+		}
+	}
+}
+
+func TestAnalyze_EmptyLedger(t *testing.T) {
+	useMinLedgerEntries(t, 0)
+	db := setupTestDB(t)
+	defer db.Close()
+
+	analysis, err := Analyze(db)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	if analysis.Analyzed {
+		t.Error("Expected the analyzer to be skipped for an empty ledger")
+	}
+	if analysis.Message == "" || analysis.MinLedgerRows != config.DefaultMinLedgerEntries {
+		t.Errorf("Expected a message and the default threshold, got %q / %d", analysis.Message, analysis.MinLedgerRows)
+	}
+	if analysis.LedgerRows != 0 {
+		t.Errorf("Expected 0 ledger rows, got %d", analysis.LedgerRows)
+	}
+	if analysis.Suggestions == nil || len(analysis.Suggestions) != 0 {
+		t.Errorf("Expected an empty, non-nil suggestion list, got %#v", analysis.Suggestions)
+	}
+}
+
+func TestAnalyze_CountsLedgerRows(t *testing.T) {
+	useMinLedgerEntries(t, 1)
+	db := setupTestDB(t)
+	defer db.Close()
+
+	// A few cheap, successful calls: data to analyze, but nothing worth suggesting
+	for i := 0; i < 3; i++ {
+		if _, err := db.Exec(`INSERT INTO token_ledger (flow_id, model_used, agent_role, prompt_hash, input_tokens, output_tokens, total_cost_usd, latency_ms, status)
+			VALUES ('flow_cheap', 'gpt-3.5-turbo', 'coder', 'h', 100, 50, 0.0001, 100, 'SUCCESS')`); err != nil {
+			t.Fatalf("Failed to insert test data: %v", err)
+		}
+	}
+
+	analysis, err := Analyze(db)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	if analysis.LedgerRows != 3 {
+		t.Errorf("Expected 3 ledger rows, got %d", analysis.LedgerRows)
+	}
+	if len(analysis.Suggestions) != 0 {
+		t.Errorf("Expected no suggestions for cheap successful calls, got %d", len(analysis.Suggestions))
+	}
+}
+
+func TestAnalyze_BelowThreshold(t *testing.T) {
+	useMinLedgerEntries(t, 5)
+	db := setupTestDB(t)
+	defer db.Close()
+
+	// Four expensive calls would produce a suggestion, but are one short of the threshold
+	insertExpensiveCalls(t, db, 4)
+
+	analysis, err := Analyze(db)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	if analysis.Analyzed || len(analysis.Suggestions) != 0 {
+		t.Errorf("Expected no analysis below the threshold, got analyzed=%v with %d suggestions", analysis.Analyzed, len(analysis.Suggestions))
+	}
+	if !strings.Contains(analysis.Message, "5") || !strings.Contains(analysis.Message, "4") {
+		t.Errorf("Expected the message to mention the threshold and current count, got %q", analysis.Message)
+	}
+
+	var stored int
+	db.QueryRow(`SELECT COUNT(*) FROM optimization_suggestions`).Scan(&stored)
+	if stored != 0 {
+		t.Errorf("Expected nothing stored below the threshold, found %d", stored)
+	}
+}
+
+func TestAnalyze_AtThreshold(t *testing.T) {
+	useMinLedgerEntries(t, 5)
+	db := setupTestDB(t)
+	defer db.Close()
+
+	insertExpensiveCalls(t, db, 5)
+
+	analysis, err := Analyze(db)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	if !analysis.Analyzed || analysis.Message != "" {
+		t.Errorf("Expected the analyzer to run once the threshold is met, got analyzed=%v message=%q", analysis.Analyzed, analysis.Message)
+	}
+	if len(analysis.Suggestions) != 1 || analysis.Suggestions[0].Type != "model_switch" {
+		t.Errorf("Expected one model_switch suggestion, got %+v", analysis.Suggestions)
+	}
+}
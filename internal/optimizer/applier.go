@@ -11,6 +11,10 @@ import (
 type FlowGraph struct {
 	Nodes []FlowNode `json:"nodes"`
 	Edges []FlowEdge `json:"edges"`
+
+	// RetryConfig is kept as-is so rewriting nodes doesn't drop an applied retry strategy
+	RetryConfig  json.RawMessage `json:"retryConfig,omitempty"`
+	OnMissingKey string          `json:"onMissingKey,omitempty"`
 }
 
 // FlowNode represents a single node in the flow
@@ -33,6 +37,14 @@ type NodeData struct {
 	Role     string `json:"role,omitempty"`
 	Prompt   string `json:"prompt,omitempty"`
 	Provider string `json:"provider,omitempty"`
+
+	SystemPrompt    string          `json:"system_prompt,omitempty"`
+	RetryConfig     json.RawMessage `json:"retryConfig,omitempty"`
+	FallbackModels  []string        `json:"fallbackModels,omitempty"`
+	MaxOutputTokens int             `json:"maxOutputTokens,omitempty"`
+	Temperature     *float64        `json:"temperature,omitempty"`
+	TopP            *float64        `json:"topP,omitempty"`
+	ResponseFormat  string          `json:"responseFormat,omitempty"`
 }
 
 // FlowEdge represents a connection between nodes
@@ -239,7 +251,7 @@ func applyModelSwitch(db *sql.DB, action ApplyAction) (*ApplyResult, error) {
 		return nil, fmt.Errorf("failed to serialize flow data: %w", err)
 	}
 
-	_, err = db.Exec("UPDATE forge_flows SET data = ?, updated_at = ? WHERE id = ?",
+	_, err = db.Exec("UPDATE forge_flows SET data = ?, updated_at = ?, revision = revision + 1 WHERE id = ?",
 		string(updatedData), time.Now(), action.FlowID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update flow: %w", err)
@@ -261,7 +273,7 @@ func applyPromptOptimization(db *sql.DB, action ApplyAction) (*ApplyResult, erro
 	// For prompt optimization, we flag the flow by updating its status
 	// In a real implementation, this could trigger a review workflow
 	_, err := db.Exec(
-		"UPDATE forge_flows SET status = 'needs_optimization', updated_at = ? WHERE id = ?",
+		"UPDATE forge_flows SET status = 'needs_optimization', updated_at = ?, revision = revision + 1 WHERE id = ?",
 		time.Now(), action.FlowID,
 	)
 	if err != nil {
@@ -308,7 +320,7 @@ func applyRetryStrategy(db *sql.DB, action ApplyAction) (*ApplyResult, error) {
 		return nil, fmt.Errorf("failed to serialize flow data: %w", err)
 	}
 
-	_, err = db.Exec("UPDATE forge_flows SET data = ?, updated_at = ? WHERE id = ?",
+	_, err = db.Exec("UPDATE forge_flows SET data = ?, updated_at = ?, revision = revision + 1 WHERE id = ?",
 		string(updatedData), time.Now(), action.FlowID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update flow: %w", err)
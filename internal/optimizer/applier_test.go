@@ -38,7 +38,8 @@ func setupApplierTestDB(t *testing.T) *sql.DB {
 			data TEXT NOT NULL,
 			status TEXT DEFAULT 'draft',
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			revision INTEGER NOT NULL DEFAULT 1
 		);
 	`
 
@@ -18,6 +18,16 @@ type Suggestion struct {
 	CreatedAt        *time.Time `json:"created_at,omitempty"`
 }
 
+// Analysis wraps one analyzer run so the UI can tell "no data yet"
+// (LedgerRows is 0) apart from "nothing to optimize" (rows but no suggestions).
+type Analysis struct {
+	Analyzed      bool         `json:"analyzed"`        // True once the analyzer has run against the ledger
+	LedgerRows    int          `json:"ledger_rows"`     // How many ledger entries the analysis considered
+	MinLedgerRows int          `json:"min_ledger_rows"` // How many entries are needed before the analyzer runs
+	Message       string       `json:"message,omitempty"`
+	Suggestions   []Suggestion `json:"suggestions"`
+}
+
 // ApplyAction represents the parsed action to be applied
 type ApplyAction struct {
 	Action    string `json:"action"`              // model_switch, prompt_optimization, retry_strategy
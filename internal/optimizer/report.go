@@ -0,0 +1,94 @@
+package optimizer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// reportSections orders and names the suggestion types in a report. Types not listed
+// here follow them, headed by the raw type name.
+var reportSections = []struct {
+	Type  string
+	Title string
+}{
+	{"model_switch", "Model switches"},
+	{"prompt_optimization", "Prompt optimizations"},
+	{"retry_strategy", "Retry strategies"},
+}
+
+// MarkdownReport renders the pending suggestions as a Markdown report, grouped by type,
+// that can be pasted into an email or ticket. Applied suggestions are left out.
+// Savings are totalled per unit, since dollars and tokens don't add up.
+func MarkdownReport(suggestions []Suggestion, generatedAt time.Time) string {
+	byType := map[string][]Suggestion{}
+	totals := map[string]float64{}
+	pending := 0
+	for _, s := range suggestions {
+		if s.Status != "pending" {
+			continue
+		}
+		byType[s.Type] = append(byType[s.Type], s)
+		totals[s.SavingsUnit] += s.EstimatedSavings
+		pending++
+	}
+
+	var b strings.Builder
+	b.WriteString("# Forge optimization report\n\n")
+	fmt.Fprintf(&b, "Generated %s. %d pending suggestion(s).\n\n", generatedAt.UTC().Format("2006-01-02 15:04 MST"), pending)
+	if pending == 0 {
+		b.WriteString("No pending suggestions right now.\n")
+		return b.String()
+	}
+	fmt.Fprintf(&b, "**Total potential savings:** %s\n", formatSavingsTotals(totals))
+
+	writeSection := func(title string, group []Suggestion) {
+		fmt.Fprintf(&b, "\n## %s (%d)\n", title, len(group))
+		for _, s := range group {
+			fmt.Fprintf(&b, "\n### %s\n\n%s\n\nEstimated savings: %s\n", s.Title, s.Description, formatSavings(s.EstimatedSavings, s.SavingsUnit))
+		}
+	}
+	for _, section := range reportSections {
+		if group := byType[section.Type]; len(group) > 0 {
+			writeSection(section.Title, group)
+			delete(byType, section.Type)
+		}
+	}
+	var others []string
+	for t := range byType {
+		others = append(others, t)
+	}
+	sort.Strings(others)
+	for _, t := range others {
+		writeSection(t, byType[t])
+	}
+	return b.String()
+}
+
+// formatSavingsTotals lists the total for each unit, dollars first.
+func formatSavingsTotals(totals map[string]float64) string {
+	units := make([]string, 0, len(totals))
+	for unit := range totals {
+		units = append(units, unit)
+	}
+	sort.Slice(units, func(i, j int) bool {
+		if (units[i] == "USD") != (units[j] == "USD") {
+			return units[i] == "USD"
+		}
+		return units[i] < units[j]
+	})
+
+	parts := make([]string, len(units))
+	for i, unit := range units {
+		parts[i] = formatSavings(totals[unit], unit)
+	}
+	return strings.Join(parts, " and ")
+}
+
+func formatSavings(amount float64, unit string) string {
+	if unit == "USD" {
+		return fmt.Sprintf("$%.4f", amount)
+	}
+	return fmt.Sprintf("%.0f %s", amount, unit)
+}
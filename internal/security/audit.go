@@ -0,0 +1,59 @@
+package security
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/logging"
+)
+
+// Actions recorded in key_audit.
+const (
+	KeyActionSet       = "SET"
+	KeyActionRotate    = "ROTATE"
+	KeyActionDelete    = "DELETE"
+	KeyActionDeleteAll = "DELETE_ALL"
+)
+
+// KeyAuditEntry is one key management operation. It deliberately has no field for the key.
+type KeyAuditEntry struct {
+	ID        int64     `json:"id"`
+	Provider  string    `json:"provider"`
+	Action    string    `json:"action"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// RecordKeyAudit appends an operation on a provider's key to key_audit.
+// A failed write is logged rather than failing the operation, which has already happened.
+func RecordKeyAudit(db *sql.DB, provider, action string) {
+	if db == nil {
+		return
+	}
+	query := `INSERT INTO key_audit (provider, action, created_at) VALUES (?, ?, ?)`
+	if _, err := db.Exec(query, provider, action, time.Now().UTC()); err != nil {
+		logging.Warnf("Failed to record %s of the %s key in the audit log: %v", action, provider, err)
+	}
+}
+
+// ListKeyAudit returns up to limit of the most recent key operations, newest first.
+func ListKeyAudit(db *sql.DB, limit int) ([]KeyAuditEntry, error) {
+	rows, err := db.Query(`SELECT id, provider, action, created_at FROM key_audit ORDER BY id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list key audit log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []KeyAuditEntry{}
+	for rows.Next() {
+		var e KeyAuditEntry
+		if err := rows.Scan(&e.ID, &e.Provider, &e.Action, &e.Timestamp); err != nil {
+			return nil, fmt.Errorf("failed to scan key audit entry: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list key audit log: %w", err)
+	}
+	return entries, nil
+}
@@ -1,11 +1,36 @@
 package security
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/zalando/go-keyring"
 )
 
 const serviceName = "forge-orchestrator"
 
+// previousKeySuffix names the entry a rotated-out key is kept under, e.g. "OpenAI.previous".
+const previousKeySuffix = ".previous"
+
+// RotationGrace is how long RotateAPIKey keeps the old key after swapping in a new one.
+var RotationGrace = 10 * time.Minute
+
+// rotateMu stops two rotations of the same key from interleaving their read and writes.
+var rotateMu sync.Mutex
+
+// keyPrefixes are the prefixes each provider's keys are issued with.
+var keyPrefixes = map[string]string{
+	"anthropic": "sk-ant-",
+	"openai":    "sk-",
+	"google":    "AIza",
+}
+
+// KnownProviders are the providers whose keys Forge looks for and reports on.
+var KnownProviders = []string{"Anthropic", "OpenAI", "Google"}
+
 // SetAPIKey stores an API key for a specific provider in the OS keyring.
 // Educational Comment: We use the `zalando/go-keyring` library which abstracts
 // the underlying OS-specific keyring implementations (Keychain on macOS,
@@ -26,3 +51,79 @@ func GetAPIKey(provider string) (string, error) {
 func DeleteAPIKey(provider string) error {
 	return keyring.Delete(serviceName, provider)
 }
+
+// DeleteAllAPIKeys removes the key of every provider in KnownProviders, and any key kept
+// from a rotation, and returns how many were stored. Providers without a key are skipped;
+// it stops at the first other error.
+func DeleteAllAPIKeys() (int, error) {
+	cleared := 0
+	for _, provider := range KnownProviders {
+		keyring.Delete(serviceName, provider+previousKeySuffix)
+		err := DeleteAPIKey(provider)
+		if errors.Is(err, keyring.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return cleared, err
+		}
+		cleared++
+	}
+	return cleared, nil
+}
+
+// ValidateAPIKey checks that key looks like one of the provider's keys: not empty, no
+// whitespace, and for the known providers the prefix their keys are issued with.
+// Educational Comment: This catches a key pasted for the wrong provider or cut short,
+// without spending a request on the provider to find out.
+func ValidateAPIKey(provider, key string) error {
+	if key == "" {
+		return errors.New("key is empty")
+	}
+	if strings.ContainsAny(key, " \t\r\n") {
+		return errors.New("key contains whitespace")
+	}
+	if prefix, ok := keyPrefixes[strings.ToLower(provider)]; ok && !strings.HasPrefix(key, prefix) {
+		return fmt.Errorf("%s keys start with %q", provider, prefix)
+	}
+	return nil
+}
+
+// RotateAPIKey validates newKey and replaces the provider's stored key with it. The old key
+// is kept as PreviousAPIKey for RotationGrace, then deleted. It returns keyring.ErrNotFound
+// if the provider has no key to rotate, and leaves the stored key untouched on any error.
+// Educational Comment: Calls already running read the old key when they started and keep
+// using it, so revoke it at the provider only once the grace period is over.
+func RotateAPIKey(provider, newKey string) error {
+	if err := ValidateAPIKey(provider, newKey); err != nil {
+		return err
+	}
+
+	rotateMu.Lock()
+	defer rotateMu.Unlock()
+
+	oldKey, err := GetAPIKey(provider)
+	if err != nil {
+		return err
+	}
+	if err := keyring.Set(serviceName, provider+previousKeySuffix, oldKey); err != nil {
+		return err
+	}
+	if err := SetAPIKey(provider, newKey); err != nil {
+		return err
+	}
+
+	time.AfterFunc(RotationGrace, func() {
+		rotateMu.Lock()
+		defer rotateMu.Unlock()
+		// A later rotation may have replaced the kept key; only drop the one from this rotation
+		if kept, err := PreviousAPIKey(provider); err == nil && kept == oldKey {
+			keyring.Delete(serviceName, provider+previousKeySuffix)
+		}
+	})
+	return nil
+}
+
+// PreviousAPIKey returns the key RotateAPIKey last replaced, while its grace period lasts.
+func PreviousAPIKey(provider string) (string, error) {
+	return keyring.Get(serviceName, provider+previousKeySuffix)
+}
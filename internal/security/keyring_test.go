@@ -2,6 +2,7 @@ package security
 
 import (
 	"testing"
+	"time"
 
 	"github.com/zalando/go-keyring"
 )
@@ -40,3 +41,47 @@ func TestKeyring(t *testing.T) {
 		t.Errorf("Expected ErrNotFound after deletion, got %v", err)
 	}
 }
+
+func TestRotateAPIKey(t *testing.T) {
+	keyring.MockInit()
+	grace := RotationGrace
+	RotationGrace = 20 * time.Millisecond
+	t.Cleanup(func() { RotationGrace = grace })
+
+	if err := RotateAPIKey("Anthropic", "sk-ant-new"); err != keyring.ErrNotFound {
+		t.Errorf("Expected ErrNotFound rotating a missing key, got %v", err)
+	}
+
+	SetAPIKey("Anthropic", "sk-ant-old")
+	if err := RotateAPIKey("Anthropic", "sk-openai-key"); err == nil {
+		t.Error("Expected a key without the sk-ant- prefix to be refused")
+	}
+	if key, _ := GetAPIKey("Anthropic"); key != "sk-ant-old" {
+		t.Errorf("Expected the old key after a failed rotation, got %q", key)
+	}
+
+	if err := RotateAPIKey("Anthropic", "sk-ant-new"); err != nil {
+		t.Fatalf("Failed to rotate key: %v", err)
+	}
+	if key, _ := GetAPIKey("Anthropic"); key != "sk-ant-new" {
+		t.Errorf("Expected the new key, got %q", key)
+	}
+	if previous, err := PreviousAPIKey("Anthropic"); err != nil || previous != "sk-ant-old" {
+		t.Errorf("Expected the old key kept during the grace period, got %q (%v)", previous, err)
+	}
+
+	// The mock keyring isn't safe for concurrent use, so read it under the lock the timer takes
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		rotateMu.Lock()
+		_, err := PreviousAPIKey("Anthropic")
+		rotateMu.Unlock()
+		if err == keyring.ErrNotFound {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the old key to be deleted after the grace period")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
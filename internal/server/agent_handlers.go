@@ -0,0 +1,102 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/agents"
+)
+
+// AgentPromptResponse shows the system prompt a role resolves to.
+type AgentPromptResponse struct {
+	Role         string `json:"role"`         // The role as requested (may be an alias)
+	ResolvedRole string `json:"resolvedRole"` // The canonical role it maps to
+	Prompt       string `json:"prompt"`       // The exact system prompt sent to the provider
+}
+
+// AgentRoleInfo describes one canonical role and the aliases that resolve to it.
+type AgentRoleInfo struct {
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases"`
+	Custom  bool     `json:"custom,omitempty"` // User-defined, from /api/agents/custom
+}
+
+// AgentRolesResponse lists every role a command or flow node can use.
+type AgentRolesResponse struct {
+	Roles   []AgentRoleInfo   `json:"roles"`
+	Aliases map[string]string `json:"aliases"` // alias -> canonical role
+}
+
+// handleListAgents returns the canonical agent roles and their aliases, followed by any
+// custom roles, for populating role pickers.
+// Educational Comment: The list is exactly what agents.ResolveRole accepts.
+func (s *Server) handleListAgents(w http.ResponseWriter, r *http.Request) {
+	aliases := agents.GetRoleAliases()
+
+	byRole := make(map[string][]string)
+	for alias, canonical := range aliases {
+		byRole[canonical] = append(byRole[canonical], alias)
+	}
+
+	roles := make([]AgentRoleInfo, 0, len(agents.GetCanonicalRoles()))
+	for _, name := range agents.GetCanonicalRoles() {
+		roleAliases := byRole[name]
+		if roleAliases == nil {
+			roleAliases = []string{}
+		}
+		sort.Strings(roleAliases)
+		roles = append(roles, AgentRoleInfo{Name: name, Aliases: roleAliases})
+	}
+
+	if s.db != nil {
+		custom, err := s.listCustomAgents()
+		if err != nil {
+			http.Error(w, "Failed to query custom agents: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for _, role := range custom {
+			roles = append(roles, AgentRoleInfo{Name: role.Name, Aliases: []string{}, Custom: true})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(AgentRolesResponse{
+		Roles:   roles,
+		Aliases: aliases,
+	})
+}
+
+// handleGetAgentPrompt returns the system prompt that a role (or alias) resolves to.
+// Educational Comment: This is the same lookup the LLM Gateway performs, so what
+// you see here is exactly what the provider receives as the system message.
+func (s *Server) handleGetAgentPrompt(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	role := r.PathValue("role")
+
+	resolved, ok := agents.ResolveRole(role)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":      "Unknown agent role: " + role,
+			"validRoles": agents.GetCanonicalRoles(),
+			"aliases":    agents.GetRoleAliases(),
+		})
+		return
+	}
+
+	prompt, err := agents.GetAgentPrompt(resolved)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(AgentPromptResponse{
+		Role:         role,
+		ResolvedRole: resolved,
+		Prompt:       prompt,
+	})
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/agents"
+)
+
+func getAgentPrompt(t *testing.T, role string) *httptest.ResponseRecorder {
+	s := &Server{}
+	req := httptest.NewRequest("GET", "/api/agents/"+role+"/prompt", nil)
+	rr := httptest.NewRecorder()
+	s.RegisterRoutes().ServeHTTP(rr, req)
+	return rr
+}
+
+func TestHandleGetAgentPrompt_CanonicalAndAlias(t *testing.T) {
+	tests := []struct {
+		role     string
+		resolved string
+		prompt   string
+	}{
+		{"Architect", "Architect", agents.SystemPromptArchitect},
+		{"Implementation", "Implementation", agents.SystemPromptImplementation},
+		{"coder", "Implementation", agents.SystemPromptImplementation},
+		{"qa", "Test", agents.SystemPromptTest},
+		{"AUDITOR", "Optimizer", agents.SystemPromptOptimizer},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.role, func(t *testing.T) {
+			rr := getAgentPrompt(t, tt.role)
+			if rr.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d", rr.Code)
+			}
+
+			var resp AgentPromptResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if resp.Role != tt.role || resp.ResolvedRole != tt.resolved {
+				t.Errorf("Expected %s -> %s, got %s -> %s", tt.role, tt.resolved, resp.Role, resp.ResolvedRole)
+			}
+			if resp.Prompt != tt.prompt {
+				t.Errorf("Expected the %s system prompt, got %q", tt.resolved, resp.Prompt)
+			}
+		})
+	}
+}
+
+func TestHandleGetAgentPrompt_UnknownRole(t *testing.T) {
+	rr := getAgentPrompt(t, "wizard")
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("Expected status 404, got %d", rr.Code)
+	}
+
+	var resp struct {
+		Error      string   `json:"error"`
+		ValidRoles []string `json:"validRoles"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Error == "" {
+		t.Error("Expected an error message")
+	}
+	if len(resp.ValidRoles) != len(agents.GetCanonicalRoles()) {
+		t.Errorf("Expected valid roles to be listed, got %v", resp.ValidRoles)
+	}
+}
+
+func TestHandleListAgents(t *testing.T) {
+	for _, path := range []string{"/api/agents", "/api/agents/roles"} {
+		t.Run(path, func(t *testing.T) { checkListAgents(t, path) })
+	}
+}
+
+// checkListAgents asserts path lists the four built-in roles and their known aliases.
+func checkListAgents(t *testing.T, path string) {
+	s := &Server{}
+	req := httptest.NewRequest("GET", path, nil)
+	rr := httptest.NewRecorder()
+	s.RegisterRoutes().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+
+	var resp AgentRolesResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	roles := make(map[string][]string)
+	for _, role := range resp.Roles {
+		roles[role.Name] = role.Aliases
+	}
+	for _, name := range []string{"Architect", "Implementation", "Test", "Optimizer"} {
+		if _, ok := roles[name]; !ok {
+			t.Errorf("Expected built-in role %s in response", name)
+		}
+	}
+
+	implAliases := map[string]bool{}
+	for _, alias := range roles["Implementation"] {
+		implAliases[alias] = true
+	}
+	for _, alias := range []string{"coder", "developer", "dev"} {
+		if !implAliases[alias] {
+			t.Errorf("Expected alias %s under Implementation, got %v", alias, roles["Implementation"])
+		}
+	}
+
+	if resp.Aliases["qa"] != "Test" || resp.Aliases["planner"] != "Architect" {
+		t.Errorf("Expected alias map to include qa->Test and planner->Architect, got %v", resp.Aliases)
+	}
+}
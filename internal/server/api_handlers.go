@@ -4,11 +4,16 @@ package server
 
 import (
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
 	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
 
 	"github.com/mikejsmith1985/forge-orchestrator/internal/config"
 	"github.com/mikejsmith1985/forge-orchestrator/internal/execution"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/logging"
 )
 
 // ExecuteRequest represents the JSON payload for the /api/execute endpoint.
@@ -22,6 +27,10 @@ type ExecuteRequest struct {
 
 	// TimeoutSeconds is optional - if not provided, uses default (no timeout).
 	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+
+	// InheritEnv is optional - false runs the command with an empty environment.
+	// If not provided, the command inherits the server's environment, minus Forge's secrets.
+	InheritEnv *bool `json:"inheritEnv,omitempty"`
 }
 
 // ExecuteResponse represents the JSON response from the /api/execute endpoint.
@@ -121,11 +130,32 @@ func (s *Server) handleExecute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Refuse commands and directories outside the configured allowlists.
+	cfg, err := config.Get()
+	if err != nil {
+		logging.Errorf("Failed to get config: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ExecuteResponse{
+			Message: "Failed to load configuration",
+			Success: false,
+		})
+		return
+	}
+	if err := checkExecuteAllowed(cfg.Execute, req); err != nil {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(ExecuteResponse{
+			Message: err.Error(),
+			Success: false,
+		})
+		return
+	}
+
 	// Create an ExecutionContext from the request.
 	ctx := execution.ExecutionContext{
 		Command:        req.Command,
 		WorkingDir:     req.WorkingDir,
 		TimeoutSeconds: req.TimeoutSeconds,
+		InheritEnv:     req.InheritEnv == nil || *req.InheritEnv,
 	}
 
 	// Execute the command using the Executor interface.
@@ -151,6 +181,51 @@ func (s *Server) handleExecute(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// shellOperators are the characters that let one bash command line run another command.
+const shellOperators = ";&|`$()<>\n"
+
+// checkExecuteAllowed returns why Execute.AllowedWorkingDirs or Execute.AllowedCommands
+// refuse req, or nil when it may run. Empty lists allow anything.
+func checkExecuteAllowed(cfg config.ExecuteConfig, req ExecuteRequest) error {
+	if len(cfg.AllowedWorkingDirs) > 0 {
+		// An empty WorkingDir runs in Forge's own directory, which must be allowed too
+		dir, err := resolveDir(req.WorkingDir)
+		if err != nil || !slices.ContainsFunc(cfg.AllowedWorkingDirs, func(allowed string) bool {
+			root, err := resolveDir(allowed)
+			return err == nil && isWithin(root, dir)
+		}) {
+			return fmt.Errorf("working directory %q is not in execute.allowed_working_dirs", req.WorkingDir)
+		}
+	}
+
+	if len(cfg.AllowedCommands) > 0 {
+		if strings.ContainsAny(req.Command, shellOperators) {
+			return errors.New("commands can't use shell operators (; & | ` $ ( ) < > or newlines) while execute.allowed_commands is set")
+		}
+		program, _, _ := strings.Cut(strings.TrimSpace(req.Command), " ")
+		if !slices.Contains(cfg.AllowedCommands, program) {
+			return fmt.Errorf("command %q is not in execute.allowed_commands", program)
+		}
+	}
+	return nil
+}
+
+// resolveDir returns dir as an absolute path with symlinks resolved, so neither ".."
+// nor a link can lead out of an allowed directory.
+func resolveDir(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(abs)
+}
+
+// isWithin reports whether path is root or inside it.
+func isWithin(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
 // handlePTYCommandExecute injects a command into an active PTY session.
 // Task 2.2: This is the API used by Flow Nodes and Command Cards to execute
 // commands in the integrated terminal, simulating a human typing.
@@ -210,7 +285,7 @@ func (s *Server) handleGetConfig(w http.ResponseWriter, r *http.Request) {
 
 	cfg, err := config.Get()
 	if err != nil {
-		log.Printf("Failed to get config: %v", err)
+		logging.Errorf("Failed to get config: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{
 			"error": "Failed to load configuration",
@@ -236,7 +311,7 @@ func (s *Server) handleSaveConfig(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := config.Save(&cfg); err != nil {
-		log.Printf("Failed to save config: %v", err)
+		logging.Errorf("Failed to save config: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{
 			"error": "Failed to save configuration",
@@ -244,7 +319,7 @@ func (s *Server) handleSaveConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Printf("Configuration saved successfully (shell: %s)", cfg.Shell.Type)
+	logging.Infof("Configuration saved successfully (shell: %s)", cfg.Shell.Type)
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{
 		"message": "Configuration saved successfully",
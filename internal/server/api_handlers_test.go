@@ -9,8 +9,11 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/mikejsmith1985/forge-orchestrator/internal/config"
 	"github.com/mikejsmith1985/forge-orchestrator/internal/data"
 	"github.com/mikejsmith1985/forge-orchestrator/internal/execution"
 )
@@ -263,3 +266,71 @@ func TestRouterHasExecuteEndpoint(t *testing.T) {
 		t.Errorf("Expected status 200, got %d", rr.Code)
 	}
 }
+
+// TestHandleExecute_Allowlist verifies that with Execute allowlists configured, commands
+// only run in the allowed directories and only the allowed programs run.
+func TestHandleExecute_Allowlist(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	allowed, other := t.TempDir(), t.TempDir()
+	sub := filepath.Join(allowed, "repo")
+	if err := os.Mkdir(sub, 0o755); err != nil {
+		t.Fatalf("Failed to create subdirectory: %v", err)
+	}
+	cfg := config.DefaultConfig()
+	cfg.Execute = config.ExecuteConfig{AllowedWorkingDirs: []string{allowed}, AllowedCommands: []string{"pwd", "echo"}}
+	if err := config.Save(cfg); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	t.Cleanup(func() { config.Save(config.DefaultConfig()) })
+
+	srv := NewServer(nil)
+	execute := func(command, dir string) (int, ExecuteResponse) {
+		body, _ := json.Marshal(ExecuteRequest{Command: command, WorkingDir: dir})
+		rr := httptest.NewRecorder()
+		srv.handleExecute(rr, httptest.NewRequest(http.MethodPost, "/api/execute", bytes.NewReader(body)))
+		var resp ExecuteResponse
+		json.Unmarshal(rr.Body.Bytes(), &resp)
+		return rr.Code, resp
+	}
+
+	// An allowed directory, or one inside it, runs the command
+	code, resp := execute("pwd", sub)
+	if want, _ := filepath.EvalSymlinks(sub); code != http.StatusOK || strings.TrimSpace(resp.Stdout) != want {
+		t.Errorf("Expected pwd to run in %s, got %d: %+v", want, code, resp)
+	}
+
+	denied := []struct{ name, command, dir string }{
+		{"other directory", "pwd", other},
+		{"escape with ..", "pwd", filepath.Join(sub, "..", "..")},
+		{"server's own directory", "pwd", ""},
+		{"program not allowed", "ls", allowed},
+		{"chained command", "echo hi; ls", allowed},
+	}
+	for _, tt := range denied {
+		if code, resp := execute(tt.command, tt.dir); code != http.StatusForbidden || resp.Success || resp.Stdout != "" {
+			t.Errorf("%s: expected 403 without running, got %d: %+v", tt.name, code, resp)
+		}
+	}
+}
+
+func TestHandleExecute_InheritEnv(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("FORGE_TEST_SETTING", "inherited")
+	srv := NewServer(nil)
+	execute := func(inherit *bool) string {
+		body, _ := json.Marshal(ExecuteRequest{Command: "echo [$FORGE_TEST_SETTING]", InheritEnv: inherit})
+		rr := httptest.NewRecorder()
+		srv.handleExecute(rr, httptest.NewRequest(http.MethodPost, "/api/execute", bytes.NewReader(body)))
+		var resp ExecuteResponse
+		json.Unmarshal(rr.Body.Bytes(), &resp)
+		return strings.TrimSpace(resp.Stdout)
+	}
+
+	if got := execute(nil); got != "[inherited]" {
+		t.Errorf("Expected the server's environment by default, got %q", got)
+	}
+	clean := false
+	if got := execute(&clean); got != "[]" {
+		t.Errorf("Expected an empty environment with inheritEnv false, got %q", got)
+	}
+}
@@ -1,10 +1,10 @@
 package server
 
 import (
-	"log"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/logging"
 )
 
 const (
@@ -19,6 +19,9 @@ const (
 
 	// Maximum message size allowed from peer
 	maxMessageSize = 512
+
+	// Messages queued for a client before the hub gives up on it as too slow
+	sendBufferSize = 256
 )
 
 // Client represents a WebSocket client connection
@@ -26,6 +29,11 @@ type Client struct {
 	hub  *Hub
 	conn *websocket.Conn
 	send chan []byte
+
+	// resumeFlowID and resumeAfterSeq come from a reconnecting client: on registering,
+	// the hub replays the flow's events numbered after resumeAfterSeq
+	resumeFlowID   int
+	resumeAfterSeq int64
 }
 
 // NewClient creates a new Client instance
@@ -33,14 +41,14 @@ func NewClient(hub *Hub, conn *websocket.Conn) *Client {
 	return &Client{
 		hub:  hub,
 		conn: conn,
-		send: make(chan []byte, 256),
+		send: make(chan []byte, sendBufferSize),
 	}
 }
 
 // readPump pumps messages from the WebSocket connection to the hub
 func (c *Client) readPump() {
 	defer func() {
-		c.hub.unregister <- c
+		c.hub.Unregister(c)
 		c.conn.Close()
 	}()
 
@@ -55,13 +63,14 @@ func (c *Client) readPump() {
 		_, message, err := c.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("error: %v", err)
+				logging.Errorf("error: %v", err)
 			}
 			break
 		}
-		log.Printf("recv: %s", message)
-		// Echo the message back to the sender for now
-		c.send <- message
+		logging.Debugf("recv: %s", message)
+		// Echo the message back to the sender for now. Going through the hub means
+		// an evicted client's closed channel is never written to.
+		c.hub.SendToClient(c, message)
 	}
 }
 
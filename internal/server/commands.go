@@ -3,12 +3,19 @@ package server
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"math"
 	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/agents"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/budget"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/config"
 	"github.com/mikejsmith1985/forge-orchestrator/internal/data"
 	"github.com/mikejsmith1985/forge-orchestrator/internal/llm"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/logging"
 	"github.com/mikejsmith1985/forge-orchestrator/internal/security"
 )
 
@@ -18,15 +25,18 @@ type CommandCard struct {
 	Name        string `json:"name"`
 	Command     string `json:"command"`
 	Description string `json:"description"`
+
+	// MaxCostUSD refuses further runs once the card's total spend reaches this cap (0 = no cap)
+	MaxCostUSD float64 `json:"max_cost_usd,omitempty"`
 }
 
 // handleGetCommands returns all command cards.
 // Educational Comment: We use a simple SELECT query to retrieve all rows.
 // In a production app with many users, we'd likely need pagination or filtering here.
 func (s *Server) handleGetCommands(w http.ResponseWriter, r *http.Request) {
-	rows, err := s.db.Query("SELECT id, name, command, description FROM command_cards ORDER BY id DESC")
+	rows, err := s.db.Query("SELECT id, name, command, description, COALESCE(max_cost_usd, 0) FROM command_cards ORDER BY id DESC")
 	if err != nil {
-		http.Error(w, "Failed to query commands: "+err.Error(), http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to query commands: "+err.Error())
 		return
 	}
 	defer rows.Close()
@@ -34,8 +44,8 @@ func (s *Server) handleGetCommands(w http.ResponseWriter, r *http.Request) {
 	var commands []CommandCard
 	for rows.Next() {
 		var c CommandCard
-		if err := rows.Scan(&c.ID, &c.Name, &c.Command, &c.Description); err != nil {
-			http.Error(w, "Failed to scan command: "+err.Error(), http.StatusInternalServerError)
+		if err := rows.Scan(&c.ID, &c.Name, &c.Command, &c.Description, &c.MaxCostUSD); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Failed to scan command: "+err.Error())
 			return
 		}
 		commands = append(commands, c)
@@ -51,18 +61,18 @@ func (s *Server) handleGetCommands(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleCreateCommand(w http.ResponseWriter, r *http.Request) {
 	var c CommandCard
 	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
 	if c.Name == "" || c.Command == "" {
-		http.Error(w, "Name and Command are required", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "Name and Command are required")
 		return
 	}
 
-	res, err := s.db.Exec("INSERT INTO command_cards (name, command, description) VALUES (?, ?, ?)", c.Name, c.Command, c.Description)
+	res, err := s.db.Exec("INSERT INTO command_cards (name, command, description, max_cost_usd) VALUES (?, ?, ?, ?)", c.Name, c.Command, c.Description, c.MaxCostUSD)
 	if err != nil {
-		http.Error(w, "Failed to insert command: "+err.Error(), http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to insert command: "+err.Error())
 		return
 	}
 
@@ -81,13 +91,13 @@ func (s *Server) handleDeleteCommand(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "Invalid ID")
 		return
 	}
 
 	_, err = s.db.Exec("DELETE FROM command_cards WHERE id = ?", id)
 	if err != nil {
-		http.Error(w, "Failed to delete command: "+err.Error(), http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to delete command: "+err.Error())
 		return
 	}
 
@@ -99,6 +109,48 @@ type RunCommandRequest struct {
 	AgentRole  string `json:"agent_role"`
 	UserPrompt string `json:"user_prompt"`
 	Provider   string `json:"provider"`
+	// Attachments are optional files appended to the prompt as context
+	Attachments []llm.Attachment `json:"attachments,omitempty"`
+	// Environment tags the ledger entry, e.g. "dev" for test runs (default prod)
+	Environment string `json:"environment,omitempty"`
+	// FallbackModels are providers to try, in order, when Provider answers 429 or 503.
+	// An entry is a provider name or a model its client runs (see llm.ResolveFallback);
+	// anything else is refused with 400. Their keys come from the keyring.
+	FallbackModels []string `json:"fallback_models,omitempty"`
+	// MaxOutputTokens caps the response length (max_tokens); 0 uses the model's maximum
+	MaxOutputTokens int `json:"max_output_tokens,omitempty"`
+}
+
+// writeAttachmentError reports an attachment validation failure,
+// using 413 when the attachments are simply too big.
+func writeAttachmentError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	if errors.Is(err, llm.ErrAttachmentsTooLarge) {
+		status = http.StatusRequestEntityTooLarge
+	}
+	writeJSONError(w, status, "Invalid attachments: "+err.Error())
+}
+
+// defaultRetryAfterSeconds is suggested to clients on a 429 when the provider didn't say how long to wait.
+const defaultRetryAfterSeconds = 30
+
+// writeLLMError reports an LLM failure with the status it deserves rather than a blanket 500:
+// a bad key is 401, a provider rate limit is 429 with Retry-After, and a timeout is 504.
+func writeLLMError(w http.ResponseWriter, prefix string, err error) {
+	status := llm.HTTPStatusForError(err)
+	if status == http.StatusTooManyRequests {
+		setRetryAfter(w, err)
+	}
+	writeJSONError(w, status, prefix+err.Error())
+}
+
+// setRetryAfter passes the provider's Retry-After hint on to the client, rounded up to whole seconds.
+func setRetryAfter(w http.ResponseWriter, err error) {
+	seconds := defaultRetryAfterSeconds
+	if wait := llm.RetryAfter(err); wait > 0 {
+		seconds = int(math.Ceil(wait.Seconds()))
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
 }
 
 // handleRunCommand executes a prompt using the LLM Gateway.
@@ -119,18 +171,35 @@ func (s *Server) handleRunCommand(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "Invalid ID")
 		return
 	}
 
 	var req RunCommandRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
 	if req.AgentRole == "" || req.Provider == "" {
-		http.Error(w, "agent_role and provider are required", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "agent_role and provider are required")
+		return
+	}
+	if req.MaxOutputTokens < 0 {
+		writeJSONError(w, http.StatusBadRequest, "max_output_tokens can't be negative")
+		return
+	}
+
+	if err := llm.ValidateAttachments(req.Attachments); err != nil {
+		writeAttachmentError(w, err)
+		return
+	}
+
+	// Convert string provider to ProviderType
+	provider := llm.ProviderType(req.Provider)
+	chain, err := llm.FallbackChain(provider, req.FallbackModels)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid fallback_models: "+err.Error())
 		return
 	}
 
@@ -143,75 +212,149 @@ func (s *Server) handleRunCommand(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if apiKey == "" {
-		http.Error(w, "Missing X-Forge-Api-Key header and no key found in keyring", http.StatusUnauthorized)
+		writeJSONError(w, http.StatusUnauthorized, "Missing X-Forge-Api-Key header and no key found in keyring")
+		return
+	}
+
+	// Cap how many command cards can be hitting the LLM at once
+	if !s.acquireCommandRun() {
+		w.Header().Set("Retry-After", "1")
+		writeJSONError(w, http.StatusTooManyRequests, "Too many command runs in progress; try again shortly")
 		return
 	}
+	defer s.releaseCommandRun()
 
 	// Fetch command from database
 	var commandPrompt string
-	err = s.db.QueryRow("SELECT command FROM command_cards WHERE id = ?", id).Scan(&commandPrompt)
+	var maxCost float64
+	err = s.db.QueryRow("SELECT command, COALESCE(max_cost_usd, 0) FROM command_cards WHERE id = ?", id).Scan(&commandPrompt, &maxCost)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			http.Error(w, "Command not found", http.StatusNotFound)
+			writeJSONError(w, http.StatusNotFound, "Command not found")
 		} else {
-			http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+			writeJSONError(w, http.StatusInternalServerError, "Database error: "+err.Error())
 		}
 		return
 	}
 
-	// Convert string provider to ProviderType
-	provider := llm.ProviderType(req.Provider)
-
-	// Time the execution
-	startTime := time.Now()
+	// Append any attached files after the command text
+	commandPrompt, err = llm.AppendAttachments(commandPrompt, req.Attachments)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid attachments: "+err.Error())
+		return
+	}
 
-	// Execute via Gateway
-	response, err := s.gateway.ExecutePrompt(req.AgentRole, commandPrompt, apiKey, provider)
+	// Refuse the call once today's token quota is used up
+	if err := budget.CheckDailyTokenLimit(s.db); err != nil {
+		writeJSONError(w, http.StatusTooManyRequests, err.Error())
+		return
+	}
 
-	// Calculate latency in milliseconds
-	latencyMs := time.Since(startTime).Milliseconds()
+	// Refuse the call once this card has spent its own cap
+	if err := budget.CheckCostCap(s.commandSpend(id), 0, maxCost); err != nil {
+		writeJSONError(w, http.StatusPaymentRequired, err.Error())
+		return
+	}
 
-	// Prepare ledger entry using the canonical data model
-	ledgerEntry := data.TokenLedgerEntry{
-		Timestamp:  time.Now(),
-		FlowID:     "cmd-" + strconv.Itoa(id),
-		ModelUsed:  string(provider),
-		AgentRole:  req.AgentRole,
-		PromptHash: "hash-" + strconv.Itoa(len(commandPrompt)),
-		Status:     "SUCCESS",
-		LatencyMs:  int(latencyMs),
+	keyFor := func(p llm.ProviderType) (string, error) {
+		if p == provider {
+			return apiKey, nil
+		}
+		return security.GetAPIKey(string(p))
 	}
 
-	if err != nil {
-		ledgerEntry.Status = "FAILED"
-		ledgerEntry.ErrorMessage = err.Error()
-		// Log failure to ledger
+	// Every call this run makes shares one request ID, returned in X-Request-Id
+	requestID := uuid.NewString()
+	w.Header().Set("X-Request-Id", requestID)
+	log := logging.ForRequest(requestID)
+
+	// The prompt as sent, kept with each ledger entry when Ledger.StorePrompts is on
+	systemPrompt, _ := llm.ResolveSystemPrompt(req.AgentRole, "")
+	ledgerPrompt := &data.LedgerPrompt{SystemPrompt: systemPrompt, UserPrompt: commandPrompt}
+
+	// Execute via Gateway, logging every call (including fallbacks) to the ledger
+	response, _, err := s.gateway.ExecuteWithFallback(req.AgentRole, commandPrompt, llm.PromptOptions{MaxOutputTokens: req.MaxOutputTokens}, chain, keyFor, func(call llm.FallbackAttempt) {
+		// Prepare ledger entry using the canonical data model
+		ledgerEntry := data.TokenLedgerEntry{
+			Timestamp:   time.Now(),
+			FlowID:      "cmd-" + strconv.Itoa(id),
+			ModelUsed:   string(call.Provider),
+			AgentRole:   agents.CanonicalRole(req.AgentRole), // "coder" is recorded as "Implementation"
+			PromptHash:  llm.HashPrompt(systemPrompt, commandPrompt),
+			Status:      "SUCCESS",
+			LatencyMs:   int(call.Latency.Milliseconds()),
+			Environment: req.Environment,
+			RequestID:   requestID,
+			Prompt:      ledgerPrompt,
+		}
+		if call.Err != nil {
+			log.Warnf("Command %d failed on %s: %v", id, call.Provider, call.Err)
+			ledgerEntry.Status = "FAILED"
+			if llm.IsCapped(call.Err) {
+				ledgerEntry.Status = "CAPPED"
+			}
+			ledgerEntry.ErrorMessage = call.Err.Error()
+		} else {
+			ledgerEntry.InputTokens = call.Response.InputTokens
+			ledgerEntry.OutputTokens = call.Response.OutputTokens
+			ledgerEntry.TotalCostUSD = call.Response.Cost
+			if call.Response.Cached {
+				ledgerEntry.Status = "CACHE_HIT"
+			}
+		}
 		s.logToLedger(ledgerEntry)
-		http.Error(w, "LLM execution failed: "+err.Error(), http.StatusInternalServerError)
+	})
+	if err != nil {
+		writeLLMError(w, "LLM execution failed: ", err)
 		return
 	}
 
-	// Update ledger entry with success details
-	ledgerEntry.InputTokens = response.InputTokens
-	ledgerEntry.OutputTokens = response.OutputTokens
-	ledgerEntry.TotalCostUSD = response.Cost
-
-	// Log success to ledger
-	s.logToLedger(ledgerEntry)
-
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 }
 
+// acquireCommandRun reserves a slot for a command run, returning false when
+// Server.MaxConcurrentCommandRuns runs are already in flight.
+// Educational Comment: We reject instead of queueing so a burst of clicks fails fast
+// and visibly, rather than quietly stacking up spend behind the scenes.
+func (s *Server) acquireCommandRun() bool {
+	limit := config.DefaultMaxConcurrentCommandRuns
+	if cfg, err := config.Get(); err == nil {
+		limit = cfg.Server.CommandRunLimit()
+	}
+
+	if s.activeCommandRuns.Add(1) > int32(limit) {
+		s.activeCommandRuns.Add(-1)
+		return false
+	}
+	return true
+}
+
+// releaseCommandRun frees the slot taken by acquireCommandRun.
+func (s *Server) releaseCommandRun() {
+	s.activeCommandRuns.Add(-1)
+}
+
+// commandSpend sums what every run of a command card has cost so far.
+// Errors count as zero spend, as in spentToday.
+func (s *Server) commandSpend(id int) float64 {
+	var spent float64
+	query := `SELECT COALESCE(SUM(total_cost_usd), 0) FROM token_ledger WHERE flow_id = ?`
+	if err := s.db.QueryRow(query, "cmd-"+strconv.Itoa(id)).Scan(&spent); err != nil {
+		return 0
+	}
+	return spent
+}
+
 // logToLedger helper to insert into token_ledger using LedgerService.
 func (s *Server) logToLedger(entry data.TokenLedgerEntry) {
 	ledgerService := data.NewLedgerService(s.db)
 	if err := ledgerService.LogUsage(entry); err != nil {
 		// Log the error but don't fail the request
 		// This is best-effort logging
-		_ = err
+		logging.ForRequest(entry.RequestID).Errorf("Failed to log to ledger: %v", err)
 	}
 }
@@ -2,13 +2,22 @@ package server
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/mikejsmith1985/forge-orchestrator/internal/budget"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/config"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/llm"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/security"
+	"github.com/zalando/go-keyring"
 	_ "modernc.org/sqlite"
 )
 
@@ -24,7 +33,8 @@ func setupTestDB(t *testing.T) *sql.DB {
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		name TEXT NOT NULL,
 		command TEXT NOT NULL,
-		description TEXT
+		description TEXT,
+		max_cost_usd REAL
 	);
 	CREATE TABLE IF NOT EXISTS token_ledger (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -38,7 +48,12 @@ func setupTestDB(t *testing.T) *sql.DB {
 		total_cost_usd REAL,
 		latency_ms INTEGER,
 		status TEXT,
-		error_message TEXT
+		error_message TEXT,
+		environment TEXT NOT NULL DEFAULT 'prod',
+		request_id TEXT,
+		idempotency_key TEXT UNIQUE,
+		replay_of INTEGER,
+		node_id TEXT
 	);
 	`)
 	if err != nil {
@@ -219,6 +234,163 @@ func TestHandleRunCommand(t *testing.T) {
 	}
 }
 
+func TestHandleRunCommand_LogsCanonicalRole(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	res, _ := db.Exec("INSERT INTO command_cards (name, command, description) VALUES (?, ?, ?)", "Test Cmd", "echo test", "Desc")
+	id, _ := res.LastInsertId()
+
+	server := NewServer(db)
+	server.gateway.OpenAIClient = &MockLLMProvider{}
+
+	body := bytes.NewBufferString(`{"agent_role": "coder", "provider": "OpenAI"}`)
+	req, _ := http.NewRequest("POST", "/api/commands/"+strconv.Itoa(int(id))+"/run", body)
+	req.Header.Set("X-Forge-Api-Key", "test-key")
+	rr := httptest.NewRecorder()
+	server.RegisterRoutes().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var response map[string]interface{}
+	json.NewDecoder(rr.Body).Decode(&response)
+	if response["AgentRole"] != "Implementation" {
+		t.Errorf("Expected the response to carry the canonical role, got %v", response["AgentRole"])
+	}
+
+	var role, promptHash string
+	if err := db.QueryRow("SELECT agent_role, prompt_hash FROM token_ledger").Scan(&role, &promptHash); err != nil {
+		t.Fatalf("Failed to query ledger: %v", err)
+	}
+	if role != "Implementation" {
+		t.Errorf("Expected the alias to be logged as Implementation, got %q", role)
+	}
+	systemPrompt, _ := llm.ResolveSystemPrompt("coder", "")
+	if want := llm.HashPrompt(systemPrompt, "echo test"); promptHash != want {
+		t.Errorf("Expected prompt hash %s, got %q", want, promptHash)
+	}
+}
+
+func TestHandleRunCommand_FallsBackWhenRateLimited(t *testing.T) {
+	keyring.MockInit()
+	t.Cleanup(keyring.MockInit) // other tests expect no stored keys
+	security.SetAPIKey("OpenAI", "fallback-key")
+
+	db := setupTestDB(t)
+	defer db.Close()
+
+	res, _ := db.Exec("INSERT INTO command_cards (name, command, description) VALUES (?, ?, ?)", "Test Cmd", "echo test", "Desc")
+	id, _ := res.LastInsertId()
+
+	server := NewServer(db)
+	server.gateway.AnthropicClient = &MockLLMProvider{
+		SendFunc: func(systemPrompt, userPrompt, apiKey string) (string, int, int, error) {
+			return "", 0, 0, &llm.APIError{Provider: llm.ProviderAnthropic, StatusCode: http.StatusTooManyRequests, Message: "slow down"}
+		},
+	}
+	var fallbackKey string
+	server.gateway.OpenAIClient = &MockLLMProvider{
+		SendFunc: func(systemPrompt, userPrompt, apiKey string) (string, int, int, error) {
+			fallbackKey = apiKey
+			return "fallback answer", 10, 20, nil
+		},
+	}
+
+	body := bytes.NewBufferString(`{"agent_role": "Architect", "provider": "Anthropic", "fallback_models": ["OpenAI"]}`)
+	req, _ := http.NewRequest("POST", "/api/commands/"+strconv.Itoa(int(id))+"/run", body)
+	req.Header.Set("X-Forge-Api-Key", "primary-key")
+	rr := httptest.NewRecorder()
+	server.RegisterRoutes().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if fallbackKey != "fallback-key" {
+		t.Errorf("Expected the fallback to use the keyring key, got %q", fallbackKey)
+	}
+
+	rows, err := db.Query("SELECT model_used, status FROM token_ledger ORDER BY id")
+	if err != nil {
+		t.Fatalf("Failed to query ledger: %v", err)
+	}
+	defer rows.Close()
+	var logged []string
+	for rows.Next() {
+		var model, status string
+		rows.Scan(&model, &status)
+		logged = append(logged, model+" "+status)
+	}
+	if len(logged) != 2 || logged[0] != "Anthropic FAILED" || logged[1] != "OpenAI SUCCESS" {
+		t.Errorf("Expected a failed Anthropic call then a successful OpenAI one, got %v", logged)
+	}
+}
+
+func TestHandleRunCommand_UnknownFallback(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	res, _ := db.Exec("INSERT INTO command_cards (name, command, description) VALUES (?, ?, ?)", "Test Cmd", "echo test", "Desc")
+	id, _ := res.LastInsertId()
+
+	server := NewServer(db)
+	server.gateway.AnthropicClient = &MockLLMProvider{
+		SendFunc: func(systemPrompt, userPrompt, apiKey string) (string, int, int, error) {
+			t.Error("Expected no call with an unknown fallback")
+			return "", 0, 0, nil
+		},
+	}
+
+	// gpt-4 is a model, but not one the OpenAI client runs
+	body := bytes.NewBufferString(`{"agent_role": "Architect", "provider": "Anthropic", "fallback_models": ["gpt-4"]}`)
+	req, _ := http.NewRequest("POST", "/api/commands/"+strconv.Itoa(int(id))+"/run", body)
+	req.Header.Set("X-Forge-Api-Key", "primary-key")
+	rr := httptest.NewRecorder()
+	server.RegisterRoutes().ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "gpt-4") {
+		t.Errorf("Expected 400 naming the fallback, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestHandleRunCommand_MaxOutputTokens(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	res, _ := db.Exec("INSERT INTO command_cards (name, command, description) VALUES (?, ?, ?)", "Test Cmd", "echo test", "Desc")
+	id, _ := res.LastInsertId()
+
+	var maxTokens int
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			MaxTokens int `json:"max_tokens"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		maxTokens = body.MaxTokens
+		w.Write([]byte(`{"content": [{"text": "ok"}]}`))
+	}))
+	defer provider.Close()
+
+	server := NewServer(db)
+	server.gateway.AnthropicClient = &llm.AnthropicClient{Endpoint: provider.URL}
+	run := func(body string) int {
+		req, _ := http.NewRequest("POST", "/api/commands/"+strconv.Itoa(int(id))+"/run", bytes.NewBufferString(body))
+		req.Header.Set("X-Forge-Api-Key", "test-key")
+		rr := httptest.NewRecorder()
+		server.RegisterRoutes().ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if code := run(`{"agent_role": "Architect", "provider": "Anthropic", "max_output_tokens": 200}`); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if maxTokens != 200 {
+		t.Errorf("Expected max_tokens 200 in the request body, got %d", maxTokens)
+	}
+
+	if code := run(`{"agent_role": "Architect", "provider": "Anthropic", "max_output_tokens": -1}`); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a negative cap, got %d", code)
+	}
+}
+
 func TestHandleRunCommand_LatencyTracking(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
@@ -464,3 +636,375 @@ func TestHandleRunCommand_CommandNotFound(t *testing.T) {
 		t.Errorf("expected 404 for non-existent command, got %d", rr.Code)
 	}
 }
+
+func TestHandleRunCommand_WithAttachments(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	res, _ := db.Exec("INSERT INTO command_cards (name, command, description) VALUES (?, ?, ?)", "Review", "Review the attached file", "Desc")
+	id, _ := res.LastInsertId()
+
+	server := NewServer(db)
+	var gotPrompt string
+	server.gateway.OpenAIClient = &MockLLMProvider{
+		SendFunc: func(systemPrompt, userPrompt, apiKey string) (string, int, int, error) {
+			gotPrompt = userPrompt
+			return "Looks good", 5, 10, nil
+		},
+	}
+	handler := server.RegisterRoutes()
+
+	body, _ := json.Marshal(RunCommandRequest{
+		AgentRole: "Implementation",
+		Provider:  "OpenAI",
+		Attachments: []llm.Attachment{
+			{Filename: "main.go", Content: "func main() {}"},
+		},
+	})
+	req, _ := http.NewRequest("POST", "/api/commands/"+strconv.Itoa(int(id))+"/run", bytes.NewBuffer(body))
+	req.Header.Set("X-Forge-Api-Key", "test-key")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if !strings.HasPrefix(gotPrompt, "Review the attached file") {
+		t.Errorf("Expected command text first, got %q", gotPrompt)
+	}
+	if !strings.Contains(gotPrompt, `filename="main.go"`) || !strings.Contains(gotPrompt, "func main() {}") {
+		t.Errorf("Expected attachment in provider prompt, got %q", gotPrompt)
+	}
+}
+
+func TestHandleRunCommand_AttachmentsTooLarge(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	res, _ := db.Exec("INSERT INTO command_cards (name, command, description) VALUES (?, ?, ?)", "Review", "Review", "Desc")
+	id, _ := res.LastInsertId()
+
+	server := NewServer(db)
+	server.gateway.OpenAIClient = &MockLLMProvider{
+		SendFunc: func(systemPrompt, userPrompt, apiKey string) (string, int, int, error) {
+			t.Error("Provider should not be called with oversized attachments")
+			return "", 0, 0, nil
+		},
+	}
+
+	body, _ := json.Marshal(RunCommandRequest{
+		AgentRole:   "Implementation",
+		Provider:    "OpenAI",
+		Attachments: []llm.Attachment{{Filename: "big.log", Content: strings.Repeat("x", llm.MaxAttachmentBytes+1)}},
+	})
+	req, _ := http.NewRequest("POST", "/api/commands/"+strconv.Itoa(int(id))+"/run", bytes.NewBuffer(body))
+	req.Header.Set("X-Forge-Api-Key", "test-key")
+	rr := httptest.NewRecorder()
+	server.RegisterRoutes().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413, got %d", rr.Code)
+	}
+}
+
+func TestHandleRunCommand_DailyTokenLimit(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	cfg := config.DefaultConfig()
+	cfg.Budget.DailyTokenLimit = 100
+	if err := config.Save(cfg); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	defer config.Save(config.DefaultConfig())
+
+	db := setupTestDB(t)
+	defer db.Close()
+
+	// Seed usage right at the limit
+	if _, err := db.Exec(`INSERT INTO token_ledger (flow_id, model_used, agent_role, prompt_hash, input_tokens, output_tokens, total_cost_usd, latency_ms, status)
+		VALUES ('seed', 'OpenAI', 'Implementation', 'h', 60, 40, 0.001, 10, 'SUCCESS')`); err != nil {
+		t.Fatalf("Failed to seed ledger: %v", err)
+	}
+	res, _ := db.Exec("INSERT INTO command_cards (name, command, description) VALUES (?, ?, ?)", "Cmd", "echo", "Desc")
+	id, _ := res.LastInsertId()
+
+	server := NewServer(db)
+	server.gateway.OpenAIClient = &MockLLMProvider{
+		SendFunc: func(systemPrompt, userPrompt, apiKey string) (string, int, int, error) {
+			t.Error("Provider should not be called once the token limit is reached")
+			return "", 0, 0, nil
+		},
+	}
+
+	body, _ := json.Marshal(RunCommandRequest{AgentRole: "Implementation", Provider: "OpenAI"})
+	req, _ := http.NewRequest("POST", "/api/commands/"+strconv.Itoa(int(id))+"/run", bytes.NewBuffer(body))
+	req.Header.Set("X-Forge-Api-Key", "test-key")
+	rr := httptest.NewRecorder()
+	server.RegisterRoutes().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected status 429, got %d", rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), budget.CodeTokenLimitExceeded) {
+		t.Errorf("Expected %s in response, got %q", budget.CodeTokenLimitExceeded, rr.Body.String())
+	}
+}
+
+func TestHandleRunCommand_CostCap(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	res, _ := db.Exec("INSERT INTO command_cards (name, command, description, max_cost_usd) VALUES (?, ?, ?, ?)", "Cmd", "echo", "Desc", 0.05)
+	id, _ := res.LastInsertId()
+
+	calls := 0
+	server := NewServer(db)
+	server.gateway.OpenAIClient = &MockLLMProvider{
+		SendFunc: func(systemPrompt, userPrompt, apiKey string) (string, int, int, error) {
+			calls++
+			return "ok", 10, 10, nil
+		},
+	}
+
+	run := func() *httptest.ResponseRecorder {
+		body, _ := json.Marshal(RunCommandRequest{AgentRole: "Implementation", Provider: "OpenAI"})
+		req, _ := http.NewRequest("POST", "/api/commands/"+strconv.Itoa(int(id))+"/run", bytes.NewBuffer(body))
+		req.Header.Set("X-Forge-Api-Key", "test-key")
+		rr := httptest.NewRecorder()
+		server.RegisterRoutes().ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Under the cap the card runs normally
+	if rr := run(); rr.Code != http.StatusOK {
+		t.Fatalf("Expected first run to succeed, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// Earlier runs of this card bring its spend up to the cap; other cards' spend doesn't count
+	if _, err := db.Exec(`INSERT INTO token_ledger (flow_id, model_used, agent_role, prompt_hash, input_tokens, output_tokens, total_cost_usd, latency_ms, status)
+		VALUES (?, 'OpenAI', 'Implementation', 'h', 1, 1, 0.05, 10, 'SUCCESS'), ('cmd-999', 'OpenAI', 'Implementation', 'h', 1, 1, 5, 10, 'SUCCESS')`,
+		"cmd-"+strconv.Itoa(int(id))); err != nil {
+		t.Fatalf("Failed to seed ledger: %v", err)
+	}
+
+	rr := run()
+	if rr.Code != http.StatusPaymentRequired {
+		t.Errorf("Expected status 402 once the cap is reached, got %d", rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), budget.CodeBudgetExceeded) {
+		t.Errorf("Expected %s in response, got %q", budget.CodeBudgetExceeded, rr.Body.String())
+	}
+	if calls != 1 {
+		t.Errorf("Expected the provider to be called once, got %d", calls)
+	}
+}
+
+func TestHandleRunCommand_ConcurrencyCap(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	cfg := config.DefaultConfig()
+	cfg.Server.MaxConcurrentCommandRuns = 2
+	if err := config.Save(cfg); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	defer config.Save(config.DefaultConfig())
+
+	db := setupTestDB(t)
+	defer db.Close()
+	// Concurrent requests must share the one in-memory database
+	db.SetMaxOpenConns(1)
+
+	res, _ := db.Exec("INSERT INTO command_cards (name, command, description) VALUES (?, ?, ?)", "Cmd", "echo", "Desc")
+	id, _ := res.LastInsertId()
+
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	server := NewServer(db)
+	server.gateway.OpenAIClient = &MockLLMProvider{
+		SendFunc: func(systemPrompt, userPrompt, apiKey string) (string, int, int, error) {
+			started <- struct{}{}
+			<-release
+			return "done", 1, 1, nil
+		},
+	}
+	handler := server.RegisterRoutes()
+
+	run := func() *httptest.ResponseRecorder {
+		body, _ := json.Marshal(RunCommandRequest{AgentRole: "Implementation", Provider: "OpenAI"})
+		req, _ := http.NewRequest("POST", "/api/commands/"+strconv.Itoa(int(id))+"/run", bytes.NewBuffer(body))
+		req.Header.Set("X-Forge-Api-Key", "test-key")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Fill both slots with runs that block inside the provider
+	results := make(chan int, 2)
+	for i := 0; i < 2; i++ {
+		go func() { results <- run().Code }()
+	}
+	<-started
+	<-started
+
+	rr := run()
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected status 429 beyond the cap, got %d", rr.Code)
+	}
+	if rr.Header().Get("Retry-After") == "" {
+		t.Error("Expected a Retry-After header on the rejected run")
+	}
+
+	close(release)
+	for i := 0; i < 2; i++ {
+		if code := <-results; code != http.StatusOK {
+			t.Errorf("Expected in-flight runs to succeed, got %d", code)
+		}
+	}
+
+	// Slots are freed once the runs finish
+	if rr := run(); rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200 after slots were released, got %d", rr.Code)
+	}
+}
+
+func TestHandleRunCommand_UpstreamErrorStatuses(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		wantStatus     int
+		wantRetryAfter string
+	}{
+		{"bad key", &llm.APIError{Provider: llm.ProviderOpenAI, StatusCode: 401, Message: "Incorrect API key"}, http.StatusUnauthorized, ""},
+		{"rate limited", &llm.APIError{Provider: llm.ProviderOpenAI, StatusCode: 429, Message: "slow down", RetryAfter: 20 * time.Second}, http.StatusTooManyRequests, "20"},
+		{"rate limited without hint", &llm.APIError{Provider: llm.ProviderOpenAI, StatusCode: 429}, http.StatusTooManyRequests, strconv.Itoa(defaultRetryAfterSeconds)},
+		{"timeout", fmt.Errorf("failed to send request: %w", context.DeadlineExceeded), http.StatusGatewayTimeout, ""},
+		{"provider outage", &llm.APIError{Provider: llm.ProviderOpenAI, StatusCode: 503, Message: "overloaded"}, http.StatusBadGateway, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := setupTestDB(t)
+			defer db.Close()
+
+			res, _ := db.Exec("INSERT INTO command_cards (name, command, description) VALUES (?, ?, ?)", "Cmd", "echo", "Desc")
+			id, _ := res.LastInsertId()
+
+			server := NewServer(db)
+			server.gateway.OpenAIClient = &MockLLMProvider{
+				SendFunc: func(systemPrompt, userPrompt, apiKey string) (string, int, int, error) {
+					return "", 0, 0, tt.err
+				},
+			}
+
+			body, _ := json.Marshal(RunCommandRequest{AgentRole: "Implementation", Provider: "OpenAI"})
+			req, _ := http.NewRequest("POST", "/api/commands/"+strconv.Itoa(int(id))+"/run", bytes.NewBuffer(body))
+			req.Header.Set("X-Forge-Api-Key", "test-key")
+			rr := httptest.NewRecorder()
+			server.RegisterRoutes().ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, rr.Code)
+			}
+			if got := rr.Header().Get("Retry-After"); got != tt.wantRetryAfter {
+				t.Errorf("Expected Retry-After %q, got %q", tt.wantRetryAfter, got)
+			}
+		})
+	}
+}
+
+func TestHandleRunCommand_InputTokenCapLogsCapped(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	cfg := config.DefaultConfig()
+	cfg.Budget.MaxInputTokens = 500
+	if err := config.Save(cfg); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	defer config.Save(config.DefaultConfig())
+
+	db := setupTestDB(t)
+	defer db.Close()
+
+	res, _ := db.Exec("INSERT INTO command_cards (name, command, description) VALUES (?, ?, ?)", "Huge", strings.Repeat("lorem ipsum dolor ", 1000), "Desc")
+	id, _ := res.LastInsertId()
+
+	server := NewServer(db)
+	server.gateway.AnthropicClient = &MockLLMProvider{
+		SendFunc: func(systemPrompt, userPrompt, apiKey string) (string, int, int, error) {
+			t.Error("Provider should not be called for an over-cap prompt")
+			return "", 0, 0, nil
+		},
+	}
+
+	body, _ := json.Marshal(RunCommandRequest{AgentRole: "Implementation", Provider: "Anthropic"})
+	req, _ := http.NewRequest("POST", "/api/commands/"+strconv.Itoa(int(id))+"/run", bytes.NewBuffer(body))
+	req.Header.Set("X-Forge-Api-Key", "test-key")
+	rr := httptest.NewRecorder()
+	server.RegisterRoutes().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413, got %d", rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), "input token cap") {
+		t.Errorf("Expected a clear cap error, got %q", rr.Body.String())
+	}
+
+	var status string
+	if err := db.QueryRow("SELECT status FROM token_ledger WHERE flow_id = ?", "cmd-"+strconv.Itoa(int(id))).Scan(&status); err != nil {
+		t.Fatalf("Expected a ledger entry: %v", err)
+	}
+	if status != "CAPPED" {
+		t.Errorf("Expected ledger status CAPPED, got %s", status)
+	}
+}
+
+func TestHandleRunCommand_RequestIDCorrelation(t *testing.T) {
+	keyring.MockInit()
+	t.Cleanup(keyring.MockInit)
+	security.SetAPIKey("OpenAI", "fallback-key")
+
+	db := setupTestDB(t)
+	defer db.Close()
+
+	res, _ := db.Exec("INSERT INTO command_cards (name, command, description) VALUES (?, ?, ?)", "Test Cmd", "echo test", "Desc")
+	id, _ := res.LastInsertId()
+
+	// Anthropic is rate limited, so each run logs two calls: the 429 and the OpenAI fallback
+	server := NewServer(db)
+	server.gateway.AnthropicClient = &MockLLMProvider{
+		SendFunc: func(systemPrompt, userPrompt, apiKey string) (string, int, int, error) {
+			return "", 0, 0, &llm.APIError{Provider: llm.ProviderAnthropic, StatusCode: http.StatusTooManyRequests}
+		},
+	}
+	server.gateway.OpenAIClient = &MockLLMProvider{}
+	handler := server.RegisterRoutes()
+
+	run := func() string {
+		body := bytes.NewBufferString(`{"agent_role": "Architect", "provider": "Anthropic", "fallback_models": ["OpenAI"]}`)
+		req, _ := http.NewRequest("POST", "/api/commands/"+strconv.Itoa(int(id))+"/run", body)
+		req.Header.Set("X-Forge-Api-Key", "primary-key")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		return rr.Header().Get("X-Request-Id")
+	}
+	first, second := run(), run()
+	if first == "" || first == second {
+		t.Fatalf("Expected a distinct request ID per run, got %q and %q", first, second)
+	}
+
+	req, _ := http.NewRequest("GET", "/api/ledger?request_id="+first, nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	var entries []LedgerEntryResponse
+	if err := json.NewDecoder(rr.Body).Decode(&entries); err != nil {
+		t.Fatalf("Failed to decode ledger: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected both calls of the first run, got %d entries", len(entries))
+	}
+	for _, e := range entries {
+		if e.RequestID != first {
+			t.Errorf("Expected request_id %s, got %q", first, e.RequestID)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/agents"
+)
+
+// listCustomAgents returns every custom role, ordered by name.
+func (s *Server) listCustomAgents() ([]agents.CustomRole, error) {
+	rows, err := s.db.Query("SELECT id, name, system_prompt FROM custom_agents ORDER BY name COLLATE NOCASE")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []agents.CustomRole{}
+	for rows.Next() {
+		var role agents.CustomRole
+		if err := rows.Scan(&role.ID, &role.Name, &role.SystemPrompt); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+// handleGetCustomAgents returns all user-defined roles.
+func (s *Server) handleGetCustomAgents(w http.ResponseWriter, r *http.Request) {
+	roles, err := s.listCustomAgents()
+	if err != nil {
+		http.Error(w, "Failed to query custom agents: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(roles)
+}
+
+// validateCustomAgent checks a role's name and prompt, writing the error response if they're unusable.
+// id is the role being updated, or 0 for a new one, so a role can keep its own name.
+func (s *Server) validateCustomAgent(w http.ResponseWriter, role *agents.CustomRole, id int64) bool {
+	role.Name = strings.TrimSpace(role.Name)
+	if role.Name == "" || strings.TrimSpace(role.SystemPrompt) == "" {
+		http.Error(w, "Name and system_prompt are required", http.StatusBadRequest)
+		return false
+	}
+	if agents.IsBuiltinRole(role.Name) {
+		http.Error(w, "Name is already used by a built-in role or alias: "+role.Name, http.StatusConflict)
+		return false
+	}
+
+	var existing int64
+	err := s.db.QueryRow("SELECT id FROM custom_agents WHERE name = ? COLLATE NOCASE AND id != ?", role.Name, id).Scan(&existing)
+	if err == nil {
+		http.Error(w, "A custom agent with this name already exists: "+role.Name, http.StatusConflict)
+		return false
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Failed to check custom agents: "+err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+// handleCreateCustomAgent adds a user-defined role.
+// Educational Comment: Once saved, the role can be used anywhere a built-in role can,
+// such as a flow node's role or a command's agent_role, because GetAgentPrompt falls back to this table.
+func (s *Server) handleCreateCustomAgent(w http.ResponseWriter, r *http.Request) {
+	var role agents.CustomRole
+	if err := json.NewDecoder(r.Body).Decode(&role); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !s.validateCustomAgent(w, &role, 0) {
+		return
+	}
+
+	res, err := s.db.Exec("INSERT INTO custom_agents (name, system_prompt) VALUES (?, ?)", role.Name, role.SystemPrompt)
+	if err != nil {
+		http.Error(w, "Failed to insert custom agent: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	role.ID, _ = res.LastInsertId()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(role)
+}
+
+// handleUpdateCustomAgent replaces a user-defined role's name and prompt.
+func (s *Server) handleUpdateCustomAgent(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var role agents.CustomRole
+	if err := json.NewDecoder(r.Body).Decode(&role); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !s.validateCustomAgent(w, &role, id) {
+		return
+	}
+
+	res, err := s.db.Exec("UPDATE custom_agents SET name = ?, system_prompt = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		role.Name, role.SystemPrompt, id)
+	if err != nil {
+		http.Error(w, "Failed to update custom agent: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "Custom agent not found", http.StatusNotFound)
+		return
+	}
+	role.ID = id
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(role)
+}
+
+// handleDeleteCustomAgent removes a user-defined role. Flows that still use it fail with an unknown role error.
+func (s *Server) handleDeleteCustomAgent(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	res, err := s.db.Exec("DELETE FROM custom_agents WHERE id = ?", id)
+	if err != nil {
+		http.Error(w, "Failed to delete custom agent: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "Custom agent not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/agents"
+)
+
+func customAgentRequest(t *testing.T, srv *Server, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	srv.RegisterRoutes().ServeHTTP(rr, httptest.NewRequest(method, path, bytes.NewBufferString(body)))
+	return rr
+}
+
+func TestCustomAgentsCRUD(t *testing.T) {
+	srv := setupFlowTestServer(t)
+	t.Cleanup(func() { agents.UseCustomRoles(nil) })
+
+	rr := customAgentRequest(t, srv, "POST", "/api/agents/custom", `{"name":"Reviewer","system_prompt":"You review pull requests."}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var created agents.CustomRole
+	if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to decode created agent: %v", err)
+	}
+	if created.ID == 0 || created.Name != "Reviewer" {
+		t.Errorf("Unexpected created agent: %+v", created)
+	}
+
+	// The new role resolves through the same lookup the gateway uses
+	rr = customAgentRequest(t, srv, "GET", "/api/agents/reviewer/prompt", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected the custom role's prompt, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var prompt AgentPromptResponse
+	json.Unmarshal(rr.Body.Bytes(), &prompt)
+	if prompt.ResolvedRole != "Reviewer" || prompt.Prompt != "You review pull requests." {
+		t.Errorf("Unexpected prompt response: %+v", prompt)
+	}
+
+	// It is listed alongside the built-ins
+	rr = customAgentRequest(t, srv, "GET", "/api/agents", "")
+	var list AgentRolesResponse
+	json.Unmarshal(rr.Body.Bytes(), &list)
+	last := list.Roles[len(list.Roles)-1]
+	if last.Name != "Reviewer" || !last.Custom {
+		t.Errorf("Expected Reviewer listed as a custom role, got %+v", list.Roles)
+	}
+
+	rr = customAgentRequest(t, srv, "PUT", "/api/agents/custom/1", `{"name":"Reviewer","system_prompt":"Be brief."}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200 on update, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if got, _ := agents.GetAgentPrompt("Reviewer"); got != "Be brief." {
+		t.Errorf("Expected the updated prompt, got %q", got)
+	}
+
+	rr = customAgentRequest(t, srv, "DELETE", "/api/agents/custom/1", "")
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("Expected 204 on delete, got %d", rr.Code)
+	}
+	if _, err := agents.GetAgentPrompt("Reviewer"); err == nil {
+		t.Error("Expected a deleted role to be unknown")
+	}
+	if rr := customAgentRequest(t, srv, "DELETE", "/api/agents/custom/1", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 deleting a missing role, got %d", rr.Code)
+	}
+}
+
+func TestCreateCustomAgent_Validation(t *testing.T) {
+	srv := setupFlowTestServer(t)
+	t.Cleanup(func() { agents.UseCustomRoles(nil) })
+
+	if rr := customAgentRequest(t, srv, "POST", "/api/agents/custom", `{"name":"Reviewer","system_prompt":"x"}`); rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", rr.Code)
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"missing prompt", `{"name":"Writer"}`, http.StatusBadRequest},
+		{"missing name", `{"system_prompt":"x"}`, http.StatusBadRequest},
+		{"built-in role", `{"name":"architect","system_prompt":"x"}`, http.StatusConflict},
+		{"built-in alias", `{"name":"QA","system_prompt":"x"}`, http.StatusConflict},
+		{"duplicate", `{"name":"reviewer","system_prompt":"x"}`, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rr := customAgentRequest(t, srv, "POST", "/api/agents/custom", tt.body); rr.Code != tt.want {
+				t.Errorf("Expected %d, got %d: %s", tt.want, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
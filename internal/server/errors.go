@@ -0,0 +1,21 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// JSONErrorResponse is the body of an API error, so the frontend can always parse a
+// failed response the same way it parses a successful one.
+type JSONErrorResponse struct {
+	Error string `json:"error"`
+	Code  int    `json:"code"` // the HTTP status, repeated for clients that only see the body
+}
+
+// writeJSONError is http.Error with a JSON body.
+func writeJSONError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(JSONErrorResponse{Error: message, Code: code})
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestErrorResponsesAreJSON(t *testing.T) {
+	srv := setupFlowTestServer(t)
+
+	tests := []struct {
+		name, method, path, body string
+		want                     int
+	}{
+		{"invalid command ID", "DELETE", "/api/commands/abc", "", http.StatusBadRequest},
+		{"invalid command body", "POST", "/api/commands", "{", http.StatusBadRequest},
+		{"command run without a role", "POST", "/api/commands/1/run", `{}`, http.StatusBadRequest},
+		{"invalid ledger body", "POST", "/api/ledger", "{", http.StatusBadRequest},
+		{"unknown ledger entry replay", "POST", "/api/ledger/9999/replay", "", http.StatusNotFound},
+		{"invalid optimization ID", "POST", "/api/ledger/optimizations/abc/apply", "", http.StatusBadRequest},
+		{"unsupported report format", "GET", "/api/ledger/optimizations/report?format=pdf", "", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			srv.RegisterRoutes().ServeHTTP(rr, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+			if rr.Code != tt.want {
+				t.Fatalf("Expected status %d, got %d: %s", tt.want, rr.Code, rr.Body.String())
+			}
+			if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Expected Content-Type application/json, got %q", ct)
+			}
+
+			var resp JSONErrorResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Expected a JSON error body, got %q: %v", rr.Body.String(), err)
+			}
+			if resp.Error == "" || resp.Code != tt.want {
+				t.Errorf("Expected an error message and code %d, got %+v", tt.want, resp)
+			}
+		})
+	}
+}
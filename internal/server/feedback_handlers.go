@@ -0,0 +1,228 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/config"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/logging"
+)
+
+// maxScreenshotBytes is the largest decoded screenshot we accept (5 MB).
+const maxScreenshotBytes = 5 << 20
+
+// Error codes returned by the feedback upload endpoint.
+// The frontend switches on these instead of parsing the message text.
+const (
+	FeedbackErrInvalidBody     = "INVALID_BODY"
+	FeedbackErrMissingImage    = "MISSING_IMAGE"
+	FeedbackErrInvalidEncoding = "INVALID_ENCODING"
+	FeedbackErrTooLarge        = "PAYLOAD_TOO_LARGE"
+	FeedbackErrUnsupportedType = "UNSUPPORTED_TYPE"
+	FeedbackErrInvalidImage    = "INVALID_IMAGE"
+	FeedbackErrWriteFailed     = "WRITE_FAILED"
+	FeedbackErrNotFound        = "NOT_FOUND"
+)
+
+// allowedScreenshotTypes maps sniffed MIME types to the file extension we store.
+var allowedScreenshotTypes = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+}
+
+// ScreenshotUploadRequest is the JSON payload for uploading a feedback screenshot.
+// Image may be a data URL ("data:image/png;base64,...") or bare base64.
+type ScreenshotUploadRequest struct {
+	Image string `json:"image"`
+}
+
+// ScreenshotUploadResponse is returned after a screenshot is stored.
+// URL points at the original; CompressedURL and ThumbnailURL at the re-encoded copies.
+type ScreenshotUploadResponse struct {
+	Filename      string `json:"filename"`
+	URL           string `json:"url"`
+	CompressedURL string `json:"compressedUrl"`
+	ThumbnailURL  string `json:"thumbnailUrl"`
+}
+
+// FeedbackErrorResponse is the body written for every failed feedback request.
+// Both fields are always populated so the client never sees an empty object.
+type FeedbackErrorResponse struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+// feedbackDir overrides the screenshot storage directory (used by tests).
+var feedbackDir string
+
+// SetFeedbackDir allows replacing the screenshot storage directory (useful for testing).
+func SetFeedbackDir(dir string) {
+	feedbackDir = dir
+}
+
+// getFeedbackDir returns the directory screenshots are written to.
+func getFeedbackDir() (string, error) {
+	if feedbackDir != "" {
+		return feedbackDir, nil
+	}
+	dataDir, err := config.GetDataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dataDir, "feedback-screenshots"), nil
+}
+
+// writeFeedbackError writes a structured JSON error with the given status.
+func writeFeedbackError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(FeedbackErrorResponse{
+		Error: message,
+		Code:  code,
+	})
+}
+
+// decodeScreenshot strips an optional data URL prefix and decodes the base64 payload.
+func decodeScreenshot(image string) ([]byte, error) {
+	if strings.HasPrefix(image, "data:") {
+		comma := strings.Index(image, ",")
+		if comma == -1 {
+			return nil, errors.New("malformed data URL")
+		}
+		image = image[comma+1:]
+	}
+	return base64.StdEncoding.DecodeString(image)
+}
+
+// handleUploadScreenshot stores a feedback screenshot on disk, alongside a
+// size-capped JPEG copy and a thumbnail for the feedback modal.
+// Every failure path responds with a FeedbackErrorResponse and a matching status code.
+func (s *Server) handleUploadScreenshot(w http.ResponseWriter, r *http.Request) {
+	// Base64 inflates the payload by ~4/3, so leave room for that plus the JSON wrapper.
+	r.Body = http.MaxBytesReader(w, r.Body, maxScreenshotBytes*4/3+1024)
+
+	var req ScreenshotUploadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			writeFeedbackError(w, http.StatusRequestEntityTooLarge, FeedbackErrTooLarge,
+				fmt.Sprintf("Screenshot exceeds the %d byte limit", maxScreenshotBytes))
+			return
+		}
+		writeFeedbackError(w, http.StatusBadRequest, FeedbackErrInvalidBody, "Invalid request body: "+err.Error())
+		return
+	}
+
+	if req.Image == "" {
+		writeFeedbackError(w, http.StatusBadRequest, FeedbackErrMissingImage, "Image is required")
+		return
+	}
+
+	imageData, err := decodeScreenshot(req.Image)
+	if err != nil {
+		writeFeedbackError(w, http.StatusBadRequest, FeedbackErrInvalidEncoding, "Image is not valid base64: "+err.Error())
+		return
+	}
+
+	if len(imageData) > maxScreenshotBytes {
+		writeFeedbackError(w, http.StatusRequestEntityTooLarge, FeedbackErrTooLarge,
+			fmt.Sprintf("Screenshot exceeds the %d byte limit", maxScreenshotBytes))
+		return
+	}
+
+	// Sniff the real content type rather than trusting the data URL prefix.
+	contentType := http.DetectContentType(imageData)
+	ext, ok := allowedScreenshotTypes[contentType]
+	if !ok {
+		writeFeedbackError(w, http.StatusUnsupportedMediaType, FeedbackErrUnsupportedType,
+			"Unsupported image type: "+contentType+" (expected PNG or JPEG)")
+		return
+	}
+
+	// Decode and re-encode before touching disk so non-images never get stored.
+	processed, err := processScreenshot(imageData)
+	if errors.Is(err, errScreenshotTooManyPixels) {
+		writeFeedbackError(w, http.StatusRequestEntityTooLarge, FeedbackErrTooLarge, "Screenshot is too large: "+err.Error())
+		return
+	}
+	if err != nil {
+		writeFeedbackError(w, http.StatusBadRequest, FeedbackErrInvalidImage, "Image could not be decoded: "+err.Error())
+		return
+	}
+
+	dir, err := getFeedbackDir()
+	if err != nil {
+		logging.Errorf("Feedback: failed to resolve screenshot directory: %v", err)
+		writeFeedbackError(w, http.StatusInternalServerError, FeedbackErrWriteFailed, "Failed to resolve screenshot directory: "+err.Error())
+		return
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		logging.Errorf("Feedback: failed to create screenshot directory: %v", err)
+		writeFeedbackError(w, http.StatusInternalServerError, FeedbackErrWriteFailed, "Failed to create screenshot directory: "+err.Error())
+		return
+	}
+
+	// The random suffix keeps two uploads in the same millisecond from overwriting each other
+	base := "feedback-" + time.Now().UTC().Format("2006-01-02T15-04-05.000Z") + "-" + uuid.NewString()[:8]
+	filename := base + ext
+	compressedName := base + "-compressed.jpg"
+	thumbnailName := base + "-thumb.jpg"
+
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{filename, imageData},
+		{compressedName, processed.Compressed},
+		{thumbnailName, processed.Thumbnail},
+	}
+	for _, f := range files {
+		if err := os.WriteFile(filepath.Join(dir, f.name), f.data, 0644); err != nil {
+			logging.Errorf("Feedback: failed to write %s: %v", f.name, err)
+			writeFeedbackError(w, http.StatusInternalServerError, FeedbackErrWriteFailed, "Failed to save screenshot: "+err.Error())
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(ScreenshotUploadResponse{
+		Filename:      filename,
+		URL:           "/api/feedback/screenshots/" + filename,
+		CompressedURL: "/api/feedback/screenshots/" + compressedName,
+		ThumbnailURL:  "/api/feedback/screenshots/" + thumbnailName,
+	})
+}
+
+// handleGetScreenshot serves a previously uploaded screenshot by filename.
+func (s *Server) handleGetScreenshot(w http.ResponseWriter, r *http.Request) {
+	// filepath.Base prevents escaping the screenshot directory via "../".
+	name := filepath.Base(r.PathValue("name"))
+	if name == "" || name == "." || name == "/" {
+		writeFeedbackError(w, http.StatusBadRequest, FeedbackErrInvalidBody, "Screenshot name is required")
+		return
+	}
+
+	dir, err := getFeedbackDir()
+	if err != nil {
+		writeFeedbackError(w, http.StatusInternalServerError, FeedbackErrWriteFailed, "Failed to resolve screenshot directory: "+err.Error())
+		return
+	}
+
+	path := filepath.Join(dir, name)
+	if _, err := os.Stat(path); err != nil {
+		writeFeedbackError(w, http.StatusNotFound, FeedbackErrNotFound, "Screenshot not found")
+		return
+	}
+
+	http.ServeFile(w, r, path)
+}
@@ -0,0 +1,245 @@
+package server
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// buildTestPNG renders a gradient PNG with light noise, which (like a real
+// screenshot) PNG cannot compress as well as JPEG does.
+func buildTestPNG(t *testing.T, width, height int) []byte {
+	rng := rand.New(rand.NewSource(42))
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			noise := uint8(rng.Intn(16))
+			img.Set(x, y, color.RGBA{uint8(x/8) + noise, uint8(y/8) + noise, 128 + noise, 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("Failed to encode test PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// encodeTestPNG builds a PNG and returns it as a data URL.
+func encodeTestPNG(t *testing.T, width, height int) string {
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buildTestPNG(t, width, height))
+}
+
+// postScreenshot sends an upload request through the router.
+func postScreenshot(t *testing.T, body []byte) *httptest.ResponseRecorder {
+	s := &Server{}
+	handler := s.RegisterRoutes()
+	req := httptest.NewRequest("POST", "/api/feedback/screenshots", bytes.NewBuffer(body))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+// assertFeedbackError checks that a response carries a populated error body.
+func assertFeedbackError(t *testing.T, rr *httptest.ResponseRecorder, wantStatus int, wantCode string) {
+	t.Helper()
+	if rr.Code != wantStatus {
+		t.Errorf("Expected status %d, got %d", wantStatus, rr.Code)
+	}
+	var resp FeedbackErrorResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode error body %q: %v", rr.Body.String(), err)
+	}
+	if resp.Error == "" {
+		t.Error("Expected non-empty error message")
+	}
+	if resp.Code != wantCode {
+		t.Errorf("Expected code %s, got %s", wantCode, resp.Code)
+	}
+}
+
+func TestHandleUploadScreenshot_Success(t *testing.T) {
+	dir := t.TempDir()
+	SetFeedbackDir(dir)
+	defer SetFeedbackDir("")
+
+	body, _ := json.Marshal(ScreenshotUploadRequest{Image: encodeTestPNG(t, 16, 16)})
+	rr := postScreenshot(t, body)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var resp ScreenshotUploadResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !strings.HasSuffix(resp.Filename, ".png") {
+		t.Errorf("Expected .png filename, got %s", resp.Filename)
+	}
+	if _, err := os.Stat(filepath.Join(dir, resp.Filename)); err != nil {
+		t.Errorf("Expected screenshot to be written: %v", err)
+	}
+	if resp.CompressedURL == "" || resp.ThumbnailURL == "" {
+		t.Errorf("Expected compressed and thumbnail URLs, got %+v", resp)
+	}
+}
+
+func TestHandleUploadScreenshot_CompressesLargePNG(t *testing.T) {
+	dir := t.TempDir()
+	SetFeedbackDir(dir)
+	defer SetFeedbackDir("")
+
+	original := buildTestPNG(t, 1800, 1200)
+	body, _ := json.Marshal(ScreenshotUploadRequest{
+		Image: "data:image/png;base64," + base64.StdEncoding.EncodeToString(original),
+	})
+	rr := postScreenshot(t, body)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var resp ScreenshotUploadResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	compressed, err := os.ReadFile(filepath.Join(dir, filepath.Base(resp.CompressedURL)))
+	if err != nil {
+		t.Fatalf("Expected compressed file: %v", err)
+	}
+	if len(compressed) >= len(original) {
+		t.Errorf("Expected compressed (%d bytes) to be smaller than original (%d bytes)", len(compressed), len(original))
+	}
+
+	compressedImg, format, err := image.DecodeConfig(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatalf("Compressed output is not a valid image: %v", err)
+	}
+	if format != "jpeg" {
+		t.Errorf("Expected compressed output to be jpeg, got %s", format)
+	}
+	if compressedImg.Width != maxCompressedDimension {
+		t.Errorf("Expected compressed width %d, got %d", maxCompressedDimension, compressedImg.Width)
+	}
+
+	thumb, err := os.ReadFile(filepath.Join(dir, filepath.Base(resp.ThumbnailURL)))
+	if err != nil {
+		t.Fatalf("Expected thumbnail file: %v", err)
+	}
+	thumbImg, _, err := image.DecodeConfig(bytes.NewReader(thumb))
+	if err != nil {
+		t.Fatalf("Thumbnail is not a valid image: %v", err)
+	}
+	if thumbImg.Width != thumbnailDimension || thumbImg.Height != thumbnailDimension*1200/1800 {
+		t.Errorf("Unexpected thumbnail size %dx%d", thumbImg.Width, thumbImg.Height)
+	}
+}
+
+func TestHandleUploadScreenshot_Errors(t *testing.T) {
+	SetFeedbackDir(t.TempDir())
+	defer SetFeedbackDir("")
+
+	t.Run("invalid JSON", func(t *testing.T) {
+		rr := postScreenshot(t, []byte("{not json"))
+		assertFeedbackError(t, rr, http.StatusBadRequest, FeedbackErrInvalidBody)
+	})
+
+	t.Run("missing image", func(t *testing.T) {
+		rr := postScreenshot(t, []byte(`{}`))
+		assertFeedbackError(t, rr, http.StatusBadRequest, FeedbackErrMissingImage)
+	})
+
+	t.Run("invalid base64", func(t *testing.T) {
+		rr := postScreenshot(t, []byte(`{"image":"data:image/png;base64,!!!"}`))
+		assertFeedbackError(t, rr, http.StatusBadRequest, FeedbackErrInvalidEncoding)
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		oversized := make([]byte, maxScreenshotBytes+1)
+		body, _ := json.Marshal(ScreenshotUploadRequest{Image: base64.StdEncoding.EncodeToString(oversized)})
+		rr := postScreenshot(t, body)
+		assertFeedbackError(t, rr, http.StatusRequestEntityTooLarge, FeedbackErrTooLarge)
+	})
+
+	t.Run("corrupt image", func(t *testing.T) {
+		// A PNG signature followed by garbage sniffs as PNG but cannot be decoded.
+		corrupt := append([]byte("\x89PNG\r\n\x1a\n"), []byte("garbage data")...)
+		body, _ := json.Marshal(ScreenshotUploadRequest{Image: base64.StdEncoding.EncodeToString(corrupt)})
+		rr := postScreenshot(t, body)
+		assertFeedbackError(t, rr, http.StatusBadRequest, FeedbackErrInvalidImage)
+	})
+
+	t.Run("too many pixels", func(t *testing.T) {
+		// A tiny PNG whose header claims 100000x100000 pixels: about 40 GB once decoded
+		huge := buildTestPNG(t, 1, 1)
+		binary.BigEndian.PutUint32(huge[16:], 100_000)
+		binary.BigEndian.PutUint32(huge[20:], 100_000)
+		binary.BigEndian.PutUint32(huge[29:], crc32.ChecksumIEEE(huge[12:29])) // IHDR checksum
+		body, _ := json.Marshal(ScreenshotUploadRequest{Image: base64.StdEncoding.EncodeToString(huge)})
+		rr := postScreenshot(t, body)
+		assertFeedbackError(t, rr, http.StatusRequestEntityTooLarge, FeedbackErrTooLarge)
+	})
+
+	t.Run("bad type", func(t *testing.T) {
+		text := base64.StdEncoding.EncodeToString([]byte("just some plain text, not an image"))
+		body, _ := json.Marshal(ScreenshotUploadRequest{Image: text})
+		rr := postScreenshot(t, body)
+		assertFeedbackError(t, rr, http.StatusUnsupportedMediaType, FeedbackErrUnsupportedType)
+	})
+}
+
+func TestHandleUploadScreenshot_UniqueNames(t *testing.T) {
+	SetFeedbackDir(t.TempDir())
+	defer SetFeedbackDir("")
+
+	body, _ := json.Marshal(ScreenshotUploadRequest{Image: encodeTestPNG(t, 4, 4)})
+	seen := map[string]bool{}
+	for range 5 {
+		rr := postScreenshot(t, body)
+		var resp ScreenshotUploadResponse
+		json.Unmarshal(rr.Body.Bytes(), &resp)
+		if resp.Filename == "" || seen[resp.Filename] {
+			t.Fatalf("Expected a new filename for every upload, got %q after %v", resp.Filename, seen)
+		}
+		seen[resp.Filename] = true
+	}
+}
+
+func TestHandleUploadScreenshot_WriteFailure(t *testing.T) {
+	// Point the storage directory at a regular file so MkdirAll fails.
+	blocker := filepath.Join(t.TempDir(), "not-a-dir")
+	if err := os.WriteFile(blocker, []byte("x"), 0644); err != nil {
+		t.Fatalf("Failed to create blocker file: %v", err)
+	}
+	SetFeedbackDir(blocker)
+	defer SetFeedbackDir("")
+
+	body, _ := json.Marshal(ScreenshotUploadRequest{Image: encodeTestPNG(t, 4, 4)})
+	rr := postScreenshot(t, body)
+	assertFeedbackError(t, rr, http.StatusInternalServerError, FeedbackErrWriteFailed)
+}
+
+func TestHandleGetScreenshot_NotFound(t *testing.T) {
+	SetFeedbackDir(t.TempDir())
+	defer SetFeedbackDir("")
+
+	s := &Server{}
+	handler := s.RegisterRoutes()
+	req := httptest.NewRequest("GET", "/api/feedback/screenshots/missing.png", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assertFeedbackError(t, rr, http.StatusNotFound, FeedbackErrNotFound)
+}
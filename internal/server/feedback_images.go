@@ -0,0 +1,152 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	_ "image/png" // Register the PNG decoder for image.Decode
+)
+
+const (
+	// maxCompressedDimension caps the longest edge of the compressed copy.
+	maxCompressedDimension = 1600
+
+	// thumbnailDimension is the longest edge of the generated thumbnail.
+	thumbnailDimension = 320
+
+	// compressedJPEGQuality and thumbnailJPEGQuality trade size for fidelity.
+	compressedJPEGQuality = 80
+	thumbnailJPEGQuality  = 70
+
+	// maxScreenshotPixels caps width x height (40 MP, above a triple-4K desktop). A small
+	// PNG can declare a huge canvas, and decoding it would take gigabytes.
+	maxScreenshotPixels = 40_000_000
+)
+
+// errScreenshotTooManyPixels means the image's header declares more than maxScreenshotPixels.
+var errScreenshotTooManyPixels = errors.New("image has too many pixels")
+
+// processedScreenshot holds the re-encoded variants of an uploaded screenshot.
+type processedScreenshot struct {
+	Compressed []byte
+	Thumbnail  []byte
+}
+
+// processScreenshot decodes a PNG/JPEG and produces a size-capped JPEG plus a thumbnail.
+// It returns an error if the bytes are not a decodable image, and errScreenshotTooManyPixels,
+// without decoding, if the header declares more than maxScreenshotPixels.
+func processScreenshot(imageData []byte) (*processedScreenshot, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(imageData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > maxScreenshotPixels {
+		return nil, fmt.Errorf("%w: %dx%d is over the %d pixel limit", errScreenshotTooManyPixels, cfg.Width, cfg.Height, maxScreenshotPixels)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(imageData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	compressed, err := encodeJPEG(scaleToFit(src, maxCompressedDimension), compressedJPEGQuality)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode compressed image: %w", err)
+	}
+
+	thumbnail, err := encodeJPEG(scaleToFit(src, thumbnailDimension), thumbnailJPEGQuality)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+
+	return &processedScreenshot{
+		Compressed: compressed,
+		Thumbnail:  thumbnail,
+	}, nil
+}
+
+// encodeJPEG writes img as a JPEG with the given quality.
+func encodeJPEG(img image.Image, quality int) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// scaleToFit shrinks img so its longest edge is at most maxDim, preserving aspect ratio.
+// Images already within bounds are returned unchanged.
+func scaleToFit(img image.Image, maxDim int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= maxDim && height <= maxDim {
+		return img
+	}
+
+	dstW, dstH := maxDim, maxDim
+	if width >= height {
+		dstH = height * maxDim / width
+	} else {
+		dstW = width * maxDim / height
+	}
+	if dstW < 1 {
+		dstW = 1
+	}
+	if dstH < 1 {
+		dstH = 1
+	}
+
+	return boxDownscale(img, dstW, dstH)
+}
+
+// boxDownscale resizes img to dstW x dstH by averaging each source block.
+// Educational Comment: A box filter is the simplest decent-quality shrink: every
+// output pixel is the average colour of the rectangle of input pixels it covers.
+func boxDownscale(img image.Image, dstW, dstH int) *image.RGBA {
+	// Normalise to RGBA so we can read the pixel buffer directly.
+	bounds := img.Bounds()
+	src := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+
+	srcW, srcH := src.Bounds().Dx(), src.Bounds().Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+
+	for y := 0; y < dstH; y++ {
+		y0 := y * srcH / dstH
+		y1 := (y + 1) * srcH / dstH
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < dstW; x++ {
+			x0 := x * srcW / dstW
+			x1 := (x + 1) * srcW / dstW
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				row := sy * src.Stride
+				for sx := x0; sx < x1; sx++ {
+					i := row + sx*4
+					r += uint64(src.Pix[i])
+					g += uint64(src.Pix[i+1])
+					b += uint64(src.Pix[i+2])
+					a += uint64(src.Pix[i+3])
+					n++
+				}
+			}
+
+			i := y*dst.Stride + x*4
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,187 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/budget"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/flows"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/llm"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/logging"
+)
+
+// defaultRunWaitTimeout is how long POST /api/flows/{id}/run?wait=true blocks before giving up on the
+// answer (the run itself carries on). ?timeout= in seconds overrides it.
+const defaultRunWaitTimeout = 10 * time.Minute
+
+// FlowRunSummary is the result of a flow run, as returned by a waiting run request.
+type FlowRunSummary struct {
+	FlowID       int                `json:"flow_id"`
+	RequestID    string             `json:"request_id"`
+	Status       string             `json:"status"` // COMPLETED, FAILED, BUDGET_EXCEEDED, or RUNNING if the wait timed out
+	Nodes        []flows.NodeResult `json:"nodes"`
+	TotalCostUSD float64            `json:"total_cost_usd"`
+	DurationMs   int64              `json:"duration_ms"`
+	Error        string             `json:"error,omitempty"`
+
+	// Cost breaks the run's tokens and cost down per node from its ledger rows,
+	// counting every retry and fallback call. It is left out while the run is still going.
+	Cost *flows.RunCost `json:"cost,omitempty"`
+}
+
+// runCollector gathers node results as the engine reports them. It is locked because a
+// timed-out request reads the summary while the run is still adding to it.
+type runCollector struct {
+	mu    sync.Mutex
+	nodes []flows.NodeResult
+}
+
+func (c *runCollector) add(result flows.NodeResult) {
+	c.mu.Lock()
+	c.nodes = append(c.nodes, result)
+	c.mu.Unlock()
+}
+
+// summary returns what has been collected so far, with the total cost of the nodes that ran.
+func (c *runCollector) summary(flowID int, requestID, status string, started time.Time) FlowRunSummary {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	summary := FlowRunSummary{
+		FlowID:     flowID,
+		RequestID:  requestID,
+		Status:     status,
+		Nodes:      append([]flows.NodeResult{}, c.nodes...),
+		DurationMs: time.Since(started).Milliseconds(),
+	}
+	for _, node := range c.nodes {
+		summary.TotalCostUSD += node.CostUSD
+	}
+	return summary
+}
+
+// handleRunFlow starts a flow run. By default it returns 202 straight away and progress
+// arrives over the WebSocket, like the UI's runs. With ?wait=true it blocks until the run
+// ends and returns a FlowRunSummary, so CI scripts can trigger a flow with one call.
+// Educational Comment: A wait that outlasts ?timeout= (default 10 minutes) answers 504 with
+// the nodes finished so far; the run itself keeps going and its result lands in the ledger.
+func (s *Server) handleRunFlow(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	// The body is optional and takes the same settings as /execute
+	var req ExecuteFlowRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := llm.ValidateAttachments(req.Attachments); err != nil {
+		writeAttachmentError(w, err)
+		return
+	}
+
+	timeout := defaultRunWaitTimeout
+	if t := r.URL.Query().Get("timeout"); t != "" {
+		seconds, err := strconv.Atoi(t)
+		if err != nil || seconds <= 0 {
+			http.Error(w, "timeout must be a positive number of seconds", http.StatusBadRequest)
+			return
+		}
+		timeout = time.Duration(seconds) * time.Second
+	}
+
+	// Turn a second run away up front; once the run is in the background nobody would see the conflict
+	if flows.IsFlowRunning(id) {
+		http.Error(w, "Flow is already running", http.StatusConflict)
+		return
+	}
+
+	requestID := uuid.NewString()
+	w.Header().Set("X-Request-Id", requestID)
+	collector := &runCollector{}
+	opts := flows.ExecuteOptions{
+		Attachments:  req.Attachments,
+		Environment:  req.Environment,
+		Resume:       req.Resume || r.URL.Query().Get("resume") == "true",
+		RequestID:    requestID,
+		OnNodeResult: collector.add,
+		Variables:    req.Variables,
+	}
+
+	started := time.Now()
+	done := make(chan error, 1)
+	s.flowRuns.Add(1)
+	go func() {
+		defer s.flowRuns.Done()
+		fileSignaler, _ := flows.NewFileSignaler()
+		done <- flows.ExecuteFlowWithOptions(id, s.db, s.gateway, flows.NewDBSignaler(s.db), fileSignaler, s.hub, opts)
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	if wait, _ := strconv.ParseBool(r.URL.Query().Get("wait")); !wait {
+		go func() {
+			if err := <-done; err != nil {
+				logging.ForRequest(requestID).Warnf("Flow %d run failed: %v", id, err)
+			}
+		}()
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]string{"status": "RUNNING", "request_id": requestID})
+		return
+	}
+
+	select {
+	case err := <-done:
+		status, code := runOutcome(err)
+		summary := collector.summary(id, requestID, status, started)
+		if err != nil {
+			summary.Error = err.Error()
+		}
+		summary.Cost = s.runCost(requestID)
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(summary)
+
+	case <-time.After(timeout):
+		summary := collector.summary(id, requestID, "RUNNING", started)
+		summary.Error = "timed out waiting for the run to finish; it is still running"
+		w.WriteHeader(http.StatusGatewayTimeout)
+		json.NewEncoder(w).Encode(summary)
+	}
+}
+
+// runOutcome maps a finished run's error to its final status and the HTTP status to answer with.
+func runOutcome(err error) (string, int) {
+	switch {
+	case err == nil:
+		return "COMPLETED", http.StatusOK
+	case errors.Is(err, flows.ErrFlowAlreadyRunning):
+		return "FAILED", http.StatusConflict
+	case errors.Is(err, budget.ErrBudgetExceeded):
+		return budget.CodeBudgetExceeded, http.StatusPaymentRequired
+	case errors.Is(err, sql.ErrNoRows):
+		return "FAILED", http.StatusNotFound
+	case errors.Is(err, flows.ErrUnresolvedVariable), errors.Is(err, llm.ErrUnknownFallback):
+		return "FAILED", http.StatusBadRequest
+	default:
+		return "FAILED", llm.HTTPStatusForError(err)
+	}
+}
+
+// runCost reads a finished run's cost breakdown, or returns nil if the ledger can't be read.
+func (s *Server) runCost(requestID string) *flows.RunCost {
+	cost, err := flows.RunCostSummary(s.db, requestID)
+	if err != nil {
+		logging.ForRequest(requestID).Warnf("Run cost summary: %v", err)
+		return nil
+	}
+	return cost
+}
@@ -0,0 +1,252 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/flows"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/security"
+	"github.com/zalando/go-keyring"
+)
+
+// setupRunFlowServer stores a two-node OpenAI flow whose second node fails when failSecond is set.
+func setupRunFlowServer(t *testing.T, failSecond bool) (*Server, string) {
+	t.Chdir(t.TempDir()) // the file signaler writes into .forge/ under the working directory
+	keyring.MockInit()
+	security.SetAPIKey("OpenAI", "dummy-key")
+
+	srv := setupFlowTestServer(t)
+	// Let background runs finish before the database closes and the working directory is
+	// restored, or a late run writes its failure into the package's own .forge/
+	t.Cleanup(srv.flowRuns.Wait)
+	flowJSON := `{"nodes": [
+		{"id": "1", "type": "agent", "data": {"role": "Architect", "prompt": "plan", "provider": "OpenAI"}},
+		{"id": "2", "type": "agent", "data": {"role": "Implementation", "prompt": "code", "provider": "OpenAI"}}
+	], "edges": []}`
+	res, err := srv.db.Exec(`INSERT INTO forge_flows (name, data, status) VALUES (?, ?, ?)`, "Run Flow", flowJSON, "active")
+	if err != nil {
+		t.Fatalf("Failed to insert flow: %v", err)
+	}
+	id, _ := res.LastInsertId()
+
+	srv.gateway.OpenAIClient = &MockLLMProvider{
+		SendFunc: func(systemPrompt, userPrompt, apiKey string) (string, int, int, error) {
+			if failSecond && userPrompt == "code" {
+				return "", 0, 0, errors.New("connection reset")
+			}
+			return "done: " + userPrompt, 10, 20, nil
+		},
+	}
+	return srv, strconv.FormatInt(id, 10)
+}
+
+func TestHandleRunFlow_WaitReturnsSummary(t *testing.T) {
+	srv, id := setupRunFlowServer(t, false)
+
+	rr := httptest.NewRecorder()
+	srv.RegisterRoutes().ServeHTTP(rr, httptest.NewRequest("POST", "/api/flows/"+id+"/run?wait=true", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var summary FlowRunSummary
+	if err := json.Unmarshal(rr.Body.Bytes(), &summary); err != nil {
+		t.Fatalf("Failed to decode summary: %v", err)
+	}
+	if summary.Status != "COMPLETED" || summary.RequestID == "" || summary.RequestID != rr.Header().Get("X-Request-Id") {
+		t.Errorf("Expected a completed summary with the run's request ID, got %+v", summary)
+	}
+	if len(summary.Nodes) != 2 || summary.Nodes[0].Output != "done: plan" || summary.Nodes[1].Output != "done: code" {
+		t.Fatalf("Expected both nodes' outputs, got %+v", summary.Nodes)
+	}
+	if summary.TotalCostUSD <= 0 || summary.TotalCostUSD != summary.Nodes[0].CostUSD+summary.Nodes[1].CostUSD {
+		t.Errorf("Expected the total to add up the node costs, got %v", summary.TotalCostUSD)
+	}
+}
+
+func TestHandleExecuteFlow_RunCostMatchesLedger(t *testing.T) {
+	srv, id := setupRunFlowServer(t, false)
+
+	rr := httptest.NewRecorder()
+	srv.RegisterRoutes().ServeHTTP(rr, httptest.NewRequest("POST", "/api/flows/"+id+"/execute", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp ExecuteFlowResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	cost := resp.Cost
+	if cost == nil || cost.RequestID != resp.RequestID || len(cost.Nodes) != 2 || cost.Nodes[0].NodeID != "1" || cost.Nodes[1].NodeID != "2" {
+		t.Fatalf("Expected a cost breakdown for nodes 1 and 2 of this run, got %+v", cost)
+	}
+
+	// The summary adds up to the run's rows in the ledger, node by node and overall
+	var calls, input, output int
+	var total float64
+	for _, node := range cost.Nodes {
+		var nodeInput, nodeOutput int
+		var nodeCost float64
+		err := srv.db.QueryRow(`SELECT SUM(input_tokens), SUM(output_tokens), SUM(total_cost_usd) FROM token_ledger WHERE request_id = ? AND node_id = ?`,
+			resp.RequestID, node.NodeID).Scan(&nodeInput, &nodeOutput, &nodeCost)
+		if err != nil {
+			t.Fatalf("Failed to read ledger rows for node %s: %v", node.NodeID, err)
+		}
+		if node.Calls != 1 || node.InputTokens != nodeInput || node.OutputTokens != nodeOutput || node.CostUSD != nodeCost {
+			t.Errorf("Expected node %s to match its ledger row (%d in, %d out, $%v), got %+v", node.NodeID, nodeInput, nodeOutput, nodeCost, node)
+		}
+	}
+	srv.db.QueryRow(`SELECT COUNT(*), SUM(input_tokens), SUM(output_tokens), SUM(total_cost_usd) FROM token_ledger WHERE request_id = ?`, resp.RequestID).
+		Scan(&calls, &input, &output, &total)
+	if calls != 2 || cost.InputTokens != input || cost.OutputTokens != output || cost.TotalCostUSD != total || total <= 0 {
+		t.Errorf("Expected totals of %d in, %d out, $%v over 2 calls, got %+v", input, output, total, cost)
+	}
+
+	// A waiting run carries the same breakdown
+	rr = httptest.NewRecorder()
+	srv.RegisterRoutes().ServeHTTP(rr, httptest.NewRequest("POST", "/api/flows/"+id+"/run?wait=true", nil))
+	var summary FlowRunSummary
+	json.Unmarshal(rr.Body.Bytes(), &summary)
+	if summary.Cost == nil || summary.Cost.RequestID != summary.RequestID || summary.Cost.TotalCostUSD != total {
+		t.Errorf("Expected the waiting run's summary to include its cost breakdown, got %+v", summary.Cost)
+	}
+}
+
+func TestHandleExecuteFlow_DistinctRunIDs(t *testing.T) {
+	srv, id := setupRunFlowServer(t, false)
+
+	execute := func() string {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		srv.RegisterRoutes().ServeHTTP(rr, httptest.NewRequest("POST", "/api/flows/"+id+"/execute", nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		return rr.Header().Get("X-Request-Id")
+	}
+	first, second := execute(), execute()
+	if first == "" || first == second {
+		t.Fatalf("Expected two runs of the same flow to get distinct run IDs, got %q and %q", first, second)
+	}
+
+	// Each run's ledger rows can be picked out by its run ID
+	for _, runID := range []string{first, second} {
+		rr := httptest.NewRecorder()
+		srv.RegisterRoutes().ServeHTTP(rr, httptest.NewRequest("GET", "/api/ledger?run_id="+runID, nil))
+		var entries []LedgerEntryResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &entries); err != nil {
+			t.Fatalf("Failed to decode ledger: %v", err)
+		}
+		if len(entries) != 2 || entries[0].RequestID != runID || entries[1].RequestID != runID {
+			t.Errorf("Expected the 2 ledger entries of run %s, got %+v", runID, entries)
+		}
+	}
+}
+
+func TestHandleRunFlow_WaitReportsFailure(t *testing.T) {
+	srv, id := setupRunFlowServer(t, true)
+
+	rr := httptest.NewRecorder()
+	srv.RegisterRoutes().ServeHTTP(rr, httptest.NewRequest("POST", "/api/flows/"+id+"/run?wait=true", nil))
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected status 500, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var summary FlowRunSummary
+	json.Unmarshal(rr.Body.Bytes(), &summary)
+	if summary.Status != "FAILED" || !strings.Contains(summary.Error, "connection reset") {
+		t.Errorf("Expected a FAILED summary with the error, got %+v", summary)
+	}
+	if len(summary.Nodes) != 2 || summary.Nodes[0].Status != "COMPLETED" || summary.Nodes[1].Status != "FAILED" {
+		t.Errorf("Expected node 1 completed and node 2 failed, got %+v", summary.Nodes)
+	}
+}
+
+func TestHandleRunFlow_AsyncByDefault(t *testing.T) {
+	srv, id := setupRunFlowServer(t, false)
+
+	rr := httptest.NewRecorder()
+	srv.RegisterRoutes().ServeHTTP(rr, httptest.NewRequest("POST", "/api/flows/"+id+"/run", nil))
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var body map[string]string
+	json.Unmarshal(rr.Body.Bytes(), &body)
+	if body["status"] != "RUNNING" || body["request_id"] == "" {
+		t.Errorf("Expected RUNNING with a request ID, got %v", body)
+	}
+
+	// The run finishes in the background
+	flowID, _ := strconv.Atoi(id)
+	signaler := flows.NewDBSignaler(srv.db)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status, err := signaler.GetStatus(flowID)
+		if err == nil && status.Status == "COMPLETED" && !flows.IsFlowRunning(flowID) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Background run did not complete, last status %+v", status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHandleRunFlow_InvalidTimeout(t *testing.T) {
+	srv := setupFlowTestServer(t)
+
+	rr := httptest.NewRecorder()
+	srv.RegisterRoutes().ServeHTTP(rr, httptest.NewRequest("POST", "/api/flows/1/run?wait=true&timeout=soon", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", rr.Code)
+	}
+}
+
+func TestHandleRunFlow_Variables(t *testing.T) {
+	t.Chdir(t.TempDir())
+	keyring.MockInit()
+	security.SetAPIKey("OpenAI", "dummy-key")
+
+	srv := setupFlowTestServer(t)
+	t.Cleanup(srv.flowRuns.Wait)
+	flowJSON := `{"nodes": [{"id": "1", "type": "agent", "data": {"role": "Architect", "prompt": "Review {{env.REPO_NAME}} at {{ env.BRANCH }}", "provider": "OpenAI"}}], "edges": []}`
+	if _, err := srv.db.Exec(`INSERT INTO forge_flows (name, data, status) VALUES (?, ?, ?)`, "Variable Flow", flowJSON, "active"); err != nil {
+		t.Fatalf("Failed to insert flow: %v", err)
+	}
+
+	var sent []string
+	srv.gateway.OpenAIClient = &MockLLMProvider{
+		SendFunc: func(systemPrompt, userPrompt, apiKey string) (string, int, int, error) {
+			sent = append(sent, userPrompt)
+			return "looks good", 10, 20, nil
+		},
+	}
+	run := func(body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		srv.RegisterRoutes().ServeHTTP(rr, httptest.NewRequest("POST", "/api/flows/1/run?wait=true", strings.NewReader(body)))
+		return rr
+	}
+
+	rr := run(`{"variables": {"REPO_NAME": "forge-orchestrator", "BRANCH": "main"}}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(sent) != 1 || sent[0] != "Review forge-orchestrator at main" {
+		t.Errorf("Expected the substituted prompt to reach the provider, got %q", sent)
+	}
+
+	// A variable without a value fails the run before any call is made
+	rr = run(`{"variables": {"REPO_NAME": "forge-orchestrator"}}`)
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "BRANCH") {
+		t.Errorf("Expected 400 naming the missing BRANCH variable, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(sent) != 1 {
+		t.Errorf("Expected no provider call for a run with a missing variable, got %d calls", len(sent))
+	}
+}
@@ -3,6 +3,7 @@ package server
 import (
 	"encoding/json"
 	"net/http"
+	"sort"
 	"strconv"
 
 	"github.com/mikejsmith1985/forge-orchestrator/internal/flows"
@@ -18,14 +19,7 @@ func (s *Server) handleGetFlowStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Try to get status from file signaler (fallback storage)
-	fileSignaler, err := flows.NewFileSignaler()
-	if err != nil {
-		http.Error(w, "Failed to initialize status reader", http.StatusInternalServerError)
-		return
-	}
-
-	status, err := fileSignaler.GetStatus(flowID)
+	status, err := s.lookupFlowStatus(flowID)
 	if err != nil {
 		// Return a default pending status if not found
 		status = &flows.FlowStatus{
@@ -37,3 +31,93 @@ func (s *Server) handleGetFlowStatus(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(status)
 }
+
+// lookupFlowStatus reads a flow's status from the database,
+// falling back to the file signaler for runs recorded before the table existed.
+func (s *Server) lookupFlowStatus(flowID int) (*flows.FlowStatus, error) {
+	if s.db != nil {
+		if status, err := flows.NewDBSignaler(s.db).GetStatus(flowID); err == nil {
+			return status, nil
+		}
+	}
+
+	fileSignaler, err := flows.NewFileSignaler()
+	if err != nil {
+		return nil, err
+	}
+	return fileSignaler.GetStatus(flowID)
+}
+
+// handleListFlowStatuses returns the latest status of every flow that has run.
+// Database rows win; file statuses only fill in flows the database doesn't know about.
+func (s *Server) handleListFlowStatuses(w http.ResponseWriter, r *http.Request) {
+	statuses, err := s.listFlowStatuses()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statuses)
+}
+
+// listFlowStatuses merges the database and file signalers into one list ordered by flow ID.
+func (s *Server) listFlowStatuses() ([]flows.FlowStatus, error) {
+	byFlow := make(map[int]flows.FlowStatus)
+
+	if s.db != nil {
+		dbStatuses, err := flows.NewDBSignaler(s.db).ListStatuses()
+		if err != nil {
+			return nil, err
+		}
+		for _, status := range dbStatuses {
+			byFlow[status.FlowID] = status
+		}
+	}
+
+	// The file signaler is best-effort: a missing directory just means no file statuses.
+	if fileSignaler, err := flows.NewFileSignaler(); err == nil {
+		if fileStatuses, err := fileSignaler.ListStatuses(); err == nil {
+			for _, status := range fileStatuses {
+				if _, exists := byFlow[status.FlowID]; !exists {
+					byFlow[status.FlowID] = status
+				}
+			}
+		}
+	}
+
+	statuses := make([]flows.FlowStatus, 0, len(byFlow))
+	for _, status := range byFlow {
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].FlowID < statuses[j].FlowID })
+	return statuses, nil
+}
+
+// handleGetFlowEventHistory returns the flow's recorded run events, oldest first, for post-mortems.
+// Optional query parameters:
+//   - run_id: only the events of that run
+//   - limit: at most this many of the latest events (default 500)
+func (s *Server) handleGetFlowEventHistory(w http.ResponseWriter, r *http.Request) {
+	flowID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid flow ID", http.StatusBadRequest)
+		return
+	}
+
+	limit := 500
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if val, err := strconv.Atoi(l); err == nil && val > 0 {
+			limit = val
+		}
+	}
+
+	events, err := flows.ListFlowEvents(s.db, flowID, r.URL.Query().Get("run_id"), limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(events)
+}
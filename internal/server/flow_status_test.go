@@ -0,0 +1,163 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/data"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/flows"
+)
+
+func setupFlowTestServer(t *testing.T) *Server {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if _, err := db.Exec(data.SQLiteSchema); err != nil {
+		t.Fatalf("Failed to init schema: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return NewServer(db)
+}
+
+func TestHandleGetFlowStatus_FromDatabase(t *testing.T) {
+	srv := setupFlowTestServer(t)
+
+	signaler := flows.NewDBSignaler(srv.db)
+	if err := signaler.NotifyStatus(4242, flows.FlowStatus{
+		FlowID:    4242,
+		Status:    "COMPLETED",
+		LastNode:  "agent-2",
+		UpdatedAt: time.Now(),
+	}); err != nil {
+		t.Fatalf("NotifyStatus failed: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/api/flows/4242/status", nil)
+	rr := httptest.NewRecorder()
+	srv.RegisterRoutes().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+
+	var status flows.FlowStatus
+	if err := json.Unmarshal(rr.Body.Bytes(), &status); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if status.Status != "COMPLETED" || status.LastNode != "agent-2" {
+		t.Errorf("Expected status from database, got %+v", status)
+	}
+}
+
+func TestHandleGetFlowStatus_Unknown(t *testing.T) {
+	srv := setupFlowTestServer(t)
+
+	req := httptest.NewRequest("GET", "/api/flows/987654/status", nil)
+	rr := httptest.NewRecorder()
+	srv.RegisterRoutes().ServeHTTP(rr, req)
+
+	var status flows.FlowStatus
+	json.Unmarshal(rr.Body.Bytes(), &status)
+	if status.Status != "UNKNOWN" {
+		t.Errorf("Expected UNKNOWN for missing flow, got %s", status.Status)
+	}
+}
+
+func TestHandleListFlowStatuses(t *testing.T) {
+	srv := setupFlowTestServer(t)
+	signaler := flows.NewDBSignaler(srv.db)
+
+	// Each flow gets several updates; only the last one should be reported.
+	updates := []flows.FlowStatus{
+		{FlowID: 9101, Status: "RUNNING", LastNode: "node-1"},
+		{FlowID: 9102, Status: "RUNNING", LastNode: "node-a"},
+		{FlowID: 9101, Status: "RUNNING", LastNode: "node-2"},
+		{FlowID: 9102, Status: "FAILED", LastNode: "node-b", Error: "boom"},
+		{FlowID: 9101, Status: "COMPLETED", LastNode: "node-3"},
+	}
+	base := time.Now().Add(-time.Minute)
+	for i, update := range updates {
+		update.UpdatedAt = base.Add(time.Duration(i) * time.Second)
+		if err := signaler.NotifyStatus(update.FlowID, update); err != nil {
+			t.Fatalf("NotifyStatus failed: %v", err)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/api/flows/status", nil)
+	rr := httptest.NewRecorder()
+	srv.RegisterRoutes().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var statuses []flows.FlowStatus
+	if err := json.Unmarshal(rr.Body.Bytes(), &statuses); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	byFlow := make(map[int]flows.FlowStatus)
+	for _, status := range statuses {
+		byFlow[status.FlowID] = status
+	}
+
+	first, ok := byFlow[9101]
+	if !ok {
+		t.Fatal("Expected flow 9101 in response")
+	}
+	if first.Status != "COMPLETED" || first.LastNode != "node-3" {
+		t.Errorf("Expected latest status for 9101, got %+v", first)
+	}
+	if !first.UpdatedAt.Equal(base.Add(4 * time.Second)) {
+		t.Errorf("Expected updated_at of last update, got %v", first.UpdatedAt)
+	}
+
+	second, ok := byFlow[9102]
+	if !ok {
+		t.Fatal("Expected flow 9102 in response")
+	}
+	if second.Status != "FAILED" || second.LastNode != "node-b" || second.Error != "boom" {
+		t.Errorf("Expected latest status for 9102, got %+v", second)
+	}
+}
+
+func TestHandleGetFlowEventHistory(t *testing.T) {
+	srv := setupFlowTestServer(t)
+	if _, err := srv.db.Exec(`INSERT INTO forge_flows (name, data, status) VALUES ('Empty', '{"nodes": [], "edges": []}', 'active')`); err != nil {
+		t.Fatalf("Failed to insert flow: %v", err)
+	}
+	if err := flows.ExecuteFlowWithOptions(1, srv.db, srv.gateway, nil, nil, nil, flows.ExecuteOptions{}); err != nil {
+		t.Fatalf("ExecuteFlowWithOptions failed: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/api/flows/1/events/history", nil)
+	rr := httptest.NewRecorder()
+	srv.RegisterRoutes().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var events []flows.FlowEvent
+	if err := json.Unmarshal(rr.Body.Bytes(), &events); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(events) != 2 || events[0].Type != flows.EventFlowStarted || events[1].Type != flows.EventFlowCompleted {
+		t.Fatalf("Expected FLOW_STARTED then FLOW_COMPLETED, got %+v", events)
+	}
+	if events[0].RunID != events[1].RunID {
+		t.Errorf("Expected both events to share a run ID, got %+v", events)
+	}
+
+	// A flow that never ran has an empty history, not an error
+	req = httptest.NewRequest("GET", "/api/flows/2/events/history", nil)
+	rr = httptest.NewRecorder()
+	srv.RegisterRoutes().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || rr.Body.String() != "[]\n" {
+		t.Errorf("Expected an empty list, got %d %q", rr.Code, rr.Body.String())
+	}
+}
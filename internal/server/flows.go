@@ -1,27 +1,70 @@
 package server
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 
+	"github.com/google/uuid"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/budget"
 	"github.com/mikejsmith1985/forge-orchestrator/internal/flows"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/llm"
 )
 
-// handleGetFlows retrieves all flows.
+// handleGetFlows lists flows, most recently updated first.
+// Optional query parameters:
+//   - search: case-insensitive substring match on the flow name
+//   - limit, offset: pagination (without limit, every matching flow is returned)
+//   - trashed=true: list soft-deleted flows instead of live ones
+//
+// The total number of matches (before pagination) is reported in X-Total-Count.
 func (s *Server) handleGetFlows(w http.ResponseWriter, r *http.Request) {
-	query := `SELECT id, name, data, status, created_at FROM forge_flows ORDER BY created_at DESC`
-	rows, err := s.db.Query(query)
+	q := r.URL.Query()
+	search := q.Get("search")
+
+	limit := -1 // SQLite treats a negative LIMIT as "no limit"
+	if l := q.Get("limit"); l != "" {
+		if val, err := strconv.Atoi(l); err == nil && val > 0 {
+			limit = val
+		}
+	}
+	offset := 0
+	if o := q.Get("offset"); o != "" {
+		if val, err := strconv.Atoi(o); err == nil && val > 0 {
+			offset = val
+		}
+	}
+
+	// instr() avoids having to escape % and _ in the search text as LIKE would need
+	where := `WHERE (? = '' OR instr(lower(name), lower(?)) > 0) AND deleted_at IS NULL`
+	if trashed, _ := strconv.ParseBool(q.Get("trashed")); trashed {
+		where = `WHERE (? = '' OR instr(lower(name), lower(?)) > 0) AND deleted_at IS NOT NULL`
+	}
+
+	var total int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM forge_flows `+where, search, search).Scan(&total); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	query := `SELECT id, name, data, status, created_at, COALESCE(max_cost_usd, 0), revision FROM forge_flows ` + where + `
+		ORDER BY COALESCE(updated_at, created_at) DESC, id DESC
+		LIMIT ? OFFSET ?`
+	rows, err := s.db.Query(query, search, search, limit, offset)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 	defer rows.Close()
 
-	var result []flows.Flow
+	result := []flows.Flow{}
 	for rows.Next() {
 		var f flows.Flow
-		if err := rows.Scan(&f.ID, &f.Name, &f.Data, &f.Status, &f.CreatedAt); err != nil {
+		if err := rows.Scan(&f.ID, &f.Name, &f.Data, &f.Status, &f.CreatedAt, &f.MaxCostUSD, &f.Revision); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -29,6 +72,7 @@ func (s *Server) handleGetFlows(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
 	json.NewEncoder(w).Encode(result)
 }
 
@@ -41,9 +85,9 @@ func (s *Server) handleGetFlow(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	query := `SELECT id, name, data, status, created_at FROM forge_flows WHERE id = ?`
+	query := `SELECT id, name, data, status, created_at, COALESCE(max_cost_usd, 0), revision FROM forge_flows WHERE id = ? AND deleted_at IS NULL`
 	var f flows.Flow
-	err = s.db.QueryRow(query, id).Scan(&f.ID, &f.Name, &f.Data, &f.Status, &f.CreatedAt)
+	err = s.db.QueryRow(query, id).Scan(&f.ID, &f.Name, &f.Data, &f.Status, &f.CreatedAt, &f.MaxCostUSD, &f.Revision)
 	if err != nil {
 		http.Error(w, "Flow not found", http.StatusNotFound)
 		return
@@ -61,8 +105,8 @@ func (s *Server) handleCreateFlow(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	query := `INSERT INTO forge_flows (name, data, status) VALUES (?, ?, ?)`
-	res, err := s.db.Exec(query, f.Name, f.Data, f.Status)
+	query := `INSERT INTO forge_flows (name, data, status, max_cost_usd) VALUES (?, ?, ?, ?)`
+	res, err := s.db.Exec(query, f.Name, f.Data, f.Status, f.MaxCostUSD)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -70,37 +114,146 @@ func (s *Server) handleCreateFlow(w http.ResponseWriter, r *http.Request) {
 
 	id, _ := res.LastInsertId()
 	f.ID = int(id)
+	f.Revision = 1
 
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(f)
 }
 
-// handleUpdateFlow updates an existing flow.
+// handleUpdateFlow updates an existing flow and responds with it at its new revision.
+// Educational Comment: This is optimistic concurrency control. The body must carry the
+// revision the client loaded; if someone else has saved the flow since, the stored revision
+// is newer and the update answers 409 instead of overwriting their edit. The client should
+// reload the flow and reapply its change.
 func (s *Server) handleUpdateFlow(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "Invalid ID")
 		return
 	}
 
 	var f flows.Flow
 	if err := json.NewDecoder(r.Body).Decode(&f); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if f.Revision <= 0 {
+		writeJSONError(w, http.StatusBadRequest, "revision is required: send the revision of the flow this update is based on")
 		return
 	}
 
-	query := `UPDATE forge_flows SET name = ?, data = ?, status = ? WHERE id = ?`
-	_, err = s.db.Exec(query, f.Name, f.Data, f.Status, id)
+	// Keep the graph being replaced as a version, in the same transaction as the edit
+	tx, err := s.db.Begin()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	if err := flows.SaveVersion(tx, id, f.Data); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	query := `UPDATE forge_flows SET name = ?, data = ?, status = ?, max_cost_usd = ?, updated_at = CURRENT_TIMESTAMP, revision = revision + 1
+		WHERE id = ? AND revision = ? AND deleted_at IS NULL`
+	res, err := tx.Exec(query, f.Name, f.Data, f.Status, f.MaxCostUSD, id, f.Revision)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		// Either the flow is gone or the revision didn't match; tell the two apart
+		var current int
+		err := tx.QueryRow(`SELECT revision FROM forge_flows WHERE id = ? AND deleted_at IS NULL`, id).Scan(&current)
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, http.StatusNotFound, "Flow not found")
+		} else if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+		} else {
+			writeJSONError(w, http.StatusConflict, fmt.Sprintf("Flow was changed by someone else (now at revision %d, update is based on %d); reload it and try again", current, f.Revision))
+		}
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	s.handleGetFlow(w, r)
+}
+
+// handleGetFlowVersions lists the kept earlier versions of a flow, newest first,
+// each with the full graph so the UI can diff it against the current one.
+func (s *Server) handleGetFlowVersions(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+
+	versions, err := flows.ListVersions(s.db, id)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(versions)
+}
+
+// handleRevertFlow puts a flow's graph back to an earlier version. The graph it replaces
+// is kept as a new version first, so a revert can itself be undone.
+func (s *Server) handleRevertFlow(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+	version, err := strconv.Atoi(r.PathValue("version"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid version")
+		return
+	}
+
+	target, err := flows.GetVersion(s.db, id, version)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeJSONError(w, http.StatusNotFound, "Flow version not found")
+		return
+	} else if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	if err := flows.SaveVersion(tx, id, target.Data); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	res, err := tx.Exec(`UPDATE forge_flows SET data = ?, updated_at = CURRENT_TIMESTAMP, revision = revision + 1 WHERE id = ? AND deleted_at IS NULL`, target.Data, id)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		writeJSONError(w, http.StatusNotFound, "Flow not found")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	s.handleGetFlow(w, r)
 }
 
-// handleDeleteFlow deletes a flow.
+// handleDeleteFlow moves a flow to the trash, where it can be restored.
+// Pass ?hard=true to remove it permanently instead.
 func (s *Server) handleDeleteFlow(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
 	id, err := strconv.Atoi(idStr)
@@ -109,16 +262,103 @@ func (s *Server) handleDeleteFlow(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	query := `DELETE FROM forge_flows WHERE id = ?`
+	query := `UPDATE forge_flows SET deleted_at = CURRENT_TIMESTAMP WHERE id = ? AND deleted_at IS NULL`
+	hard, _ := strconv.ParseBool(r.URL.Query().Get("hard"))
+	if hard {
+		query = `DELETE FROM forge_flows WHERE id = ?`
+	}
+
 	_, err = s.db.Exec(query, id)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	// A permanently deleted flow has nothing left to revert
+	if hard {
+		if _, err := s.db.Exec(`DELETE FROM flow_versions WHERE flow_id = ?`, id); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleRestoreFlow brings a soft-deleted flow back out of the trash.
+func (s *Server) handleRestoreFlow(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	res, err := s.db.Exec(`UPDATE forge_flows SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL`, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "Flow not found in trash", http.StatusNotFound)
+		return
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// handleEstimateFlow projects what running a flow would cost, without calling any provider.
+func (s *Server) handleEstimateFlow(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var flowData string
+	err = s.db.QueryRow(`SELECT data FROM forge_flows WHERE id = ? AND deleted_at IS NULL`, id).Scan(&flowData)
+	if err != nil {
+		http.Error(w, "Flow not found", http.StatusNotFound)
+		return
+	}
+
+	var graph flows.FlowGraph
+	if err := json.Unmarshal([]byte(flowData), &graph); err != nil {
+		http.Error(w, "Failed to parse flow data: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// A node the engine couldn't run (unknown role or provider) can't be priced either
+	estimate, err := flows.EstimateGraph(graph)
+	if err != nil {
+		http.Error(w, "Cannot estimate flow: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	estimate.FlowID = id
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(estimate)
+}
+
+// ExecuteFlowRequest is the optional body for POST /api/flows/{id}/execute.
+type ExecuteFlowRequest struct {
+	Attachments []llm.Attachment `json:"attachments,omitempty"`
+	// Environment tags the run's ledger entries, e.g. "dev" for test runs (default prod)
+	Environment string `json:"environment,omitempty"`
+	// Resume skips nodes that finished in the last, failed run (also ?resume=true)
+	Resume bool `json:"resume,omitempty"`
+	// Variables fill the {{env.NAME}} references in the nodes' prompts
+	Variables map[string]string `json:"variables,omitempty"`
+}
+
+// ExecuteFlowResponse is the result of a successful POST /api/flows/{id}/execute.
+type ExecuteFlowResponse struct {
+	Status    string         `json:"status"`
+	RequestID string         `json:"request_id"`
+	Cost      *flows.RunCost `json:"cost,omitempty"` // per-node and total tokens and cost of the run
+}
+
 // handleExecuteFlow triggers the execution of a flow.
 func (s *Server) handleExecuteFlow(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
@@ -128,15 +368,45 @@ func (s *Server) handleExecuteFlow(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Create file signaler for fallback
+	// The body is optional; when present it can carry attachments for every node
+	var req ExecuteFlowRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := llm.ValidateAttachments(req.Attachments); err != nil {
+		writeAttachmentError(w, err)
+		return
+	}
+
+	// Persist status in the database, with the file signaler as fallback
+	dbSignaler := flows.NewDBSignaler(s.db)
 	fileSignaler, _ := flows.NewFileSignaler()
 
 	// Execute the flow with Hub integration for real-time broadcasts
-	if err := flows.ExecuteFlowWithHub(id, s.db, s.gateway, nil, fileSignaler, s.hub); err != nil {
-		http.Error(w, "Flow execution failed: "+err.Error(), http.StatusInternalServerError)
+	// The request ID is returned even on failure, so the run's ledger rows and log lines can be looked up
+	resume := req.Resume || r.URL.Query().Get("resume") == "true"
+	requestID := uuid.NewString()
+	w.Header().Set("X-Request-Id", requestID)
+	opts := flows.ExecuteOptions{Attachments: req.Attachments, Environment: req.Environment, Resume: resume, RequestID: requestID, Variables: req.Variables}
+	if err := flows.ExecuteFlowWithOptions(id, s.db, s.gateway, dbSignaler, fileSignaler, s.hub, opts); err != nil {
+		if errors.Is(err, flows.ErrFlowAlreadyRunning) {
+			http.Error(w, "Flow is already running", http.StatusConflict)
+			return
+		}
+		if errors.Is(err, budget.ErrBudgetExceeded) {
+			http.Error(w, "Flow execution stopped: "+err.Error(), http.StatusPaymentRequired)
+			return
+		}
+		if errors.Is(err, flows.ErrUnresolvedVariable) || errors.Is(err, llm.ErrUnknownFallback) {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeLLMError(w, "Flow execution failed: ", err)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(`{"status": "completed"}`))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ExecuteFlowResponse{Status: "completed", RequestID: requestID, Cost: s.runCost(requestID)})
 }
@@ -0,0 +1,400 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/flows"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/llm"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/security"
+	"github.com/zalando/go-keyring"
+)
+
+func TestHandleExecuteFlow_UpstreamRateLimit(t *testing.T) {
+	// The file signaler writes into .forge/ under the working directory
+	t.Chdir(t.TempDir())
+	keyring.MockInit()
+	security.SetAPIKey("OpenAI", "dummy-key")
+
+	srv := setupFlowTestServer(t)
+	flowJSON := `{"nodes": [{"id": "1", "type": "agent", "data": {"role": "Implementation", "prompt": "code", "provider": "OpenAI"}}], "edges": []}`
+	res, err := srv.db.Exec(`INSERT INTO forge_flows (name, data, status) VALUES (?, ?, ?)`, "Flow", flowJSON, "active")
+	if err != nil {
+		t.Fatalf("Failed to insert flow: %v", err)
+	}
+	id, _ := res.LastInsertId()
+
+	srv.gateway.OpenAIClient = &MockLLMProvider{
+		SendFunc: func(systemPrompt, userPrompt, apiKey string) (string, int, int, error) {
+			return "", 0, 0, &llm.APIError{Provider: llm.ProviderOpenAI, StatusCode: 429, Message: "slow down"}
+		},
+	}
+
+	req := httptest.NewRequest("POST", "/api/flows/"+strconv.FormatInt(id, 10)+"/execute", nil)
+	rr := httptest.NewRecorder()
+	srv.RegisterRoutes().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("Retry-After") == "" {
+		t.Error("Expected a Retry-After header")
+	}
+}
+
+// insertFlowsForListing adds flows with distinct update times, oldest first.
+func insertFlowsForListing(t *testing.T, srv *Server, names ...string) {
+	for i, name := range names {
+		_, err := srv.db.Exec(`INSERT INTO forge_flows (name, data, status, updated_at) VALUES (?, '{}', 'active', datetime('2025-01-01', ?))`,
+			name, "+"+strconv.Itoa(i)+" hours")
+		if err != nil {
+			t.Fatalf("Failed to insert flow: %v", err)
+		}
+	}
+}
+
+func listFlows(t *testing.T, srv *Server, query string) ([]flows.Flow, string) {
+	rr := httptest.NewRecorder()
+	srv.RegisterRoutes().ServeHTTP(rr, httptest.NewRequest("GET", "/api/flows"+query, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var result []flows.Flow
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to decode flows: %v", err)
+	}
+	return result, rr.Header().Get("X-Total-Count")
+}
+
+func flowNames(list []flows.Flow) []string {
+	names := make([]string, len(list))
+	for i, f := range list {
+		names[i] = f.Name
+	}
+	return names
+}
+
+func TestHandleGetFlows_NoParamsReturnsAllNewestFirst(t *testing.T) {
+	srv := setupFlowTestServer(t)
+	insertFlowsForListing(t, srv, "Alpha", "Beta", "Gamma")
+
+	list, total := listFlows(t, srv, "")
+	if got := strings.Join(flowNames(list), ","); got != "Gamma,Beta,Alpha" {
+		t.Errorf("Expected all flows newest first, got %s", got)
+	}
+	if total != "3" {
+		t.Errorf("Expected X-Total-Count 3, got %q", total)
+	}
+}
+
+func TestHandleGetFlows_Search(t *testing.T) {
+	srv := setupFlowTestServer(t)
+	insertFlowsForListing(t, srv, "Deploy API", "Refactor UI", "deploy docs", "100%_done")
+
+	list, total := listFlows(t, srv, "?search=DEPLOY")
+	if got := strings.Join(flowNames(list), ","); got != "deploy docs,Deploy API" {
+		t.Errorf("Expected case-insensitive name matches, got %s", got)
+	}
+	if total != "2" {
+		t.Errorf("Expected X-Total-Count 2, got %q", total)
+	}
+
+	// LIKE wildcards in the search text are matched literally
+	list, _ = listFlows(t, srv, "?search="+url.QueryEscape("%_"))
+	if got := strings.Join(flowNames(list), ","); got != "100%_done" {
+		t.Errorf("Expected literal match on %%_, got %s", got)
+	}
+
+	list, total = listFlows(t, srv, "?search=nothing-matches")
+	if list == nil || len(list) != 0 || total != "0" {
+		t.Errorf("Expected an empty list, got %v (total %q)", list, total)
+	}
+}
+
+func TestHandleGetFlows_Pagination(t *testing.T) {
+	srv := setupFlowTestServer(t)
+	insertFlowsForListing(t, srv, "F1", "F2", "F3", "F4", "F5")
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"?limit=2", "F5,F4"},
+		{"?limit=2&offset=2", "F3,F2"},
+		{"?limit=2&offset=4", "F1"},
+		{"?limit=2&offset=5", ""},
+		{"?offset=3", "F2,F1"},
+		{"?limit=0", "F5,F4,F3,F2,F1"},
+		{"?limit=abc&offset=-1", "F5,F4,F3,F2,F1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			list, total := listFlows(t, srv, tt.query)
+			if got := strings.Join(flowNames(list), ","); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+			if total != "5" {
+				t.Errorf("Expected X-Total-Count 5 regardless of page, got %q", total)
+			}
+		})
+	}
+}
+
+// flowRequest sends a request to a /api/flows/{id} route and returns the status code.
+func flowRequest(t *testing.T, srv *Server, method, path string) int {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	srv.RegisterRoutes().ServeHTTP(rr, httptest.NewRequest(method, path, nil))
+	return rr.Code
+}
+
+func TestHandleDeleteFlow_SoftDeleteAndRestore(t *testing.T) {
+	srv := setupFlowTestServer(t)
+	insertFlowsForListing(t, srv, "Keep", "Trash")
+
+	var id int
+	if err := srv.db.QueryRow(`SELECT id FROM forge_flows WHERE name = 'Trash'`).Scan(&id); err != nil {
+		t.Fatalf("Failed to find flow: %v", err)
+	}
+	flowPath := "/api/flows/" + strconv.Itoa(id)
+
+	if code := flowRequest(t, srv, http.MethodDelete, flowPath); code != http.StatusNoContent {
+		t.Fatalf("Expected 204 from delete, got %d", code)
+	}
+
+	// The row is still there, just hidden from the normal views
+	list, total := listFlows(t, srv, "")
+	if got := strings.Join(flowNames(list), ","); got != "Keep" || total != "1" {
+		t.Errorf("Expected only Keep after soft delete, got %q (total %q)", got, total)
+	}
+	if code := flowRequest(t, srv, http.MethodGet, flowPath); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a trashed flow, got %d", code)
+	}
+	list, _ = listFlows(t, srv, "?trashed=true")
+	if got := strings.Join(flowNames(list), ","); got != "Trash" {
+		t.Errorf("Expected Trash in the trash listing, got %q", got)
+	}
+
+	if code := flowRequest(t, srv, http.MethodPost, flowPath+"/restore"); code != http.StatusNoContent {
+		t.Fatalf("Expected 204 from restore, got %d", code)
+	}
+	list, _ = listFlows(t, srv, "")
+	if got := strings.Join(flowNames(list), ","); got != "Trash,Keep" {
+		t.Errorf("Expected restored flow back in the list, got %q", got)
+	}
+
+	// Restoring a flow that isn't in the trash is a 404
+	if code := flowRequest(t, srv, http.MethodPost, flowPath+"/restore"); code != http.StatusNotFound {
+		t.Errorf("Expected 404 restoring a live flow, got %d", code)
+	}
+}
+
+func TestHandleDeleteFlow_Hard(t *testing.T) {
+	srv := setupFlowTestServer(t)
+	insertFlowsForListing(t, srv, "Gone")
+
+	var id int
+	if err := srv.db.QueryRow(`SELECT id FROM forge_flows WHERE name = 'Gone'`).Scan(&id); err != nil {
+		t.Fatalf("Failed to find flow: %v", err)
+	}
+	flowPath := "/api/flows/" + strconv.Itoa(id)
+
+	if code := flowRequest(t, srv, http.MethodDelete, flowPath+"?hard=true"); code != http.StatusNoContent {
+		t.Fatalf("Expected 204 from hard delete, got %d", code)
+	}
+
+	var count int
+	srv.db.QueryRow(`SELECT COUNT(*) FROM forge_flows`).Scan(&count)
+	if count != 0 {
+		t.Errorf("Expected the row to be removed, found %d", count)
+	}
+	if code := flowRequest(t, srv, http.MethodPost, flowPath+"/restore"); code != http.StatusNotFound {
+		t.Errorf("Expected 404 restoring a hard-deleted flow, got %d", code)
+	}
+}
+
+func TestHandleUpdateFlow_VersionsAndRevert(t *testing.T) {
+	srv := setupFlowTestServer(t)
+	insertFlowsForListing(t, srv, "Versioned")
+	flowPath := "/api/flows/1"
+
+	revision := 1
+	update := func(data string) {
+		t.Helper()
+		body, _ := json.Marshal(flows.Flow{Name: "Versioned", Data: data, Status: "active", Revision: revision})
+		rr := httptest.NewRecorder()
+		srv.RegisterRoutes().ServeHTTP(rr, httptest.NewRequest("PUT", flowPath, strings.NewReader(string(body))))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected 200 from update, got %d: %s", rr.Code, rr.Body.String())
+		}
+		revision++
+	}
+	versions := func() []flows.FlowVersion {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		srv.RegisterRoutes().ServeHTTP(rr, httptest.NewRequest("GET", flowPath+"/versions", nil))
+		var list []flows.FlowVersion
+		if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil {
+			t.Fatalf("Failed to decode versions: %v", err)
+		}
+		return list
+	}
+
+	update(`{"nodes": [{"id": "first"}]}`)
+	update(`{"nodes": [{"id": "second"}]}`)
+	update(`{"nodes": [{"id": "second"}]}`) // unchanged, so no new version
+
+	list := versions()
+	if len(list) != 2 || list[0].Version != 2 || list[0].Data != `{"nodes": [{"id": "first"}]}` || list[1].Version != 1 || list[1].Data != "{}" {
+		t.Fatalf("Expected versions 2 (first edit) and 1 (original), got %+v", list)
+	}
+
+	// Revert to the first edit; the graph it replaces becomes version 3
+	rr := httptest.NewRecorder()
+	srv.RegisterRoutes().ServeHTTP(rr, httptest.NewRequest("POST", flowPath+"/revert/2", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200 from revert, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var reverted flows.Flow
+	json.Unmarshal(rr.Body.Bytes(), &reverted)
+	if reverted.Data != `{"nodes": [{"id": "first"}]}` {
+		t.Errorf("Expected the first edit's graph back, got %q", reverted.Data)
+	}
+	if list := versions(); len(list) != 3 || list[0].Data != `{"nodes": [{"id": "second"}]}` {
+		t.Errorf("Expected the replaced graph kept as version 3, got %+v", list)
+	}
+
+	if code := flowRequest(t, srv, http.MethodPost, flowPath+"/revert/99"); code != http.StatusNotFound {
+		t.Errorf("Expected 404 reverting to an unknown version, got %d", code)
+	}
+	if code := flowRequest(t, srv, http.MethodPost, flowPath+"/revert/latest"); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a non-numeric version, got %d", code)
+	}
+}
+
+func TestHandleUpdateFlow_StaleRevision(t *testing.T) {
+	srv := setupFlowTestServer(t)
+	insertFlowsForListing(t, srv, "Shared")
+	flowPath := "/api/flows/1"
+
+	put := func(data string, revision int) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(flows.Flow{Name: "Shared", Data: data, Status: "active", Revision: revision})
+		rr := httptest.NewRecorder()
+		srv.RegisterRoutes().ServeHTTP(rr, httptest.NewRequest("PUT", flowPath, strings.NewReader(string(body))))
+		return rr
+	}
+
+	// Two clients load the flow at revision 1; the first to save wins
+	rr := put(`{"nodes": [{"id": "alice"}]}`, 1)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200 from the first update, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var saved flows.Flow
+	json.Unmarshal(rr.Body.Bytes(), &saved)
+	if saved.Revision != 2 || saved.Data != `{"nodes": [{"id": "alice"}]}` {
+		t.Errorf("Expected the saved flow at revision 2, got %+v", saved)
+	}
+
+	// The second save is based on revision 1, which is now stale
+	rr = put(`{"nodes": [{"id": "bob"}]}`, 1)
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("Expected 409 for a stale revision, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var data string
+	srv.db.QueryRow(`SELECT data FROM forge_flows WHERE id = 1`).Scan(&data)
+	if data != `{"nodes": [{"id": "alice"}]}` {
+		t.Errorf("Expected the first edit to survive the conflict, got %q", data)
+	}
+	if list, _ := flows.ListVersions(srv.db, 1); len(list) != 1 {
+		t.Errorf("Expected the refused update not to record a version, got %d versions", len(list))
+	}
+
+	// Reloading and retrying on the current revision goes through
+	if rr := put(`{"nodes": [{"id": "bob"}]}`, 2); rr.Code != http.StatusOK {
+		t.Errorf("Expected 200 retrying on the current revision, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if rr := put(`{}`, 0); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an update without a revision, got %d", rr.Code)
+	}
+	flowPath = "/api/flows/99"
+	if rr := put(`{}`, 1); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 updating an unknown flow, got %d", rr.Code)
+	}
+}
+
+func TestHandleEstimateFlow(t *testing.T) {
+	srv := setupFlowTestServer(t)
+
+	// Two agent nodes on different providers, plus a comment node that costs nothing
+	flowJSON := `{"nodes": [
+		{"id": "1", "type": "agent", "data": {"label": "Plan", "role": "Architect", "prompt": "Design a REST API for a todo app", "provider": "Anthropic"}},
+		{"id": "note", "type": "comment", "data": {}},
+		{"id": "2", "type": "agent", "data": {"label": "Build", "role": "Implementation", "prompt": "Implement the todo API in Go", "provider": "OpenAI"}}
+	], "edges": []}`
+	res, err := srv.db.Exec(`INSERT INTO forge_flows (name, data, status) VALUES (?, ?, ?)`, "Estimate Flow", flowJSON, "active")
+	if err != nil {
+		t.Fatalf("Failed to insert flow: %v", err)
+	}
+	id, _ := res.LastInsertId()
+
+	rr := httptest.NewRecorder()
+	srv.RegisterRoutes().ServeHTTP(rr, httptest.NewRequest("GET", "/api/flows/"+strconv.Itoa(int(id))+"/estimate", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var estimate flows.FlowEstimate
+	if err := json.Unmarshal(rr.Body.Bytes(), &estimate); err != nil {
+		t.Fatalf("Failed to decode estimate: %v", err)
+	}
+
+	if len(estimate.Nodes) != 2 || estimate.Nodes[0].Provider != "Anthropic" || estimate.Nodes[1].Provider != "OpenAI" {
+		t.Fatalf("Expected the two agent nodes in order, got %+v", estimate.Nodes)
+	}
+
+	var tokens int
+	var cost float64
+	for _, node := range estimate.Nodes {
+		// Each node carries its role's system prompt, so even short prompts are tens of tokens
+		if node.InputTokens < 20 || node.InputTokens > 5000 {
+			t.Errorf("Implausible token estimate for node %s: %d", node.NodeID, node.InputTokens)
+		}
+		if node.CostUSD <= 0 {
+			t.Errorf("Expected a positive cost for node %s", node.NodeID)
+		}
+		tokens += node.InputTokens
+		cost += node.CostUSD
+	}
+	if estimate.InputTokens != tokens || estimate.EstimatedCostUSD != cost {
+		t.Errorf("Expected totals to sum the nodes (%d tokens, $%v), got %d tokens, $%v", tokens, cost, estimate.InputTokens, estimate.EstimatedCostUSD)
+	}
+	// Input for two short prompts at a few dollars per million tokens is well under a cent
+	if estimate.EstimatedCostUSD > 0.01 {
+		t.Errorf("Expected a sub-cent estimate, got $%v", estimate.EstimatedCostUSD)
+	}
+	if estimate.FlowID != int(id) {
+		t.Errorf("Expected flow_id %d, got %d", id, estimate.FlowID)
+	}
+}
+
+func TestHandleEstimateFlow_Errors(t *testing.T) {
+	srv := setupFlowTestServer(t)
+	flowJSON := `{"nodes": [{"id": "1", "type": "agent", "data": {"role": "Implementation", "prompt": "x", "provider": "Gemini"}}], "edges": []}`
+	if _, err := srv.db.Exec(`INSERT INTO forge_flows (name, data, status) VALUES (?, ?, ?)`, "Bad Provider", flowJSON, "active"); err != nil {
+		t.Fatalf("Failed to insert flow: %v", err)
+	}
+
+	if code := flowRequest(t, srv, http.MethodGet, "/api/flows/1/estimate"); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unsupported provider, got %d", code)
+	}
+	if code := flowRequest(t, srv, http.MethodGet, "/api/flows/999/estimate"); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing flow, got %d", code)
+	}
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// gzipMinBytes is the smallest response body worth compressing. Below it, gzip's header
+// and the CPU spent outweigh the bytes saved.
+const gzipMinBytes = 1024
+
+// GzipMiddleware gzips responses of at least gzipMinBytes for clients that send
+// Accept-Encoding: gzip, such as the ledger list and export. Smaller responses, responses
+// the handler already encoded, and WebSocket upgrades are passed through as they are.
+// Educational Comment: Whether a body is large enough is only known once the handler has
+// written it, so the first gzipMinBytes are held back until the size (or a Flush) decides.
+func GzipMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") || !acceptsGzip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Accept-Encoding")
+		gw := &gzipResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		defer gw.finish()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+// acceptsGzip reports whether the request's Accept-Encoding allows gzip.
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			return strings.ReplaceAll(params, " ", "") != "q=0"
+		}
+	}
+	return false
+}
+
+// gzipResponseWriter buffers the start of a response until it knows whether to compress it.
+// It forwards Flush and Hijack, so streaming handlers keep working through the middleware.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool         // the handler called WriteHeader
+	buf         bytes.Buffer // body held back while undecided
+	decided     bool         // the header has gone out, compressed or not
+	gz          *gzip.Writer // set once compressing
+}
+
+func (w *gzipResponseWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.status = status
+}
+
+func (w *gzipResponseWriter) Write(p []byte) (int, error) {
+	if w.decided {
+		if w.gz != nil {
+			return w.gz.Write(p)
+		}
+		return w.ResponseWriter.Write(p)
+	}
+
+	w.buf.Write(p)
+	if w.buf.Len() >= gzipMinBytes {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// decide sends the header, gzipped if compress is true and the handler hasn't
+// already set an encoding, then the body held back so far.
+func (w *gzipResponseWriter) decide(compress bool) error {
+	w.decided = true
+	header := w.Header()
+	if compress && header.Get("Content-Encoding") == "" && w.status != http.StatusNoContent && w.status != http.StatusNotModified {
+		if header.Get("Content-Type") == "" {
+			// net/http would sniff the compressed bytes instead
+			header.Set("Content-Type", http.DetectContentType(w.buf.Bytes()))
+		}
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+		w.gz = gzip.NewWriter(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+
+	held := w.buf.Bytes()
+	w.buf = bytes.Buffer{}
+	if len(held) == 0 {
+		return nil
+	}
+	var err error
+	if w.gz != nil {
+		_, err = w.gz.Write(held)
+	} else {
+		_, err = w.ResponseWriter.Write(held)
+	}
+	return err
+}
+
+// finish sends a response too small to compress, or closes the gzip stream.
+func (w *gzipResponseWriter) finish() {
+	if !w.decided {
+		if !w.wroteHeader && w.buf.Len() == 0 {
+			return // the handler wrote nothing (or hijacked the connection)
+		}
+		w.decide(false)
+		return
+	}
+	if w.gz != nil {
+		w.gz.Close()
+	}
+}
+
+// Flush sends what the handler has written so far. A response flushed before it reached
+// gzipMinBytes is treated as a stream and sent uncompressed.
+func (w *gzipResponseWriter) Flush() {
+	if !w.decided {
+		w.decide(false)
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *gzipResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	return hijacker.Hijack()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
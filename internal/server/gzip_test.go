@@ -0,0 +1,86 @@
+package server
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// serveGzip runs a handler writing body through GzipMiddleware.
+func serveGzip(body string, header map[string]string) *httptest.ResponseRecorder {
+	handler := GzipMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, body)
+	}))
+	req := httptest.NewRequest("GET", "/api/ledger", nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestGzipMiddleware_CompressesLargeResponses(t *testing.T) {
+	body := `[` + strings.Repeat(`{"flow_id":"1","model_used":"gpt-4o"},`, 200) + `{}]`
+	rr := serveGzip(body, map[string]string{"Accept-Encoding": "deflate, gzip"})
+
+	if rr.Header().Get("Content-Encoding") != "gzip" || rr.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("Expected a gzip-encoded response, got headers %v", rr.Header())
+	}
+	if rr.Body.Len() >= len(body) {
+		t.Errorf("Expected the body to shrink from %d bytes, got %d", len(body), rr.Body.Len())
+	}
+	zr, err := gzip.NewReader(rr.Body)
+	if err != nil {
+		t.Fatalf("Body isn't gzip: %v", err)
+	}
+	if got, _ := io.ReadAll(zr); string(got) != body {
+		t.Errorf("Decompressed body doesn't match what the handler wrote")
+	}
+	if rr.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected the handler's Content-Type to be kept, got %q", rr.Header().Get("Content-Type"))
+	}
+}
+
+func TestGzipMiddleware_PassesThrough(t *testing.T) {
+	large := strings.Repeat("x", 4*gzipMinBytes)
+	tests := []struct {
+		name   string
+		body   string
+		header map[string]string
+	}{
+		{"small response", `{"status":"ok"}`, map[string]string{"Accept-Encoding": "gzip"}},
+		{"client without gzip", large, nil},
+		{"gzip refused", large, map[string]string{"Accept-Encoding": "gzip;q=0, identity"}},
+		{"websocket upgrade", large, map[string]string{"Accept-Encoding": "gzip", "Upgrade": "websocket"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := serveGzip(tt.body, tt.header)
+			if rr.Header().Get("Content-Encoding") != "" {
+				t.Errorf("Expected no Content-Encoding, got %q", rr.Header().Get("Content-Encoding"))
+			}
+			if rr.Body.String() != tt.body {
+				t.Errorf("Expected the body unchanged, got %d bytes", rr.Body.Len())
+			}
+		})
+	}
+}
+
+func TestGzipMiddleware_KeepsStatus(t *testing.T) {
+	handler := GzipMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSONError(w, http.StatusNotFound, "Flow not found")
+	}))
+	req := httptest.NewRequest("GET", "/api/flows/9", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound || !strings.Contains(rr.Body.String(), "Flow not found") {
+		t.Errorf("Expected the handler's 404 uncompressed, got %d: %q", rr.Code, rr.Body.String())
+	}
+}
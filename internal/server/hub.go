@@ -1,11 +1,17 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
-	"log"
 	"sync"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/logging"
 )
 
+// replayBufferSize is how many of each flow's latest events the hub keeps for clients that
+// reconnect mid-run. It stays under sendBufferSize so a full replay fits in a new client's buffer.
+const replayBufferSize = 128
+
 // Hub maintains the set of active clients and broadcasts messages to clients
 type Hub struct {
 	clients    map[*Client]bool
@@ -13,6 +19,24 @@ type Hub struct {
 	register   chan *Client
 	unregister chan *Client
 	mu         sync.RWMutex
+
+	// done is closed when the hub loop returns, so senders never wait on a hub that has stopped
+	done     chan struct{}
+	doneOnce sync.Once
+
+	// streams numbers each flow's events and keeps the latest for replay. Only the hub loop uses it.
+	streams map[int]*flowStream
+}
+
+// flowStream is the sequence counter and replay buffer of one flow's events.
+type flowStream struct {
+	seq    int64
+	events []sequencedEvent // oldest first, at most replayBufferSize
+}
+
+type sequencedEvent struct {
+	seq     int64
+	message []byte
 }
 
 // NewHub creates a new Hub instance
@@ -22,57 +46,180 @@ func NewHub() *Hub {
 		broadcast:  make(chan []byte, 256),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
+		done:       make(chan struct{}),
+		streams:    make(map[int]*flowStream),
 	}
 }
 
 // Run starts the hub's main loop
 func (h *Hub) Run() {
+	h.RunContext(context.Background())
+}
+
+// RunContext runs the hub's main loop until ctx is cancelled,
+// then disconnects every remaining client.
+func (h *Hub) RunContext(ctx context.Context) {
+	defer h.doneOnce.Do(func() { close(h.done) })
 	for {
 		select {
+		case <-ctx.Done():
+			h.mu.Lock()
+			for client := range h.clients {
+				close(client.send)
+				delete(h.clients, client)
+			}
+			h.mu.Unlock()
+			return
+
 		case client := <-h.register:
 			h.mu.Lock()
 			h.clients[client] = true
+			if client.resumeFlowID != 0 {
+				h.replay(client)
+			}
 			h.mu.Unlock()
-			log.Println("Client connected")
+			logging.Infof("Client connected")
 
 		case client := <-h.unregister:
 			h.mu.Lock()
 			if _, ok := h.clients[client]; ok {
 				delete(h.clients, client)
 				close(client.send)
-				log.Println("Client disconnected")
+				logging.Infof("Client disconnected")
 			}
 			h.mu.Unlock()
 
 		case message := <-h.broadcast:
-			h.mu.RLock()
+			// Never wait on a client: one that has fallen a full buffer behind is dropped,
+			// so it can't hold up flow updates for everyone else
+			message = h.sequence(message)
+			h.mu.Lock()
 			for client := range h.clients {
 				select {
 				case client.send <- message:
 				default:
-					close(client.send)
-					delete(h.clients, client)
+					h.evict(client)
 				}
 			}
-			h.mu.RUnlock()
+			h.mu.Unlock()
+		}
+	}
+}
+
+// Register hands a new client to the hub loop. It reports false if the hub has
+// already stopped, in which case the caller should close the connection itself.
+func (h *Hub) Register(client *Client) bool {
+	select {
+	case h.register <- client:
+		return true
+	case <-h.done:
+		return false
+	}
+}
+
+// Unregister removes a client. It returns at once if the hub has stopped,
+// since the loop already closed every client on its way out.
+func (h *Hub) Unregister(client *Client) {
+	select {
+	case h.unregister <- client:
+	case <-h.done:
+	}
+}
+
+// evict drops a client whose send buffer has overflowed and closes its connection,
+// which also unblocks a writePump stuck writing to it. The caller must hold h.mu.
+// The browser reconnects and reloads state, which beats silently missing updates.
+func (h *Hub) evict(client *Client) {
+	if _, ok := h.clients[client]; !ok {
+		return // already gone
+	}
+	delete(h.clients, client)
+	close(client.send)
+	if client.conn != nil {
+		client.conn.Close()
+	}
+	logging.Warnf("Dropped a WebSocket client that fell %d messages behind", cap(client.send))
+}
+
+// sequence stamps a flow event (any message whose payload has a flowId) with the next "seq"
+// of its flow and keeps it for replay. Other messages are returned unchanged.
+func (h *Hub) sequence(message []byte) []byte {
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(message, &envelope); err != nil {
+		return message
+	}
+	var payload struct {
+		FlowID *int `json:"flowId"`
+	}
+	if err := json.Unmarshal(envelope["payload"], &payload); err != nil || payload.FlowID == nil {
+		return message
+	}
+
+	stream := h.streams[*payload.FlowID]
+	if stream == nil {
+		stream = &flowStream{}
+		h.streams[*payload.FlowID] = stream
+	}
+	stream.seq++
+	envelope["seq"], _ = json.Marshal(stream.seq)
+	sequenced, err := json.Marshal(envelope)
+	if err != nil {
+		return message
+	}
+
+	stream.events = append(stream.events, sequencedEvent{seq: stream.seq, message: sequenced})
+	if len(stream.events) > replayBufferSize {
+		stream.events = stream.events[len(stream.events)-replayBufferSize:]
+	}
+	return sequenced
+}
+
+// replay queues the events of the client's flow that came after the last one it saw.
+// If some of them have already left the buffer, a REPLAY_INCOMPLETE message goes first
+// so the client knows to reload the flow's status instead. The caller must hold h.mu.
+func (h *Hub) replay(client *Client) {
+	stream := h.streams[client.resumeFlowID]
+	if stream == nil {
+		return
+	}
+	if len(stream.events) > 0 && stream.events[0].seq > client.resumeAfterSeq+1 {
+		notice, _ := json.Marshal(map[string]interface{}{
+			"type": "REPLAY_INCOMPLETE",
+			"payload": map[string]interface{}{
+				"flowId":    client.resumeFlowID,
+				"oldestSeq": stream.events[0].seq,
+			},
+		})
+		client.send <- notice // a new client's buffer is empty and larger than the replay
+	}
+	for _, event := range stream.events {
+		if event.seq > client.resumeAfterSeq {
+			client.send <- event.message
 		}
 	}
 }
 
-// Broadcast sends a message to all connected clients
+// Broadcast sends a message to all connected clients.
+// Once the hub has stopped the message is dropped, as there is no one left to send it to.
 func (h *Hub) Broadcast(message []byte) {
-	h.broadcast <- message
+	select {
+	case h.broadcast <- message:
+	case <-h.done:
+	}
 }
 
-// SendToClient sends a message to a specific client
+// SendToClient sends a message to a specific client, dropping it if its buffer is full.
+// Messages to a client that has already disconnected are discarded.
 func (h *Hub) SendToClient(client *Client, message []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.clients[client]; !ok {
+		return
+	}
 	select {
 	case client.send <- message:
 	default:
-		h.mu.Lock()
-		close(client.send)
-		delete(h.clients, client)
-		h.mu.Unlock()
+		h.evict(client)
 	}
 }
 
@@ -90,7 +237,7 @@ func (h *Hub) BroadcastFlowStatus(flowID int, status, nodeID, timestamp string)
 	}
 	data, err := json.Marshal(message)
 	if err != nil {
-		log.Printf("Error marshaling flow status: %v", err)
+		logging.Errorf("Error marshaling flow status: %v", err)
 		return
 	}
 	h.Broadcast(data)
@@ -107,7 +254,7 @@ func (h *Hub) BroadcastLedgerUpdate(entryID int) {
 	}
 	data, err := json.Marshal(message)
 	if err != nil {
-		log.Printf("Error marshaling ledger update: %v", err)
+		logging.Errorf("Error marshaling ledger update: %v", err)
 		return
 	}
 	h.Broadcast(data)
@@ -124,7 +271,7 @@ func (h *Hub) BroadcastOptimizationAvailable(optimizationID int) {
 	}
 	data, err := json.Marshal(message)
 	if err != nil {
-		log.Printf("Error marshaling optimization notification: %v", err)
+		logging.Errorf("Error marshaling optimization notification: %v", err)
 		return
 	}
 	h.Broadcast(data)
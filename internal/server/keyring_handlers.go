@@ -3,7 +3,9 @@ package server
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/mikejsmith1985/forge-orchestrator/internal/security"
 	"github.com/zalando/go-keyring"
@@ -21,6 +23,23 @@ type SetAPIKeyResponse struct {
 	Message string `json:"message"`
 }
 
+// RotateAPIKeyRequest is the payload of POST /api/keys/{provider}/rotate.
+type RotateAPIKeyRequest struct {
+	Key string `json:"key"`
+}
+
+// RotateAPIKeyResponse reports a rotation and until when the old key is kept.
+type RotateAPIKeyResponse struct {
+	Status             string    `json:"status"`
+	Message            string    `json:"message"`
+	PreviousValidUntil time.Time `json:"previous_valid_until"`
+}
+
+// DeleteAllAPIKeysResponse reports how many stored keys DELETE /api/keys removed.
+type DeleteAllAPIKeysResponse struct {
+	Cleared int `json:"cleared"`
+}
+
 // KeyStatus represents the status of a single API key provider.
 type KeyStatus struct {
 	Provider string `json:"provider"`
@@ -49,6 +68,7 @@ func (s *Server) handleSetAPIKey(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Failed to set API key: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	security.RecordKeyAudit(s.db, req.Provider, security.KeyActionSet)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -61,11 +81,9 @@ func (s *Server) handleSetAPIKey(w http.ResponseWriter, r *http.Request) {
 // handleGetAPIKeyStatus checks which API keys are present in the keyring.
 // Returns a structured response with lowercase provider names for frontend consistency.
 func (s *Server) handleGetAPIKeyStatus(w http.ResponseWriter, r *http.Request) {
-	// List of providers to check
-	providers := []string{"Anthropic", "OpenAI", "Google"}
-	keys := make([]KeyStatus, 0, len(providers))
+	keys := make([]KeyStatus, 0, len(security.KnownProviders))
 
-	for _, p := range providers {
+	for _, p := range security.KnownProviders {
 		_, err := security.GetAPIKey(p)
 		isSet := err == nil
 
@@ -95,6 +113,77 @@ func (s *Server) handleDeleteAPIKey(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Failed to delete API key: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	security.RecordKeyAudit(s.db, provider, security.KeyActionDelete)
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// handleRotateAPIKey swaps a provider's stored key for a new one without a moment where
+// no key is set. A key that fails validation is refused and the old key stays in place.
+func (s *Server) handleRotateAPIKey(w http.ResponseWriter, r *http.Request) {
+	provider := r.PathValue("provider")
+	var req RotateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := security.ValidateAPIKey(provider, req.Key); err != nil {
+		http.Error(w, "Invalid API key: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := security.RotateAPIKey(provider, req.Key); err != nil {
+		if err == keyring.ErrNotFound {
+			http.Error(w, "Key not found; use POST /api/keys to set a first key", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to rotate API key: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	security.RecordKeyAudit(s.db, provider, security.KeyActionRotate)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RotateAPIKeyResponse{
+		Status:             "ok",
+		Message:            "API key rotated successfully",
+		PreviousValidUntil: time.Now().Add(security.RotationGrace).UTC(),
+	})
+}
+
+// handleDeleteAllAPIKeys removes the keys of every known provider, for a clean reset
+// such as switching accounts.
+func (s *Server) handleDeleteAllAPIKeys(w http.ResponseWriter, r *http.Request) {
+	cleared, err := security.DeleteAllAPIKeys()
+	if cleared > 0 {
+		// Recorded even on error: the keys cleared before it are gone
+		security.RecordKeyAudit(s.db, "all", security.KeyActionDeleteAll)
+	}
+	if err != nil {
+		http.Error(w, "Failed to delete API keys: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(DeleteAllAPIKeysResponse{Cleared: cleared})
+}
+
+// handleGetKeyAudit returns when keys were set, rotated and deleted, newest first.
+// Optional query parameter limit: at most this many entries (default 100).
+func (s *Server) handleGetKeyAudit(w http.ResponseWriter, r *http.Request) {
+	limit := 100
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if val, err := strconv.Atoi(l); err == nil && val > 0 {
+			limit = val
+		}
+	}
+
+	entries, err := security.ListKeyAudit(s.db, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"entries": entries})
+}
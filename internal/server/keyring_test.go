@@ -163,4 +163,115 @@ func TestKeyringHandlers(t *testing.T) {
 			t.Errorf("Expected ErrNotFound, got %v", err)
 		}
 	})
+
+	t.Run("DeleteAllAPIKeys", func(t *testing.T) {
+		keyring.MockInit()
+		security.SetAPIKey("Anthropic", "sk-anthropic")
+		security.SetAPIKey("OpenAI", "sk-openai")
+
+		routes := NewServer(nil).RegisterRoutes()
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, httptest.NewRequest("DELETE", "/api/keys", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var deleted DeleteAllAPIKeysResponse
+		if err := json.NewDecoder(w.Body).Decode(&deleted); err != nil || deleted.Cleared != 2 {
+			t.Errorf("Expected 2 keys cleared, got %+v (%v)", deleted, err)
+		}
+
+		// Every provider now shows as unconfigured
+		w = httptest.NewRecorder()
+		routes.ServeHTTP(w, httptest.NewRequest("GET", "/api/keys/status", nil))
+		var status KeyStatusResponse
+		if err := json.NewDecoder(w.Body).Decode(&status); err != nil || len(status.Keys) != len(security.KnownProviders) {
+			t.Fatalf("Expected a status for every provider, got %+v (%v)", status, err)
+		}
+		for _, key := range status.Keys {
+			if key.IsSet {
+				t.Errorf("Expected %s to be unconfigured after clearing all keys", key.Provider)
+			}
+		}
+
+		// Clearing again finds nothing to clear
+		w = httptest.NewRecorder()
+		routes.ServeHTTP(w, httptest.NewRequest("DELETE", "/api/keys", nil))
+		if err := json.NewDecoder(w.Body).Decode(&deleted); err != nil || deleted.Cleared != 0 {
+			t.Errorf("Expected nothing left to clear, got %+v (%v)", deleted, err)
+		}
+	})
+
+	t.Run("RotateAPIKey", func(t *testing.T) {
+		keyring.MockInit()
+		security.SetAPIKey("OpenAI", "sk-old")
+		routes := NewServer(nil).RegisterRoutes()
+		rotate := func(provider, key string) *httptest.ResponseRecorder {
+			body, _ := json.Marshal(RotateAPIKeyRequest{Key: key})
+			req := httptest.NewRequest("POST", "/api/keys/"+provider+"/rotate", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			routes.ServeHTTP(w, req)
+			return w
+		}
+
+		// A key for the wrong provider is refused and the old key stays in place
+		if w := rotate("OpenAI", "AIza-google-key"); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for an invalid key, got %d: %s", w.Code, w.Body.String())
+		}
+		if key, _ := security.GetAPIKey("OpenAI"); key != "sk-old" {
+			t.Errorf("Expected the old key after a failed rotation, got %q", key)
+		}
+
+		w := rotate("OpenAI", "sk-new")
+		var resp RotateAPIKeyResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); w.Code != http.StatusOK || err != nil || resp.PreviousValidUntil.IsZero() {
+			t.Fatalf("Expected the rotation to succeed, got %d: %+v (%v)", w.Code, resp, err)
+		}
+		if key, _ := security.GetAPIKey("OpenAI"); key != "sk-new" {
+			t.Errorf("Expected the new key to be stored, got %q", key)
+		}
+		if previous, _ := security.PreviousAPIKey("OpenAI"); previous != "sk-old" {
+			t.Errorf("Expected the old key kept for in-flight calls, got %q", previous)
+		}
+
+		// There's nothing to rotate for a provider without a key
+		if w := rotate("Anthropic", "sk-ant-new"); w.Code != http.StatusNotFound {
+			t.Errorf("Expected 404 rotating a missing key, got %d", w.Code)
+		}
+	})
+
+	t.Run("AuditLog", func(t *testing.T) {
+		keyring.MockInit()
+		srv := setupFlowTestServer(t)
+		routes := srv.RegisterRoutes()
+
+		body, _ := json.Marshal(SetAPIKeyRequest{Provider: "OpenAI", Key: "sk-audited-secret"})
+		req := httptest.NewRequest("POST", "/api/keys", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		routes.ServeHTTP(httptest.NewRecorder(), req)
+		routes.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("DELETE", "/api/keys/OpenAI", nil))
+
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, httptest.NewRequest("GET", "/api/keys/audit", nil))
+		if strings.Contains(w.Body.String(), "sk-audited-secret") {
+			t.Fatal("The audit log must never contain the key")
+		}
+		var audit struct {
+			Entries []security.KeyAuditEntry `json:"entries"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&audit); err != nil || len(audit.Entries) != 2 {
+			t.Fatalf("Expected 2 audit entries, got %+v (%v)", audit, err)
+		}
+		// Newest first
+		if e := audit.Entries[0]; e.Provider != "OpenAI" || e.Action != security.KeyActionDelete || e.Timestamp.IsZero() {
+			t.Errorf("Expected the delete first, got %+v", e)
+		}
+		if e := audit.Entries[1]; e.Provider != "OpenAI" || e.Action != security.KeyActionSet {
+			t.Errorf("Expected the set second, got %+v", e)
+		}
+
+		if _, err := srv.db.Exec(`DELETE FROM key_audit`); err == nil {
+			t.Error("Expected key_audit to refuse deletes")
+		}
+	})
 }
@@ -3,46 +3,58 @@ package server
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/mikejsmith1985/forge-orchestrator/internal/config"
 	"github.com/mikejsmith1985/forge-orchestrator/internal/data"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/llm"
 	"github.com/mikejsmith1985/forge-orchestrator/internal/tokenizer"
 )
 
 // LedgerEntryResponse is the JSON API representation of a ledger entry.
 // It uses string for timestamp to ensure consistent JSON serialization.
 type LedgerEntryResponse struct {
-	ID           int64   `json:"id"`
-	Timestamp    string  `json:"timestamp"`
-	FlowID       string  `json:"flow_id"`
-	ModelUsed    string  `json:"model_used"`
-	AgentRole    string  `json:"agent_role"`
-	PromptHash   string  `json:"prompt_hash"`
-	InputTokens  int     `json:"input_tokens"`
-	OutputTokens int     `json:"output_tokens"`
-	TotalCostUSD float64 `json:"total_cost_usd"`
-	LatencyMs    int     `json:"latency_ms"`
-	Status       string  `json:"status"`
-	ErrorMessage string  `json:"error_message,omitempty"`
+	ID             int64   `json:"id"`
+	Timestamp      string  `json:"timestamp"`
+	FlowID         string  `json:"flow_id"`
+	ModelUsed      string  `json:"model_used"`
+	AgentRole      string  `json:"agent_role"`
+	PromptHash     string  `json:"prompt_hash"`
+	InputTokens    int     `json:"input_tokens"`
+	OutputTokens   int     `json:"output_tokens"`
+	TotalCostUSD   float64 `json:"total_cost_usd"`
+	LatencyMs      int     `json:"latency_ms"`
+	Status         string  `json:"status"`
+	ErrorMessage   string  `json:"error_message,omitempty"`
+	Environment    string  `json:"environment"`
+	RequestID      string  `json:"request_id,omitempty"`
+	IdempotencyKey string  `json:"idempotency_key,omitempty"`
+	ReplayOf       int64   `json:"replay_of,omitempty"`
 }
 
 // ToResponse converts a TokenLedgerEntry to its API response format.
 func ToLedgerResponse(entry data.TokenLedgerEntry) LedgerEntryResponse {
 	return LedgerEntryResponse{
-		ID:           entry.ID,
-		Timestamp:    entry.Timestamp.Format(time.RFC3339),
-		FlowID:       entry.FlowID,
-		ModelUsed:    entry.ModelUsed,
-		AgentRole:    entry.AgentRole,
-		PromptHash:   entry.PromptHash,
-		InputTokens:  entry.InputTokens,
-		OutputTokens: entry.OutputTokens,
-		TotalCostUSD: entry.TotalCostUSD,
-		LatencyMs:    entry.LatencyMs,
-		Status:       entry.Status,
-		ErrorMessage: entry.ErrorMessage,
+		ID:             entry.ID,
+		Timestamp:      entry.Timestamp.Format(time.RFC3339),
+		FlowID:         entry.FlowID,
+		ModelUsed:      entry.ModelUsed,
+		AgentRole:      entry.AgentRole,
+		PromptHash:     entry.PromptHash,
+		InputTokens:    entry.InputTokens,
+		OutputTokens:   entry.OutputTokens,
+		TotalCostUSD:   entry.TotalCostUSD,
+		LatencyMs:      entry.LatencyMs,
+		Status:         entry.Status,
+		ErrorMessage:   entry.ErrorMessage,
+		Environment:    entry.Environment,
+		RequestID:      entry.RequestID,
+		IdempotencyKey: entry.IdempotencyKey,
+		ReplayOf:       entry.ReplayOf,
 	}
 }
 
@@ -58,6 +70,8 @@ type LedgerEntryRequest struct {
 	LatencyMs    int     `json:"latency_ms"`
 	Status       string  `json:"status"`
 	ErrorMessage string  `json:"error_message,omitempty"`
+	Environment  string  `json:"environment,omitempty"`
+	RequestID    string  `json:"request_id,omitempty"`
 }
 
 // ToEntry converts a LedgerEntryRequest to a TokenLedgerEntry.
@@ -74,49 +88,150 @@ func (r LedgerEntryRequest) ToEntry() data.TokenLedgerEntry {
 		LatencyMs:    r.LatencyMs,
 		Status:       r.Status,
 		ErrorMessage: r.ErrorMessage,
+		Environment:  r.Environment,
+		RequestID:    r.RequestID,
+	}
+}
+
+// ledgerEnvironment returns the normalized ?environment= filter, or "" to include every environment.
+func ledgerEnvironment(r *http.Request) string {
+	if env := r.URL.Query().Get("environment"); env != "" {
+		return data.NormalizeEnvironment(env)
 	}
+	return ""
 }
 
 // handleCreateLedgerEntry inserts a new entry into the token_ledger table.
+// With an Idempotency-Key header, a repeat of an earlier request returns the entry it
+// created (200) instead of logging the call again; a new key returns the created entry (201).
 func (s *Server) handleCreateLedgerEntry(w http.ResponseWriter, r *http.Request) {
 	var req LedgerEntryRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	entry := req.ToEntry()
+	entry.IdempotencyKey = strings.TrimSpace(r.Header.Get("Idempotency-Key"))
 	ledgerService := data.NewLedgerService(s.db)
+	if entry.IdempotencyKey != "" {
+		if existing, err := ledgerService.GetEntryByIdempotencyKey(entry.IdempotencyKey); err == nil {
+			writeLedgerEntry(w, http.StatusOK, existing)
+			return
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
 	if err := ledgerService.LogUsage(entry); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		// A concurrent request with the same key may have won the insert
+		if entry.IdempotencyKey != "" {
+			if existing, lookupErr := ledgerService.GetEntryByIdempotencyKey(entry.IdempotencyKey); lookupErr == nil {
+				writeLedgerEntry(w, http.StatusOK, existing)
+				return
+			}
+		}
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if entry.IdempotencyKey == "" {
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+	created, err := ledgerService.GetEntryByIdempotencyKey(entry.IdempotencyKey)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	writeLedgerEntry(w, http.StatusCreated, created)
+}
 
-	w.WriteHeader(http.StatusCreated)
+// writeLedgerEntry answers with a single ledger entry as JSON.
+func writeLedgerEntry(w http.ResponseWriter, status int, entry *data.TokenLedgerEntry) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ToLedgerResponse(*entry))
+}
+
+// handleGetLedgerEntry returns one ledger entry by ID.
+func (s *Server) handleGetLedgerEntry(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+
+	entry, err := data.NewLedgerService(s.db).GetEntry(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeJSONError(w, http.StatusNotFound, "Ledger entry not found")
+		return
+	} else if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeLedgerEntry(w, http.StatusOK, entry)
+}
+
+// handleGetLedgerPrompt returns the prompt text stored for a ledger entry, for auditing.
+// Prompts are only kept while ledger.store_prompts is on, so most entries answer 404.
+func (s *Server) handleGetLedgerPrompt(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+
+	prompt, err := data.NewLedgerService(s.db).GetPrompt(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeJSONError(w, http.StatusNotFound, "No prompt was stored for this entry")
+		return
+	} else if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(prompt)
 }
 
 // handleEstimateTokens estimates the number of tokens in a given text string.
 // It uses tiktoken for accurate OpenAI tokenization or falls back to heuristic
-// for other providers.
+// for other providers. A messages array is counted as a chat request instead,
+// including each message's role and formatting overhead.
 func (s *Server) handleEstimateTokens(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		Text     string `json:"text"`
-		Provider string `json:"provider"`
-		Model    string `json:"model"`
+		Text     string              `json:"text"`
+		Messages []tokenizer.Message `json:"messages,omitempty"`
+		Provider string              `json:"provider"`
+		Model    string              `json:"model"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Text != "" && len(req.Messages) > 0 {
+		writeJSONError(w, http.StatusBadRequest, "Send either text or messages, not both")
 		return
 	}
 
-	estimator := tokenizer.NewEstimator()
-	result := estimator.Estimate(req.Text, req.Provider, req.Model)
+	var result tokenizer.EstimationResult
+	if len(req.Messages) > 0 {
+		result = tokenizer.Default().EstimateMessages(req.Messages, req.Provider, req.Model)
+	} else {
+		result = tokenizer.Default().Estimate(req.Text, req.Provider, req.Model)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(result)
 }
 
 // handleGetLedger retrieves the history of agent executions.
+// An optional ?environment= limits the list to one environment, and ?request_id=
+// to the calls of one flow or command run.
+// Educational Comment: Each flow execution gets a fresh request ID, so it doubles as the
+// run ID: ?run_id= is accepted as another name for ?request_id= to pick out a single run.
 func (s *Server) handleGetLedger(w http.ResponseWriter, r *http.Request) {
 	limit := 50
 	if l := r.URL.Query().Get("limit"); l != "" {
@@ -125,18 +240,56 @@ func (s *Server) handleGetLedger(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	requestID := r.URL.Query().Get("request_id")
+	if requestID == "" {
+		requestID = r.URL.Query().Get("run_id")
+	}
+
+	entries, err := s.recentLedgerEntries(limit, ledgerEnvironment(r), requestID)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(entries); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+}
+
+// recentLedgerEntries returns the newest limit entries for env ("" = every environment), newest first.
+// A non-empty requestID keeps only that run's entries.
+func (s *Server) recentLedgerEntries(limit int, env, requestID string) ([]LedgerEntryResponse, error) {
+	var conditions []string
+	args := []any{}
+	if env != "" {
+		conditions = append(conditions, "environment = ?")
+		args = append(args, env)
+	}
+	if requestID != "" {
+		conditions = append(conditions, "request_id = ?")
+		args = append(args, requestID)
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, limit)
+
 	query := `
 		SELECT id, timestamp, flow_id, model_used, agent_role, prompt_hash, 
-		       input_tokens, output_tokens, total_cost_usd, latency_ms, status, error_message
+		       input_tokens, output_tokens, total_cost_usd, latency_ms, status, error_message, environment, COALESCE(request_id, ''),
+		       COALESCE(replay_of, 0)
 		FROM token_ledger
+		` + where + `
 		ORDER BY timestamp DESC
 		LIMIT ?
 	`
 
-	rows, err := s.db.Query(query, limit)
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -146,26 +299,128 @@ func (s *Server) handleGetLedger(w http.ResponseWriter, r *http.Request) {
 		var errMsg sql.NullString
 		if err := rows.Scan(
 			&e.ID, &e.Timestamp, &e.FlowID, &e.ModelUsed, &e.AgentRole, &e.PromptHash,
-			&e.InputTokens, &e.OutputTokens, &e.TotalCostUSD, &e.LatencyMs, &e.Status, &errMsg,
+			&e.InputTokens, &e.OutputTokens, &e.TotalCostUSD, &e.LatencyMs, &e.Status, &errMsg, &e.Environment, &e.RequestID,
+			&e.ReplayOf,
 		); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+			return nil, err
 		}
 		if errMsg.Valid {
 			e.ErrorMessage = errMsg.String
 		}
 		entries = append(entries, ToLedgerResponse(e))
 	}
+	return entries, rows.Err()
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(entries); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+// defaultDailyBudgetUSD is the daily spending limit when Budget.DailyLimitUSD is unset.
+const defaultDailyBudgetUSD = 10.00
+
+// dailyLimitUSD returns Budget.DailyLimitUSD, or defaultDailyBudgetUSD when no daily limit is configured.
+func dailyLimitUSD(b config.BudgetConfig) float64 {
+	if b.DailyLimitUSD > 0 {
+		return b.DailyLimitUSD
+	}
+	return defaultDailyBudgetUSD
+}
+
+// budgetConfig returns the Budget section of the config, or the zero value if it can't be read.
+func budgetConfig() config.BudgetConfig {
+	if cfg, err := config.Get(); err == nil {
+		return cfg.Budget
+	}
+	return config.BudgetConfig{}
+}
+
+// budgetDay returns the UTC bounds of "today" in the configured Budget.TimeZone.
+func budgetDay() (string, string) {
+	return data.DayRange(time.Now(), budgetConfig().Location())
+}
+
+// spentToday sums today's ledger costs across every environment, since all of
+// it is real money. Errors count as zero spend so a broken ledger never blocks the budget meter.
+func (s *Server) spentToday() float64 {
+	start, end := budgetDay()
+	return s.spentBetween(start, end, "")
+}
+
+// ledgerWindow builds the WHERE clause selecting ledger rows in [start, end), which are
+// UTC strings from data.DayRange or data.MonthRange, for env ("" = every environment).
+func ledgerWindow(start, end, env string) (string, []any) {
+	where := `WHERE datetime(timestamp) >= ? AND datetime(timestamp) < ?`
+	args := []any{start, end}
+	if env != "" {
+		where += ` AND environment = ?`
+		args = append(args, env)
+	}
+	return where, args
+}
+
+// spentBetween sums ledger costs in the ledgerWindow. Errors count as zero spend.
+func (s *Server) spentBetween(start, end, env string) float64 {
+	var spent float64
+	where, args := ledgerWindow(start, end, env)
+	if err := s.db.QueryRow(`SELECT COALESCE(SUM(total_cost_usd), 0) FROM token_ledger `+where, args...).Scan(&spent); err != nil {
+		return 0
+	}
+	return spent
+}
+
+// tokensBetween sums input and output tokens in the ledgerWindow. Errors count as zero.
+func (s *Server) tokensBetween(start, end, env string) (input, output int) {
+	where, args := ledgerWindow(start, end, env)
+	query := `SELECT COALESCE(SUM(input_tokens), 0), COALESCE(SUM(output_tokens), 0) FROM token_ledger ` + where
+	if err := s.db.QueryRow(query, args...).Scan(&input, &output); err != nil {
+		return 0, 0
+	}
+	return input, output
+}
+
+// callCounts tallies today's LLM calls by outcome.
+type callCounts struct {
+	Total   int
+	Success int
+	Failure int
+}
+
+// callCountsToday counts today's ledger entries for env ("" = every environment).
+// SUCCESS and CACHE_HIT count as successes; anything else (FAILED, TIMEOUT, CAPPED, ...)
+// counts as a failure. Errors yield zero counts.
+func (s *Server) callCountsToday(env string) callCounts {
+	var c callCounts
+	start, end := budgetDay()
+	where, args := ledgerWindow(start, end, env)
+	query := `
+		SELECT COUNT(*),
+		       COALESCE(SUM(CASE WHEN status IN ('SUCCESS', 'CACHE_HIT') THEN 1 ELSE 0 END), 0)
+		FROM token_ledger ` + where
+	if err := s.db.QueryRow(query, args...).Scan(&c.Total, &c.Success); err != nil {
+		return callCounts{}
+	}
+	c.Failure = c.Total - c.Success
+	return c
+}
+
+// Cost models, as reported in cost_model
+const (
+	costModelPerToken  = "per_token"
+	costModelPerPrompt = "per_prompt"
+)
+
+// providerPricing looks up how a provider bills. Tests replace it to exercise prompt-billed providers.
+var providerPricing = llm.Pricing
+
+// costModel names a provider's PrimaryCostUnit for API responses.
+func costModel(unit llm.PrimaryCostUnit) string {
+	if unit == llm.CostUnitPrompt {
+		return costModelPerPrompt
 	}
+	return costModelPerToken
 }
 
 // BudgetResponse represents the current budget status for the UI.
 // Task 4.2: This provides the Dynamic Budget Meter data.
+// Educational Comment: cost_model says what the model's provider actually bills for,
+// so the UI can label the meter in prompts or tokens instead of guessing.
 type BudgetResponse struct {
 	TotalBudget      float64 `json:"totalBudget"`
 	SpentToday       float64 `json:"spentToday"`
@@ -173,10 +428,22 @@ type BudgetResponse struct {
 	RemainingPrompts int     `json:"remainingPrompts"`
 	CostUnit         string  `json:"costUnit"`
 	Model            string  `json:"model"`
+	CallsToday       int     `json:"callsToday"`
+	SuccessCount     int     `json:"successCount"`
+	FailureCount     int     `json:"failureCount"`
+	Environment      string  `json:"environment,omitempty"` // Set when the figures are for one environment only
+
+	CostModel    string `json:"cost_model"`    // per_token or per_prompt
+	InputTokens  int    `json:"input_tokens"`  // Prompt tokens sent today
+	OutputTokens int    `json:"output_tokens"` // Response tokens received today
+	// RemainingTokens is how many tokens the remaining budget buys at the model's average
+	// of input and output rates. It is omitted for per_prompt models, where tokens aren't billed.
+	RemainingTokens *int `json:"remainingTokens,omitempty"`
 }
 
 // handleGetBudget returns the current budget status for the selected model.
 // Task 4.2: Provides data for the Dynamic Budget Meter UI.
+// An optional ?environment= (e.g. prod) leaves other environments' spend out of the figures.
 func (s *Server) handleGetBudget(w http.ResponseWriter, r *http.Request) {
 	// Get optional model parameter (defaults to current active model)
 	model := r.URL.Query().Get("model")
@@ -185,36 +452,117 @@ func (s *Server) handleGetBudget(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Calculate spent today from ledger
-	var spentToday float64
-	query := `SELECT COALESCE(SUM(total_cost_usd), 0) FROM token_ledger WHERE date(timestamp) = date('now')`
-	if err := s.db.QueryRow(query).Scan(&spentToday); err != nil {
-		spentToday = 0
-	}
+	env := ledgerEnvironment(r)
+	start, end := budgetDay()
+	spentToday := s.spentBetween(start, end, env)
 
-	// Default budget configuration (could be made configurable)
-	totalBudget := 10.00 // $10 daily budget
+	totalBudget := dailyLimitUSD(budgetConfig())
 	remainingBudget := totalBudget - spentToday
 	if remainingBudget < 0 {
 		remainingBudget = 0
 	}
 
+	pricing := providerPricing(providerForModel(model))
+
 	// Calculate remaining prompts based on average cost per prompt
 	// Using $0.01 per prompt as a reasonable estimate for GPT-4o
 	avgCostPerPrompt := 0.01
 	if model == "gpt-3.5-turbo" {
 		avgCostPerPrompt = 0.002
 	}
+	if pricing.PrimaryCostUnit == llm.CostUnitPrompt && pricing.PromptRate > 0 {
+		// Every prompt costs the same, so this is exact rather than an estimate
+		avgCostPerPrompt = pricing.PromptRate
+	}
 	remainingPrompts := int(remainingBudget / avgCostPerPrompt)
 
+	var remainingTokens *int
+	if perMillion := (pricing.InputRate + pricing.OutputRate) / 2; pricing.PrimaryCostUnit != llm.CostUnitPrompt && perMillion > 0 {
+		tokens := int(remainingBudget / perMillion * 1_000_000)
+		remainingTokens = &tokens
+	}
+
+	counts := s.callCountsToday(env)
+	inputTokens, outputTokens := s.tokensBetween(start, end, env)
+
 	response := BudgetResponse{
 		TotalBudget:      totalBudget,
 		SpentToday:       spentToday,
 		RemainingBudget:  remainingBudget,
 		RemainingPrompts: remainingPrompts,
-		CostUnit:         "TOKEN", // Default to TOKEN for most models
+		CostUnit:         string(pricing.PrimaryCostUnit),
 		Model:            model,
+		CallsToday:       counts.Total,
+		SuccessCount:     counts.Success,
+		FailureCount:     counts.Failure,
+		Environment:      env,
+		CostModel:        costModel(pricing.PrimaryCostUnit),
+		InputTokens:      inputTokens,
+		OutputTokens:     outputTokens,
+		RemainingTokens:  remainingTokens,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
+
+// MonthlyBudgetResponse is the month-to-date view of the budget meter.
+type MonthlyBudgetResponse struct {
+	Month           string  `json:"month"` // e.g. "2025-03", in the configured time zone
+	MonthlyLimit    float64 `json:"monthlyLimit"`
+	SpentThisMonth  float64 `json:"spentThisMonth"`
+	RemainingBudget float64 `json:"remainingBudget"`
+	TimeZone        string  `json:"timeZone"`
+	Environment     string  `json:"environment,omitempty"`
+	CostModel       string  `json:"cost_model"`    // per_token or per_prompt, for ?model= (default gpt-4o)
+	InputTokens     int     `json:"input_tokens"`  // Prompt tokens sent this month
+	OutputTokens    int     `json:"output_tokens"` // Response tokens received this month
+}
+
+// monthlyLimitUSD returns Budget.MonthlyLimitUSD, or the daily limit for
+// every day of now's month when no monthly limit is configured.
+func monthlyLimitUSD(b config.BudgetConfig, now time.Time) float64 {
+	if b.MonthlyLimitUSD > 0 {
+		return b.MonthlyLimitUSD
+	}
+	// Day 0 of next month normalises to the last day of this one
+	daysInMonth := time.Date(now.Year(), now.Month()+1, 0, 0, 0, 0, 0, now.Location()).Day()
+	return dailyLimitUSD(b) * float64(daysInMonth)
+}
+
+// handleGetMonthlyBudget returns month-to-date spend against the monthly limit.
+// The month follows Budget.TimeZone, like the daily budget, and ?environment= filters as for /api/budget.
+func (s *Server) handleGetMonthlyBudget(w http.ResponseWriter, r *http.Request) {
+	budget := budgetConfig()
+	loc := budget.Location()
+	now := time.Now().In(loc)
+
+	start, end := data.MonthRange(now, loc)
+	env := ledgerEnvironment(r)
+	spent := s.spentBetween(start, end, env)
+	inputTokens, outputTokens := s.tokensBetween(start, end, env)
+	limit := monthlyLimitUSD(budget, now)
+
+	model := r.URL.Query().Get("model")
+	if model == "" {
+		model = "gpt-4o"
+	}
+
+	remaining := limit - spent
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(MonthlyBudgetResponse{
+		Month:           now.Format("2006-01"),
+		MonthlyLimit:    limit,
+		SpentThisMonth:  spent,
+		RemainingBudget: remaining,
+		TimeZone:        loc.String(),
+		Environment:     env,
+		CostModel:       costModel(providerPricing(providerForModel(model)).PrimaryCostUnit),
+		InputTokens:     inputTokens,
+		OutputTokens:    outputTokens,
+	})
+}
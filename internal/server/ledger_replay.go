@@ -0,0 +1,115 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/budget"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/data"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/llm"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/logging"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/security"
+)
+
+// ReplayResponse is the result of replaying a ledger entry.
+type ReplayResponse struct {
+	Entry   LedgerEntryResponse `json:"entry"` // the new entry, with replay_of set to the source
+	Content string              `json:"content"`
+}
+
+// handleReplayLedgerEntry re-runs the prompt behind a past ledger entry through the
+// gateway, on the same provider and agent role, so a call that failed transiently can be
+// retried as it was. The call is logged as a new entry whose replay_of is the source's ID.
+// Educational Comment: Only calls whose prompt was kept in ledger_prompts can be replayed,
+// which needs Ledger.StorePrompts on when the call was made. Any other entry answers 409.
+func (s *Server) handleReplayLedgerEntry(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+
+	ledgerService := data.NewLedgerService(s.db)
+	source, err := ledgerService.GetEntry(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeJSONError(w, http.StatusNotFound, "Ledger entry not found")
+		return
+	} else if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+	prompt, err := ledgerService.GetPrompt(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeJSONError(w, http.StatusConflict, "No prompt was stored for this entry, so it can't be replayed (enable ledger.store_prompts to keep prompts)")
+		return
+	} else if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Database error: "+err.Error())
+		return
+	}
+
+	// model_used holds the provider name, or the requested model for OpenAI proxy calls
+	provider := providerForModel(source.ModelUsed)
+	apiKey := r.Header.Get("X-Forge-Api-Key")
+	if apiKey == "" {
+		apiKey, _ = security.GetAPIKey(string(provider))
+	}
+	if apiKey == "" {
+		writeJSONError(w, http.StatusUnauthorized, "Missing X-Forge-Api-Key header and no key found in keyring")
+		return
+	}
+
+	if err := budget.CheckDailyTokenLimit(s.db); err != nil {
+		writeJSONError(w, http.StatusTooManyRequests, err.Error())
+		return
+	}
+
+	requestID := uuid.NewString()
+	w.Header().Set("X-Request-Id", requestID)
+
+	startTime := time.Now()
+	response, err := s.gateway.ExecutePromptWithOptions(source.AgentRole, prompt.UserPrompt, apiKey, provider, llm.PromptOptions{SystemOverride: prompt.SystemPrompt, NoCache: true})
+	entry := data.TokenLedgerEntry{
+		Timestamp:   time.Now(),
+		FlowID:      source.FlowID,
+		ModelUsed:   source.ModelUsed,
+		AgentRole:   source.AgentRole,
+		PromptHash:  source.PromptHash,
+		Status:      "SUCCESS",
+		LatencyMs:   int(time.Since(startTime).Milliseconds()),
+		Environment: source.Environment,
+		RequestID:   requestID,
+		ReplayOf:    source.ID,
+		Prompt:      prompt,
+	}
+	if err != nil {
+		entry.Status = "FAILED"
+		if llm.IsCapped(err) {
+			entry.Status = "CAPPED"
+		}
+		entry.ErrorMessage = err.Error()
+		s.logToLedger(entry)
+		writeLLMError(w, "Replay failed: ", err)
+		return
+	}
+	entry.InputTokens = response.InputTokens
+	entry.OutputTokens = response.OutputTokens
+	entry.TotalCostUSD = response.Cost
+
+	newID, err := ledgerService.Insert(entry)
+	if err != nil && newID == 0 {
+		writeJSONError(w, http.StatusInternalServerError, "Replay succeeded but could not be logged: "+err.Error())
+		return
+	} else if err != nil {
+		logging.ForRequest(requestID).Warnf("Replay of ledger entry %d: %v", id, err)
+	}
+	entry.ID = newID
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(ReplayResponse{Entry: ToLedgerResponse(entry), Content: response.Content})
+}
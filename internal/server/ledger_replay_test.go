@@ -0,0 +1,113 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/config"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/data"
+)
+
+// useStorePrompts turns on Ledger.StorePrompts for the test.
+func useStorePrompts(t *testing.T) {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	cfg := config.DefaultConfig()
+	cfg.Ledger.StorePrompts = true
+	if err := config.Save(cfg); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	t.Cleanup(func() { config.Save(config.DefaultConfig()) })
+}
+
+func TestHandleReplayLedgerEntry(t *testing.T) {
+	useStorePrompts(t)
+	srv := setupFlowTestServer(t)
+	ledgerService := data.NewLedgerService(srv.db)
+
+	var sentSystem, sentUser string
+	srv.gateway.OpenAIClient = &MockLLMProvider{
+		SendFunc: func(systemPrompt, userPrompt, apiKey string) (string, int, int, error) {
+			sentSystem, sentUser = systemPrompt, userPrompt
+			return "second time lucky", 12, 34, nil
+		},
+	}
+
+	sourceID, err := ledgerService.Insert(data.TokenLedgerEntry{
+		FlowID:       "7",
+		ModelUsed:    "OpenAI",
+		AgentRole:    "Architect",
+		PromptHash:   "hash-11",
+		Status:       "FAILED",
+		ErrorMessage: "connection reset",
+		Environment:  "dev",
+		Prompt:       &data.LedgerPrompt{SystemPrompt: "You are terse.", UserPrompt: "plan a thing"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to log the source entry: %v", err)
+	}
+	unstoredID, err := ledgerService.Insert(data.TokenLedgerEntry{FlowID: "7", ModelUsed: "OpenAI", AgentRole: "Architect", Status: "FAILED"})
+	if err != nil {
+		t.Fatalf("Failed to log the entry without a prompt: %v", err)
+	}
+
+	replay := func(id int64) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/ledger/"+strconv.FormatInt(id, 10)+"/replay", nil)
+		req.Header.Set("X-Forge-Api-Key", "test-key")
+		rr := httptest.NewRecorder()
+		srv.RegisterRoutes().ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := replay(sourceID)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if sentSystem != "You are terse." || sentUser != "plan a thing" {
+		t.Errorf("Expected the stored prompt to be sent again, got system %q and user %q", sentSystem, sentUser)
+	}
+
+	var resp ReplayResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Content != "second time lucky" || resp.Entry.ReplayOf != sourceID || resp.Entry.ID == sourceID {
+		t.Errorf("Expected a new entry replaying %d with the response, got %+v", sourceID, resp)
+	}
+
+	// The new entry is in the ledger, linked to its source and replayable in turn
+	entry, err := ledgerService.GetEntry(resp.Entry.ID)
+	if err != nil {
+		t.Fatalf("Failed to read the replay entry: %v", err)
+	}
+	if entry.ReplayOf != sourceID || entry.Status != "SUCCESS" || entry.FlowID != "7" || entry.Environment != "dev" || entry.OutputTokens != 34 {
+		t.Errorf("Expected a successful dev entry for flow 7 replaying %d, got %+v", sourceID, entry)
+	}
+	if prompt, err := ledgerService.GetPrompt(entry.ID); err != nil || prompt.UserPrompt != "plan a thing" {
+		t.Errorf("Expected the replay's prompt to be stored too, got %+v (%v)", prompt, err)
+	}
+
+	// The stored prompt can be read back for auditing
+	rr = httptest.NewRecorder()
+	srv.RegisterRoutes().ServeHTTP(rr, httptest.NewRequest("GET", "/api/ledger/"+strconv.FormatInt(sourceID, 10)+"/prompt", nil))
+	var stored data.LedgerPrompt
+	json.Unmarshal(rr.Body.Bytes(), &stored)
+	if rr.Code != http.StatusOK || stored.SystemPrompt != "You are terse." || stored.UserPrompt != "plan a thing" {
+		t.Errorf("Expected the source's prompt from GET /prompt, got %d: %s", rr.Code, rr.Body.String())
+	}
+	rr = httptest.NewRecorder()
+	srv.RegisterRoutes().ServeHTTP(rr, httptest.NewRequest("GET", "/api/ledger/"+strconv.FormatInt(unstoredID, 10)+"/prompt", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an entry without a stored prompt, got %d", rr.Code)
+	}
+
+	if rr := replay(unstoredID); rr.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for an entry without a stored prompt, got %d", rr.Code)
+	}
+	if rr := replay(9999); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown entry, got %d", rr.Code)
+	}
+}
@@ -7,9 +7,13 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	_ "modernc.org/sqlite"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/config"
 	"github.com/mikejsmith1985/forge-orchestrator/internal/data"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/llm"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/tokenizer"
 )
 
 func TestHandleEstimateTokens(t *testing.T) {
@@ -95,6 +99,36 @@ func TestHandleEstimateTokensWithProvider(t *testing.T) {
 	}
 }
 
+func TestHandleEstimateTokensWithMessages(t *testing.T) {
+	s := &Server{}
+	handler := s.RegisterRoutes()
+
+	estimate := func(body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/tokens/estimate", bytes.NewBufferString(body))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := estimate(`{"provider": "anthropic", "messages": [
+		{"role": "system", "content": "You are a helpful assistant."},
+		{"role": "user", "content": "What is the capital of France?"}
+	]}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var result tokenizer.EstimationResult
+	json.Unmarshal(rr.Body.Bytes(), &result)
+	// The joined text alone estimates at 15; roles and message overhead add 11
+	if result.Count != 26 {
+		t.Errorf("Expected the structured estimate of 26, got %+v", result)
+	}
+
+	if rr := estimate(`{"text": "hi", "messages": [{"role": "user", "content": "hi"}]}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 when both text and messages are sent, got %d", rr.Code)
+	}
+}
+
 func TestHandleCreateLedgerEntry(t *testing.T) {
 	db, err := sql.Open("sqlite", ":memory:")
 	if err != nil {
@@ -143,6 +177,65 @@ func TestHandleCreateLedgerEntry(t *testing.T) {
 	}
 }
 
+func TestHandleCreateLedgerEntry_IdempotencyKey(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(data.SQLiteSchema); err != nil {
+		t.Fatal(err)
+	}
+	handler := NewServer(db).RegisterRoutes()
+
+	post := func(key string, inputTokens int) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]interface{}{
+			"flow_id": "retry-flow", "model_used": "gpt-4", "agent_role": "developer", "prompt_hash": "h",
+			"input_tokens": inputTokens, "output_tokens": 50, "total_cost_usd": 0.003, "latency_ms": 500, "status": "SUCCESS",
+		})
+		req, _ := http.NewRequest("POST", "/api/ledger", bytes.NewBuffer(body))
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	first := post("call-1", 100)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("Expected 201 for a new key, got %d: %s", first.Code, first.Body.String())
+	}
+	var created LedgerEntryResponse
+	json.Unmarshal(first.Body.Bytes(), &created)
+
+	// A retry with the same key gets the original entry back, even if its body differs
+	repeat := post("call-1", 999)
+	if repeat.Code != http.StatusOK {
+		t.Fatalf("Expected 200 for a repeated key, got %d: %s", repeat.Code, repeat.Body.String())
+	}
+	var existing LedgerEntryResponse
+	json.Unmarshal(repeat.Body.Bytes(), &existing)
+	if existing.ID != created.ID || existing.InputTokens != 100 || existing.IdempotencyKey != "call-1" {
+		t.Errorf("Expected the original entry %+v, got %+v", created, existing)
+	}
+
+	if rr := post("call-2", 100); rr.Code != http.StatusCreated {
+		t.Errorf("Expected 201 for a different key, got %d", rr.Code)
+	}
+	// Requests without a key are never deduplicated
+	post("", 100)
+	post("", 100)
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM token_ledger WHERE flow_id = 'retry-flow'").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 4 {
+		t.Errorf("Expected 4 rows (one per key plus two unkeyed), got %d", count)
+	}
+}
+
 func TestHandleGetLedger(t *testing.T) {
 	db, err := sql.Open("sqlite", ":memory:")
 	if err != nil {
@@ -367,3 +460,344 @@ func TestHandleEstimateTokens_Errors(t *testing.T) {
 		})
 	}
 }
+
+func TestHandleGetBudget_CallCounts(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(data.SQLiteSchema); err != nil {
+		t.Fatal(err)
+	}
+
+	ledger := data.NewLedgerService(db)
+	for _, status := range []string{"SUCCESS", "SUCCESS", "SUCCESS", "FAILED", "CAPPED"} {
+		if err := ledger.LogUsage(data.TokenLedgerEntry{
+			FlowID: "flow-1", ModelUsed: "gpt-4o", AgentRole: "Implementation", PromptHash: "h",
+			TotalCostUSD: 0.5, Status: status,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Yesterday's calls must not be counted
+	if _, err := db.Exec(`INSERT INTO token_ledger (flow_id, model_used, agent_role, prompt_hash, input_tokens, output_tokens, total_cost_usd, latency_ms, status, timestamp)
+		VALUES ('old', 'gpt-4o', 'Implementation', 'h', 1, 1, 1.0, 1, 'FAILED', datetime('now', '-1 day'))`); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewServer(db)
+	rr := httptest.NewRecorder()
+	s.RegisterRoutes().ServeHTTP(rr, httptest.NewRequest("GET", "/api/budget", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+
+	var resp BudgetResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if resp.CallsToday != 5 || resp.SuccessCount != 3 || resp.FailureCount != 2 {
+		t.Errorf("Expected 5 calls (3 success, 2 failure), got %d (%d success, %d failure)",
+			resp.CallsToday, resp.SuccessCount, resp.FailureCount)
+	}
+	if resp.SpentToday != 2.5 {
+		t.Errorf("Expected $2.50 spent today, got %v", resp.SpentToday)
+	}
+}
+
+func TestHandleGetBudget_TimeZone(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	cfg := config.DefaultConfig()
+	cfg.Budget.TimeZone = "Pacific/Kiritimati"
+	if err := config.Save(cfg); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	defer config.Save(config.DefaultConfig())
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(data.SQLiteSchema); err != nil {
+		t.Fatal(err)
+	}
+
+	// One call just after local midnight (today) and one just before it (yesterday)
+	loc := cfg.Budget.Location()
+	local := time.Now().In(loc)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	ledger := data.NewLedgerService(db)
+	for _, ts := range []time.Time{midnight.Add(time.Minute), midnight.Add(-time.Minute)} {
+		if err := ledger.LogUsage(data.TokenLedgerEntry{
+			Timestamp: ts, FlowID: "flow-1", ModelUsed: "gpt-4o", AgentRole: "Implementation", PromptHash: "h",
+			TotalCostUSD: 1.25, Status: "SUCCESS",
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := NewServer(db)
+	rr := httptest.NewRecorder()
+	s.RegisterRoutes().ServeHTTP(rr, httptest.NewRequest("GET", "/api/budget", nil))
+
+	var resp BudgetResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if resp.SpentToday != 1.25 || resp.CallsToday != 1 {
+		t.Errorf("Expected only the call after local midnight ($1.25, 1 call), got $%v over %d calls", resp.SpentToday, resp.CallsToday)
+	}
+}
+
+// seedEnvironmentLedger logs $1 of prod spend and $4 of dev spend for today.
+func seedEnvironmentLedger(t *testing.T) *Server {
+	t.Helper()
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec(data.SQLiteSchema); err != nil {
+		t.Fatal(err)
+	}
+
+	ledger := data.NewLedgerService(db)
+	for _, e := range []struct {
+		env  string
+		cost float64
+	}{{"", 1}, {"dev", 3}, {"Dev", 1}} {
+		if err := ledger.LogUsage(data.TokenLedgerEntry{
+			FlowID: "flow-1", ModelUsed: "gpt-4o", AgentRole: "Implementation", PromptHash: "h",
+			TotalCostUSD: e.cost, Status: "SUCCESS", Environment: e.env,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewServer(db)
+}
+
+func TestHandleGetBudget_Environment(t *testing.T) {
+	s := seedEnvironmentLedger(t)
+
+	tests := []struct {
+		query string
+		spent float64
+		calls int
+	}{
+		{"", 5, 3},
+		{"?environment=prod", 1, 1},
+		{"?environment=DEV", 4, 2},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		s.RegisterRoutes().ServeHTTP(rr, httptest.NewRequest("GET", "/api/budget"+tt.query, nil))
+
+		var resp BudgetResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("failed to parse response: %v", err)
+		}
+		if resp.SpentToday != tt.spent || resp.CallsToday != tt.calls {
+			t.Errorf("%q: expected $%v over %d calls, got $%v over %d", tt.query, tt.spent, tt.calls, resp.SpentToday, resp.CallsToday)
+		}
+	}
+
+	// Dev runs are left out of the production budget, but still count towards enforcement
+	if spent := s.spentToday(); spent != 5 {
+		t.Errorf("Expected enforcement to see all $5, got $%v", spent)
+	}
+}
+
+func TestHandleGetLedger_Environment(t *testing.T) {
+	s := seedEnvironmentLedger(t)
+
+	rr := httptest.NewRecorder()
+	s.RegisterRoutes().ServeHTTP(rr, httptest.NewRequest("GET", "/api/ledger?environment=dev", nil))
+
+	var entries []LedgerEntryResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &entries); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 dev entries, got %d", len(entries))
+	}
+	for _, e := range entries {
+		if e.Environment != "dev" {
+			t.Errorf("Expected only dev entries, got %q", e.Environment)
+		}
+	}
+}
+
+func TestHandleGetLedgerEntry(t *testing.T) {
+	s := seedEnvironmentLedger(t)
+
+	rr := httptest.NewRecorder()
+	s.RegisterRoutes().ServeHTTP(rr, httptest.NewRequest("GET", "/api/ledger/2", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var entry LedgerEntryResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &entry); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if entry.ID != 2 || entry.FlowID != "flow-1" || entry.Environment != "dev" || entry.TotalCostUSD != 3 {
+		t.Errorf("Expected the second seeded entry, got %+v", entry)
+	}
+
+	for path, want := range map[string]int{
+		"/api/ledger/999": http.StatusNotFound,
+		"/api/ledger/abc": http.StatusBadRequest,
+	} {
+		rr := httptest.NewRecorder()
+		s.RegisterRoutes().ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		if rr.Code != want {
+			t.Errorf("GET %s: expected status %d, got %d", path, want, rr.Code)
+		}
+	}
+}
+
+func getBudget(t *testing.T, s *Server, query string) BudgetResponse {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	s.RegisterRoutes().ServeHTTP(rr, httptest.NewRequest("GET", "/api/budget"+query, nil))
+	var resp BudgetResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	return resp
+}
+
+func TestHandleGetBudget_PerTokenModel(t *testing.T) {
+	s := seedEnvironmentLedger(t)
+	if _, err := s.db.Exec(`UPDATE token_ledger SET input_tokens = 100, output_tokens = 40`); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := getBudget(t, s, "?model=claude-3-5-sonnet")
+	if resp.CostModel != "per_token" || resp.CostUnit != "TOKEN" {
+		t.Errorf("Expected per_token/TOKEN, got %s/%s", resp.CostModel, resp.CostUnit)
+	}
+	if resp.InputTokens != 300 || resp.OutputTokens != 120 {
+		t.Errorf("Expected 300 input and 120 output tokens, got %d and %d", resp.InputTokens, resp.OutputTokens)
+	}
+	// $5 left at Anthropic's average of $3 and $15 per 1M tokens
+	if resp.RemainingTokens == nil || *resp.RemainingTokens != 555555 {
+		t.Errorf("Expected 555555 remaining tokens, got %v", resp.RemainingTokens)
+	}
+}
+
+func TestHandleGetBudget_PerPromptModel(t *testing.T) {
+	providerPricing = func(provider llm.ProviderType) llm.LLMConfig {
+		return llm.LLMConfig{Provider: string(provider), PrimaryCostUnit: llm.CostUnitPrompt, PromptRate: 0.25}
+	}
+	t.Cleanup(func() { providerPricing = llm.Pricing })
+
+	resp := getBudget(t, seedEnvironmentLedger(t), "")
+	if resp.CostModel != "per_prompt" || resp.CostUnit != "PROMPT" {
+		t.Errorf("Expected per_prompt/PROMPT, got %s/%s", resp.CostModel, resp.CostUnit)
+	}
+	// $5 left at $0.25 a prompt
+	if resp.RemainingPrompts != 20 {
+		t.Errorf("Expected 20 remaining prompts, got %d", resp.RemainingPrompts)
+	}
+	if resp.RemainingTokens != nil {
+		t.Errorf("Expected no remaining tokens for a per-prompt model, got %d", *resp.RemainingTokens)
+	}
+}
+
+func TestHandleGetBudget_ConfiguredDailyLimit(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	cfg := config.DefaultConfig()
+	cfg.Budget.DailyLimitUSD = 20
+	if err := config.Save(cfg); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	defer config.Save(config.DefaultConfig())
+
+	resp := getBudget(t, seedEnvironmentLedger(t), "")
+	if resp.TotalBudget != 20 || resp.RemainingBudget != 15 {
+		t.Errorf("Expected $15 of the configured $20 remaining, got $%v of $%v", resp.RemainingBudget, resp.TotalBudget)
+	}
+}
+
+func TestHandleGetMonthlyBudget(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	cfg := config.DefaultConfig()
+	cfg.Budget.TimeZone = "Pacific/Kiritimati"
+	cfg.Budget.MonthlyLimitUSD = 50
+	if err := config.Save(cfg); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	defer config.Save(config.DefaultConfig())
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(data.SQLiteSchema); err != nil {
+		t.Fatal(err)
+	}
+
+	// Entries either side of local midnight on the 1st: only those after it are this month.
+	// Kiritimati is UTC+14, so the boundary falls on a different UTC day than the local one.
+	loc := cfg.Budget.Location()
+	local := time.Now().In(loc)
+	monthStart := time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, loc)
+	ledger := data.NewLedgerService(db)
+	entries := []struct {
+		ts   time.Time
+		cost float64
+	}{
+		{monthStart.Add(-time.Minute), 7.00},                  // last month
+		{monthStart.AddDate(0, -1, 0), 3.00},                  // start of last month
+		{monthStart.Add(time.Minute), 1.25},                   // first minute of this month
+		{monthStart.Add(time.Hour), 2.50},                     // later on the 1st
+		{monthStart.AddDate(0, 1, 0), 9.00},                   // next month (should never count)
+		{monthStart.AddDate(0, 1, 0).Add(-time.Minute), 0.25}, // last minute of this month
+	}
+	for _, e := range entries {
+		if err := ledger.LogUsage(data.TokenLedgerEntry{
+			Timestamp: e.ts, FlowID: "flow-1", ModelUsed: "gpt-4o", AgentRole: "Implementation", PromptHash: "h",
+			TotalCostUSD: e.cost, Status: "SUCCESS",
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := NewServer(db)
+	rr := httptest.NewRecorder()
+	s.RegisterRoutes().ServeHTTP(rr, httptest.NewRequest("GET", "/api/budget/monthly", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var resp MonthlyBudgetResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if resp.SpentThisMonth != 4.00 {
+		t.Errorf("Expected $4.00 month-to-date, got $%v", resp.SpentThisMonth)
+	}
+	if resp.MonthlyLimit != 50 || resp.RemainingBudget != 46 {
+		t.Errorf("Expected $46 of $50 remaining, got $%v of $%v", resp.RemainingBudget, resp.MonthlyLimit)
+	}
+	if want := local.Format("2006-01"); resp.Month != want || resp.TimeZone != "Pacific/Kiritimati" {
+		t.Errorf("Expected month %s in Pacific/Kiritimati, got %s in %s", want, resp.Month, resp.TimeZone)
+	}
+}
+
+func TestMonthlyLimitUSD_DefaultsToDailyBudget(t *testing.T) {
+	feb := time.Date(2024, 2, 10, 0, 0, 0, 0, time.UTC) // leap year
+	if got := monthlyLimitUSD(config.BudgetConfig{}, feb); got != defaultDailyBudgetUSD*29 {
+		t.Errorf("Expected 29 days of daily budget, got %v", got)
+	}
+	if got := monthlyLimitUSD(config.BudgetConfig{DailyLimitUSD: 2}, feb); got != 58 {
+		t.Errorf("Expected 29 days of the configured daily limit, got %v", got)
+	}
+	if got := monthlyLimitUSD(config.BudgetConfig{MonthlyLimitUSD: 75}, feb); got != 75 {
+		t.Errorf("Expected configured limit 75, got %v", got)
+	}
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/metrics"
+)
+
+func TestMetricsEndpointAfterLLMCall(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	srv := NewServer(db)
+	srv.gateway.OpenAIClient = &MockLLMProvider{}
+	handler := MetricsMiddleware(srv.RegisterRoutes())
+
+	before := metrics.LLMCalls.Value("OpenAI", "success")
+
+	// Simulate an LLM call through the proxy endpoint
+	body := `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`
+	req := httptest.NewRequest("POST", "/api/v1/chat/completions", bytes.NewBufferString(body))
+	req.Header.Set("X-Forge-Api-Key", "test-key")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected LLM call to succeed, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if got := metrics.LLMCalls.Value("OpenAI", "success"); got != before+1 {
+		t.Errorf("Expected LLM call counter to increase by 1, went from %v to %v", before, got)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+
+	out := rr.Body.String()
+	for _, want := range []string{
+		`forge_llm_calls_total{provider="OpenAI",status="success"}`,
+		`forge_llm_tokens_total{provider="OpenAI",direction="input"}`,
+		"forge_pty_sessions",
+		`forge_http_request_duration_seconds_count{method="POST",route="POST /api/v1/chat/completions",status="200"}`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected scrape to contain %q", want)
+		}
+	}
+}
+
+func TestAPIMetricsEndpointAfterCommandRun(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	res, _ := db.Exec("INSERT INTO command_cards (name, command, description) VALUES (?, ?, ?)", "Cmd", "echo", "Desc")
+	id, _ := res.LastInsertId()
+
+	srv := NewServer(db)
+	srv.gateway.OpenAIClient = &MockLLMProvider{}
+	handler := srv.RegisterRoutes()
+
+	before := metrics.LedgerEntries.Value("SUCCESS")
+
+	body := `{"agent_role":"Implementation","provider":"OpenAI"}`
+	req := httptest.NewRequest("POST", "/api/commands/"+strconv.FormatInt(id, 10)+"/run", bytes.NewBufferString(body))
+	req.Header.Set("X-Forge-Api-Key", "test-key")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected command run to succeed, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if got := metrics.LedgerEntries.Value("SUCCESS"); got != before+1 {
+		t.Errorf("Expected ledger entry counter to increase by 1, went from %v to %v", before, got)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/api/metrics", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Expected Prometheus text content type, got %q", ct)
+	}
+
+	out := rr.Body.String()
+	for _, want := range []string{
+		`forge_ledger_entries_total{status="SUCCESS"}`,
+		`forge_llm_calls_total{provider="OpenAI",status="success"}`,
+		`forge_llm_cost_usd_total{provider="OpenAI"}`,
+		"forge_pty_sessions",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected scrape to contain %q", want)
+		}
+	}
+}
+
+func TestMetricsMiddlewareLabelsUnmatchedRoutes(t *testing.T) {
+	before := metrics.HTTPRequestDuration.Count("GET", "unmatched", "404")
+
+	handler := MetricsMiddleware(http.NotFoundHandler())
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/nope/123", nil))
+
+	if got := metrics.HTTPRequestDuration.Count("GET", "unmatched", "404"); got != before+1 {
+		t.Errorf("Expected unmatched request to be recorded, count went from %d to %d", before, got)
+	}
+}
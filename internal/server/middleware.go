@@ -1,10 +1,18 @@
 package server
 
 import (
-	"log"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/config"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/logging"
 )
 
 // Default allowed origins for development
@@ -26,6 +34,49 @@ var defaultAllowedOrigins = []string{
 // allowedOrigins holds the set of allowed origins for CORS
 var allowedOrigins map[string]bool
 
+// allowCredentials controls whether Access-Control-Allow-Credentials is sent.
+// Off by default; enable with FORGE_CORS_ALLOW_CREDENTIALS=true when Forge sits
+// behind an authenticating proxy that relies on cookies or auth headers.
+var allowCredentials bool
+
+// wildcardOrigin is an allow-list entry like "https://*.mycompany.com",
+// split into the part before and after the "*".
+type wildcardOrigin struct {
+	prefix string // e.g. "https://"
+	suffix string // e.g. ".mycompany.com"
+}
+
+// wildcardOrigins holds the allow-list entries that contain a subdomain wildcard
+var wildcardOrigins []wildcardOrigin
+
+// parseWildcardOrigin recognises entries of the form "scheme://*.domain[:port]".
+func parseWildcardOrigin(origin string) (wildcardOrigin, bool) {
+	schemeEnd := strings.Index(origin, "://")
+	if schemeEnd == -1 {
+		return wildcardOrigin{}, false
+	}
+	prefix := origin[:schemeEnd+3]
+	rest := origin[schemeEnd+3:]
+	if !strings.HasPrefix(rest, "*.") || len(rest) <= 2 {
+		return wildcardOrigin{}, false
+	}
+	return wildcardOrigin{prefix: prefix, suffix: rest[1:]}, true
+}
+
+// matches reports whether origin is exactly one subdomain label under the wildcard.
+// "https://app.mycompany.com" matches "https://*.mycompany.com", but
+// "https://a.b.mycompany.com" and "https://mycompany.com" do not.
+func (w wildcardOrigin) matches(origin string) bool {
+	if !strings.HasPrefix(origin, w.prefix) || !strings.HasSuffix(origin, w.suffix) {
+		return false
+	}
+	if len(origin) <= len(w.prefix)+len(w.suffix) {
+		return false
+	}
+	label := origin[len(w.prefix) : len(origin)-len(w.suffix)]
+	return !strings.ContainsAny(label, "./:@*")
+}
+
 // InitCORS initializes the CORS configuration from environment variables
 func InitCORS() []string {
 	envOrigins := os.Getenv("FORGE_ALLOWED_ORIGINS")
@@ -41,20 +92,43 @@ func InitCORS() []string {
 	}
 
 	allowedOrigins = make(map[string]bool)
+	wildcardOrigins = nil
 	for _, o := range origins {
+		if wildcard, ok := parseWildcardOrigin(o); ok {
+			wildcardOrigins = append(wildcardOrigins, wildcard)
+			continue
+		}
 		allowedOrigins[o] = true
 	}
 
-	log.Printf("CORS: Allowed origins: %v", origins)
+	allowCredentials = false
+	if envCreds := os.Getenv("FORGE_CORS_ALLOW_CREDENTIALS"); envCreds != "" {
+		enabled, err := strconv.ParseBool(envCreds)
+		if err != nil {
+			logging.Warnf("CORS: Ignoring invalid FORGE_CORS_ALLOW_CREDENTIALS value %q", envCreds)
+		}
+		allowCredentials = enabled
+	}
+
+	logging.Infof("CORS: Allowed origins: %v (credentials: %v)", origins, allowCredentials)
 	return origins
 }
 
-// IsAllowedOrigin checks if the given origin is in the whitelist
+// IsAllowedOrigin checks if the given origin is in the whitelist,
+// either as an exact entry or under a wildcard subdomain entry
 func IsAllowedOrigin(origin string) bool {
 	if origin == "" {
 		return true // Same-origin requests don't send Origin header
 	}
-	return allowedOrigins[origin]
+	if allowedOrigins[origin] {
+		return true
+	}
+	for _, wildcard := range wildcardOrigins {
+		if wildcard.matches(origin) {
+			return true
+		}
+	}
+	return false
 }
 
 // CORSMiddleware creates a middleware that handles CORS
@@ -64,17 +138,24 @@ func CORSMiddleware(next http.Handler) http.Handler {
 
 		// If origin is provided and not allowed, return 403
 		if origin != "" && !IsAllowedOrigin(origin) {
-			log.Printf("CORS: Blocked request from origin: %s", origin)
+			logging.Warnf("CORS: Blocked request from origin: %s", origin)
 			http.Error(w, "Forbidden", http.StatusForbidden)
 			return
 		}
 
-		// Set CORS headers for allowed origins
+		// Set CORS headers for allowed origins.
+		// We always echo the specific origin (never "*"), which is also what
+		// browsers require before they'll honour Allow-Credentials.
 		if origin != "" {
 			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Add("Vary", "Origin")
 			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-Forge-Api-Key")
-			w.Header().Set("Access-Control-Allow-Credentials", "true")
+			// Let cross-origin UIs read the pagination total from list endpoints
+			w.Header().Set("Access-Control-Expose-Headers", "X-Total-Count")
+			if allowCredentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
 			w.Header().Set("Access-Control-Max-Age", "86400") // 24 hours
 		}
 
@@ -87,3 +168,72 @@ func CORSMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// bodyLimitExempt lists the paths that enforce their own body cap. Screenshot
+// uploads are capped at maxScreenshotBytes by their handler.
+var bodyLimitExempt = map[string]bool{
+	"/api/feedback/screenshots": true,
+}
+
+// requestBodyLimit returns Server.MaxRequestBodyBytes, or the default if the config can't be read.
+func requestBodyLimit() int64 {
+	if cfg, err := config.Get(); err == nil {
+		return cfg.Server.RequestBodyLimit()
+	}
+	return config.DefaultMaxRequestBodyBytes
+}
+
+// BodyLimitMiddleware answers 413 for request bodies over Server.MaxRequestBodyBytes, so a
+// huge payload can't exhaust memory.
+// Educational Comment: The body is read up front (at most the limit) rather than wrapped in
+// http.MaxBytesReader alone, because handlers report any decode failure as a 400 and a
+// too-large body deserves its own status however the handler reads it.
+func BodyLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body == nil || r.Body == http.NoBody || bodyLimitExempt[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		limit := requestBodyLimit()
+		tooLarge := fmt.Sprintf("Request body exceeds the %d byte limit", limit)
+		if r.ContentLength > limit {
+			writeJSONError(w, http.StatusRequestEntityTooLarge, tooLarge)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+		if err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				writeJSONError(w, http.StatusRequestEntityTooLarge, tooLarge)
+			} else {
+				writeJSONError(w, http.StatusBadRequest, "Failed to read request body: "+err.Error())
+			}
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// JSONContentTypeMiddleware answers 415 for POST, PUT and PATCH bodies sent with a
+// Content-Type other than application/json, instead of letting the handler fail to decode
+// them. A body without a Content-Type is let through, as older scripts send none.
+func JSONContentTypeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hasBody := r.Body != nil && r.Body != http.NoBody && r.ContentLength != 0
+		contentType := r.Header.Get("Content-Type")
+		if !hasBody || contentType == "" ||
+			(r.Method != http.MethodPost && r.Method != http.MethodPut && r.Method != http.MethodPatch) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if mediaType, _, err := mime.ParseMediaType(contentType); err != nil || mediaType != "application/json" {
+			writeJSONError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/json, got "+contentType)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -4,7 +4,10 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/config"
 )
 
 func TestInitCORS_DefaultOrigins(t *testing.T) {
@@ -53,6 +56,54 @@ func TestInitCORS_CustomOrigins(t *testing.T) {
 	}
 }
 
+func TestIsAllowedOrigin_WildcardSubdomain(t *testing.T) {
+	os.Setenv("FORGE_ALLOWED_ORIGINS", "https://*.mycompany.com, http://localhost:5173")
+	defer os.Unsetenv("FORGE_ALLOWED_ORIGINS")
+
+	InitCORS()
+
+	allowed := []string{
+		"https://app.mycompany.com",
+		"https://forge.mycompany.com",
+		"http://localhost:5173", // exact entries keep working alongside wildcards
+		"",                      // same-origin requests still pass
+	}
+	for _, origin := range allowed {
+		if !IsAllowedOrigin(origin) {
+			t.Errorf("Expected %q to be allowed", origin)
+		}
+	}
+
+	blocked := []string{
+		"https://mycompany.com",              // bare domain is not a subdomain
+		"https://a.b.mycompany.com",          // only a single label may replace the wildcard
+		"http://app.mycompany.com",           // scheme must match
+		"https://app.mycompany.com.evil.com", // suffix must be at the end
+		"https://evilmycompany.com",          // no dot before the domain
+		"https://app.mycompany.com:8443",     // port must match the entry
+		"https://*.mycompany.com",            // the literal pattern is not an origin
+	}
+	for _, origin := range blocked {
+		if IsAllowedOrigin(origin) {
+			t.Errorf("Expected %q to be blocked", origin)
+		}
+	}
+}
+
+func TestIsAllowedOrigin_WildcardWithPort(t *testing.T) {
+	os.Setenv("FORGE_ALLOWED_ORIGINS", "https://*.mycompany.com:8443")
+	defer os.Unsetenv("FORGE_ALLOWED_ORIGINS")
+
+	InitCORS()
+
+	if !IsAllowedOrigin("https://app.mycompany.com:8443") {
+		t.Error("Expected subdomain with matching port to be allowed")
+	}
+	if IsAllowedOrigin("https://app.mycompany.com") {
+		t.Error("Expected subdomain without port to be blocked")
+	}
+}
+
 func TestIsAllowedOrigin_EmptyOrigin(t *testing.T) {
 	InitCORS()
 
@@ -87,6 +138,61 @@ func TestCORSMiddleware_AllowedOrigin(t *testing.T) {
 	}
 }
 
+func TestCORSMiddleware_CredentialsOffByDefault(t *testing.T) {
+	os.Unsetenv("FORGE_ALLOWED_ORIGINS")
+	os.Unsetenv("FORGE_CORS_ALLOW_CREDENTIALS")
+	InitCORS()
+
+	handler := CORSMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest("GET", "/api/health", nil)
+	req.Header.Set("Origin", "http://localhost:8080")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if got := rr.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("Expected no Access-Control-Allow-Credentials header by default, got %q", got)
+	}
+}
+
+func TestCORSMiddleware_CredentialsEnabled(t *testing.T) {
+	os.Unsetenv("FORGE_ALLOWED_ORIGINS")
+	os.Setenv("FORGE_CORS_ALLOW_CREDENTIALS", "true")
+	defer func() {
+		os.Unsetenv("FORGE_CORS_ALLOW_CREDENTIALS")
+		InitCORS()
+	}()
+	InitCORS()
+
+	handler := CORSMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, method := range []string{"GET", "OPTIONS"} {
+		req := httptest.NewRequest(method, "/api/health", nil)
+		req.Header.Set("Origin", "http://localhost:8080")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if got := rr.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+			t.Errorf("%s: expected Access-Control-Allow-Credentials true, got %q", method, got)
+		}
+		if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "http://localhost:8080" {
+			t.Errorf("%s: expected the specific origin to be echoed, got %q", method, got)
+		}
+	}
+
+	// Requests without an Origin get no CORS headers at all
+	req := httptest.NewRequest("GET", "/api/health", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if got := rr.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("Expected no credentials header without Origin, got %q", got)
+	}
+}
+
 func TestCORSMiddleware_BlockedOrigin(t *testing.T) {
 	os.Unsetenv("FORGE_ALLOWED_ORIGINS")
 	InitCORS()
@@ -201,3 +307,89 @@ func TestWebSocketOriginCheck_NoOrigin(t *testing.T) {
 		t.Error("Expected WebSocket connection without Origin header to be allowed")
 	}
 }
+
+func TestBodyLimitMiddleware(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	cfg := config.DefaultConfig()
+	cfg.Server.MaxRequestBodyBytes = 1024
+	if err := config.Save(cfg); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	defer config.Save(config.DefaultConfig())
+
+	srv := setupFlowTestServer(t)
+	handler := srv.RegisterRoutes()
+	oversized := `{"name": "` + strings.Repeat("x", 2048) + `"}`
+
+	for _, path := range []string{"/api/flows", "/api/ledger"} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("POST", path, strings.NewReader(oversized)))
+		if rr.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("Expected 413 for an oversized POST %s, got %d", path, rr.Code)
+		}
+	}
+
+	// A body without a Content-Length is cut off at the limit too
+	req := httptest.NewRequest("POST", "/api/flows", strings.NewReader(oversized))
+	req.ContentLength = -1
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 for an oversized chunked body, got %d", rr.Code)
+	}
+
+	// Bodies under the limit reach the handler intact
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", "/api/ledger", strings.NewReader(`{"flow_id": "1", "model_used": "OpenAI", "agent_role": "Architect", "status": "SUCCESS"}`)))
+	if rr.Code != http.StatusCreated {
+		t.Errorf("Expected 201 for a small body, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// Screenshot uploads keep their own, larger cap
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", "/api/feedback/screenshots", strings.NewReader(oversized)))
+	if rr.Code == http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected the screenshot upload to be exempt from the API body limit")
+	}
+}
+
+func TestJSONContentTypeMiddleware(t *testing.T) {
+	srv := setupFlowTestServer(t)
+	handler := srv.RegisterRoutes()
+	body := `{"flow_id": "1", "model_used": "OpenAI", "agent_role": "Architect", "status": "SUCCESS"}`
+
+	tests := []struct {
+		name, method, path, contentType string
+		want                            int
+	}{
+		{"form post", "POST", "/api/ledger", "application/x-www-form-urlencoded", http.StatusUnsupportedMediaType},
+		{"plain text", "POST", "/api/commands", "text/plain", http.StatusUnsupportedMediaType},
+		{"wrong type on PUT", "PUT", "/api/flows/1", "text/xml", http.StatusUnsupportedMediaType},
+		{"malformed type", "POST", "/api/ledger", "application/", http.StatusUnsupportedMediaType},
+		{"JSON", "POST", "/api/ledger", "application/json", http.StatusCreated},
+		{"JSON with charset", "POST", "/api/ledger", "application/json; charset=utf-8", http.StatusCreated},
+		{"no content type", "POST", "/api/ledger", "", http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if rr.Code != tt.want {
+				t.Errorf("Expected status %d, got %d: %s", tt.want, rr.Code, rr.Body.String())
+			}
+		})
+	}
+
+	// The screenshot upload takes a JSON body like every other endpoint, not a multipart form
+	req := httptest.NewRequest("POST", "/api/feedback/screenshots", strings.NewReader("--boundary--"))
+	req.Header.Set("Content-Type", "multipart/form-data; boundary=boundary")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("Expected a multipart screenshot upload to get 415, got %d", rr.Code)
+	}
+}
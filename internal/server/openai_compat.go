@@ -0,0 +1,263 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/agents"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/budget"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/data"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/llm"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/security"
+)
+
+// defaultCompatAgentRole is used when the caller doesn't pick a Forge agent role.
+// OpenAI clients know nothing about roles, so we default to the general-purpose coder.
+const defaultCompatAgentRole = "Implementation"
+
+// ChatCompletionMessage is a single message in an OpenAI-style conversation.
+type ChatCompletionMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// ChatCompletionRequest mirrors the subset of the OpenAI chat completions request we support.
+type ChatCompletionRequest struct {
+	Model    string                  `json:"model"`
+	Messages []ChatCompletionMessage `json:"messages"`
+}
+
+// ChatCompletionChoice is one generated answer in a ChatCompletionResponse.
+type ChatCompletionChoice struct {
+	Index        int                   `json:"index"`
+	Message      ChatCompletionMessage `json:"message"`
+	FinishReason string                `json:"finish_reason"`
+}
+
+// ChatCompletionUsage reports token counts in OpenAI's field names.
+type ChatCompletionUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// ChatCompletionResponse mirrors the OpenAI chat completions response shape.
+type ChatCompletionResponse struct {
+	ID      string                 `json:"id"`
+	Object  string                 `json:"object"`
+	Created int64                  `json:"created"`
+	Model   string                 `json:"model"`
+	Choices []ChatCompletionChoice `json:"choices"`
+	Usage   ChatCompletionUsage    `json:"usage"`
+}
+
+// chatCompletionError is the OpenAI error envelope, so SDKs surface our messages properly.
+type chatCompletionError struct {
+	Error struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+		Code    string `json:"code,omitempty"`
+	} `json:"error"`
+}
+
+// writeChatCompletionError writes an error in the shape OpenAI SDKs expect.
+func writeChatCompletionError(w http.ResponseWriter, status int, errType, code, message string) {
+	var resp chatCompletionError
+	resp.Error.Message = message
+	resp.Error.Type = errType
+	resp.Error.Code = code
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// providerForModel maps an OpenAI-style model name onto one of our providers.
+// Claude models go to Anthropic; everything else is treated as OpenAI.
+func providerForModel(model string) llm.ProviderType {
+	lower := strings.ToLower(model)
+	if strings.HasPrefix(lower, "claude") || lower == "anthropic" {
+		return llm.ProviderAnthropic
+	}
+	return llm.ProviderOpenAI
+}
+
+// compatModel finds the provider that runs model and the model's canonical name.
+// Each gateway client talks to a single model, so only those names are accepted: any
+// other would be answered, and billed, by a different model than the caller asked for.
+// The second result lists the accepted names for the error message.
+func (s *Server) compatModel(model string) (llm.ProviderType, string, []string, bool) {
+	var supported []string
+	for _, info := range s.gateway.Providers() {
+		for _, name := range info.Models {
+			if strings.EqualFold(name, model) {
+				return info.Provider, name, nil, true
+			}
+			supported = append(supported, name)
+		}
+	}
+	return "", "", supported, false
+}
+
+// flattenChatMessages turns a conversation into a single prompt for Gateway.ExecutePrompt.
+// Educational Comment: The gateway takes one system prompt (chosen by agent role) and one
+// user prompt, so we keep the caller's own system messages and history as labelled text.
+func flattenChatMessages(messages []ChatCompletionMessage) string {
+	if len(messages) == 1 && messages[0].Role == "user" {
+		return messages[0].Content
+	}
+
+	var sb strings.Builder
+	for i, msg := range messages {
+		if i > 0 {
+			sb.WriteString("\n\n")
+		}
+		sb.WriteString(msg.Role)
+		sb.WriteString(": ")
+		sb.WriteString(msg.Content)
+	}
+	return sb.String()
+}
+
+// compatErrorType maps an upstream failure status onto OpenAI's error type names,
+// so SDK retry logic (which keys off these) behaves the same as against OpenAI.
+func compatErrorType(status int) string {
+	switch status {
+	case http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return "authentication_error"
+	default:
+		return "api_error"
+	}
+}
+
+// compatAPIKey finds the provider key for a proxied request.
+// Order: X-Forge-Api-Key header, then the keyring, then the Authorization bearer token.
+// The keyring wins over the bearer token because OpenAI SDKs insist on sending some key,
+// which is usually a placeholder when pointed at Forge.
+func compatAPIKey(r *http.Request, provider llm.ProviderType) string {
+	if key := r.Header.Get("X-Forge-Api-Key"); key != "" {
+		return key
+	}
+	if key, err := security.GetAPIKey(string(provider)); err == nil && key != "" {
+		return key
+	}
+	return strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+}
+
+// handleChatCompletions is an OpenAI-compatible proxy in front of the LLM Gateway.
+// Existing OpenAI SDK tools can point their base URL at Forge and get budget
+// enforcement and ledger tracking without any code changes.
+// The Forge agent role can be chosen with the X-Forge-Agent-Role header, and the
+// ledger environment (e.g. dev) with X-Forge-Environment.
+func (s *Server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	var req ChatCompletionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeChatCompletionError(w, http.StatusBadRequest, "invalid_request_error", "", "Invalid request body: "+err.Error())
+		return
+	}
+
+	if req.Model == "" {
+		writeChatCompletionError(w, http.StatusBadRequest, "invalid_request_error", "", "model is required")
+		return
+	}
+	if len(req.Messages) == 0 {
+		writeChatCompletionError(w, http.StatusBadRequest, "invalid_request_error", "", "messages must not be empty")
+		return
+	}
+
+	// Refuse new spend once today's budget is used up
+	if limit, spent := dailyLimitUSD(budgetConfig()), s.spentToday(); spent >= limit {
+		writeChatCompletionError(w, http.StatusTooManyRequests, "insufficient_quota", "budget_exceeded",
+			fmt.Sprintf("Daily budget of $%.2f exhausted ($%.2f spent)", limit, spent))
+		return
+	}
+
+	if err := budget.CheckDailyTokenLimit(s.db); err != nil {
+		writeChatCompletionError(w, http.StatusTooManyRequests, "insufficient_quota", budget.CodeTokenLimitExceeded, err.Error())
+		return
+	}
+
+	provider, model, supported, ok := s.compatModel(req.Model)
+	if !ok {
+		writeChatCompletionError(w, http.StatusBadRequest, "invalid_request_error", "model_not_found",
+			fmt.Sprintf("Model %q is not supported; use one of: %s", req.Model, strings.Join(supported, ", ")))
+		return
+	}
+	apiKey := compatAPIKey(r, provider)
+	if apiKey == "" {
+		writeChatCompletionError(w, http.StatusUnauthorized, "invalid_request_error", "missing_api_key",
+			"No API key provided and none found in keyring for "+string(provider))
+		return
+	}
+
+	agentRole := r.Header.Get("X-Forge-Agent-Role")
+	if agentRole == "" {
+		agentRole = defaultCompatAgentRole
+	}
+
+	prompt := flattenChatMessages(req.Messages)
+	systemPrompt, _ := llm.ResolveSystemPrompt(agentRole, "")
+
+	startTime := time.Now()
+	response, err := s.gateway.ExecutePrompt(agentRole, prompt, apiKey, provider)
+	latencyMs := time.Since(startTime).Milliseconds()
+
+	ledgerEntry := data.TokenLedgerEntry{
+		Timestamp:   time.Now(),
+		FlowID:      "openai-compat",
+		ModelUsed:   string(provider), // like flows and commands, so stats and replays group by provider
+		AgentRole:   agents.CanonicalRole(agentRole),
+		PromptHash:  llm.HashPrompt(systemPrompt, prompt),
+		Status:      "SUCCESS",
+		LatencyMs:   int(latencyMs),
+		Environment: r.Header.Get("X-Forge-Environment"),
+		Prompt:      &data.LedgerPrompt{SystemPrompt: systemPrompt, UserPrompt: prompt},
+	}
+
+	if err != nil {
+		ledgerEntry.Status = "FAILED"
+		if llm.IsCapped(err) {
+			ledgerEntry.Status = "CAPPED"
+		}
+		ledgerEntry.ErrorMessage = err.Error()
+		s.logToLedger(ledgerEntry)
+		status := llm.HTTPStatusForError(err)
+		if status == http.StatusTooManyRequests {
+			setRetryAfter(w, err)
+		}
+		writeChatCompletionError(w, status, compatErrorType(status), "", "LLM execution failed: "+err.Error())
+		return
+	}
+
+	ledgerEntry.InputTokens = response.InputTokens
+	ledgerEntry.OutputTokens = response.OutputTokens
+	ledgerEntry.TotalCostUSD = response.Cost
+	if response.Cached {
+		ledgerEntry.Status = "CACHE_HIT"
+	}
+	s.logToLedger(ledgerEntry)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ChatCompletionResponse{
+		ID:      "chatcmpl-" + uuid.New().String(),
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   model,
+		Choices: []ChatCompletionChoice{{
+			Index:        0,
+			Message:      ChatCompletionMessage{Role: "assistant", Content: response.Content},
+			FinishReason: "stop",
+		}},
+		Usage: ChatCompletionUsage{
+			PromptTokens:     response.InputTokens,
+			CompletionTokens: response.OutputTokens,
+			TotalTokens:      response.InputTokens + response.OutputTokens,
+		},
+	})
+}
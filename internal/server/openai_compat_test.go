@@ -0,0 +1,298 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/config"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/llm"
+)
+
+func postChatCompletion(t *testing.T, srv *Server, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/api/v1/chat/completions", bytes.NewBufferString(body))
+	req.Header.Set("X-Forge-Api-Key", "test-key")
+	rr := httptest.NewRecorder()
+	srv.RegisterRoutes().ServeHTTP(rr, req)
+	return rr
+}
+
+func TestHandleChatCompletions(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	srv := NewServer(db)
+	var gotPrompt, gotKey string
+	mockProvider := &MockLLMProvider{
+		SendFunc: func(systemPrompt, userPrompt, apiKey string) (string, int, int, error) {
+			gotPrompt, gotKey = userPrompt, apiKey
+			return "Hello from Forge", 12, 7, nil
+		},
+	}
+	srv.gateway.OpenAIClient = mockProvider
+	srv.gateway.AnthropicClient = &MockLLMProvider{
+		SendFunc: func(systemPrompt, userPrompt, apiKey string) (string, int, int, error) {
+			t.Error("Expected gpt-4o to route to OpenAI, not Anthropic")
+			return "", 0, 0, nil
+		},
+	}
+
+	rr := postChatCompletion(t, srv, `{"model":"gpt-4o","messages":[{"role":"user","content":"Say hello"}]}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var resp ChatCompletionResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Object != "chat.completion" || resp.Model != "gpt-4o" || resp.ID == "" {
+		t.Errorf("Unexpected response envelope: %+v", resp)
+	}
+	if len(resp.Choices) != 1 || resp.Choices[0].Message.Role != "assistant" || resp.Choices[0].Message.Content != "Hello from Forge" {
+		t.Errorf("Unexpected choices: %+v", resp.Choices)
+	}
+	if resp.Usage.PromptTokens != 12 || resp.Usage.CompletionTokens != 7 || resp.Usage.TotalTokens != 19 {
+		t.Errorf("Unexpected usage: %+v", resp.Usage)
+	}
+	if gotPrompt != "Say hello" || gotKey != "test-key" {
+		t.Errorf("Provider got prompt %q key %q", gotPrompt, gotKey)
+	}
+
+	var flowID, model, status, promptHash string
+	var inputTokens, outputTokens int
+	err := db.QueryRow("SELECT flow_id, model_used, status, input_tokens, output_tokens, prompt_hash FROM token_ledger").
+		Scan(&flowID, &model, &status, &inputTokens, &outputTokens, &promptHash)
+	if err != nil {
+		t.Fatalf("Expected a ledger entry: %v", err)
+	}
+	if flowID != "openai-compat" || model != "OpenAI" || status != "SUCCESS" || inputTokens != 12 || outputTokens != 7 {
+		t.Errorf("Unexpected ledger entry: %s %s %s %d %d", flowID, model, status, inputTokens, outputTokens)
+	}
+	systemPrompt, _ := llm.ResolveSystemPrompt(defaultCompatAgentRole, "")
+	if want := llm.HashPrompt(systemPrompt, "Say hello"); promptHash != want {
+		t.Errorf("Expected prompt hash %s, got %s", want, promptHash)
+	}
+}
+
+func TestHandleChatCompletions_UnsupportedModel(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	srv := NewServer(db)
+	srv.gateway.OpenAIClient = &MockLLMProvider{
+		SendFunc: func(systemPrompt, userPrompt, apiKey string) (string, int, int, error) {
+			t.Error("Expected an unsupported model not to reach a provider")
+			return "", 0, 0, nil
+		},
+	}
+
+	// gpt-4 is priced in the registry, but the OpenAI client only talks to gpt-4o
+	rr := postChatCompletion(t, srv, `{"model":"gpt-4","messages":[{"role":"user","content":"Hi"}]}`)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp chatCompletionError
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode error: %v", err)
+	}
+	if resp.Error.Code != "model_not_found" || !strings.Contains(resp.Error.Message, llm.OpenAIModel) {
+		t.Errorf("Unexpected error: %+v", resp.Error)
+	}
+
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM token_ledger").Scan(&count)
+	if count != 0 {
+		t.Errorf("Expected no ledger entry for a refused model, got %d", count)
+	}
+}
+
+func TestHandleChatCompletions_RoutesClaudeToAnthropic(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	srv := NewServer(db)
+	called := false
+	srv.gateway.AnthropicClient = &MockLLMProvider{
+		SendFunc: func(systemPrompt, userPrompt, apiKey string) (string, int, int, error) {
+			called = true
+			return "ok", 1, 1, nil
+		},
+	}
+
+	body := `{"model":"claude-3-5-sonnet-20240620","messages":[{"role":"system","content":"Be brief"},{"role":"user","content":"Hi"}]}`
+	rr := postChatCompletion(t, srv, body)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if !called {
+		t.Error("Expected claude model to route to Anthropic")
+	}
+}
+
+func TestHandleChatCompletions_PromptBilledLedgerCost(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	cfg := config.DefaultConfig()
+	cfg.Budget.Pricing = map[string]config.ProviderPricing{
+		string(llm.ProviderAnthropic): {CostUnit: "PROMPT", PromptRate: 0.05},
+	}
+	if err := config.Save(cfg); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	t.Cleanup(func() { config.Save(config.DefaultConfig()) })
+
+	db := setupTestDB(t)
+	defer db.Close()
+
+	srv := NewServer(db)
+	tokens := []int{5, 50_000}
+	srv.gateway.AnthropicClient = &MockLLMProvider{
+		SendFunc: func(systemPrompt, userPrompt, apiKey string) (string, int, int, error) {
+			n := tokens[0]
+			tokens = tokens[1:]
+			return "ok", n, n, nil
+		},
+	}
+
+	for range 2 {
+		rr := postChatCompletion(t, srv, `{"model":"claude-3-5-sonnet-20240620","messages":[{"role":"user","content":"Hi"}]}`)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+	}
+
+	// A tiny call and a huge one are both charged the flat rate
+	rows, err := db.Query("SELECT total_cost_usd FROM token_ledger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var count int
+	for rows.Next() {
+		var cost float64
+		if err := rows.Scan(&cost); err != nil {
+			t.Fatal(err)
+		}
+		if cost != 0.05 {
+			t.Errorf("Expected each prompt-billed call to cost $0.05, got $%v", cost)
+		}
+		count++
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 ledger entries, got %d", count)
+	}
+}
+
+func TestHandleChatCompletions_BudgetExceeded(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec(`INSERT INTO token_ledger (flow_id, model_used, agent_role, prompt_hash, input_tokens, output_tokens, total_cost_usd, status)
+		VALUES ('seed', 'gpt-4o', 'Implementation', 'h', 1, 1, ?, 'SUCCESS')`, defaultDailyBudgetUSD+1)
+	if err != nil {
+		t.Fatalf("Failed to seed ledger: %v", err)
+	}
+
+	srv := NewServer(db)
+	srv.gateway.OpenAIClient = &MockLLMProvider{
+		SendFunc: func(systemPrompt, userPrompt, apiKey string) (string, int, int, error) {
+			t.Error("Provider should not be called when over budget")
+			return "", 0, 0, nil
+		},
+	}
+
+	rr := postChatCompletion(t, srv, `{"model":"gpt-4o","messages":[{"role":"user","content":"Hi"}]}`)
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429, got %d", rr.Code)
+	}
+
+	var resp chatCompletionError
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode error: %v", err)
+	}
+	if resp.Error.Code != "budget_exceeded" || resp.Error.Message == "" {
+		t.Errorf("Unexpected error body: %+v", resp)
+	}
+}
+
+func TestHandleChatCompletions_ConfiguredDailyLimit(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	cfg := config.DefaultConfig()
+	cfg.Budget.DailyLimitUSD = 2
+	if err := config.Save(cfg); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	defer config.Save(config.DefaultConfig())
+
+	db := setupTestDB(t)
+	defer db.Close()
+	_, err := db.Exec(`INSERT INTO token_ledger (flow_id, model_used, agent_role, prompt_hash, input_tokens, output_tokens, total_cost_usd, status)
+		VALUES ('seed', 'gpt-4o', 'Implementation', 'h', 1, 1, 2.50, 'SUCCESS')`)
+	if err != nil {
+		t.Fatalf("Failed to seed ledger: %v", err)
+	}
+
+	rr := postChatCompletion(t, NewServer(db), `{"model":"gpt-4o","messages":[{"role":"user","content":"Hi"}]}`)
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected $2.50 spent to exhaust a $2 daily limit, got %d", rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), "$2.00") {
+		t.Errorf("Expected the configured limit in the error, got %s", rr.Body.String())
+	}
+}
+
+func TestHandleChatCompletions_ProviderErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantType   string
+	}{
+		{"unauthorized", &llm.APIError{Provider: llm.ProviderOpenAI, StatusCode: 401, Message: "bad key"}, http.StatusUnauthorized, "authentication_error"},
+		{"rate limited", &llm.APIError{Provider: llm.ProviderOpenAI, StatusCode: 429, Message: "slow down"}, http.StatusTooManyRequests, "rate_limit_error"},
+		{"upstream outage", &llm.APIError{Provider: llm.ProviderOpenAI, StatusCode: 500, Message: "oops"}, http.StatusBadGateway, "api_error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := setupTestDB(t)
+			defer db.Close()
+
+			srv := NewServer(db)
+			srv.gateway.OpenAIClient = &MockLLMProvider{
+				SendFunc: func(systemPrompt, userPrompt, apiKey string) (string, int, int, error) {
+					return "", 0, 0, tt.err
+				},
+			}
+
+			rr := postChatCompletion(t, srv, `{"model":"gpt-4o","messages":[{"role":"user","content":"Hi"}]}`)
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tt.wantStatus, rr.Code)
+			}
+
+			var resp chatCompletionError
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Failed to decode error: %v", err)
+			}
+			if resp.Error.Type != tt.wantType {
+				t.Errorf("Expected error type %s, got %s", tt.wantType, resp.Error.Type)
+			}
+		})
+	}
+}
+
+func TestHandleChatCompletions_InvalidRequest(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	srv := NewServer(db)
+
+	for _, body := range []string{`{bad`, `{"messages":[{"role":"user","content":"x"}]}`, `{"model":"gpt-4o","messages":[]}`} {
+		rr := postChatCompletion(t, srv, body)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Body %s: expected 400, got %d", body, rr.Code)
+		}
+	}
+}
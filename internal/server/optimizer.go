@@ -4,40 +4,60 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/mikejsmith1985/forge-orchestrator/internal/optimizer"
 )
 
-// handleGetOptimizations triggers the analyzer and returns a list of suggestions.
+// handleGetOptimizations triggers the analyzer and returns its suggestions,
+// wrapped with how many ledger rows were analyzed so the UI can pick the right empty state.
 func (s *Server) handleGetOptimizations(w http.ResponseWriter, r *http.Request) {
-	suggestions, err := optimizer.AnalyzeLedger(s.db)
+	analysis, err := optimizer.Analyze(s.db)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(suggestions)
+	json.NewEncoder(w).Encode(analysis)
+}
+
+// handleGetOptimizationReport returns the pending suggestions as a Markdown report to share
+// with a team. It runs the analyzer first, so it matches what /api/ledger/optimizations shows.
+func (s *Server) handleGetOptimizationReport(w http.ResponseWriter, r *http.Request) {
+	if format := r.URL.Query().Get("format"); format != "" && format != "md" {
+		writeJSONError(w, http.StatusBadRequest, "Unsupported format: only md is available")
+		return
+	}
+
+	analysis, err := optimizer.Analyze(s.db)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	w.Write([]byte(optimizer.MarkdownReport(analysis.Suggestions, time.Now())))
 }
 
 // handleApplyOptimization applies a selected optimization suggestion.
 func (s *Server) handleApplyOptimization(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
 	if idStr == "" {
-		http.Error(w, "Optimization ID is required", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "Optimization ID is required")
 		return
 	}
 
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, "Invalid optimization ID", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "Invalid optimization ID")
 		return
 	}
 
 	// Apply the optimization using the applier
 	result, err := optimizer.ApplyOptimization(s.db, id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
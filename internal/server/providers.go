@@ -0,0 +1,64 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/llm"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/models"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/security"
+)
+
+// ProviderResponse describes one provider the gateway can route to, for the UI's dropdowns.
+type ProviderResponse struct {
+	Name         string   `json:"name"` // as used in flow nodes and run requests, e.g. "Anthropic"
+	Models       []string `json:"models"`
+	DefaultModel string   `json:"default_model"`
+	CostUnit     string   `json:"cost_unit"`   // TOKEN or PROMPT
+	InputRate    float64  `json:"input_rate"`  // USD per 1M input tokens
+	OutputRate   float64  `json:"output_rate"` // USD per 1M output tokens
+	PromptRate   float64  `json:"prompt_rate"` // USD per call, for PROMPT providers
+	Configured   bool     `json:"configured"`  // whether the keyring holds its API key
+}
+
+// handleGetProviders lists the providers the gateway knows, with their models, pricing
+// (including any Budget.Pricing override) and whether each is ready to use.
+func (s *Server) handleGetProviders(w http.ResponseWriter, r *http.Request) {
+	providers := []ProviderResponse{}
+	for _, info := range s.gateway.Providers() {
+		pricing := llm.Pricing(info.Provider)
+		_, err := security.GetAPIKey(string(info.Provider))
+		providers = append(providers, ProviderResponse{
+			Name:         string(info.Provider),
+			Models:       info.Models,
+			DefaultModel: info.DefaultModel,
+			CostUnit:     string(pricing.PrimaryCostUnit),
+			InputRate:    pricing.InputRate,
+			OutputRate:   pricing.OutputRate,
+			PromptRate:   pricing.PromptRate,
+			Configured:   err == nil,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"providers": providers})
+}
+
+// handleGetModels lists every model in the registry with its provider, rates and context
+// window. The model each provider's client calls shows its effective pricing, including
+// any Budget.Pricing override, so it matches what the ledger bills.
+func (s *Server) handleGetModels(w http.ResponseWriter, r *http.Request) {
+	list := models.All()
+	for i, m := range list {
+		pricing := llm.Pricing(llm.ProviderType(m.Provider))
+		if pricing.Model != m.Name {
+			continue
+		}
+		list[i].InputRate = pricing.InputRate
+		list[i].OutputRate = pricing.OutputRate
+		list[i].ContextWindow = pricing.MaxTokens
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"models": list})
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/config"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/llm"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/models"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/security"
+	"github.com/zalando/go-keyring"
+)
+
+func TestHandleGetProviders(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir()) // built-in pricing
+	keyring.MockInit()
+	t.Cleanup(keyring.MockInit) // other tests expect no stored keys
+	security.SetAPIKey("OpenAI", "sk-test")
+
+	srv := NewServer(nil)
+	rr := httptest.NewRecorder()
+	srv.RegisterRoutes().ServeHTTP(rr, httptest.NewRequest("GET", "/api/providers", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var body struct {
+		Providers []ProviderResponse `json:"providers"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(body.Providers) != 2 {
+		t.Fatalf("Expected Anthropic and OpenAI, got %+v", body.Providers)
+	}
+
+	byName := map[string]ProviderResponse{}
+	for _, p := range body.Providers {
+		byName[p.Name] = p
+	}
+	openAI, anthropic := byName["OpenAI"], byName["Anthropic"]
+	if !openAI.Configured || anthropic.Configured {
+		t.Errorf("Expected only OpenAI to be configured, got OpenAI=%v Anthropic=%v", openAI.Configured, anthropic.Configured)
+	}
+	if openAI.DefaultModel != llm.OpenAIModel || len(openAI.Models) != 1 || openAI.Models[0] != llm.OpenAIModel {
+		t.Errorf("Expected OpenAI's model list, got %+v", openAI)
+	}
+	if anthropic.DefaultModel != llm.AnthropicModel || anthropic.CostUnit != "TOKEN" || anthropic.InputRate != 3.00 {
+		t.Errorf("Expected Anthropic's model and token pricing, got %+v", anthropic)
+	}
+}
+
+func TestHandleGetModels(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	cfg := config.DefaultConfig()
+	cfg.Budget.Pricing = map[string]config.ProviderPricing{"Anthropic": {InputRate: 2, OutputRate: 10}}
+	if err := config.Save(cfg); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	t.Cleanup(func() { config.Save(config.DefaultConfig()) })
+
+	srv := NewServer(nil)
+	rr := httptest.NewRecorder()
+	srv.RegisterRoutes().ServeHTTP(rr, httptest.NewRequest("GET", "/api/models", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var body struct {
+		Models []models.Model `json:"models"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	byName := map[string]models.Model{}
+	for _, m := range body.Models {
+		byName[m.Name] = m
+	}
+
+	if m := byName["gpt-4o"]; m.Provider != "OpenAI" || m.InputRate != 5.00 || m.OutputRate != 15.00 || m.ContextWindow != 128_000 {
+		t.Errorf("Expected gpt-4o's registry metadata, got %+v", m)
+	}
+	if m := byName["gpt-4"]; m.ContextWindow != 8_192 || m.CheaperAlternative != "gpt-3.5-turbo" {
+		t.Errorf("Expected gpt-4's window and cheaper alternative, got %+v", m)
+	}
+	// The override applies to the model Anthropic's client calls, not to the rest of its models
+	if m := byName[llm.AnthropicModel]; m.InputRate != 2 || m.OutputRate != 10 || m.ContextWindow != 200_000 {
+		t.Errorf("Expected the configured Anthropic rates, got %+v", m)
+	}
+	if m := byName["claude-3-opus"]; m.InputRate != 15.00 {
+		t.Errorf("Expected claude-3-opus at its list price, got %+v", m)
+	}
+}
@@ -5,7 +5,6 @@ package server
 import (
 	"fmt"
 	"io"
-	"log"
 	"os"
 	"os/exec"
 	"runtime"
@@ -14,6 +13,9 @@ import (
 
 	"github.com/gorilla/websocket"
 	"github.com/mikejsmith1985/forge-orchestrator/internal/config"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/execution"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/logging"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/metrics"
 )
 
 // PTYSession represents an active PTY session connected to a WebSocket client.
@@ -59,20 +61,20 @@ func (pm *PTYManager) CreateSession(sessionID string, conn *websocket.Conn) (*PT
 	// Load configuration
 	cfg, err := config.Get()
 	if err != nil {
-		log.Printf("Failed to load config, using defaults: %v", err)
+		logging.Warnf("Failed to load config, using defaults: %v", err)
 		cfg = config.DefaultConfig()
 	}
 
-	// Determine shell based on configuration and platform
-	shell := ""
+	// Determine shell based on configuration and platform.
+	// /api/execute picks its shell the same way, so commands behave alike in both.
+	shell := execution.DefaultShell(cfg.Shell)
 	shellArgs := []string{}
 	var cmd *exec.Cmd
 
 	if runtime.GOOS == "windows" {
 		// Windows shell selection
-		switch cfg.Shell.Type {
-		case config.ShellWSL:
-			shell = "wsl.exe"
+		switch shell {
+		case "wsl.exe":
 			if cfg.Shell.WSLDistro != "" {
 				shellArgs = append(shellArgs, "-d", cfg.Shell.WSLDistro)
 			}
@@ -83,7 +85,7 @@ func (pm *PTYManager) CreateSession(sessionID string, conn *websocket.Conn) (*PT
 				// Default to current working directory
 				cwd, err := os.Getwd()
 				if err != nil {
-					log.Printf("Failed to get current directory, using home: %v", err)
+					logging.Warnf("Failed to get current directory, using home: %v", err)
 					startDir = "~"
 				} else {
 					// Convert Windows path to WSL path format
@@ -92,26 +94,16 @@ func (pm *PTYManager) CreateSession(sessionID string, conn *websocket.Conn) (*PT
 			}
 			
 			shellArgs = append(shellArgs, "--cd", startDir, "-e", "bash", "-l")
-			log.Printf("Starting WSL terminal (distro: %s, dir: %s)", cfg.Shell.WSLDistro, startDir)
-		case config.ShellPowerShell:
-			shell = "powershell.exe"
-			log.Printf("Starting PowerShell terminal")
-		case config.ShellCmd:
-			shell = "cmd.exe"
-			log.Printf("Starting CMD terminal")
+			logging.Infof("Starting WSL terminal (distro: %s, dir: %s)", cfg.Shell.WSLDistro, startDir)
+		case "powershell.exe":
+			logging.Infof("Starting PowerShell terminal")
 		default:
-			// Default to CMD on Windows
-			shell = "cmd.exe"
-			log.Printf("Starting CMD terminal (default)")
+			logging.Infof("Starting CMD terminal (shell type: %q)", cfg.Shell.Type)
 		}
 	} else {
 		// Unix/Linux shell selection
-		shell = os.Getenv("SHELL")
-		if shell == "" {
-			shell = "/bin/bash"
-		}
 		shellArgs = []string{"-l"}
-		log.Printf("Starting Unix shell: %s", shell)
+		logging.Infof("Starting Unix shell: %s", shell)
 	}
 
 	// Create the command (only used on Unix)
@@ -134,7 +126,7 @@ func (pm *PTYManager) CreateSession(sessionID string, conn *websocket.Conn) (*PT
 	}
 	if err != nil {
 		errMsg := fmt.Sprintf("Failed to start %s terminal: %v", shell, err)
-		log.Printf("PTY creation error: %s", errMsg)
+		logging.Errorf("PTY creation error: %s", errMsg)
 		return nil, fmt.Errorf("%s", errMsg)
 	}
 
@@ -147,9 +139,10 @@ func (pm *PTYManager) CreateSession(sessionID string, conn *websocket.Conn) (*PT
 
 	pm.mu.Lock()
 	pm.sessions[sessionID] = session
+	metrics.PTYSessions.Set(float64(len(pm.sessions)))
 	pm.mu.Unlock()
 
-	log.Printf("PTY session %s created successfully with shell: %s", sessionID, shell)
+	logging.Infof("PTY session %s created successfully with shell: %s", sessionID, shell)
 
 	// Start goroutine to read from PTY and send to WebSocket
 	go session.readPTYLoop()
@@ -158,7 +151,7 @@ func (pm *PTYManager) CreateSession(sessionID string, conn *websocket.Conn) (*PT
 	if cmd != nil {
 		go func() {
 			_ = cmd.Wait()
-			log.Printf("PTY session %s: shell process exited", sessionID)
+			logging.Infof("PTY session %s: shell process exited", sessionID)
 			session.closeOnce.Do(func() {
 				close(session.done)
 			})
@@ -181,6 +174,7 @@ func (pm *PTYManager) CloseSession(sessionID string) {
 	session, exists := pm.sessions[sessionID]
 	if exists {
 		delete(pm.sessions, sessionID)
+		metrics.PTYSessions.Set(float64(len(pm.sessions)))
 	}
 	pm.mu.Unlock()
 
@@ -189,6 +183,19 @@ func (pm *PTYManager) CloseSession(sessionID string) {
 	}
 }
 
+// CloseAll closes and removes every active PTY session.
+func (pm *PTYManager) CloseAll() {
+	pm.mu.Lock()
+	sessions := pm.sessions
+	pm.sessions = make(map[string]*PTYSession)
+	metrics.PTYSessions.Set(0)
+	pm.mu.Unlock()
+
+	for _, session := range sessions {
+		session.Close()
+	}
+}
+
 // readPTYLoop reads output from the PTY and sends it to the WebSocket.
 func (s *PTYSession) readPTYLoop() {
 	buf := make([]byte, 4096)
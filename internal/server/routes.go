@@ -1,12 +1,26 @@
 package server
 
 import (
+	"encoding/json"
 	"net/http"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/metrics"
 )
 
+// RegisterRoutes returns the API routes with request body checks and response compression applied.
 func (s *Server) RegisterRoutes() http.Handler {
+	return GzipMiddleware(BodyLimitMiddleware(JSONContentTypeMiddleware(s.Mux())))
+}
+
+// Mux returns the API routes without middleware, for callers that register more
+// handlers on it. They should wrap the result in GzipMiddleware, BodyLimitMiddleware
+// and JSONContentTypeMiddleware themselves.
+func (s *Server) Mux() *http.ServeMux {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/api/health", s.healthHandler)
+	// Prometheus scrape endpoint, also under /api for setups that only proxy the API prefix
+	mux.Handle("GET /metrics", metrics.Handler())
+	mux.Handle("GET /api/metrics", metrics.Handler())
 	mux.HandleFunc("/ws", s.websocketHandler)
 	// PTY WebSocket endpoint for integrated terminal
 	mux.HandleFunc("/ws/pty", s.handlePTYWebSocket)
@@ -15,8 +29,16 @@ func (s *Server) RegisterRoutes() http.Handler {
 	mux.HandleFunc("POST /api/execute", s.handleExecute)
 	mux.HandleFunc("POST /api/ledger", s.handleCreateLedgerEntry)
 	mux.HandleFunc("GET /api/ledger", s.handleGetLedger)
+	mux.HandleFunc("GET /api/ledger/trends", s.handleGetLedgerTrends)
+	mux.HandleFunc("GET /api/ledger/{id}", s.handleGetLedgerEntry)
+	mux.HandleFunc("GET /api/ledger/{id}/prompt", s.handleGetLedgerPrompt)
+	mux.HandleFunc("POST /api/ledger/{id}/replay", s.handleReplayLedgerEntry)
 	mux.HandleFunc("POST /api/tokens/estimate", s.handleEstimateTokens)
 	mux.HandleFunc("GET /api/budget", s.handleGetBudget)
+	mux.HandleFunc("GET /api/budget/monthly", s.handleGetMonthlyBudget)
+	mux.HandleFunc("GET /api/stats", s.handleGetStats)
+	mux.HandleFunc("POST /api/spending/pause", s.handlePauseSpending)
+	mux.HandleFunc("POST /api/spending/resume", s.handleResumeSpending)
 
 	// Command Cards Routes
 	mux.HandleFunc("GET /api/commands", s.handleGetCommands)
@@ -29,12 +51,18 @@ func (s *Server) RegisterRoutes() http.Handler {
 
 	// Optimizer Routes
 	mux.HandleFunc("GET /api/ledger/optimizations", s.handleGetOptimizations)
+	mux.HandleFunc("GET /api/ledger/optimizations/report", s.handleGetOptimizationReport)
 	mux.HandleFunc("POST /api/ledger/optimizations/{id}/apply", s.handleApplyOptimization)
 
 	// Keyring Routes
 	mux.HandleFunc("POST /api/keys", s.handleSetAPIKey)
 	mux.HandleFunc("GET /api/keys/status", s.handleGetAPIKeyStatus)
+	mux.HandleFunc("GET /api/keys/audit", s.handleGetKeyAudit)
+	mux.HandleFunc("GET /api/providers", s.handleGetProviders)
+	mux.HandleFunc("GET /api/models", s.handleGetModels)
+	mux.HandleFunc("DELETE /api/keys", s.handleDeleteAllAPIKeys)
 	mux.HandleFunc("DELETE /api/keys/{provider}", s.handleDeleteAPIKey)
+	mux.HandleFunc("POST /api/keys/{provider}/rotate", s.handleRotateAPIKey)
 
 	// Flows Routes
 	mux.HandleFunc("GET /api/flows", s.handleGetFlows)
@@ -42,8 +70,15 @@ func (s *Server) RegisterRoutes() http.Handler {
 	mux.HandleFunc("POST /api/flows", s.handleCreateFlow)
 	mux.HandleFunc("PUT /api/flows/{id}", s.handleUpdateFlow)
 	mux.HandleFunc("DELETE /api/flows/{id}", s.handleDeleteFlow)
+	mux.HandleFunc("POST /api/flows/{id}/restore", s.handleRestoreFlow)
+	mux.HandleFunc("GET /api/flows/{id}/versions", s.handleGetFlowVersions)
+	mux.HandleFunc("POST /api/flows/{id}/revert/{version}", s.handleRevertFlow)
 	mux.HandleFunc("POST /api/flows/{id}/execute", s.handleExecuteFlow)
+	mux.HandleFunc("POST /api/flows/{id}/run", s.handleRunFlow)
+	mux.HandleFunc("GET /api/flows/{id}/estimate", s.handleEstimateFlow)
+	mux.HandleFunc("GET /api/flows/status", s.handleListFlowStatuses)
 	mux.HandleFunc("GET /api/flows/{id}/status", s.handleGetFlowStatus)
+	mux.HandleFunc("GET /api/flows/{id}/events/history", s.handleGetFlowEventHistory)
 
 	// Welcome/Onboarding Routes
 	mux.HandleFunc("GET /api/welcome", s.handleGetWelcome)
@@ -53,6 +88,22 @@ func (s *Server) RegisterRoutes() http.Handler {
 	mux.HandleFunc("GET /api/config", s.handleGetConfig)
 	mux.HandleFunc("POST /api/config", s.handleSaveConfig)
 
+	// Agent Routes
+	mux.HandleFunc("GET /api/agents", s.handleListAgents)
+	mux.HandleFunc("GET /api/agents/roles", s.handleListAgents)
+	mux.HandleFunc("GET /api/agents/{role}/prompt", s.handleGetAgentPrompt)
+	mux.HandleFunc("GET /api/agents/custom", s.handleGetCustomAgents)
+	mux.HandleFunc("POST /api/agents/custom", s.handleCreateCustomAgent)
+	mux.HandleFunc("PUT /api/agents/custom/{id}", s.handleUpdateCustomAgent)
+	mux.HandleFunc("DELETE /api/agents/custom/{id}", s.handleDeleteCustomAgent)
+
+	// OpenAI-compatible proxy so external SDK-based tools get budget checks and ledger tracking
+	mux.HandleFunc("POST /api/v1/chat/completions", s.handleChatCompletions)
+
+	// Feedback Routes
+	mux.HandleFunc("POST /api/feedback/screenshots", s.handleUploadScreenshot)
+	mux.HandleFunc("GET /api/feedback/screenshots/{name}", s.handleGetScreenshot)
+
 	return mux
 }
 
@@ -62,9 +113,19 @@ func (s *Server) RegisterRoutesWithCORS() http.Handler {
 }
 
 func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
+	warm, method := s.tokenizerWarm()
+	health := map[string]interface{}{
+		"status":         "ok",
+		"spendingPaused": spendingPaused(),
+		"tokenizerWarm":  warm,
+	}
+	if warm {
+		health["tokenizerMethod"] = method // tiktoken, or heuristic when its data couldn't be loaded
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(`{"status": "ok"}`))
+	json.NewEncoder(w).Encode(health)
 }
 
 func (s *Server) websocketHandler(w http.ResponseWriter, r *http.Request) {
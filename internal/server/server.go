@@ -1,25 +1,81 @@
 package server
 
 import (
+	"context"
 	"database/sql"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/mikejsmith1985/forge-orchestrator/internal/agents"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/lifecycle"
 	"github.com/mikejsmith1985/forge-orchestrator/internal/llm"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/logging"
 )
 
+// closeTimeout bounds how long Close waits for the hub loop of a server made by NewServer.
+const closeTimeout = 5 * time.Second
+
 type Server struct {
 	db         *sql.DB
 	gateway    *llm.Gateway
 	hub        *Hub
 	ptyManager *PTYManager
+	cancel     context.CancelFunc
+
+	// lc runs the hub loop; ownsLC means NewServer made it and Close must shut it down
+	lc     *lifecycle.Manager
+	ownsLC bool
+
+	// flowRuns tracks /api/flows/{id}/run executions, which outlive the request that started them
+	flowRuns sync.WaitGroup
+
+	// activeCommandRuns counts in-flight /api/commands/{id}/run calls for the concurrency cap
+	activeCommandRuns atomic.Int32
+
+	// tokenizerMethod is the estimation method found by WarmTokenizer; unset until it has run
+	tokenizerMethod atomic.Value
 }
 
+// NewServer creates a Server whose background work runs on a lifecycle manager of its own,
+// stopped by Close. main uses NewServerWithLifecycle so its Shutdown waits for the hub too.
 func NewServer(db *sql.DB) *Server {
+	s := NewServerWithLifecycle(db, lifecycle.New())
+	s.ownsLC = true
+	return s
+}
+
+// NewServerWithLifecycle creates a Server and starts its hub loop as the "hub" task of lc.
+// The hub stops when lc shuts down or when Close is called, whichever comes first.
+func NewServerWithLifecycle(db *sql.DB, lc *lifecycle.Manager) *Server {
+	ctx, cancel := context.WithCancel(lc.Context())
 	hub := NewHub()
-	go hub.Run()
+	lc.Go("hub", func(context.Context) {
+		hub.RunContext(ctx)
+	})
+	// Let role lookups (gateway, flows, /api/agents) find the user's custom roles
+	agents.UseCustomRoles(db)
 	return &Server{
 		db:         db,
 		gateway:    llm.NewGateway(),
 		hub:        hub,
 		ptyManager: NewPTYManager(),
+		cancel:     cancel,
+		lc:         lc,
+	}
+}
+
+// Close stops the hub loop and terminates any open PTY sessions.
+func (s *Server) Close() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	if s.ptyManager != nil {
+		s.ptyManager.CloseAll()
+	}
+	if s.ownsLC {
+		if err := s.lc.Shutdown(closeTimeout); err != nil {
+			logging.Errorf("server close: %v", err)
+		}
 	}
 }
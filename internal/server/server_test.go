@@ -1,6 +1,9 @@
 package server
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -8,6 +11,8 @@ import (
 	"time"
 
 	"github.com/gorilla/websocket"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/lifecycle"
 )
 
 func TestHealthHandler(t *testing.T) {
@@ -35,10 +40,15 @@ func TestHealthHandler(t *testing.T) {
 	}
 
 	// Check the response body is what we expect.
-	expected := `{"status": "ok"}`
-	if strings.TrimSpace(rr.Body.String()) != expected {
-		t.Errorf("handler returned unexpected body: got %v want %v",
-			rr.Body.String(), expected)
+	var body map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("handler returned invalid JSON: %v", err)
+	}
+	if body["status"] != "ok" {
+		t.Errorf("handler returned unexpected status: got %v want ok", body["status"])
+	}
+	if _, ok := body["spendingPaused"]; !ok {
+		t.Errorf("handler should report spendingPaused, got %v", rr.Body.String())
 	}
 }
 
@@ -150,3 +160,193 @@ func TestHubBroadcast(t *testing.T) {
 		t.Errorf("Client 2 received wrong message: got %v want %v", string(msg2), string(testMessage))
 	}
 }
+
+func TestHubEvictsSlowClient(t *testing.T) {
+	hub := NewHub()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go hub.RunContext(ctx)
+
+	// The stalled client never reads; the healthy one drains its buffer as messages arrive
+	stalled := &Client{hub: hub, send: make(chan []byte, 2)}
+	healthy := &Client{hub: hub, send: make(chan []byte, 2)}
+	hub.register <- stalled
+	hub.register <- healthy
+
+	received := make(chan string, 10)
+	go func() {
+		for message := range healthy.send {
+			received <- string(message)
+		}
+	}()
+
+	for i := 0; i < 5; i++ {
+		hub.Broadcast([]byte(fmt.Sprintf("update %d", i)))
+		select {
+		case got := <-received:
+			if want := fmt.Sprintf("update %d", i); got != want {
+				t.Fatalf("Healthy client got %q, want %q", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Healthy client stopped receiving at update %d", i)
+		}
+	}
+
+	hub.mu.RLock()
+	stillRegistered := hub.clients[stalled]
+	hub.mu.RUnlock()
+	if stillRegistered {
+		t.Error("Expected the stalled client to be evicted")
+	}
+
+	// The stalled client's channel holds what fit in its buffer, then is closed
+	var queued int
+	for range stalled.send {
+		queued++
+	}
+	if queued != 2 {
+		t.Errorf("Expected 2 buffered messages before eviction, got %d", queued)
+	}
+
+	// Sending to an evicted client is a no-op rather than a panic
+	hub.SendToClient(stalled, []byte("late"))
+}
+
+func TestHubRunContextStopsOnCancel(t *testing.T) {
+	hub := NewHub()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		hub.RunContext(ctx)
+		close(done)
+	}()
+
+	client := &Client{hub: hub, send: make(chan []byte, 1)}
+	hub.register <- client
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Hub did not stop after context cancellation")
+	}
+
+	// The client's send channel should be closed so its writePump exits
+	if _, ok := <-client.send; ok {
+		t.Error("Expected client send channel to be closed on shutdown")
+	}
+}
+
+func TestServerHubStopsWithLifecycle(t *testing.T) {
+	lc := lifecycle.New()
+	s := NewServerWithLifecycle(nil, lc)
+	client := &Client{hub: s.hub, send: make(chan []byte, 1)}
+	if !s.hub.Register(client) {
+		t.Fatal("Expected a running hub to accept the client")
+	}
+
+	if err := lc.Shutdown(time.Second); err != nil {
+		t.Fatalf("Shutdown did not wait for the hub: %v", err)
+	}
+	if running := lc.Running(); len(running) != 0 {
+		t.Errorf("Expected no running tasks after shutdown, got %v", running)
+	}
+
+	// A client leaving after the hub stopped must not block its readPump forever
+	unregistered := make(chan struct{})
+	go func() {
+		s.hub.Unregister(client)
+		close(unregistered)
+	}()
+	select {
+	case <-unregistered:
+	case <-time.After(time.Second):
+		t.Fatal("Unregister blocked after the hub stopped")
+	}
+	if s.hub.Register(&Client{hub: s.hub, send: make(chan []byte, 1)}) {
+		t.Error("Expected a stopped hub to refuse new clients")
+	}
+
+	// Broadcasts after the hub stopped are dropped, even once the buffer would be full
+	broadcasted := make(chan struct{})
+	go func() {
+		for range cap(s.hub.broadcast) + 10 {
+			s.hub.Broadcast([]byte(`{"type":"LEDGER_UPDATE"}`))
+		}
+		close(broadcasted)
+	}()
+	select {
+	case <-broadcasted:
+	case <-time.After(time.Second):
+		t.Fatal("Broadcast blocked after the hub stopped")
+	}
+}
+
+func TestWebSocketReplaysMissedFlowEvents(t *testing.T) {
+	s := NewServer(nil)
+	defer s.Close()
+	server := httptest.NewServer(s.RegisterRoutes())
+	defer server.Close()
+	u := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+
+	// readEvents reads until n messages arrived; the write pump may batch them one per line
+	readEvents := func(ws *websocket.Conn, n int) []map[string]interface{} {
+		var events []map[string]interface{}
+		ws.SetReadDeadline(time.Now().Add(2 * time.Second))
+		for len(events) < n {
+			_, p, err := ws.ReadMessage()
+			if err != nil {
+				t.Fatalf("read after %d events: %v", len(events), err)
+			}
+			for _, line := range strings.Split(string(p), "\n") {
+				var event map[string]interface{}
+				if err := json.Unmarshal([]byte(line), &event); err != nil {
+					t.Fatalf("invalid event %q: %v", line, err)
+				}
+				events = append(events, event)
+			}
+		}
+		return events
+	}
+	nodeCompleted := func(flowID int, nodeID string) []byte {
+		return []byte(fmt.Sprintf(`{"type":"NODE_COMPLETED","payload":{"flowId":%d,"nodeId":%q}}`, flowID, nodeID))
+	}
+
+	ws, _, err := websocket.DefaultDialer.Dial(u, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	s.hub.Broadcast(nodeCompleted(7, "1"))
+	first := readEvents(ws, 1)
+	if first[0]["seq"] != float64(1) {
+		t.Fatalf("Expected the first event to be seq 1, got %v", first[0])
+	}
+
+	// The connection drops while the flow carries on
+	ws.Close()
+	s.hub.Broadcast(nodeCompleted(7, "2"))
+	s.hub.Broadcast(nodeCompleted(8, "other flow"))
+	s.hub.Broadcast(nodeCompleted(7, "3"))
+
+	ws, _, err = websocket.DefaultDialer.Dial(u+"?flow_id=7&last_seq=1", nil)
+	if err != nil {
+		t.Fatalf("reconnect: %v", err)
+	}
+	defer ws.Close()
+
+	var nodes []string
+	for len(nodes) < 2 {
+		for _, event := range readEvents(ws, 1) {
+			payload := event["payload"].(map[string]interface{})
+			if payload["flowId"] != float64(7) {
+				continue // the other flow's event arrives live if the reconnect beat it to the hub
+			}
+			nodes = append(nodes, fmt.Sprintf("%v:%v", event["seq"], payload["nodeId"]))
+		}
+	}
+	if strings.Join(nodes, ",") != "2:2,3:3" {
+		t.Errorf("Expected the missed events 2 and 3 in order, got %v", nodes)
+	}
+}
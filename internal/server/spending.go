@@ -0,0 +1,57 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/config"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/logging"
+)
+
+// SpendingStatusResponse reports whether the spending kill switch is on.
+type SpendingStatusResponse struct {
+	Paused bool `json:"paused"`
+}
+
+// spendingPaused reports the persisted kill switch state (false if the config can't be read).
+func spendingPaused() bool {
+	cfg, err := config.Get()
+	return err == nil && cfg.Budget.SpendingPaused
+}
+
+// setSpendingPaused persists the kill switch so it survives a restart.
+func setSpendingPaused(paused bool) error {
+	cfg, err := config.Get()
+	if err != nil {
+		return err
+	}
+	cfg.Budget.SpendingPaused = paused
+	return config.Save(cfg)
+}
+
+// handlePauseSpending is the emergency stop: the LLM Gateway refuses every
+// provider call until spending is resumed.
+func (s *Server) handlePauseSpending(w http.ResponseWriter, r *http.Request) {
+	s.writeSpendingToggle(w, true)
+}
+
+// handleResumeSpending lifts the emergency stop.
+func (s *Server) handleResumeSpending(w http.ResponseWriter, r *http.Request) {
+	s.writeSpendingToggle(w, false)
+}
+
+func (s *Server) writeSpendingToggle(w http.ResponseWriter, paused bool) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := setSpendingPaused(paused); err != nil {
+		logging.Errorf("Failed to update spending pause: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Failed to save configuration",
+		})
+		return
+	}
+
+	logging.Infof("Spending paused: %v", paused)
+	json.NewEncoder(w).Encode(SpendingStatusResponse{Paused: paused})
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/config"
+)
+
+func TestSpendingPauseAndResume(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	if err := config.Save(config.DefaultConfig()); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	defer config.Save(config.DefaultConfig())
+
+	db := setupTestDB(t)
+	defer db.Close()
+	res, _ := db.Exec("INSERT INTO command_cards (name, command, description) VALUES (?, ?, ?)", "Cmd", "echo", "Desc")
+	id, _ := res.LastInsertId()
+
+	calls := 0
+	srv := NewServer(db)
+	srv.gateway.OpenAIClient = &MockLLMProvider{
+		SendFunc: func(systemPrompt, userPrompt, apiKey string) (string, int, int, error) {
+			calls++
+			return "ok", 1, 1, nil
+		},
+	}
+	handler := srv.RegisterRoutes()
+
+	post := func(path string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, bytes.NewReader(body))
+		req.Header.Set("X-Forge-Api-Key", "test-key")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+	runBody, _ := json.Marshal(RunCommandRequest{AgentRole: "Implementation", Provider: "OpenAI"})
+	runPath := "/api/commands/" + strconv.Itoa(int(id)) + "/run"
+	healthPaused := func() bool {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", "/api/health", nil))
+		var health struct {
+			SpendingPaused bool `json:"spendingPaused"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &health)
+		return health.SpendingPaused
+	}
+
+	rr := post("/api/spending/pause", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected pause to succeed, got %d", rr.Code)
+	}
+	if !healthPaused() {
+		t.Error("Expected /api/health to report spending paused")
+	}
+	if cfg, _ := config.Get(); !cfg.Budget.SpendingPaused {
+		t.Error("Expected the pause to be persisted in config")
+	}
+
+	rr = post(runPath, runBody)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 while paused, got %d", rr.Code)
+	}
+	if calls != 0 {
+		t.Errorf("Expected no provider calls while paused, got %d", calls)
+	}
+
+	rr = post("/api/spending/resume", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected resume to succeed, got %d", rr.Code)
+	}
+	if healthPaused() {
+		t.Error("Expected /api/health to report spending resumed")
+	}
+
+	rr = post(runPath, runBody)
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200 after resume, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if calls != 1 {
+		t.Errorf("Expected one provider call after resume, got %d", calls)
+	}
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+const (
+	// statsRecentEntries is how many ledger entries /api/stats includes
+	statsRecentEntries = 10
+	// statsTopModels is how many models /api/stats ranks
+	statsTopModels = 5
+	// statsTopModelDays is the window, in days, the model ranking covers
+	statsTopModelDays = 30
+)
+
+// StatsToday is today's activity, in the budget's time zone.
+type StatsToday struct {
+	SpentUSD     float64 `json:"spent_usd"`
+	BudgetUSD    float64 `json:"budget_usd"`
+	RemainingUSD float64 `json:"remaining_usd"`
+	Calls        int     `json:"calls"`
+	SuccessCount int     `json:"success_count"`
+	FailureCount int     `json:"failure_count"`
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+}
+
+// ModelStats is one model's share of recent spend.
+type ModelStats struct {
+	Model   string  `json:"model"`
+	Calls   int     `json:"calls"`
+	CostUSD float64 `json:"cost_usd"`
+	Tokens  int     `json:"tokens"`
+}
+
+// StatsResponse is everything the dashboard needs in one round trip.
+type StatsResponse struct {
+	Today              StatsToday            `json:"today"`
+	RecentEntries      []LedgerEntryResponse `json:"recent_entries"`
+	TopModels          []ModelStats          `json:"top_models"` // By cost over the last statsTopModelDays days
+	PendingSuggestions int                   `json:"pending_suggestions"`
+	TimeZone           string                `json:"time_zone"`
+}
+
+// topModels ranks models by cost since the given UTC time string (comparable with datetime(timestamp)).
+func (s *Server) topModels(since string, limit int) ([]ModelStats, error) {
+	rows, err := s.db.Query(`
+		SELECT model_used, COUNT(*), COALESCE(SUM(total_cost_usd), 0), COALESCE(SUM(input_tokens + output_tokens), 0)
+		FROM token_ledger
+		WHERE datetime(timestamp) >= ?
+		GROUP BY model_used
+		ORDER BY SUM(total_cost_usd) DESC, COUNT(*) DESC
+		LIMIT ?`, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	models := []ModelStats{}
+	for rows.Next() {
+		var m ModelStats
+		if err := rows.Scan(&m.Model, &m.Calls, &m.CostUSD, &m.Tokens); err != nil {
+			return nil, err
+		}
+		models = append(models, m)
+	}
+	return models, rows.Err()
+}
+
+// handleGetStats aggregates the dashboard's budget, recent ledger entries, top models
+// and pending optimization count, which otherwise take four separate requests.
+func (s *Server) handleGetStats(w http.ResponseWriter, r *http.Request) {
+	budget := budgetConfig()
+	loc := budget.Location()
+	start, end := budgetDay()
+
+	today := StatsToday{
+		SpentUSD:  s.spentBetween(start, end, ""),
+		BudgetUSD: dailyLimitUSD(budget),
+	}
+	today.RemainingUSD = max(today.BudgetUSD-today.SpentUSD, 0)
+	counts := s.callCountsToday("")
+	today.Calls, today.SuccessCount, today.FailureCount = counts.Total, counts.Success, counts.Failure
+	today.InputTokens, today.OutputTokens = s.tokensBetween(start, end, "")
+
+	recent, err := s.recentLedgerEntries(statsRecentEntries, "", "")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	since := time.Now().AddDate(0, 0, -statsTopModelDays).UTC().Format("2006-01-02 15:04:05")
+	models, err := s.topModels(since, statsTopModels)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var pending int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM optimization_suggestions WHERE status = 'pending'`).Scan(&pending); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(StatsResponse{
+		Today:              today,
+		RecentEntries:      recent,
+		TopModels:          models,
+		PendingSuggestions: pending,
+		TimeZone:           loc.String(),
+	})
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/data"
+)
+
+func getStats(t *testing.T, srv *Server) StatsResponse {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	srv.RegisterRoutes().ServeHTTP(rr, httptest.NewRequest("GET", "/api/stats", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp StatsResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode stats: %v", err)
+	}
+	return resp
+}
+
+func TestHandleGetStats(t *testing.T) {
+	useTrendsTimeZone(t, "")
+	srv := setupFlowTestServer(t)
+
+	ledger := data.NewLedgerService(srv.db)
+	for _, e := range []data.TokenLedgerEntry{
+		{ModelUsed: "gpt-4o", TotalCostUSD: 1.00, InputTokens: 100, OutputTokens: 10, Status: "SUCCESS"},
+		{ModelUsed: "gpt-4o", TotalCostUSD: 2.00, InputTokens: 200, OutputTokens: 20, Status: "FAILED"},
+		{ModelUsed: "claude-3-5-sonnet", TotalCostUSD: 0.50, InputTokens: 50, OutputTokens: 5, Status: "SUCCESS"},
+		// Too old for today's figures, but still in the model ranking
+		{Timestamp: time.Now().AddDate(0, 0, -3), ModelUsed: "claude-3-5-sonnet", TotalCostUSD: 4.00, Status: "SUCCESS"},
+	} {
+		e.FlowID, e.AgentRole, e.PromptHash = "flow-1", "Implementation", "h"
+		if err := ledger.LogUsage(e); err != nil {
+			t.Fatalf("Failed to seed ledger: %v", err)
+		}
+	}
+	if _, err := srv.db.Exec(`
+		INSERT INTO optimization_suggestions (type, title, description, estimated_savings, savings_unit, apply_action, status)
+		VALUES ('model_switch', 'a', 'd', 1, 'USD', '{}', 'pending'),
+		       ('model_switch', 'b', 'd', 1, 'USD', '{}', 'pending'),
+		       ('model_switch', 'c', 'd', 1, 'USD', '{}', 'applied')`); err != nil {
+		t.Fatalf("Failed to seed suggestions: %v", err)
+	}
+
+	resp := getStats(t, srv)
+
+	today := resp.Today
+	if today.SpentUSD != 3.50 || today.BudgetUSD != defaultDailyBudgetUSD || today.RemainingUSD != defaultDailyBudgetUSD-3.50 {
+		t.Errorf("Expected $3.50 of $%v spent today, got %+v", defaultDailyBudgetUSD, today)
+	}
+	if today.Calls != 3 || today.SuccessCount != 2 || today.FailureCount != 1 {
+		t.Errorf("Expected 3 calls (2 success, 1 failure), got %+v", today)
+	}
+	if today.InputTokens != 350 || today.OutputTokens != 35 {
+		t.Errorf("Expected 350 input and 35 output tokens, got %+v", today)
+	}
+
+	if len(resp.RecentEntries) != 4 {
+		t.Errorf("Expected all 4 entries in recent_entries, got %d", len(resp.RecentEntries))
+	}
+
+	if len(resp.TopModels) != 2 {
+		t.Fatalf("Expected 2 top models, got %+v", resp.TopModels)
+	}
+	if top := resp.TopModels[0]; top.Model != "claude-3-5-sonnet" || top.Calls != 2 || top.CostUSD != 4.50 {
+		t.Errorf("Expected claude-3-5-sonnet first with 2 calls and $4.50, got %+v", top)
+	}
+
+	if resp.PendingSuggestions != 2 {
+		t.Errorf("Expected 2 pending suggestions, got %d", resp.PendingSuggestions)
+	}
+}
+
+func TestHandleGetStats_Empty(t *testing.T) {
+	useTrendsTimeZone(t, "")
+	srv := setupFlowTestServer(t)
+
+	rr := httptest.NewRecorder()
+	srv.RegisterRoutes().ServeHTTP(rr, httptest.NewRequest("GET", "/api/stats", nil))
+
+	// Every section is present, with empty lists rather than null
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(rr.Body.Bytes(), &raw); err != nil {
+		t.Fatalf("Failed to decode stats: %v", err)
+	}
+	for _, section := range []string{"today", "recent_entries", "top_models", "pending_suggestions"} {
+		if _, ok := raw[section]; !ok {
+			t.Errorf("Expected a %s section", section)
+		}
+	}
+	if string(raw["recent_entries"]) != "[]" || string(raw["top_models"]) != "[]" {
+		t.Errorf("Expected empty lists, got recent_entries=%s top_models=%s", raw["recent_entries"], raw["top_models"])
+	}
+}
@@ -0,0 +1,27 @@
+package server
+
+import (
+	"time"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/logging"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/tokenizer"
+)
+
+// WarmTokenizer makes one token estimate so the shared estimator loads tiktoken's
+// encoding now rather than on the first real request. main runs it in the background
+// at startup; /api/health reports once it has finished.
+// Educational Comment: Loading the encoding downloads and parses its BPE data, which
+// takes seconds. Offline it falls back to the heuristic, which still counts as warm:
+// the estimator has settled on its method and later estimates won't wait.
+func (s *Server) WarmTokenizer() {
+	start := time.Now()
+	result := tokenizer.Default().Estimate("warmup", "openai", "")
+	s.tokenizerMethod.Store(result.Method)
+	logging.Infof("Tokenizer warmed up in %s (%s)", time.Since(start).Round(time.Millisecond), result.Method)
+}
+
+// tokenizerWarm reports whether WarmTokenizer has finished, and the estimation method it settled on.
+func (s *Server) tokenizerWarm() (bool, string) {
+	method, _ := s.tokenizerMethod.Load().(string)
+	return method != "", method
+}
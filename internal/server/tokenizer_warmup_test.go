@@ -0,0 +1,35 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWarmTokenizer_ReportedInHealth(t *testing.T) {
+	s := NewServer(nil)
+	health := func() map[string]interface{} {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		s.RegisterRoutes().ServeHTTP(rr, httptest.NewRequest("GET", "/api/health", nil))
+		var body map[string]interface{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Fatalf("Failed to decode health: %v", err)
+		}
+		return body
+	}
+
+	if body := health(); body["tokenizerWarm"] != false {
+		t.Errorf("Expected tokenizerWarm false before warmup, got %v", body)
+	}
+
+	// Offline the estimator falls back to the heuristic, which still completes the warmup
+	s.WarmTokenizer()
+	body := health()
+	if body["tokenizerWarm"] != true {
+		t.Errorf("Expected tokenizerWarm true after warmup, got %v", body)
+	}
+	if method := body["tokenizerMethod"]; method != "tiktoken" && method != "heuristic" {
+		t.Errorf("Expected the estimation method in health, got %v", method)
+	}
+}
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// defaultTrendDays is how far back /api/ledger/trends looks when days isn't given
+	defaultTrendDays = 30
+	// maxTrendDays keeps an hourly chart from asking for an unbounded number of buckets
+	maxTrendDays = 366
+)
+
+// TrendBucket is the ledger activity within one time bucket.
+type TrendBucket struct {
+	Start   string  `json:"start"` // RFC 3339, in the configured time zone
+	CostUSD float64 `json:"cost_usd"`
+	Tokens  int     `json:"tokens"`
+	Calls   int     `json:"calls"`
+}
+
+// TrendsResponse is a continuous, oldest-first series of buckets for charting.
+type TrendsResponse struct {
+	Bucket   string        `json:"bucket"`
+	Days     int           `json:"days"`
+	TimeZone string        `json:"time_zone"`
+	Buckets  []TrendBucket `json:"buckets"`
+}
+
+// trendBucketStart returns the start of the bucket containing t.
+// Days and weeks start at local midnight, and weeks start on Monday.
+func trendBucketStart(t time.Time, bucket string) time.Time {
+	switch bucket {
+	case "hour":
+		return t.Truncate(time.Hour)
+	case "week":
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	}
+}
+
+// nextTrendBucket returns the start of the bucket after the one starting at start.
+// AddDate keeps days and weeks aligned to local midnight across DST changes.
+func nextTrendBucket(start time.Time, bucket string) time.Time {
+	switch bucket {
+	case "hour":
+		return start.Add(time.Hour)
+	case "week":
+		return start.AddDate(0, 0, 7)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// trendBucketCount is how many buckets cover the last days days, including the current one.
+func trendBucketCount(bucket string, days int) int {
+	switch bucket {
+	case "hour":
+		return days * 24
+	case "week":
+		return (days + 6) / 7
+	default:
+		return days
+	}
+}
+
+// handleGetLedgerTrends returns cost, tokens and calls per bucket for charting.
+// Query parameters: bucket (day, hour or week; default day) and days (default 30).
+// Every bucket in the range is present, with zeros where nothing ran, so charts stay continuous.
+// Educational Comment: SQL groups the ledger by UTC hour, and those hours are then
+// folded into buckets in Budget.TimeZone. Grouping by date(timestamp) directly would
+// split days at UTC midnight, which disagrees with the daily budget for most users.
+func (s *Server) handleGetLedgerTrends(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	bucket := q.Get("bucket")
+	if bucket == "" {
+		bucket = "day"
+	}
+	if bucket != "day" && bucket != "hour" && bucket != "week" {
+		http.Error(w, "bucket must be one of day, hour or week", http.StatusBadRequest)
+		return
+	}
+
+	days := defaultTrendDays
+	if d := q.Get("days"); d != "" {
+		if val, err := strconv.Atoi(d); err == nil && val > 0 {
+			days = min(val, maxTrendDays)
+		}
+	}
+
+	loc := budgetConfig().Location()
+	count := trendBucketCount(bucket, days)
+
+	// Lay out the empty series first, oldest to newest, ending with the current bucket
+	starts := make([]time.Time, count)
+	starts[count-1] = trendBucketStart(time.Now().In(loc), bucket)
+	for i := count - 2; i >= 0; i-- {
+		// Step back by finding the bucket containing the instant just before the next one
+		starts[i] = trendBucketStart(starts[i+1].Add(-time.Nanosecond), bucket)
+	}
+	end := nextTrendBucket(starts[count-1], bucket)
+
+	buckets := make([]TrendBucket, count)
+	index := make(map[int64]int, count) // keyed by Unix time, since time.Time == also compares locations
+	for i, start := range starts {
+		buckets[i].Start = start.Format(time.RFC3339)
+		index[start.Unix()] = i
+	}
+
+	const layout = "2006-01-02 15:04:05"
+	query := `
+		SELECT strftime('%Y-%m-%d %H:00:00', timestamp) AS hour,
+		       COALESCE(SUM(total_cost_usd), 0), COALESCE(SUM(input_tokens + output_tokens), 0), COUNT(*)
+		FROM token_ledger
+		WHERE datetime(timestamp) >= ? AND datetime(timestamp) < ?
+		GROUP BY hour`
+	rows, err := s.db.Query(query, starts[0].UTC().Format(layout), end.UTC().Format(layout))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hour string
+		var cost float64
+		var tokens, calls int
+		if err := rows.Scan(&hour, &cost, &tokens, &calls); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		utcHour, err := time.ParseInLocation(layout, hour, time.UTC)
+		if err != nil {
+			continue
+		}
+		i, ok := index[trendBucketStart(utcHour.In(loc), bucket).Unix()]
+		if !ok {
+			continue
+		}
+		buckets[i].CostUSD += cost
+		buckets[i].Tokens += tokens
+		buckets[i].Calls += calls
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(TrendsResponse{
+		Bucket:   bucket,
+		Days:     days,
+		TimeZone: loc.String(),
+		Buckets:  buckets,
+	})
+}
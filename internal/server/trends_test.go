@@ -0,0 +1,159 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/config"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/data"
+)
+
+// useTrendsTimeZone saves a config with the given Budget.TimeZone for the test.
+func useTrendsTimeZone(t *testing.T, zone string) *time.Location {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	cfg := config.DefaultConfig()
+	cfg.Budget.TimeZone = zone
+	if err := config.Save(cfg); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	t.Cleanup(func() { config.Save(config.DefaultConfig()) })
+	return cfg.Budget.Location()
+}
+
+// seedTrendEntry logs one ledger entry at ts.
+func seedTrendEntry(t *testing.T, srv *Server, ts time.Time, cost float64, tokens int) {
+	t.Helper()
+	if err := data.NewLedgerService(srv.db).LogUsage(data.TokenLedgerEntry{
+		Timestamp: ts, FlowID: "flow-1", ModelUsed: "gpt-4o", AgentRole: "Implementation", PromptHash: "h",
+		InputTokens: tokens, TotalCostUSD: cost, Status: "SUCCESS",
+	}); err != nil {
+		t.Fatalf("Failed to seed ledger: %v", err)
+	}
+}
+
+func getTrends(t *testing.T, srv *Server, query string) TrendsResponse {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	srv.RegisterRoutes().ServeHTTP(rr, httptest.NewRequest("GET", "/api/ledger/trends"+query, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp TrendsResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode trends: %v", err)
+	}
+	return resp
+}
+
+func TestHandleGetLedgerTrends_DailyWithGaps(t *testing.T) {
+	loc := useTrendsTimeZone(t, "")
+	srv := setupFlowTestServer(t)
+
+	today := trendBucketStart(time.Now().In(loc), "day")
+	seedTrendEntry(t, srv, today, 1.50, 100)
+	seedTrendEntry(t, srv, today.Add(time.Second), 0.50, 50)
+	seedTrendEntry(t, srv, today.AddDate(0, 0, -2).Add(time.Hour), 2.00, 300)
+	seedTrendEntry(t, srv, today.AddDate(0, 0, -30), 9.00, 900) // outside the 7-day window
+
+	resp := getTrends(t, srv, "?bucket=day&days=7")
+	if resp.Bucket != "day" || resp.Days != 7 {
+		t.Errorf("Expected bucket=day days=7, got %s/%d", resp.Bucket, resp.Days)
+	}
+	if len(resp.Buckets) != 7 {
+		t.Fatalf("Expected 7 daily buckets, got %d", len(resp.Buckets))
+	}
+
+	for i, b := range resp.Buckets {
+		want := today.AddDate(0, 0, i-6).Format(time.RFC3339)
+		if b.Start != want {
+			t.Errorf("Bucket %d: expected start %s, got %s", i, want, b.Start)
+		}
+		switch i {
+		case 6:
+			if b.Calls != 2 || b.CostUSD != 2.00 || b.Tokens != 150 {
+				t.Errorf("Today: expected 2 calls/$2.00/150 tokens, got %+v", b)
+			}
+		case 4:
+			if b.Calls != 1 || b.CostUSD != 2.00 || b.Tokens != 300 {
+				t.Errorf("Two days ago: expected 1 call/$2.00/300 tokens, got %+v", b)
+			}
+		default:
+			// Empty days are still present, as zeros
+			if b.Calls != 0 || b.CostUSD != 0 || b.Tokens != 0 {
+				t.Errorf("Bucket %d: expected zeros, got %+v", i, b)
+			}
+		}
+	}
+}
+
+func TestHandleGetLedgerTrends_BucketCounts(t *testing.T) {
+	loc := useTrendsTimeZone(t, "")
+	srv := setupFlowTestServer(t)
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"", 30},
+		{"?bucket=hour&days=2", 48},
+		{"?bucket=week&days=30", 5},
+		{"?bucket=week&days=7", 1},
+		{"?days=0", 30},
+		{"?days=10000", maxTrendDays},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			resp := getTrends(t, srv, tt.query)
+			if len(resp.Buckets) != tt.want {
+				t.Errorf("Expected %d buckets, got %d", tt.want, len(resp.Buckets))
+			}
+		})
+	}
+
+	// Weekly buckets start on a Monday at local midnight
+	for _, b := range getTrends(t, srv, "?bucket=week&days=30").Buckets {
+		start, err := time.Parse(time.RFC3339, b.Start)
+		if err != nil {
+			t.Fatalf("Bad bucket start %q: %v", b.Start, err)
+		}
+		start = start.In(loc)
+		if start.Weekday() != time.Monday || start.Hour() != 0 {
+			t.Errorf("Expected weeks to start Monday 00:00, got %s", start)
+		}
+	}
+}
+
+func TestHandleGetLedgerTrends_TimeZone(t *testing.T) {
+	loc := useTrendsTimeZone(t, "Pacific/Kiritimati")
+	srv := setupFlowTestServer(t)
+
+	// Either side of local midnight lands in different local days, even though
+	// both are on the same UTC date
+	today := trendBucketStart(time.Now().In(loc), "day")
+	seedTrendEntry(t, srv, today.Add(time.Minute), 1, 10)
+	seedTrendEntry(t, srv, today.Add(-time.Minute), 2, 20)
+
+	resp := getTrends(t, srv, "?days=2")
+	if resp.TimeZone != "Pacific/Kiritimati" || len(resp.Buckets) != 2 {
+		t.Fatalf("Expected 2 buckets in Pacific/Kiritimati, got %d in %s", len(resp.Buckets), resp.TimeZone)
+	}
+	if resp.Buckets[0].Calls != 1 || resp.Buckets[0].CostUSD != 2 {
+		t.Errorf("Yesterday: expected the call before midnight, got %+v", resp.Buckets[0])
+	}
+	if resp.Buckets[1].Calls != 1 || resp.Buckets[1].CostUSD != 1 {
+		t.Errorf("Today: expected the call after midnight, got %+v", resp.Buckets[1])
+	}
+}
+
+func TestHandleGetLedgerTrends_InvalidBucket(t *testing.T) {
+	srv := setupFlowTestServer(t)
+
+	rr := httptest.NewRecorder()
+	srv.RegisterRoutes().ServeHTTP(rr, httptest.NewRequest("GET", "/api/ledger/trends?bucket=month", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown bucket, got %d", rr.Code)
+	}
+}
@@ -3,14 +3,15 @@ package server
 import (
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
 	"runtime"
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 	"github.com/mikejsmith1985/forge-orchestrator/internal/config"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/logging"
 )
 
 var upgrader = websocket.Upgrader{
@@ -23,7 +24,7 @@ var upgrader = websocket.Upgrader{
 		}
 		allowed := IsAllowedOrigin(origin)
 		if !allowed {
-			log.Printf("WebSocket: Blocked connection from origin: %s", origin)
+			logging.Warnf("WebSocket: Blocked connection from origin: %s", origin)
 		}
 		return allowed
 	},
@@ -39,15 +40,39 @@ type PTYMessage struct {
 
 // handleWebSocket upgrades the HTTP connection to a WebSocket connection
 // and handles the client communication using the Hub pattern.
+// Educational Comment: Flow events carry a per-flow "seq". A client reconnecting mid-run
+// passes ?flow_id=&last_seq= with the last one it saw and gets the events it missed first
+// (without last_seq, every buffered event of the flow).
 func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	var resumeFlowID int
+	var resumeAfterSeq int64
+	if flowID := r.URL.Query().Get("flow_id"); flowID != "" {
+		var err error
+		if resumeFlowID, err = strconv.Atoi(flowID); err != nil {
+			http.Error(w, "Invalid flow_id", http.StatusBadRequest)
+			return
+		}
+		if lastSeq := r.URL.Query().Get("last_seq"); lastSeq != "" {
+			if resumeAfterSeq, err = strconv.ParseInt(lastSeq, 10, 64); err != nil || resumeAfterSeq < 0 {
+				http.Error(w, "last_seq must be a non-negative number", http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Println("upgrade:", err)
+		logging.Errorf("upgrade: %v", err)
 		return
 	}
 
 	client := NewClient(s.hub, conn)
-	s.hub.register <- client
+	client.resumeFlowID = resumeFlowID
+	client.resumeAfterSeq = resumeAfterSeq
+	if !s.hub.Register(client) {
+		conn.Close() // shutting down
+		return
+	}
 
 	// Start goroutines for reading and writing
 	go client.writePump()
@@ -60,19 +85,19 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handlePTYWebSocket(w http.ResponseWriter, r *http.Request) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Println("PTY WebSocket upgrade error:", err)
+		logging.Errorf("PTY WebSocket upgrade error: %v", err)
 		return
 	}
 
 	// Generate a unique session ID
 	sessionID := uuid.New().String()
 
-	log.Printf("Creating PTY session %s...", sessionID)
+	logging.Infof("Creating PTY session %s...", sessionID)
 
 	// Create the PTY session
 	session, err := s.ptyManager.CreateSession(sessionID, conn)
 	if err != nil {
-		log.Printf("Failed to create PTY session %s: %v", sessionID, err)
+		logging.Errorf("Failed to create PTY session %s: %v", sessionID, err)
 		
 		// Send detailed error message to client
 		errorMsg := fmt.Sprintf("\r\n\x1b[31m✗ Failed to create terminal session\x1b[0m\r\n\r\n")
@@ -95,7 +120,7 @@ func (s *Server) handlePTYWebSocket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Printf("PTY session created successfully: %s", sessionID)
+	logging.Infof("PTY session created successfully: %s", sessionID)
 
 	// Send welcome message with shell info
 	cfg, _ := config.Get()
@@ -107,7 +132,7 @@ func (s *Server) handlePTYWebSocket(w http.ResponseWriter, r *http.Request) {
 
 	// Store session ID in connection for later reference
 	conn.SetCloseHandler(func(code int, text string) error {
-		log.Printf("PTY WebSocket closed: %s (code: %d, reason: %s)", sessionID, code, text)
+		logging.Infof("PTY WebSocket closed: %s (code: %d, reason: %s)", sessionID, code, text)
 		s.ptyManager.CloseSession(sessionID)
 		return nil
 	})
@@ -120,7 +145,7 @@ func (s *Server) handlePTYWebSocket(w http.ResponseWriter, r *http.Request) {
 			_, message, err := conn.ReadMessage()
 			if err != nil {
 				if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
-					log.Printf("PTY WebSocket read error: %v", err)
+					logging.Warnf("PTY WebSocket read error: %v", err)
 				}
 				return
 			}
@@ -133,7 +158,7 @@ func (s *Server) handlePTYWebSocket(w http.ResponseWriter, r *http.Request) {
 					session.Write([]byte(msg.Data))
 				case "resize":
 					if err := session.Resize(msg.Rows, msg.Cols); err != nil {
-						log.Printf("Resize error: %v", err)
+						logging.Warnf("Resize error: %v", err)
 					}
 				case "prompt_watcher":
 					session.SetPromptWatcher(msg.Data == "enable")
@@ -0,0 +1,35 @@
+package tls
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/config"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// NewACMEManager returns an autocert.Manager for the configured domains, caching the
+// account key and certificates in the cache directory, which it creates if needed.
+// Serve HTTPS with the manager's TLSConfig; it fetches and renews certificates as needed.
+func NewACMEManager(cfg config.ACMEConfig) (*autocert.Manager, error) {
+	if len(cfg.Domains) == 0 {
+		return nil, errors.New("server.acme.domains must list at least one domain")
+	}
+
+	cacheDir, err := cfg.ResolvedCacheDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find ACME cache directory: %w", err)
+	}
+	// The cache holds the account's private key, so only we may read it
+	if err := os.MkdirAll(cacheDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create ACME cache directory: %w", err)
+	}
+
+	return &autocert.Manager{
+		Prompt:     autocert.AcceptTOS, // turning ACME on accepts the CA's terms of service
+		HostPolicy: autocert.HostWhitelist(cfg.Domains...),
+		Cache:      autocert.DirCache(cacheDir),
+		Email:      cfg.Email,
+	}, nil
+}
@@ -0,0 +1,52 @@
+package tls
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/config"
+)
+
+func TestNewACMEManager_CreatesCacheDir(t *testing.T) {
+	cacheDir := filepath.Join(t.TempDir(), "nested", "acme")
+	manager, err := NewACMEManager(config.ACMEConfig{Enabled: true, Domains: []string{"forge.example.com"}, CacheDir: cacheDir})
+	if err != nil {
+		t.Fatalf("NewACMEManager failed: %v", err)
+	}
+
+	info, err := os.Stat(cacheDir)
+	if err != nil || !info.IsDir() {
+		t.Fatalf("Expected the cache directory to be created: %v", err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0700 {
+		t.Errorf("Expected the cache directory to be private (0700), got %v", info.Mode().Perm())
+	}
+
+	// Only the configured domains may get a certificate
+	if err := manager.HostPolicy(context.Background(), "forge.example.com"); err != nil {
+		t.Errorf("Expected the configured domain to be allowed: %v", err)
+	}
+	if err := manager.HostPolicy(context.Background(), "other.example.com"); err == nil {
+		t.Error("Expected other domains to be refused")
+	}
+}
+
+func TestNewACMEManager_DefaultCacheDirAndDomains(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	t.Setenv("LOCALAPPDATA", os.Getenv("XDG_DATA_HOME"))
+
+	if _, err := NewACMEManager(config.ACMEConfig{Enabled: true}); err == nil {
+		t.Error("Expected an error without domains")
+	}
+
+	if _, err := NewACMEManager(config.ACMEConfig{Enabled: true, Domains: []string{"forge.example.com"}}); err != nil {
+		t.Fatalf("NewACMEManager failed: %v", err)
+	}
+	dataDir, _ := config.GetDataDir()
+	if _, err := os.Stat(filepath.Join(dataDir, "acme-cache")); err != nil {
+		t.Errorf("Expected the default cache directory in the data directory: %v", err)
+	}
+}
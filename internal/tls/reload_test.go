@@ -0,0 +1,87 @@
+package tls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeCert writes a new self-signed pair to the paths and returns its serial number.
+func writeCert(t *testing.T, certPath, keyPath string) string {
+	t.Helper()
+	certPEM, keyPEM, err := GenerateSelfSignedCert()
+	if err != nil {
+		t.Fatalf("Failed to generate certificate: %v", err)
+	}
+	if err := os.WriteFile(certPath, certPEM, 0644); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+	if err := os.WriteFile(keyPath, keyPEM, 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	block, _ := pem.Decode(certPEM)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	return cert.SerialNumber.String()
+}
+
+// servedSerial connects to addr and returns the serial number of the certificate it presents.
+func servedSerial(t *testing.T, addr string) string {
+	t.Helper()
+	conn, err := tls.Dial("tcp", addr, &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatalf("TLS handshake failed: %v", err)
+	}
+	defer conn.Close()
+	return conn.ConnectionState().PeerCertificates[0].SerialNumber.String()
+}
+
+func TestCertReloader_ServesRenewedCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	first := writeCert(t, certPath, keyPath)
+
+	reloader, err := NewCertReloader(certPath, keyPath)
+	if err != nil {
+		t.Fatalf("Failed to load certificate: %v", err)
+	}
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", reloader.TLSConfig())
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	srv := &http.Server{Handler: http.NotFoundHandler()}
+	go srv.Serve(listener)
+	t.Cleanup(func() { srv.Close() })
+	addr := listener.Addr().String()
+
+	if got := servedSerial(t, addr); got != first {
+		t.Fatalf("Expected the initial certificate %s, got %s", first, got)
+	}
+
+	// Swapping the files changes nothing until Reload
+	second := writeCert(t, certPath, keyPath)
+	if got := servedSerial(t, addr); got != first {
+		t.Errorf("Expected the initial certificate before Reload, got %s", got)
+	}
+	if err := reloader.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if got := servedSerial(t, addr); got != second {
+		t.Errorf("Expected the renewed certificate %s, got %s", second, got)
+	}
+
+	// A half-written renewal is refused and the current certificate stays
+	os.WriteFile(keyPath, []byte("not a key"), 0600)
+	if err := reloader.Reload(); err == nil {
+		t.Error("Expected Reload to fail with a broken key file")
+	}
+	if got := servedSerial(t, addr); got != second {
+		t.Errorf("Expected the renewed certificate to stay after a failed reload, got %s", got)
+	}
+}
@@ -13,12 +13,41 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 )
 
-// GenerateSelfSignedCert creates a self-signed certificate for development use.
+// DefaultDevHosts are the names a development certificate is always valid for.
+var DefaultDevHosts = []string{"localhost", "127.0.0.1"}
+
+// GenerateSelfSignedCert creates a self-signed certificate for development use, valid for DefaultDevHosts.
 // Returns the certificate and key as PEM-encoded bytes.
 func GenerateSelfSignedCert() (certPEM, keyPEM []byte, err error) {
+	return GenerateSelfSignedCertFor(nil)
+}
+
+// GenerateSelfSignedCertFor creates a development certificate valid for DefaultDevHosts and
+// extraHosts, which may be host names or IP addresses, e.g. a LAN IP other devices connect to.
+// Educational Comment: Browsers only check the Subject Alternative Names, not the Common
+// Name, so every name the server is reached by must be listed there.
+func GenerateSelfSignedCertFor(extraHosts []string) (certPEM, keyPEM []byte, err error) {
+	var dnsNames []string
+	var ipAddresses []net.IP
+	for _, host := range append(append([]string{}, DefaultDevHosts...), extraHosts...) {
+		host = strings.TrimSpace(host)
+		if host == "" {
+			continue
+		}
+		if ip := net.ParseIP(host); ip != nil {
+			if !slices.ContainsFunc(ipAddresses, ip.Equal) {
+				ipAddresses = append(ipAddresses, ip)
+			}
+		} else if !slices.Contains(dnsNames, host) {
+			dnsNames = append(dnsNames, host)
+		}
+	}
+
 	// Generate RSA private key
 	priv, err := rsa.GenerateKey(rand.Reader, 2048)
 	if err != nil {
@@ -42,8 +71,8 @@ func GenerateSelfSignedCert() (certPEM, keyPEM []byte, err error) {
 		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
 		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
 		BasicConstraintsValid: true,
-		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
-		DNSNames:              []string{"localhost"},
+		IPAddresses:           ipAddresses,
+		DNSNames:              dnsNames,
 	}
 
 	// Create certificate
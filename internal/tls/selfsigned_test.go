@@ -3,6 +3,7 @@ package tls
 import (
 	"crypto/x509"
 	"encoding/pem"
+	"strings"
 	"testing"
 	"time"
 )
@@ -82,6 +83,35 @@ func TestGenerateSelfSignedCert(t *testing.T) {
 	}
 }
 
+func TestGenerateSelfSignedCertFor_ExtraSANs(t *testing.T) {
+	certPEM, _, err := GenerateSelfSignedCertFor([]string{"forge.lan", " 192.168.1.20 ", "localhost", "::1", ""})
+	if err != nil {
+		t.Fatalf("Failed to generate certificate: %v", err)
+	}
+	block, _ := pem.Decode(certPEM)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+
+	// The defaults stay, extras are added once each, and IPs go in the IP SANs
+	if got := strings.Join(cert.DNSNames, ","); got != "localhost,forge.lan" {
+		t.Errorf("Expected DNS SANs localhost,forge.lan, got %s", got)
+	}
+	var ips []string
+	for _, ip := range cert.IPAddresses {
+		ips = append(ips, ip.String())
+	}
+	if got := strings.Join(ips, ","); got != "127.0.0.1,192.168.1.20,::1" {
+		t.Errorf("Expected IP SANs 127.0.0.1,192.168.1.20,::1, got %s", got)
+	}
+	for _, host := range []string{"forge.lan", "192.168.1.20", "localhost"} {
+		if err := cert.VerifyHostname(host); err != nil {
+			t.Errorf("Expected the certificate to be valid for %s: %v", host, err)
+		}
+	}
+}
+
 func TestLoadTLSConfig(t *testing.T) {
 	certPEM, keyPEM, err := GenerateSelfSignedCert()
 	if err != nil {
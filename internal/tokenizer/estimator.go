@@ -1,12 +1,44 @@
 package tokenizer
 
 import (
+	"fmt"
 	"regexp"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/mikejsmith1985/forge-orchestrator/internal/logging"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/models"
 	"github.com/pkoukk/tiktoken-go"
 )
 
+// encodingRetryInterval is how long an encoding that failed to load (usually because its
+// BPE file couldn't be downloaded) is left alone before the next estimate tries again.
+const encodingRetryInterval = 5 * time.Minute
+
+// codePatterns matches brackets and operators, which are usually single tokens
+var codePatterns = regexp.MustCompile(`[{}\[\]();:,<>=+\-*/&|!@#$%^~]`)
+
+// loadEncoding builds the tiktoken encoding for a model. It downloads the BPE data on
+// first use, so it fails offline. Tests replace it to simulate that.
+var loadEncoding = func(model string) (*tiktoken.Tiktoken, error) {
+	encoding, err := tiktoken.EncodingForModel(model)
+	if err != nil {
+		// Try cl100k_base as fallback (GPT-4 and GPT-3.5-turbo use this)
+		encoding, err = tiktoken.GetEncoding("cl100k_base")
+	}
+	return encoding, err
+}
+
+// defaultEstimator is shared by every caller of Default
+var defaultEstimator = NewEstimator()
+
+// Default returns the process-wide estimator, so its loaded encodings are reused
+// across requests instead of being rebuilt for every estimate.
+func Default() *Estimator {
+	return defaultEstimator
+}
+
 // EstimationResult contains the token estimation details
 type EstimationResult struct {
 	Count    int    `json:"count"`
@@ -15,12 +47,37 @@ type EstimationResult struct {
 	Model    string `json:"model,omitempty"`
 }
 
-// Estimator handles token estimation with multiple methods
-type Estimator struct{}
+// Per-message overhead of chat requests, as documented for OpenAI's chat models: every
+// message is wrapped in a few formatting tokens, and the reply is primed with a few more.
+// Anthropic doesn't publish its framing, so the same figures are used as an approximation.
+const (
+	tokensPerMessage   = 3
+	replyPrimingTokens = 3
+)
+
+// Message is one entry of a chat-structured request, e.g. {"role": "system", "content": "..."}.
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Estimator handles token estimation with multiple methods.
+// It is safe for concurrent use.
+type Estimator struct {
+	mu        sync.Mutex
+	encodings map[string]*cachedEncoding // by model name
+}
+
+// cachedEncoding is a model's loaded encoding, or the time it last failed to load.
+type cachedEncoding struct {
+	encoding *tiktoken.Tiktoken
+	err      error
+	failedAt time.Time
+}
 
 // NewEstimator creates a new token estimator
 func NewEstimator() *Estimator {
-	return &Estimator{}
+	return &Estimator{encodings: make(map[string]*cachedEncoding)}
 }
 
 // Estimate returns an accurate token count using tiktoken for OpenAI models
@@ -35,7 +92,10 @@ func (e *Estimator) Estimate(text string, provider string, model string) Estimat
 	// Try tiktoken for OpenAI models
 	if provider == "openai" {
 		if model == "" {
-			model = "gpt-4"
+			// Count as the model the OpenAI client actually calls
+			if m, ok := models.Default(provider); ok {
+				model = m.Name
+			}
 		}
 		count, err := e.estimateWithTiktoken(text, model)
 		if err == nil {
@@ -57,20 +117,74 @@ func (e *Estimator) Estimate(text string, provider string, model string) Estimat
 	}
 }
 
+// EstimateMessages counts a chat-structured request the way providers bill it: each
+// message's content and role, plus the per-message and reply-priming overhead. This is
+// higher, and closer to the billed input tokens, than estimating the joined text.
+func (e *Estimator) EstimateMessages(messages []Message, provider string, model string) EstimationResult {
+	if len(messages) == 0 {
+		result := e.Estimate("", provider, model)
+		result.Count = 0
+		return result
+	}
+
+	var result EstimationResult
+	count := replyPrimingTokens
+	for _, m := range messages {
+		result = e.Estimate(m.Content, provider, model)
+		count += tokensPerMessage + result.Count + e.Estimate(m.Role, provider, model).Count
+	}
+	result.Count = count
+	return result
+}
+
 // estimateWithTiktoken uses the tiktoken library for accurate OpenAI token counts
 func (e *Estimator) estimateWithTiktoken(text string, model string) (int, error) {
-	encoding, err := tiktoken.EncodingForModel(model)
+	encoding, err := e.encoding(model)
 	if err != nil {
-		// Try cl100k_base as fallback (GPT-4 and GPT-3.5-turbo use this)
-		encoding, err = tiktoken.GetEncoding("cl100k_base")
-		if err != nil {
-			return 0, err
-		}
+		return 0, err
 	}
 	tokens := encoding.Encode(text, nil, nil)
 	return len(tokens), nil
 }
 
+// encoding returns the model's encoding, loading it on first use. Building one compiles
+// its regexes and rank tables, which costs far more than encoding a prompt.
+// Educational Comment: The lock is held while loading so concurrent first requests
+// build the encoding once rather than racing to build it several times.
+func (e *Estimator) encoding(model string) (*tiktoken.Tiktoken, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if cached, ok := e.encodings[model]; ok {
+		if cached.err == nil || time.Since(cached.failedAt) < encodingRetryInterval {
+			return cached.encoding, cached.err
+		}
+	}
+
+	encoding, err := safeLoadEncoding(model)
+	if err == nil && encoding == nil {
+		err = fmt.Errorf("no encoding for model %s", model)
+	}
+	cached := &cachedEncoding{encoding: encoding, err: err}
+	if err != nil {
+		cached.encoding, cached.failedAt = nil, time.Now()
+		logging.Warnf("Token estimates for %s fall back to the heuristic: tiktoken data unavailable: %v", model, err)
+	}
+	e.encodings[model] = cached
+	return cached.encoding, cached.err
+}
+
+// safeLoadEncoding calls loadEncoding, turning a panic while the encoding data is
+// parsed into an error so a bad download degrades to the heuristic instead.
+func safeLoadEncoding(model string) (encoding *tiktoken.Tiktoken, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			encoding, err = nil, fmt.Errorf("loading encoding for %s panicked: %v", model, r)
+		}
+	}()
+	return loadEncoding(model)
+}
+
 // estimateHeuristic provides an improved word-based estimation
 func (e *Estimator) estimateHeuristic(text string, provider string) int {
 	// Base: word count
@@ -78,7 +192,6 @@ func (e *Estimator) estimateHeuristic(text string, provider string) int {
 	wordCount := len(words)
 
 	// Code pattern adjustments (brackets, operators are usually single tokens)
-	codePatterns := regexp.MustCompile(`[{}\[\]();:,<>=+\-*/&|!@#$%^~]`)
 	codeTokens := len(codePatterns.FindAllString(text, -1))
 
 	// Non-ASCII penalty (typically 2-4 tokens each)
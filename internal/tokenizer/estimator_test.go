@@ -1,8 +1,13 @@
 package tokenizer
 
 import (
+	"errors"
+	"fmt"
 	"strings"
+	"sync"
 	"testing"
+
+	"github.com/pkoukk/tiktoken-go"
 )
 
 func TestEstimator_EstimateWithTiktoken(t *testing.T) {
@@ -161,3 +166,122 @@ func TestEstimator_LongText(t *testing.T) {
 		t.Errorf("Estimate() for long text = %v, expected 400-800 tokens", result.Count)
 	}
 }
+
+func TestEstimator_ConcurrentUse(t *testing.T) {
+	e := NewEstimator()
+	inputs := []struct{ text, provider, model string }{
+		{"hello world", "openai", "gpt-4"},
+		{"func main() { fmt.Println(\"hi\") }", "openai", "gpt-3.5-turbo"},
+		{"The quick brown fox", "anthropic", ""},
+		{"こんにちは世界", "openai", ""},
+	}
+
+	// What each input estimates to on its own, before any sharing
+	want := make([]EstimationResult, len(inputs))
+	for i, in := range inputs {
+		want[i] = NewEstimator().Estimate(in.text, in.provider, in.model)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan string, 64)
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				n := (g + i) % len(inputs)
+				in := inputs[n]
+				if got := e.Estimate(in.text, in.provider, in.model); got != want[n] {
+					errs <- fmt.Sprintf("%q: got %+v, want %+v", in.text, got, want[n])
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	// Each model's encoding is loaded once and kept
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	// (gpt-4, gpt-3.5-turbo and gpt-4o, the registry's default for an unnamed OpenAI model)
+	if len(e.encodings) != 3 {
+		t.Errorf("Expected the three OpenAI models' encodings to be cached, got %d", len(e.encodings))
+	}
+}
+
+func TestDefaultEstimatorIsShared(t *testing.T) {
+	if Default() != Default() {
+		t.Error("Expected Default to return the same estimator every time")
+	}
+}
+
+// BenchmarkEstimate_Fresh is the old per-request pattern, building a new estimator each time.
+func BenchmarkEstimate_Fresh(b *testing.B) {
+	text := strings.Repeat("The quick brown fox jumps over the lazy dog. ", 20)
+	for i := 0; i < b.N; i++ {
+		NewEstimator().Estimate(text, "openai", "gpt-4")
+	}
+}
+
+// BenchmarkEstimate_Shared reuses the shared estimator and its loaded encoding.
+func BenchmarkEstimate_Shared(b *testing.B) {
+	text := strings.Repeat("The quick brown fox jumps over the lazy dog. ", 20)
+	e := Default()
+	for i := 0; i < b.N; i++ {
+		e.Estimate(text, "openai", "gpt-4")
+	}
+}
+
+func TestEstimator_FallsBackWhenEncodingUnavailable(t *testing.T) {
+	original := loadEncoding
+	t.Cleanup(func() { loadEncoding = original })
+
+	text := "The quick brown fox jumps over the lazy dog"
+	for name, load := range map[string]func(string) (*tiktoken.Tiktoken, error){
+		"download fails": func(string) (*tiktoken.Tiktoken, error) { return nil, errors.New("no network") },
+		"data corrupt":   func(string) (*tiktoken.Tiktoken, error) { panic("bad BPE line") },
+	} {
+		t.Run(name, func(t *testing.T) {
+			loadEncoding = load
+			result := NewEstimator().Estimate(text, "openai", "gpt-4")
+			if result.Method != "heuristic" || result.Provider != "openai" {
+				t.Errorf("Expected a heuristic OpenAI estimate, got %+v", result)
+			}
+			// Nine words at ~1.3 tokens each
+			if result.Count < 9 || result.Count > 15 {
+				t.Errorf("Expected a sensible count for nine words, got %d", result.Count)
+			}
+		})
+	}
+}
+
+func TestEstimator_EstimateMessages(t *testing.T) {
+	e := NewEstimator()
+	messages := []Message{
+		{Role: "system", Content: "You are a helpful assistant."},
+		{Role: "user", Content: "What is the capital of France?"},
+	}
+
+	// Heuristic counts are deterministic: 7 + 8 for the contents, 1 for each role,
+	// 3 per message and 3 to prime the reply
+	structured := e.EstimateMessages(messages, "anthropic", "")
+	if structured.Count != 26 || structured.Method != "heuristic" || structured.Provider != "anthropic" {
+		t.Errorf("Expected a heuristic estimate of 26, got %+v", structured)
+	}
+
+	naive := e.Estimate(messages[0].Content+"\n"+messages[1].Content, "anthropic", "")
+	if naive.Count != 15 {
+		t.Errorf("Expected the joined text to estimate at 15, got %d", naive.Count)
+	}
+	if structured.Count-naive.Count != 11 {
+		t.Errorf("Expected the message overhead to add 11 tokens, got %d", structured.Count-naive.Count)
+	}
+
+	if empty := e.EstimateMessages(nil, "openai", ""); empty.Count != 0 {
+		t.Errorf("Expected no tokens for no messages, got %d", empty.Count)
+	}
+}
@@ -10,13 +10,23 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"runtime/debug"
 	"strings"
 	"time"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/logging"
 )
 
 // Version is set at build time via -ldflags "-X github.com/mikejsmith1985/forge-orchestrator/internal/updater.Version=x.y.z"
 var Version = "1.2.3"
 
+// Commit and BuildDate are also set at build time via -ldflags, alongside Version:
+// -X .../internal/updater.Commit=<git sha> -X .../internal/updater.BuildDate=<RFC 3339 timestamp>
+var (
+	Commit    = ""
+	BuildDate = ""
+)
+
 // GitHub repository information
 const (
 	repoOwner = "mikejsmith1985"
@@ -61,14 +71,58 @@ type ReleaseInfo struct {
 	IsCurrent    bool   `json:"isCurrent"`
 }
 
+// BuildInfo describes exactly which build is running, for triaging feedback reports.
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"buildDate"`
+	GoVersion string `json:"goVersion"`
+}
+
 // GetVersion returns the current version.
 func GetVersion() string {
 	return Version
 }
 
+// GetBuildInfo returns the version along with build metadata.
+// Educational Comment: When the ldflags weren't set (e.g. a plain `go build`), we fall back
+// to the VCS stamp the Go toolchain embeds, and finally to "unknown".
+func GetBuildInfo() BuildInfo {
+	info := BuildInfo{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+	}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range bi.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.BuildDate == "" {
+					info.BuildDate = setting.Value
+				}
+			}
+		}
+	}
+
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.BuildDate == "" {
+		info.BuildDate = "unknown"
+	}
+	return info
+}
+
 // CheckForUpdate checks GitHub for a newer version.
 func CheckForUpdate() (*UpdateInfo, error) {
 	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/releases/latest", repoOwner, repoName)
+	logging.Debugf("[Updater] Checking %s (current version %s)", url, Version)
 
 	client := &http.Client{Timeout: 10 * time.Second}
 	req, err := http.NewRequest("GET", url, nil)
@@ -109,6 +163,7 @@ func CheckForUpdate() (*UpdateInfo, error) {
 	isNewer := compareVersions(latestVersion, currentVersion) > 0
 
 	if !isNewer {
+		logging.Debugf("[Updater] Up to date (latest release %s)", release.TagName)
 		return &UpdateInfo{
 			Available:      false,
 			CurrentVersion: Version,
@@ -132,6 +187,7 @@ func CheckForUpdate() (*UpdateInfo, error) {
 	if downloadURL == "" {
 		return nil, fmt.Errorf("no binary available for %s/%s", runtime.GOOS, runtime.GOARCH)
 	}
+	logging.Infof("[Updater] Version %s is available (current %s)", release.TagName, Version)
 
 	return &UpdateInfo{
 		Available:      true,
@@ -182,6 +238,7 @@ func DownloadUpdate(info *UpdateInfo) (string, error) {
 		os.Chmod(tmpFile, 0755)
 	}
 
+	logging.Debugf("[Updater] Downloaded %s to %s", info.AssetName, tmpFile)
 	return tmpFile, nil
 }
 
@@ -221,6 +278,7 @@ func ApplyUpdate(newBinaryPath string) error {
 		// On Unix, we can do atomic replace
 		if err := os.Rename(newBinaryPath, currentPath); err != nil {
 			// Fallback to copy
+			logging.Warnf("[Updater] Rename into place failed, copying instead: %v", err)
 			if err := copyFile(newBinaryPath, currentPath); err != nil {
 				return fmt.Errorf("failed to install new binary: %w", err)
 			}
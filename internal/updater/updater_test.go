@@ -12,6 +12,40 @@ func TestGetVersion(t *testing.T) {
 	}
 }
 
+func TestGetBuildInfo(t *testing.T) {
+	origCommit, origDate := Commit, BuildDate
+	defer func() { Commit, BuildDate = origCommit, origDate }()
+
+	Commit = "abc1234"
+	BuildDate = "2025-01-02T03:04:05Z"
+
+	info := GetBuildInfo()
+	if info.Version != Version {
+		t.Errorf("Expected version %s, got %s", Version, info.Version)
+	}
+	if info.Commit != "abc1234" {
+		t.Errorf("Expected commit abc1234, got %s", info.Commit)
+	}
+	if info.BuildDate != "2025-01-02T03:04:05Z" {
+		t.Errorf("Expected build date 2025-01-02T03:04:05Z, got %s", info.BuildDate)
+	}
+	if info.GoVersion != runtime.Version() {
+		t.Errorf("Expected Go version %s, got %s", runtime.Version(), info.GoVersion)
+	}
+}
+
+func TestGetBuildInfo_DefaultsWhenUnset(t *testing.T) {
+	origCommit, origDate := Commit, BuildDate
+	defer func() { Commit, BuildDate = origCommit, origDate }()
+
+	Commit, BuildDate = "", ""
+
+	info := GetBuildInfo()
+	if info.Commit == "" || info.BuildDate == "" {
+		t.Errorf("Expected commit and build date to fall back to a value, got %+v", info)
+	}
+}
+
 func TestGetAssetName(t *testing.T) {
 	name := getAssetName()
 
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"embed"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io/fs"
@@ -14,6 +16,8 @@ import (
 	"os/exec"
 	"os/signal"
 	"runtime"
+	"slices"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -22,6 +26,8 @@ import (
 
 	"github.com/mikejsmith1985/forge-orchestrator/internal/config"
 	"github.com/mikejsmith1985/forge-orchestrator/internal/data"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/lifecycle"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/logging"
 	"github.com/mikejsmith1985/forge-orchestrator/internal/server"
 	forgetls "github.com/mikejsmith1985/forge-orchestrator/internal/tls"
 	"github.com/mikejsmith1985/forge-orchestrator/internal/updater"
@@ -30,124 +36,130 @@ import (
 //go:embed frontend/dist/*
 var frontendEmbed embed.FS
 
-// Preferred ports to try, in order
-var preferredPorts = []int{8080, 8333, 9000, 3000, 3333}
+// shutdownTimeout bounds how long we wait for in-flight requests and background tasks.
+const shutdownTimeout = 10 * time.Second
+
+// shutdownRequested is signalled by the /api/shutdown endpoint.
+var shutdownRequested = make(chan struct{}, 1)
+
+// certReloader serves the FORGE_TLS_CERT/FORGE_TLS_KEY pair; nil unless those are set.
+var certReloader *forgetls.CertReloader
 
 func main() {
+	// Subcommands run to completion without starting the HTTP server
+	if len(os.Args) > 1 && os.Args[1] == "run-flow" {
+		os.Exit(runFlowCommand(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
 	// Parse command line flags
-	devTLS := flag.Bool("dev-tls", false, "Generate self-signed certificate for development")
-	noBrowser := flag.Bool("no-browser", false, "Don't open browser on startup")
-	flag.Parse()
+	opts, err := parseFlags(os.Args[1:], os.Getenv, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	} else if err != nil {
+		os.Exit(2)
+	}
 
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
-		log.Printf("Warning: Failed to load config, using defaults: %v", err)
+		logging.Warnf("Warning: Failed to load config, using defaults: %v", err)
 		cfg = config.DefaultConfig()
 	}
 
+	// Pick console or JSON logging before anything else is logged
+	logging.Init(cfg.Logging.Format, cfg.Logging.Level)
+
 	// Initialize CORS configuration
 	server.InitCORS()
 
-	// Get database path from config
-	dbPath, err := config.GetDatabasePath()
-	if err != nil {
-		log.Printf("Warning: Failed to get data directory, using local path: %v", err)
-		dbPath = "forge_ledger.db"
-	}
-
-	// Initialize SQLite Database
-	db, err := sql.Open("sqlite", dbPath)
+	db, dbPath, err := openDatabase()
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer db.Close()
 
-	// Initialize Schema
-	if _, err := db.Exec(data.SQLiteSchema); err != nil {
-		log.Fatal(err)
-	}
-
-	// Get the build output directory from the embed.FS
-	distFS, err := fs.Sub(frontendEmbed, "frontend/dist")
-	if err != nil {
-		log.Fatal(err)
-	}
+	// Background tasks are started through the lifecycle manager so they stop on shutdown
+	lc := lifecycle.New()
 
 	// Initialize Server
-	srv := server.NewServer(db)
-	router := srv.RegisterRoutes()
-
-	// Cast to *http.ServeMux to add handlers
-	mux, ok := router.(*http.ServeMux)
-	if !ok {
-		log.Fatal("Router is not *http.ServeMux")
-	}
-
-	// Add update API endpoints
-	mux.HandleFunc("/api/version", handleVersion)
-	mux.HandleFunc("/api/update/check", handleUpdateCheck)
-	mux.HandleFunc("/api/update/apply", handleUpdateApply)
-	mux.HandleFunc("/api/update/versions", handleListVersions)
-
-	// Add config API endpoints
-	mux.HandleFunc("/api/config", handleConfig)
-	mux.HandleFunc("/api/wsl/detect", handleWSLDetect)
-
-	// Add shutdown endpoint
-	mux.HandleFunc("/api/shutdown", handleShutdown)
-
-	// SPA Handler (must be last)
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		// Prepare path for fs.Open (no leading slash)
-		path := strings.TrimPrefix(r.URL.Path, "/")
-		if path == "" {
-			path = "."
-		}
-
-		// Try to open the file to check if it exists
-		f, err := distFS.Open(path)
-		if err != nil {
-			// If file not found, assume SPA route and serve index.html
-			r.URL.Path = "/"
-		} else {
-			defer f.Close()
-		}
-
-		http.FileServer(http.FS(distFS)).ServeHTTP(w, r)
+	srv := server.NewServerWithLifecycle(db, lc)
+	lc.Go("server", func(ctx context.Context) {
+		<-ctx.Done()
+		srv.Close()
+	})
+	// Load the tokenizer now so the first token estimate doesn't wait for it
+	lc.Go("tokenizer-warmup", func(ctx context.Context) {
+		srv.WarmTokenizer()
 	})
+	handler := newHandler(srv, frontendEmbed)
 
-	// Wrap the entire mux with CORS middleware
-	handler := server.CORSMiddleware(mux)
+	httpServer := newHTTPServer(handler, cfg.Server)
 
 	// Handle graceful shutdown
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
 
 	go func() {
-		<-stop
-		log.Println("\n👋 Shutting down Forge Orchestrator...")
-		os.Exit(0)
+		select {
+		case <-stop:
+		case <-shutdownRequested:
+		}
+		logging.Infof("\n👋 Shutting down Forge Orchestrator...")
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := httpServer.Shutdown(ctx); err != nil {
+			logging.Warnf("Warning: HTTP server shutdown: %v", err)
+		}
 	}()
 
 	// Check for TLS configuration
 	tlsCert := os.Getenv("FORGE_TLS_CERT")
 	tlsKey := os.Getenv("FORGE_TLS_KEY")
 
+	var serveErr error
 	if tlsCert != "" && tlsKey != "" {
-		// Production TLS with provided certificates
-		addr := fmt.Sprintf(":%d", cfg.Server.Port)
-		log.Printf("🔒 Starting HTTPS server on %s", addr)
-		if err := http.ListenAndServeTLS(addr, tlsCert, tlsKey, handler); err != nil {
-			log.Fatal(err)
+		// Production TLS with provided certificates, re-read on SIGHUP or POST /api/tls/reload
+		certReloader, err = forgetls.NewCertReloader(tlsCert, tlsKey)
+		if err != nil {
+			log.Fatalf("Failed to load TLS certificate: %v", err)
+		}
+		httpServer.TLSConfig = certReloader.TLSConfig()
+
+		lc.Go("tls-reload", func(ctx context.Context) {
+			hup := make(chan os.Signal, 1)
+			signal.Notify(hup, syscall.SIGHUP)
+			defer signal.Stop(hup)
+			for {
+				select {
+				case <-hup:
+					reloadCertificate()
+				case <-ctx.Done():
+					return
+				}
+			}
+		})
+
+		httpServer.Addr = tlsAddress(cfg.Server)
+		logging.Infof("🔒 Starting HTTPS server on %s", httpServer.Addr)
+		serveErr = httpServer.ListenAndServeTLS("", "")
+	} else if cfg.Server.ACME.Enabled {
+		// Certificates from Let's Encrypt, fetched on the first request for each domain
+		challengeServer, err := configureACME(httpServer, cfg.Server)
+		if err != nil {
+			log.Fatalf("Failed to set up ACME: %v", err)
 		}
-	} else if *devTLS {
+		lc.Go("acme-http", func(ctx context.Context) {
+			serveACMEChallenges(ctx, challengeServer, cfg.Server.Port)
+		})
+		logging.Infof("🔒 Starting HTTPS server on %s for %s (Let's Encrypt)", httpServer.Addr, strings.Join(cfg.Server.ACME.Domains, ", "))
+		serveErr = httpServer.ListenAndServeTLS("", "")
+	} else if opts.DevTLS {
 		// Development TLS with self-signed certificate
-		addr := fmt.Sprintf(":%d", cfg.Server.Port)
-		log.Println("⚠️  Generating self-signed certificate for development")
-		log.Println("⚠️  This is NOT suitable for production use!")
+		httpServer.Addr = tlsAddress(cfg.Server)
+		logging.Warnf("⚠️  Generating self-signed certificate for development")
+		logging.Warnf("⚠️  This is NOT suitable for production use!")
 
-		certPEM, keyPEM, err := forgetls.GenerateSelfSignedCert()
+		certPEM, keyPEM, err := forgetls.GenerateSelfSignedCertFor(opts.DevTLSHosts)
 		if err != nil {
 			log.Fatalf("Failed to generate self-signed certificate: %v", err)
 		}
@@ -159,71 +171,286 @@ func main() {
 		if err != nil {
 			log.Fatalf("Failed to load TLS config: %v", err)
 		}
+		httpServer.TLSConfig = tlsConfig
 
-		server := &http.Server{
-			Addr:      addr,
-			Handler:   handler,
-			TLSConfig: tlsConfig,
-		}
-
-		log.Printf("🔒 Starting HTTPS server on %s (self-signed)", addr)
+		logging.Infof("🔒 Starting HTTPS server on %s (self-signed for %s)", httpServer.Addr, strings.Join(slices.Concat(forgetls.DefaultDevHosts, opts.DevTLSHosts), ", "))
 		// Empty strings since we're using TLSConfig directly
-		if err := server.ListenAndServeTLS("", ""); err != nil {
-			log.Fatal(err)
-		}
+		serveErr = httpServer.ListenAndServeTLS("", "")
 	} else {
 		// HTTP mode with port fallback
-		addr, listener, err := findAvailablePort(cfg.Server.Port)
+		addr, listener, err := findAvailablePort(cfg.Server.ListenAddress(), cfg.Server.Port, cfg.Server.FallbackPorts())
 		if err != nil {
 			log.Fatalf("Failed to find available port: %v", err)
 		}
 
-		log.Printf("🔥 Forge Orchestrator v%s starting at http://%s", updater.GetVersion(), addr)
-		log.Printf("📁 Database: %s", dbPath)
+		logging.Infof("🔥 Forge Orchestrator v%s starting at http://%s", updater.GetVersion(), addr)
+		logging.Infof("📁 Database: %s", dbPath)
+		reportPortFallback(cfg.Server.Port, listener.Addr())
+
+		if opts.Headless {
+			logging.Infof("%s", headlessBanner(listener.Addr()))
+		} else if shouldOpenBrowser(opts, cfg.Server.OpenBrowser, os.Getenv) {
+			// Auto-open browser (unless disabled)
+			lc.Go("open-browser", func(ctx context.Context) {
+				openUI(ctx, listener)
+			})
+		}
+
+		serveErr = httpServer.Serve(listener)
+	}
+
+	if serveErr != nil && serveErr != http.ErrServerClosed {
+		log.Fatal(serveErr)
+	}
+
+	// The HTTP server has stopped; now stop background tasks
+	if err := lc.Shutdown(shutdownTimeout); err != nil {
+		logging.Warnf("Warning: %v", err)
+	}
+}
+
+// acmeHTTPPort is where Let's Encrypt sends HTTP-01 challenges; the CA doesn't allow another port.
+const acmeHTTPPort = 80
+
+// configureACME points the server at the configured address, serving certificates that
+// an ACME manager obtains and renews for cfg.ACME.Domains. It returns the server for
+// port 80 that answers the manager's HTTP-01 challenges and redirects all else to HTTPS.
+func configureACME(httpServer *http.Server, cfg config.ServerConfig) (*http.Server, error) {
+	manager, err := forgetls.NewACMEManager(cfg.ACME)
+	if err != nil {
+		return nil, err
+	}
+	httpServer.Addr = tlsAddress(cfg)
+	httpServer.TLSConfig = manager.TLSConfig()
+
+	challengeServer := &http.Server{
+		Addr:              net.JoinHostPort(cfg.ListenAddress(), strconv.Itoa(acmeHTTPPort)),
+		Handler:           manager.HTTPHandler(nil),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return challengeServer, nil
+}
 
-		// Auto-open browser (unless disabled)
-		if cfg.Server.OpenBrowser && !*noBrowser && os.Getenv("NO_BROWSER") == "" {
-			go openBrowser("http://" + addr)
+// serveACMEChallenges runs the HTTP-01 challenge server until ctx is cancelled.
+// Educational Comment: the HTTPS listener itself can only answer TLS-ALPN-01, which
+// Let's Encrypt checks on port 443 alone. Answering on port 80 lets certificates be issued
+// whatever port Forge serves HTTPS on; without it, HTTPS must be on port 443.
+func serveACMEChallenges(ctx context.Context, srv *http.Server, httpsPort int) {
+	listener, err := listen("tcp", srv.Addr)
+	if err != nil {
+		if httpsPort == 443 {
+			logging.Warnf("ACME: can't listen on %s (%v); certificates will be validated over port 443 only", srv.Addr, err)
+		} else {
+			logging.Warnf("⚠️  ACME: can't listen on %s (%v) and HTTPS isn't on port 443, so Let's Encrypt can't validate the domain", srv.Addr, err)
 		}
+		return
+	}
+	logging.Infof("ACME: answering HTTP-01 challenges on %s", listener.Addr())
 
-		if err := http.Serve(listener, handler); err != nil {
-			log.Fatal(err)
+	served := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			srv.Close()
+		case <-served:
 		}
+	}()
+	if err := srv.Serve(listener); err != nil && err != http.ErrServerClosed {
+		logging.Warnf("ACME challenge server: %v", err)
+	}
+	close(served)
+}
+
+// openDatabase opens the ledger database from the config's data directory and brings its schema up to date.
+// It returns the path it opened alongside the handle.
+func openDatabase() (*sql.DB, string, error) {
+	// Get database path from config
+	dbPath, err := config.GetDatabasePath()
+	if err != nil {
+		logging.Warnf("Warning: Failed to get data directory, using local path: %v", err)
+		dbPath = "forge_ledger.db"
+	}
+
+	// Initialize SQLite Database
+	db, err := data.Open(dbPath)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Initialize Schema
+	if _, err := db.Exec(data.SQLiteSchema); err != nil {
+		db.Close()
+		return nil, "", err
+	}
+
+	// Add any columns missing from tables created by an older or interrupted run
+	if repairs, err := data.RepairSchema(db); err != nil {
+		db.Close()
+		return nil, "", fmt.Errorf("failed to repair database schema: %w", err)
+	} else if len(repairs) > 0 {
+		logging.Infof("🔧 Repaired database schema (%d changes)", len(repairs))
+	}
+	return db, dbPath, nil
+}
+
+// newHTTPServer returns the HTTP server for handler, with the configured timeouts.
+// HTTP/2 is offered alongside HTTP/1.1 when serving TLS, so many streaming clients
+// can share one connection; plain HTTP stays on HTTP/1.1.
+// Educational Comment: Without timeouts a client that opens a connection and never
+// finishes its request holds a goroutine forever. WebSocket connections clear these
+// deadlines when they upgrade, so the timeouts don't cut terminal sessions short.
+func newHTTPServer(handler http.Handler, cfg config.ServerConfig) *http.Server {
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(true)
+	return &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: min(cfg.ReadTimeout(), 10*time.Second),
+		ReadTimeout:       cfg.ReadTimeout(),
+		WriteTimeout:      cfg.WriteTimeout(),
+		IdleTimeout:       cfg.IdleTimeout(),
+		Protocols:         protocols,
 	}
 }
 
-// findAvailablePort tries the preferred port first, then falls back to alternatives
-func findAvailablePort(preferred int) (string, net.Listener, error) {
+// newHandler registers the API, the app-level endpoints and the UI on one handler.
+// frontend holds the embedded frontend/dist; without a built UI a placeholder page is served instead.
+func newHandler(srv *server.Server, frontend fs.FS) http.Handler {
+	mux := srv.Mux()
+
+	// Add update API endpoints
+	mux.HandleFunc("/api/version", handleVersion)
+	mux.HandleFunc("/api/update/check", handleUpdateCheck)
+	mux.HandleFunc("/api/update/apply", handleUpdateApply)
+	mux.HandleFunc("/api/update/versions", handleListVersions)
+
+	// Add config API endpoints
+	mux.HandleFunc("/api/config", handleConfig)
+	mux.HandleFunc("/api/wsl/detect", handleWSLDetect)
+
+	// Add shutdown endpoint
+	mux.HandleFunc("/api/shutdown", handleShutdown)
+
+	// Add TLS certificate reload endpoint
+	mux.HandleFunc("/api/tls/reload", handleTLSReload)
+
+	// SPA Handler (must be last)
+	mux.HandleFunc("/", spaHandler(frontend))
+
+	// Wrap the entire mux with request metrics, CORS, response compression and request body checks
+	return server.CORSMiddleware(server.MetricsMiddleware(server.GzipMiddleware(server.BodyLimitMiddleware(server.JSONContentTypeMiddleware(mux)))))
+}
+
+// listen opens a TCP listener. Tests replace it to observe the requested address.
+var listen = net.Listen
+
+// findAvailablePort tries the preferred port first, then the fallback list in order,
+// and finally lets the OS assign one. All attempts bind to bindAddr.
+func findAvailablePort(bindAddr string, preferred int, fallbacks []int) (string, net.Listener, error) {
+	warnIfAllInterfaces(bindAddr)
+
 	// Try preferred port first
 	ports := []int{preferred}
-	for _, p := range preferredPorts {
+	for _, p := range fallbacks {
 		if p != preferred {
 			ports = append(ports, p)
 		}
 	}
 
 	for _, port := range ports {
-		addr := fmt.Sprintf("127.0.0.1:%d", port)
-		listener, err := net.Listen("tcp", addr)
+		addr := net.JoinHostPort(bindAddr, strconv.Itoa(port))
+		listener, err := listen("tcp", addr)
 		if err == nil {
-			return addr, listener, nil
+			// The listener's own address, which has the real port even when port is 0
+			return listener.Addr().String(), listener, nil
 		}
-		log.Printf("Port %d unavailable, trying next...", port)
+		logging.Infof("Port %d unavailable, trying next...", port)
 	}
 
 	// Fallback: let OS assign a random available port
-	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	listener, err := listen("tcp", net.JoinHostPort(bindAddr, "0"))
 	if err != nil {
 		return "", nil, fmt.Errorf("no available ports: %w", err)
 	}
 	addr := listener.Addr().String()
-	log.Printf("Using OS-assigned port: %s", addr)
+	logging.Infof("Using OS-assigned port: %s", addr)
 	return addr, listener, nil
 }
 
+// tlsAddress returns the address an HTTPS server listens on: the configured bind address
+// and port. There's no port fallback over TLS, since certificates and links name the port.
+func tlsAddress(cfg config.ServerConfig) string {
+	warnIfAllInterfaces(cfg.ListenAddress())
+	return net.JoinHostPort(cfg.ListenAddress(), strconv.Itoa(cfg.Port))
+}
+
+// warnIfAllInterfaces warns when Forge is about to listen on every interface.
+func warnIfAllInterfaces(bindAddr string) {
+	if isAllInterfaces(bindAddr) {
+		logging.Warnf("⚠️  WARNING: binding to %s exposes Forge (including terminal access) to your whole network", bindAddr)
+	}
+}
+
+// isAllInterfaces reports whether a bind address listens on every interface.
+func isAllInterfaces(bindAddr string) bool {
+	return bindAddr == "0.0.0.0" || bindAddr == "::"
+}
+
+// reportPortFallback warns, in a banner that's hard to miss, when Forge couldn't get the
+// configured port, so a bookmark or another tool pointed at that port isn't silently broken.
+func reportPortFallback(preferred int, bound net.Addr) {
+	port := bound.(*net.TCPAddr).Port
+	if preferred == 0 || port == preferred {
+		return
+	}
+	logging.Warnf("⚠️  ==========================================================")
+	logging.Warnf("⚠️  Port %d is already in use, so Forge is running on port %d.", preferred, port)
+	logging.Warnf("⚠️  Open %s (set server.port in the config to change this)", localURL(bound))
+	logging.Warnf("⚠️  ==========================================================")
+}
+
+// headlessBanner tells a headless user where to reach Forge. Only a listener off loopback
+// can be reached from another machine; on loopback (the default) it points at bind_address.
+func headlessBanner(bound net.Addr) string {
+	tcp := bound.(*net.TCPAddr)
+	switch {
+	case tcp.IP.IsLoopback():
+		return fmt.Sprintf("Running headless on %s, which only this machine can reach; use the API locally, or set server.bind_address (e.g. 0.0.0.0) to open Forge from another machine", bound)
+	case tcp.IP.IsUnspecified():
+		return fmt.Sprintf("Running headless; open http://<this machine's address>:%d from another machine or use the API directly", tcp.Port)
+	default:
+		return fmt.Sprintf("Running headless; open http://%s from another machine or use the API directly", bound)
+	}
+}
+
+// localURL returns the URL a browser on this machine reaches the listener at. A listener on
+// every interface (0.0.0.0 or ::) is reached through localhost.
+func localURL(bound net.Addr) string {
+	tcp := bound.(*net.TCPAddr)
+	host := tcp.IP.String()
+	if tcp.IP == nil || tcp.IP.IsUnspecified() {
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, strconv.Itoa(tcp.Port))
+}
+
+// openBrowserFunc opens a URL in the browser. Tests replace it to observe the URL.
+var openBrowserFunc = openBrowser
+
+// openUI opens the browser at the address the listener actually bound, which isn't the
+// configured port when that was taken.
+func openUI(ctx context.Context, listener net.Listener) {
+	openBrowserFunc(ctx, localURL(listener.Addr()))
+}
+
 // openBrowser opens the default browser to the given URL
-func openBrowser(url string) {
-	time.Sleep(500 * time.Millisecond) // Small delay to let server start
+func openBrowser(ctx context.Context, url string) {
+	// Small delay to let server start
+	select {
+	case <-time.After(500 * time.Millisecond):
+	case <-ctx.Done():
+		return
+	}
 	var cmd *exec.Cmd
 	switch runtime.GOOS {
 	case "darwin":
@@ -242,9 +469,7 @@ func openBrowser(url string) {
 
 func handleVersion(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
-		"version": updater.GetVersion(),
-	})
+	json.NewEncoder(w).Encode(updater.GetBuildInfo())
 }
 
 func handleUpdateCheck(w http.ResponseWriter, r *http.Request) {
@@ -252,7 +477,7 @@ func handleUpdateCheck(w http.ResponseWriter, r *http.Request) {
 
 	info, err := updater.CheckForUpdate()
 	if err != nil {
-		log.Printf("[Updater] Check failed: %v", err)
+		logging.Errorf("[Updater] Check failed: %v", err)
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"available":      false,
 			"currentVersion": updater.GetVersion(),
@@ -291,10 +516,10 @@ func handleUpdateApply(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Download the update
-	log.Printf("[Updater] Downloading %s...", info.AssetName)
+	logging.Infof("[Updater] Downloading %s...", info.AssetName)
 	tmpPath, err := updater.DownloadUpdate(info)
 	if err != nil {
-		log.Printf("[Updater] Download failed: %v", err)
+		logging.Errorf("[Updater] Download failed: %v", err)
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"success": false,
 			"error":   "Download failed: " + err.Error(),
@@ -303,9 +528,9 @@ func handleUpdateApply(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Apply the update
-	log.Printf("[Updater] Applying update...")
+	logging.Infof("[Updater] Applying update...")
 	if err := updater.ApplyUpdate(tmpPath); err != nil {
-		log.Printf("[Updater] Apply failed: %v", err)
+		logging.Errorf("[Updater] Apply failed: %v", err)
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"success": false,
 			"error":   "Apply failed: " + err.Error(),
@@ -313,7 +538,7 @@ func handleUpdateApply(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Printf("[Updater] Update applied successfully! Restarting...")
+	logging.Infof("[Updater] Update applied successfully! Restarting...")
 
 	// Send success response
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -332,7 +557,7 @@ func handleUpdateApply(w http.ResponseWriter, r *http.Request) {
 func restartSelf() {
 	executable, err := os.Executable()
 	if err != nil {
-		log.Printf("[Updater] Failed to get executable path: %v", err)
+		logging.Errorf("[Updater] Failed to get executable path: %v", err)
 		os.Exit(1)
 	}
 
@@ -354,7 +579,7 @@ func handleListVersions(w http.ResponseWriter, r *http.Request) {
 
 	releases, err := updater.ListReleases(10) // Get last 10 releases
 	if err != nil {
-		log.Printf("[Updater] Failed to list releases: %v", err)
+		logging.Errorf("[Updater] Failed to list releases: %v", err)
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"error":    err.Error(),
 			"releases": []interface{}{},
@@ -462,9 +687,43 @@ func handleShutdown(w http.ResponseWriter, r *http.Request) {
 	}
 	w.Header().Set("Content-Type", "application/json")
 	w.Write([]byte(`{"status":"shutting down"}`))
-	log.Println("👋 Shutdown requested from browser")
+	logging.Infof("👋 Shutdown requested from browser")
 	go func() {
 		time.Sleep(500 * time.Millisecond)
-		os.Exit(0)
+		select {
+		case shutdownRequested <- struct{}{}:
+		default:
+		}
 	}()
 }
+
+// reloadCertificate re-reads the TLS certificate and key, keeping the current pair on error.
+func reloadCertificate() error {
+	if err := certReloader.Reload(); err != nil {
+		logging.Errorf("TLS certificate reload failed, still serving the previous one: %v", err)
+		return err
+	}
+	logging.Infof("🔒 Reloaded TLS certificate (expires %s)", certReloader.NotAfter().Format(time.RFC3339))
+	return nil
+}
+
+// handleTLSReload applies a renewed FORGE_TLS_CERT/FORGE_TLS_KEY without a restart.
+func handleTLSReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if certReloader == nil {
+		http.Error(w, "Not serving a certificate from FORGE_TLS_CERT and FORGE_TLS_KEY", http.StatusConflict)
+		return
+	}
+	if err := reloadCertificate(); err != nil {
+		http.Error(w, "Failed to reload certificate: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":    "reloaded",
+		"not_after": certReloader.NotAfter(),
+	})
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/config"
+)
+
+// occupyPort grabs a free localhost port and keeps it busy until the test ends.
+func occupyPort(t *testing.T) int {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to occupy a port: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// freePort finds a port that is currently free.
+func freePort(t *testing.T) int {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to find a free port: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+	return port
+}
+
+func TestFindAvailablePort_UsesCustomList(t *testing.T) {
+	busyPreferred := occupyPort(t)
+	busyFallback := occupyPort(t)
+	want := freePort(t)
+
+	addr, listener, err := findAvailablePort("127.0.0.1", busyPreferred, []int{busyFallback, want})
+	if err != nil {
+		t.Fatalf("findAvailablePort failed: %v", err)
+	}
+	defer listener.Close()
+
+	if addr != fmt.Sprintf("127.0.0.1:%d", want) {
+		t.Errorf("Expected first free port from the custom list (%d), got %s", want, addr)
+	}
+}
+
+func TestFindAvailablePort_FallsBackToOSAssigned(t *testing.T) {
+	busyPreferred := occupyPort(t)
+	busyFallbacks := []int{occupyPort(t), occupyPort(t)}
+
+	addr, listener, err := findAvailablePort("127.0.0.1", busyPreferred, busyFallbacks)
+	if err != nil {
+		t.Fatalf("findAvailablePort failed: %v", err)
+	}
+	defer listener.Close()
+
+	port := listener.Addr().(*net.TCPAddr).Port
+	if port == busyPreferred || port == busyFallbacks[0] || port == busyFallbacks[1] {
+		t.Errorf("Expected an OS-assigned port, got one from the busy list: %s", addr)
+	}
+}
+
+func TestOpenUI_UsesBoundAddress(t *testing.T) {
+	var opened []string
+	originalOpen := openBrowserFunc
+	openBrowserFunc = func(ctx context.Context, url string) { opened = append(opened, url) }
+	defer func() { openBrowserFunc = originalOpen }()
+
+	busyPreferred := occupyPort(t)
+	_, listener, err := findAvailablePort("127.0.0.1", busyPreferred, nil)
+	if err != nil {
+		t.Fatalf("findAvailablePort failed: %v", err)
+	}
+	defer listener.Close()
+
+	openUI(context.Background(), listener)
+
+	want := fmt.Sprintf("http://127.0.0.1:%d", listener.Addr().(*net.TCPAddr).Port)
+	if len(opened) != 1 || opened[0] != want || strings.HasSuffix(opened[0], fmt.Sprintf(":%d", busyPreferred)) {
+		t.Errorf("Expected the browser opened at the bound address %s, got %v", want, opened)
+	}
+}
+
+func TestLocalURL_AllInterfacesUsesLocalhost(t *testing.T) {
+	for _, tt := range []struct {
+		addr *net.TCPAddr
+		want string
+	}{
+		{&net.TCPAddr{IP: net.IPv4zero, Port: 8333}, "http://localhost:8333"},
+		{&net.TCPAddr{IP: net.IPv6unspecified, Port: 8333}, "http://localhost:8333"},
+		{&net.TCPAddr{IP: net.ParseIP("192.168.1.50"), Port: 9000}, "http://192.168.1.50:9000"},
+		{&net.TCPAddr{IP: net.IPv6loopback, Port: 9000}, "http://[::1]:9000"},
+	} {
+		if got := localURL(tt.addr); got != tt.want {
+			t.Errorf("localURL(%v) = %s, want %s", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestHeadlessBanner_OnlySuggestsOtherMachinesOffLoopback(t *testing.T) {
+	tests := []struct {
+		addr    *net.TCPAddr
+		want    string
+		wantNot string
+	}{
+		{&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 8080}, "server.bind_address", "open http://127.0.0.1:8080 from another machine"},
+		{&net.TCPAddr{IP: net.IPv6loopback, Port: 8080}, "server.bind_address", "open http"},
+		{&net.TCPAddr{IP: net.IPv4(192, 168, 1, 50), Port: 8080}, "open http://192.168.1.50:8080 from another machine", "bind_address"},
+		{&net.TCPAddr{IP: net.IPv4zero, Port: 8080}, ":8080 from another machine", "0.0.0.0"},
+	}
+	for _, tt := range tests {
+		got := headlessBanner(tt.addr)
+		if !strings.Contains(got, tt.want) || strings.Contains(got, tt.wantNot) {
+			t.Errorf("headlessBanner(%v) = %q, want it to mention %q and not %q", tt.addr, got, tt.want, tt.wantNot)
+		}
+	}
+}
+
+func TestFindAvailablePort_UsesBindAddress(t *testing.T) {
+	var requested []string
+	originalListen := listen
+	listen = func(network, addr string) (net.Listener, error) {
+		requested = append(requested, addr)
+		return net.Listen(network, "127.0.0.1:0")
+	}
+	defer func() { listen = originalListen }()
+
+	_, listener, err := findAvailablePort("192.168.1.50", 8123, nil)
+	if err != nil {
+		t.Fatalf("findAvailablePort failed: %v", err)
+	}
+	defer listener.Close()
+
+	if len(requested) != 1 || requested[0] != "192.168.1.50:8123" {
+		t.Errorf("Expected listen on 192.168.1.50:8123, got %v", requested)
+	}
+}
+
+func TestTLSAddress_UsesBindAddress(t *testing.T) {
+	tests := []struct {
+		cfg  config.ServerConfig
+		want string
+	}{
+		{config.ServerConfig{Port: 8443}, "127.0.0.1:8443"},
+		{config.ServerConfig{BindAddress: "192.168.1.50", Port: 443}, "192.168.1.50:443"},
+		{config.ServerConfig{BindAddress: "::", Port: 8443}, "[::]:8443"},
+	}
+	for _, tt := range tests {
+		if got := tlsAddress(tt.cfg); got != tt.want {
+			t.Errorf("tlsAddress(%+v) = %s, want %s", tt.cfg, got, tt.want)
+		}
+	}
+}
+
+func TestHandleVersion_IncludesBuildMetadata(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/version", nil)
+	rr := httptest.NewRecorder()
+
+	handleVersion(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+
+	var body map[string]string
+	if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	for _, field := range []string{"version", "commit", "buildDate", "goVersion"} {
+		if body[field] == "" {
+			t.Errorf("Expected %q in version response, got %v", field, body)
+		}
+	}
+}
+
+func TestNewHTTPServer_AppliesTimeouts(t *testing.T) {
+	srv := newHTTPServer(http.NotFoundHandler(), config.ServerConfig{ReadTimeoutSeconds: 5, WriteTimeoutSeconds: 300, IdleTimeoutSeconds: 45})
+	if srv.ReadTimeout != 5*time.Second || srv.ReadHeaderTimeout != 5*time.Second ||
+		srv.WriteTimeout != 300*time.Second || srv.IdleTimeout != 45*time.Second {
+		t.Errorf("Expected the configured timeouts, got read=%v header=%v write=%v idle=%v",
+			srv.ReadTimeout, srv.ReadHeaderTimeout, srv.WriteTimeout, srv.IdleTimeout)
+	}
+	if srv.Protocols == nil || !srv.Protocols.HTTP1() || !srv.Protocols.HTTP2() {
+		t.Errorf("Expected HTTP/1.1 and HTTP/2 to be enabled, got %v", srv.Protocols)
+	}
+
+	// Unset timeouts fall back to the defaults, and writes aren't limited
+	srv = newHTTPServer(http.NotFoundHandler(), config.ServerConfig{})
+	if srv.ReadTimeout != config.DefaultReadTimeout || srv.ReadHeaderTimeout != 10*time.Second ||
+		srv.WriteTimeout != 0 || srv.IdleTimeout != config.DefaultIdleTimeout {
+		t.Errorf("Expected the default timeouts, got read=%v header=%v write=%v idle=%v",
+			srv.ReadTimeout, srv.ReadHeaderTimeout, srv.WriteTimeout, srv.IdleTimeout)
+	}
+}
+
+func TestHandleTLSReload_RequiresCertificateFiles(t *testing.T) {
+	rr := httptest.NewRecorder()
+	handleTLSReload(rr, httptest.NewRequest(http.MethodPost, "/api/tls/reload", nil))
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Expected 409 without FORGE_TLS_CERT/FORGE_TLS_KEY, got %d", rr.Code)
+	}
+}
+
+func TestConfigureACME_ServesManagedCertificates(t *testing.T) {
+	cacheDir := filepath.Join(t.TempDir(), "acme")
+	cfg := config.ServerConfig{Port: 8443, ACME: config.ACMEConfig{Enabled: true, Domains: []string{"forge.example.com"}, CacheDir: cacheDir}}
+	srv := newHTTPServer(http.NotFoundHandler(), cfg)
+
+	challengeServer, err := configureACME(srv, cfg)
+	if err != nil {
+		t.Fatalf("configureACME failed: %v", err)
+	}
+	if srv.Addr != "127.0.0.1:8443" {
+		t.Errorf("Expected the default bind address and configured port, got %q", srv.Addr)
+	}
+	if srv.TLSConfig == nil || srv.TLSConfig.GetCertificate == nil || !slices.Contains(srv.TLSConfig.NextProtos, "acme-tls/1") {
+		t.Errorf("Expected a TLS config that answers ACME challenges and fetches certificates, got %+v", srv.TLSConfig)
+	}
+	if _, err := os.Stat(cacheDir); err != nil {
+		t.Errorf("Expected the cache directory to be created: %v", err)
+	}
+	if challengeServer == nil || challengeServer.Addr != "127.0.0.1:80" {
+		t.Errorf("Expected an HTTP-01 challenge server on port 80 of the bind address, got %+v", challengeServer)
+	}
+
+	// Without domains there's nothing to get a certificate for
+	cfg.ACME.Domains = nil
+	if _, err := configureACME(newHTTPServer(http.NotFoundHandler(), cfg), cfg); err == nil {
+		t.Error("Expected an error without domains")
+	}
+}
+
+func TestServeACMEChallenges_RedirectsUntilCancelled(t *testing.T) {
+	cfg := config.ServerConfig{Port: 8443, ACME: config.ACMEConfig{Enabled: true, Domains: []string{"forge.example.com"}, CacheDir: t.TempDir()}}
+	challengeServer, err := configureACME(newHTTPServer(http.NotFoundHandler(), cfg), cfg)
+	if err != nil {
+		t.Fatalf("configureACME failed: %v", err)
+	}
+
+	// Port 80 needs privileges, so listen on a free port instead
+	var requested string
+	bound := make(chan net.Addr, 1)
+	originalListen := listen
+	listen = func(network, addr string) (net.Listener, error) {
+		requested = addr
+		listener, err := net.Listen(network, "127.0.0.1:0")
+		if err == nil {
+			bound <- listener.Addr()
+		}
+		return listener, err
+	}
+	defer func() { listen = originalListen }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		serveACMEChallenges(ctx, challengeServer, cfg.Port)
+		close(stopped)
+	}()
+	addr := <-bound
+	if requested != "127.0.0.1:80" {
+		t.Errorf("Expected to listen on 127.0.0.1:80, got %s", requested)
+	}
+
+	// Anything but a challenge is sent to HTTPS
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	req, _ := http.NewRequest(http.MethodGet, "http://"+addr.String()+"/flows", nil)
+	req.Host = "forge.example.com"
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Request to the challenge server failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound || resp.Header.Get("Location") != "https://forge.example.com/flows" {
+		t.Errorf("Expected a redirect to HTTPS, got %d to %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Challenge server did not stop after cancellation")
+	}
+}
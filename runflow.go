@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/agents"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/budget"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/config"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/flows"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/llm"
+	"github.com/mikejsmith1985/forge-orchestrator/internal/logging"
+)
+
+// Exit codes for run-flow, so scripts can tell why a run didn't succeed
+const (
+	exitOK             = 0
+	exitFailed         = 1
+	exitUsage          = 2
+	exitBudgetExceeded = 3
+)
+
+// runFlowArgs are the arguments to the run-flow subcommand.
+type runFlowArgs struct {
+	FlowID      int
+	Environment string
+	Resume      bool
+}
+
+// parseRunFlowArgs reads `run-flow [-environment name] [-resume] <id>`.
+func parseRunFlowArgs(args []string, output io.Writer) (runFlowArgs, error) {
+	var parsed runFlowArgs
+
+	flags := flag.NewFlagSet("run-flow", flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.Usage = func() {
+		fmt.Fprintln(output, "Usage: forge-orchestrator run-flow [-environment name] [-resume] <flow id>")
+		flags.PrintDefaults()
+	}
+	flags.StringVar(&parsed.Environment, "environment", "", "Tag the run's ledger entries with this environment (default prod)")
+	flags.BoolVar(&parsed.Resume, "resume", false, "Skip the nodes that finished in the flow's last, failed run")
+	if err := flags.Parse(args); err != nil {
+		return parsed, err
+	}
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return parsed, fmt.Errorf("expected exactly one flow id, got %d arguments", flags.NArg())
+	}
+	id, err := strconv.Atoi(flags.Arg(0))
+	if err != nil || id <= 0 {
+		return parsed, fmt.Errorf("invalid flow id %q", flags.Arg(0))
+	}
+	parsed.FlowID = id
+	return parsed, nil
+}
+
+// exitCodeForStatus maps a flow's final status to the process exit code.
+// Anything other than COMPLETED is a failure, including a run that never reported a final status.
+func exitCodeForStatus(status string) int {
+	switch status {
+	case "COMPLETED":
+		return exitOK
+	case budget.CodeBudgetExceeded:
+		return exitBudgetExceeded
+	default:
+		return exitFailed
+	}
+}
+
+// printSignaler writes each status change to out as it happens, then passes it on to next
+// so a running UI still sees the run's progress.
+type printSignaler struct {
+	out  io.Writer
+	next flows.Signaler
+
+	mu   sync.Mutex
+	last flows.FlowStatus
+}
+
+// NotifyStatus prints the status and forwards it.
+func (p *printSignaler) NotifyStatus(flowID int, status flows.FlowStatus) error {
+	p.mu.Lock()
+	p.last = status
+	line := fmt.Sprintf("%s flow %d %s", status.UpdatedAt.Format("15:04:05"), flowID, status.Status)
+	if status.LastNode != "" {
+		line += " (node " + status.LastNode + ")"
+	}
+	if status.Error != "" {
+		line += ": " + status.Error
+	}
+	fmt.Fprintln(p.out, line)
+	p.mu.Unlock()
+
+	if p.next == nil {
+		return nil
+	}
+	return p.next.NotifyStatus(flowID, status)
+}
+
+// GetStatus returns the last status printed.
+func (p *printSignaler) GetStatus(flowID int) (*flows.FlowStatus, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	status := p.last
+	return &status, nil
+}
+
+// runFlowCommand runs one flow in this process with the same engine the server uses,
+// streaming status lines to stdout. It returns the process exit code.
+// Educational Comment: The run reads and writes the same database as the server, so
+// the ledger and flow status are shared with a UI that happens to be open.
+func runFlowCommand(args []string, stdout, stderr io.Writer) int {
+	parsed, err := parseRunFlowArgs(args, stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	} else if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		cfg = config.DefaultConfig()
+	}
+	logging.Init(cfg.Logging.Format, cfg.Logging.Level)
+
+	db, _, err := openDatabase()
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to open database: %v\n", err)
+		return exitFailed
+	}
+	defer db.Close()
+	agents.UseCustomRoles(db)
+
+	signaler := &printSignaler{out: stdout, next: flows.NewDBSignaler(db)}
+	opts := flows.ExecuteOptions{Environment: parsed.Environment, Resume: parsed.Resume}
+	if err := flows.ExecuteFlowWithOptions(parsed.FlowID, db, llm.NewGateway(), signaler, nil, nil, opts); err != nil {
+		fmt.Fprintf(stderr, "Flow %d failed: %v\n", parsed.FlowID, err)
+	}
+
+	status, _ := signaler.GetStatus(parsed.FlowID)
+	return exitCodeForStatus(status.Status)
+}
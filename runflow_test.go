@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/mikejsmith1985/forge-orchestrator/internal/budget"
+)
+
+func TestParseRunFlowArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    runFlowArgs
+		wantErr bool
+	}{
+		{"id", []string{"7"}, runFlowArgs{FlowID: 7}, false},
+		{"environment", []string{"-environment", "ci", "7"}, runFlowArgs{FlowID: 7, Environment: "ci"}, false},
+		{"resume", []string{"-resume", "7"}, runFlowArgs{FlowID: 7, Resume: true}, false},
+		{"missing id", nil, runFlowArgs{}, true},
+		{"extra args", []string{"7", "8"}, runFlowArgs{}, true},
+		{"not a number", []string{"seven"}, runFlowArgs{}, true},
+		{"zero", []string{"0"}, runFlowArgs{}, true},
+		{"unknown flag", []string{"-fast", "7"}, runFlowArgs{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRunFlowArgs(tt.args, io.Discard)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error=%v, got %v", tt.wantErr, err)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestExitCodeForStatus(t *testing.T) {
+	tests := map[string]int{
+		"COMPLETED":               exitOK,
+		"FAILED":                  exitFailed,
+		budget.CodeBudgetExceeded: exitBudgetExceeded,
+		"RUNNING":                 exitFailed, // the run ended without a final status
+		"":                        exitFailed,
+	}
+	for status, want := range tests {
+		if got := exitCodeForStatus(status); got != want {
+			t.Errorf("%q: expected exit code %d, got %d", status, want, got)
+		}
+	}
+}
+
+func TestRunFlowCommand(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	db, _, err := openDatabase()
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	_, err = db.Exec(`INSERT INTO forge_flows (name, data, status) VALUES ('Empty', '{"nodes": [], "edges": []}', 'active')`)
+	db.Close()
+	if err != nil {
+		t.Fatalf("Failed to insert flow: %v", err)
+	}
+
+	var stdout bytes.Buffer
+	if code := runFlowCommand([]string{"1"}, &stdout, io.Discard); code != exitOK {
+		t.Errorf("Expected exit code 0, got %d", code)
+	}
+	if !strings.Contains(stdout.String(), "flow 1 RUNNING") || !strings.Contains(stdout.String(), "flow 1 COMPLETED") {
+		t.Errorf("Expected RUNNING and COMPLETED status lines, got %q", stdout.String())
+	}
+
+	stdout.Reset()
+	if code := runFlowCommand([]string{"99"}, &stdout, io.Discard); code != exitFailed {
+		t.Errorf("Expected exit code 1 for a missing flow, got %d", code)
+	}
+	if !strings.Contains(stdout.String(), "flow 99 FAILED") {
+		t.Errorf("Expected a FAILED status line, got %q", stdout.String())
+	}
+
+	if code := runFlowCommand(nil, io.Discard, io.Discard); code != exitUsage {
+		t.Errorf("Expected exit code 2 without a flow id, got %d", code)
+	}
+}